@@ -1,30 +1,49 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/tulpa-code/tulpa/internal/checkpoint"
 	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/csync"
 	"github.com/tulpa-code/tulpa/internal/db"
 	"github.com/tulpa-code/tulpa/internal/format"
 	"github.com/tulpa-code/tulpa/internal/history"
 	"github.com/tulpa-code/tulpa/internal/llm/agent"
+	"github.com/tulpa-code/tulpa/internal/llm/prompt"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
 	"github.com/tulpa-code/tulpa/internal/log"
 	"github.com/tulpa-code/tulpa/internal/lsp"
 	"github.com/tulpa-code/tulpa/internal/message"
 	"github.com/tulpa-code/tulpa/internal/permission"
 	"github.com/tulpa-code/tulpa/internal/pubsub"
 	"github.com/tulpa-code/tulpa/internal/session"
-	"github.com/charmbracelet/x/ansi"
 )
 
+// ErrInterrupted is returned by [App.RunNonInteractive] when the run was
+// canceled before it completed.
+var ErrInterrupted = errors.New("interrupted")
+
+// interruptFlushTimeout is how long an interrupted run waits for the agent to
+// return the partial response before giving up on it.
+const interruptFlushTimeout = 5 * time.Second
+
 type App struct {
 	Sessions    session.Service
 	Messages    message.Service
@@ -35,6 +54,11 @@ type App struct {
 
 	LSPClients *csync.Map[string, *lsp.Client]
 
+	// lspRestartMu serializes LSP restarts, and stopLSPWatch stops the
+	// health checks restarting crashed LSP clients.
+	lspRestartMu sync.Mutex
+	stopLSPWatch context.CancelFunc
+
 	config *config.Config
 
 	serviceEventsWG *sync.WaitGroup
@@ -51,26 +75,42 @@ type App struct {
 func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	q := db.New(conn)
 	sessions := session.NewService(q)
-	messages := message.NewService(q)
+	redactor, err := message.NewRedactor(cfg.Options.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+	messages := message.NewService(q, redactor)
+	eventBuffer, err := cfg.Options.EventBuffer()
+	if err != nil {
+		return nil, err
+	}
 	files := history.NewService(q, conn)
 	skipPermissionsRequests := cfg.Permissions != nil && cfg.Permissions.SkipRequests
 	allowedTools := []string{}
-	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
-		allowedTools = cfg.Permissions.AllowedTools
+	var permissionRules []permission.Rule
+	if cfg.Permissions != nil {
+		if cfg.Permissions.AllowedTools != nil {
+			allowedTools = cfg.Permissions.AllowedTools
+		}
+		rules, err := permission.CompileRules(cfg.Permissions.Rules)
+		if err != nil {
+			return nil, err
+		}
+		permissionRules = rules
 	}
 
 	app := &App{
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
-		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
+		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools, permissionRules, permission.NewAuditLog(permission.AuditLogPath(cfg.Options.DataDirectory), redactor)),
 		LSPClients:  csync.NewMap[string, *lsp.Client](),
 
 		globalCtx: ctx,
 
 		config: cfg,
 
-		events:          make(chan tea.Msg, 100),
+		events:          make(chan tea.Msg, eventBuffer),
 		serviceEventsWG: &sync.WaitGroup{},
 		tuiWG:           &sync.WaitGroup{},
 	}
@@ -91,6 +131,8 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	} else {
 		slog.Warn("No agent configuration found")
 	}
+
+	app.watchAgents()
 	return app, nil
 }
 
@@ -99,22 +141,73 @@ func (app *App) Config() *config.Config {
 	return app.config
 }
 
-// RunNonInteractive handles the execution flow when a prompt is provided via
-// CLI flag.
-func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool) error {
-	slog.Info("Running in non-interactive mode")
+// NonInteractiveOptions configures [App.RunNonInteractive].
+type NonInteractiveOptions struct {
+	// Hide the spinner
+	Quiet bool
+	// Shown next to the spinner, format.DefaultSpinnerLabel when empty
+	SpinnerLabel string
+	// OutputText or OutputJSON
+	Output string
+	// Where the responses are written, stdout if nil
+	Out io.Writer
+	// Writes the run instead of the formatter of Output when set
+	Formatter OutputFormatter
+	// Files sent with the first prompt
+	Attachments []message.Attachment
+	// Environment variables set for the bash commands of the run, over those
+	// of the agent
+	Env map[string]string
+	// Tools forbidden in the run, subagents included, whatever the agents
+	// allow
+	DisabledTools []string
+}
+
+// RunNonInteractive handles the execution flow when prompts are provided via
+// CLI flag or stdin. The prompts run one after the other in a single session.
+// Responses are streamed to stdout as plain text, or as newline-delimited
+// JSON events when the output is [OutputJSON], unless another formatter is
+// given.
+func (app *App) RunNonInteractive(ctx context.Context, prompts []string, opts NonInteractiveOptions) error {
+	slog.Info("Running in non-interactive mode", "prompts", len(prompts))
+	if len(prompts) == 0 {
+		return fmt.Errorf("no prompt provided")
+	}
+	// Check the attachments before anything runs.
+	firstPrompt, images, err := app.attachToPrompt(prompts[0], opts.Attachments)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	defer handleInterrupts(cancel)()
 
-	// Start progress bar and spinner
-	fmt.Printf(ansi.SetIndeterminateProgressBar)
-	defer fmt.Printf(ansi.ResetProgressBar)
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	asJSON := opts.Formatter == nil && opts.Output == OutputJSON
+	// The spinner and the progress bar would corrupt redirected output.
+	quiet := opts.Quiet || asJSON || !format.IsTerminal(os.Stdout)
+	formatter := opts.Formatter
+	switch {
+	case formatter != nil:
+	case asJSON:
+		formatter = NewJSONFormatter(out)
+	default:
+		formatter = NewPlainFormatter(out)
+	}
+	if !quiet {
+		defer fmt.Printf(ansi.ResetProgressBar)
+	}
 
 	var spinner *format.Spinner
-	if !quiet {
-		spinner = format.NewSpinner(ctx, cancel, "Generating")
-		spinner.Start()
+	startSpinner := func() {
+		if !quiet {
+			spinner = format.NewSpinner(ctx, cancel, os.Stderr, opts.SpinnerLabel)
+			spinner.Start()
+		}
 	}
 
 	// Helper function to stop spinner once.
@@ -130,10 +223,10 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	titlePrefix := "Non-interactive: "
 	var titleSuffix string
 
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
+	if len(prompts[0]) > maxPromptLengthForTitle {
+		titleSuffix = prompts[0][:maxPromptLengthForTitle] + "..."
 	} else {
-		titleSuffix = prompt
+		titleSuffix = prompts[0]
 	}
 	title := titlePrefix + titleSuffix
 
@@ -145,63 +238,278 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 
 	// Automatically approve all permission requests for this non-interactive session
 	app.Permissions.AutoApproveSession(sess.ID)
-
-	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt)
-	if err != nil {
-		return fmt.Errorf("failed to start agent processing stream: %w", err)
-	}
+	defer app.Permissions.ClearSession(sess.ID)
+	tools.SetSessionEnv(sess.ID, opts.Env)
+	defer tools.SetSessionEnv(sess.ID, nil)
+	agent.SetSessionDisabledTools(sess.ID, opts.DisabledTools)
+	defer agent.SetSessionDisabledTools(sess.ID, nil)
+	// Nothing reads the background jobs once the run is over.
+	defer tools.StopJobs(sess.ID)
 
 	messageEvents := app.Messages.Subscribe(ctx)
-	messageReadBytes := make(map[string]int)
+	agentEvents := app.CoderAgent.Subscribe(ctx)
+	// Messages are published repeatedly while they stream, tool calls and
+	// results are only reported once.
+	reported := make(map[string]bool)
+
+	// runPrompt streams the response to a single prompt. It reports false
+	// when the run was canceled, after flushing the partial response.
+	runPrompt := func(prompt string, attachments []message.Attachment) (bool, error) {
+		if !quiet {
+			// Until the agent announces the steps of a plan
+			fmt.Printf(ansi.SetIndeterminateProgressBar)
+		}
+		startSpinner()
+		done, err := app.CoderAgent.Run(ctx, sess.ID, prompt, attachments...)
+		if err != nil {
+			return false, fmt.Errorf("failed to start agent processing stream: %w", err)
+		}
 
-	for {
-		select {
-		case result := <-done:
-			stopSpinner()
+		ctxDone := ctx.Done()
+		var flushTimeout <-chan time.Time
+		for {
+			select {
+			case result := <-done:
+				stopSpinner()
+
+				cancelled := errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled)
+				if result.Error != nil && !cancelled {
+					formatter.OnError(sess.ID, result.Error)
+					return false, fmt.Errorf("agent processing failed: %w", result.Error)
+				}
 
-			if result.Error != nil {
-				if errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled) {
+				runResult := RunResult{Session: sess, Message: result.Message, JSON: result.JSON}
+				if s, err := app.Sessions.Get(ctx, sess.ID); err == nil {
+					runResult.Session = s
+				}
+				if cancelled {
 					slog.Info("Non-interactive: agent processing cancelled", "session_id", sess.ID)
-					return nil
+					runResult.Err = result.Error
 				}
-				return fmt.Errorf("agent processing failed: %w", result.Error)
-			}
+				if err := formatter.OnResult(runResult); err != nil {
+					return false, err
+				}
+				return !cancelled, nil
 
-			msgContent := result.Message.Content().String()
-			readBts := messageReadBytes[result.Message.ID]
+			case event, ok := <-messageEvents:
+				if !ok {
+					messageEvents = nil
+					continue
+				}
+				msg := event.Payload
+				if msg.SessionID != sess.ID {
+					continue
+				}
+				for _, result := range msg.ToolResults() {
+					if !reported["result:"+result.ToolCallID] {
+						reported["result:"+result.ToolCallID] = true
+						formatter.OnToolResult(msg, result)
+					}
+				}
+				// Summaries of compacted conversations aren't part of the response.
+				if msg.Role == message.Assistant && len(msg.Parts) > 0 && msg.SummaryPart() == nil {
+					stopSpinner()
+					if err := formatter.OnDelta(msg); err != nil {
+						return false, err
+					}
+					for _, call := range msg.ToolCalls() {
+						if call.Finished && !reported["call:"+call.ID] {
+							reported["call:"+call.ID] = true
+							formatter.OnToolCall(msg, call)
+						}
+					}
+				}
 
-			if len(msgContent) < readBts {
-				slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(msgContent), "read_bytes", readBts)
-				return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(msgContent), readBts)
+			case event, ok := <-agentEvents:
+				if !ok {
+					agentEvents = nil
+					continue
+				}
+				if event.Payload.SessionID != sess.ID {
+					continue
+				}
+				status := event.Payload
+				switch {
+				case status.Type == agent.AgentEventTypeFallback && status.Fallback != nil:
+					formatter.OnStatus(status)
+					if spinner != nil {
+						spinner.SetLabel("Fell back to " + status.Fallback.To.Name)
+					}
+				case status.Type == agent.AgentEventTypeRetry && status.Retry != nil:
+					formatter.OnStatus(status)
+					if spinner != nil {
+						spinner.SetLabel(fmt.Sprintf("Retrying (%d/%d)", status.Retry.Attempt, status.Retry.MaxRetries))
+					}
+				case status.Type == agent.AgentEventTypeToolLimit && status.ToolLimit != nil:
+					formatter.OnStatus(status)
+					if spinner != nil {
+						spinner.SetLabel(fmt.Sprintf("Tool iterations %d/%d", status.ToolLimit.Used, status.ToolLimit.Max))
+					}
+				case status.Type == agent.AgentEventTypeCompaction && status.Compaction != nil:
+					formatter.OnStatus(status)
+					if spinner != nil {
+						spinner.SetLabel("Compacting conversation")
+					}
+				case status.Type == agent.AgentEventTypeStep && status.Step != nil:
+					formatter.OnStatus(status)
+					if !quiet {
+						fmt.Print(ansi.SetProgressBar(status.Step.Percent()))
+					}
+					if spinner != nil {
+						label := fmt.Sprintf("Step %d/%d", status.Step.Step, status.Step.Total)
+						if status.Step.Description != "" {
+							label += ": " + status.Step.Description
+						}
+						spinner.SetLabel(label)
+					}
+				}
+
+			case <-ctxDone:
+				// Give the agent a moment to hand back the partial response.
+				stopSpinner()
+				ctxDone = nil
+				flushTimeout = time.After(interruptFlushTimeout)
+
+			case <-flushTimeout:
+				return false, nil
 			}
-			fmt.Println(msgContent[readBts:])
-			messageReadBytes[result.Message.ID] = len(msgContent)
+		}
+	}
 
-			slog.Info("Non-interactive: run completed", "session_id", sess.ID)
-			return nil
+	canceled := func(completed int) error {
+		if len(prompts) > 1 {
+			slog.Info("Non-interactive: batch canceled", "session_id", sess.ID, "completed", completed)
+			fmt.Fprintf(os.Stderr, "Canceled after %d of %d prompts\n", completed, len(prompts))
+		}
+		return ErrInterrupted
+	}
 
-		case event := <-messageEvents:
-			msg := event.Payload
-			if msg.SessionID == sess.ID && msg.Role == message.Assistant && len(msg.Parts) > 0 {
-				stopSpinner()
+	for i, prompt := range prompts {
+		if ctx.Err() != nil {
+			return canceled(i)
+		}
+		formatter.OnPrompt(i+1, len(prompts))
 
-				content := msg.Content().String()
-				readBytes := messageReadBytes[msg.ID]
+		var attachments []message.Attachment
+		if i == 0 {
+			prompt, attachments = firstPrompt, images
+		}
+		completed, err := runPrompt(prompt, attachments)
+		if err != nil {
+			if len(prompts) > 1 {
+				return fmt.Errorf("prompt %d of %d: %w", i+1, len(prompts), err)
+			}
+			return err
+		}
+		if !completed {
+			return canceled(i)
+		}
+	}
 
-				if len(content) < readBytes {
-					slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(content), "read_bytes", readBytes)
-					return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(content), readBytes)
-				}
+	slog.Info("Non-interactive: run completed", "session_id", sess.ID)
+	return nil
+}
 
-				part := content[readBytes:]
-				fmt.Print(part)
-				messageReadBytes[msg.ID] = len(content)
+// attachToPrompt returns prompt with the text attachments appended and the
+// image attachments to send with it. Attachments the coder model can't take
+// are an error.
+func (app *App) attachToPrompt(prompt string, attachments []message.Attachment) (string, []message.Attachment, error) {
+	var images []message.Attachment
+	var b strings.Builder
+	b.WriteString(prompt)
+	for _, attachment := range attachments {
+		switch {
+		case attachment.IsText():
+			writeTextAttachment(&b, attachment)
+		case attachment.IsImage():
+			if model := app.CoderAgent.Model(); !model.SupportsImages {
+				return "", nil, fmt.Errorf("cannot attach %s: %s does not support images", attachment.FilePath, model.Name)
 			}
+			images = append(images, attachment)
+		default:
+			return "", nil, fmt.Errorf("cannot attach %s: %s files are not supported, only text and images", attachment.FilePath, attachment.MimeType)
+		}
+	}
+	return b.String(), images, nil
+}
+
+// ExpandTypedMentions expands the mentions of a prompt typed on the command
+// line, writing the warnings to stderr. Piped input and prompt files are not
+// expanded, they may mention files the user never meant to send.
+func (app *App) ExpandTypedMentions(prompt string) string {
+	prompt, warnings := app.ExpandMentions(prompt)
+	for _, warning := range warnings {
+		slog.Warn("Mention not attached", "warning", warning)
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return prompt
+}
+
+// writeTextAttachment appends a text attachment to a prompt.
+func writeTextAttachment(b *strings.Builder, attachment message.Attachment) {
+	fmt.Fprintf(b, "\n\n<attachment path=%q>\n%s\n</attachment>", attachment.FilePath, strings.TrimRight(string(attachment.Content), "\n"))
+}
+
+// DryRun writes what the given agent, or the default one when agentID is
+// empty, would send to its provider for prompt without calling it: as
+// Markdown, or as a JSON object when the output is [OutputJSON].
+func (app *App) DryRun(ctx context.Context, agentID, prompt string, opts NonInteractiveOptions) error {
+	agentID = cmp.Or(agentID, app.config.DefaultAgentID())
+	agentCfg, ok := app.config.Agents()[agentID]
+	if !ok {
+		return fmt.Errorf("agent %q not found", agentID)
+	}
+	service := app.CoderAgent
+	if agentID != app.config.DefaultAgentID() || service == nil {
+		var err error
+		service, err = agent.NewAgent(ctx, agentCfg, app.Permissions, app.Sessions, app.Messages, app.History, app.LSPClients)
+		if err != nil {
+			return fmt.Errorf("failed to create agent %s: %w", agentID, err)
+		}
+	}
+
+	dryRun, err := service.DryRun(ctx, "", prompt)
+	if err != nil {
+		return err
+	}
+	dryRun.Tools = slices.DeleteFunc(dryRun.Tools, func(tool agent.DryRunTool) bool {
+		return slices.Contains(opts.DisabledTools, tool.Name)
+	})
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	if opts.Output == OutputJSON {
+		return json.NewEncoder(out).Encode(dryRun)
+	}
+	_, err = fmt.Fprint(out, dryRun)
+	return err
+}
 
-		case <-ctx.Done():
-			stopSpinner()
-			return ctx.Err()
+// handleInterrupts cancels a non-interactive run on the first SIGINT so the
+// partial response can be flushed, and exits with status 130 on the second.
+// The returned function stops handling them.
+func handleInterrupts(cancel context.CancelFunc) func() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	stop := make(chan struct{})
+	go func() {
+		for n := 0; ; n++ {
+			select {
+			case <-interrupts:
+				if n > 0 {
+					os.Exit(130)
+				}
+				slog.Info("Non-interactive: interrupted, flushing partial output")
+				cancel()
+			case <-stop:
+				return
+			}
 		}
+	}()
+	return func() {
+		signal.Stop(interrupts)
+		close(stop)
 	}
 }
 
@@ -212,13 +520,37 @@ func (app *App) UpdateAgentModel() error {
 func (app *App) setupEvents() {
 	ctx, cancel := context.WithCancel(app.globalCtx)
 	app.eventsCtx = ctx
-	setupSubscriber(ctx, app.serviceEventsWG, "sessions", app.Sessions.Subscribe, app.events)
-	setupSubscriber(ctx, app.serviceEventsWG, "messages", app.Messages.Subscribe, app.events)
-	setupSubscriber(ctx, app.serviceEventsWG, "permissions", app.Permissions.Subscribe, app.events)
-	setupSubscriber(ctx, app.serviceEventsWG, "permissions-notifications", app.Permissions.SubscribeNotifications, app.events)
-	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
-	setupSubscriber(ctx, app.serviceEventsWG, "mcp", agent.SubscribeMCPEvents, app.events)
-	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
+	slowAfter := app.config.Options.SlowConsumerWait()
+	setupSubscriber(ctx, app.serviceEventsWG, "sessions", app.Sessions.Subscribe, coalesceSessions, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "messages", app.Messages.Subscribe, coalesceMessages, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "permissions", app.Permissions.Subscribe, nil, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "permissions-notifications", app.Permissions.SubscribeNotifications, nil, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, coalesceHistory, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "mcp", agent.SubscribeMCPEvents, nil, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, nil, slowAfter, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "jobs", tools.SubscribeJobs, nil, slowAfter, app.events)
+	app.serviceEventsWG.Go(func() {
+		// Files written by the agent change the directory tree shown in the
+		// system prompt.
+		for range app.History.Subscribe(ctx) {
+			prompt.InvalidateDirectoryTree()
+		}
+	})
+	app.serviceEventsWG.Go(func() {
+		// Permission decisions remembered for a session, its background jobs,
+		// the tokens counted for its budget and its checkpoint index end with
+		// it.
+		for event := range app.Sessions.Subscribe(ctx) {
+			if event.Type == pubsub.DeletedEvent {
+				app.Permissions.ClearSession(event.Payload.ID)
+				tools.StopJobs(event.Payload.ID)
+				agent.ClearSessionUsage(event.Payload.ID)
+				if err := checkpoint.Remove(ctx, app.config.WorkingDir(), event.Payload.ID); err != nil {
+					slog.Error("Failed to remove the checkpoints of a deleted session", "session_id", event.Payload.ID, "error", err)
+				}
+			}
+		}
+	})
 	cleanupFunc := func() error {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -227,43 +559,12 @@ func (app *App) setupEvents() {
 	app.cleanupFuncs = append(app.cleanupFuncs, cleanupFunc)
 }
 
-func setupSubscriber[T any](
-	ctx context.Context,
-	wg *sync.WaitGroup,
-	name string,
-	subscriber func(context.Context) <-chan pubsub.Event[T],
-	outputCh chan<- tea.Msg,
-) {
-	wg.Go(func() {
-		subCh := subscriber(ctx)
-		for {
-			select {
-			case event, ok := <-subCh:
-				if !ok {
-					slog.Debug("subscription channel closed", "name", name)
-					return
-				}
-				var msg tea.Msg = event
-				select {
-				case outputCh <- msg:
-				case <-time.After(2 * time.Second):
-					slog.Warn("message dropped due to slow consumer", "name", name)
-				case <-ctx.Done():
-					slog.Debug("subscription cancelled", "name", name)
-					return
-				}
-			case <-ctx.Done():
-				slog.Debug("subscription cancelled", "name", name)
-				return
-			}
-		}
-	})
-}
-
+// InitCoderAgent creates the agent sessions start with, the default agent of
+// the config.
 func (app *App) InitCoderAgent() error {
-	coderAgentCfg := app.config.Agents["coder"]
+	coderAgentCfg := app.config.Agents()[app.config.DefaultAgentID()]
 	if coderAgentCfg.ID == "" {
-		return fmt.Errorf("coder agent configuration is missing")
+		return fmt.Errorf("%s agent configuration is missing", app.config.DefaultAgentID())
 	}
 	var err error
 	app.CoderAgent, err = agent.NewAgent(
@@ -283,10 +584,39 @@ func (app *App) InitCoderAgent() error {
 	// Add MCP client cleanup to shutdown process
 	app.cleanupFuncs = append(app.cleanupFuncs, agent.CloseMCPClients)
 
-	setupSubscriber(app.eventsCtx, app.serviceEventsWG, "coderAgent", app.CoderAgent.Subscribe, app.events)
+	setupSubscriber(app.eventsCtx, app.serviceEventsWG, "coderAgent", app.CoderAgent.Subscribe, coalesceAgentEvents, app.config.Options.SlowConsumerWait(), app.events)
 	return nil
 }
 
+// watchAgents reloads the agent configs when their files change, so edits
+// apply without restarting.
+func (app *App) watchAgents() {
+	ctx, cancel := context.WithCancel(app.globalCtx)
+	changes, err := app.config.WatchAgents(ctx)
+	if err != nil {
+		cancel()
+		slog.Warn("Agent configs will not be reloaded on change", "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for change := range changes {
+			defaultAgent := app.config.DefaultAgentID()
+			if app.CoderAgent != nil && slices.Contains(change.Changed, defaultAgent) {
+				if err := app.CoderAgent.UpdateConfig(change.Agents[defaultAgent]); err != nil {
+					slog.Error("Failed to apply reloaded agent config", "agent", defaultAgent, "error", err)
+				}
+			}
+		}
+	})
+	app.cleanupFuncs = append(app.cleanupFuncs, func() error {
+		cancel()
+		wg.Wait()
+		return nil
+	})
+}
+
 // Subscribe sends events to the TUI as tea.Msgs.
 func (app *App) Subscribe(program *tea.Program) {
 	defer log.RecoverPanic("app.Subscribe", func() {
@@ -324,8 +654,12 @@ func (app *App) Shutdown() {
 	if app.CoderAgent != nil {
 		app.CoderAgent.CancelAll()
 	}
+	tools.StopAllJobs()
 
 	// Shutdown all LSP clients.
+	if app.stopLSPWatch != nil {
+		app.stopLSPWatch()
+	}
 	for name, client := range app.LSPClients.Seq2() {
 		shutdownCtx, cancel := context.WithTimeout(app.globalCtx, 5*time.Second)
 		if err := client.Close(shutdownCtx); err != nil {
@@ -334,6 +668,10 @@ func (app *App) Shutdown() {
 		cancel()
 	}
 
+	if err := provider.StopRecording(); err != nil {
+		slog.Error("Failed to close the provider recording", "error", err)
+	}
+
 	// Call call cleanup functions.
 	for _, cleanup := range app.cleanupFuncs {
 		if cleanup != nil {
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/db"
+	"github.com/tulpa-code/tulpa/internal/llm/agent"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/permission"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+// fakeAgent answers each prompt with the result of answer, in the session
+// of the prompt, and tells on started when it starts answering.
+type fakeAgent struct {
+	agent.Service
+	broker  *pubsub.Broker[agent.AgentEvent]
+	answer  func(ctx context.Context, prompt string) agent.AgentEvent
+	started chan string
+
+	mu       sync.Mutex
+	sessions []string
+}
+
+func (a *fakeAgent) Subscribe(ctx context.Context) <-chan pubsub.Event[agent.AgentEvent] {
+	return a.broker.Subscribe(ctx)
+}
+
+func (a *fakeAgent) Run(ctx context.Context, sessionID string, content string, _ ...message.Attachment) (<-chan agent.AgentEvent, error) {
+	a.mu.Lock()
+	a.sessions = append(a.sessions, sessionID)
+	a.mu.Unlock()
+	done := make(chan agent.AgentEvent, 1)
+	go func() {
+		a.started <- content
+		event := a.answer(ctx, content)
+		event.Message.SessionID = sessionID
+		done <- event
+	}()
+	return done, nil
+}
+
+// newTestApp returns an app running its prompts with answer, storing its
+// sessions in a new database.
+func newTestApp(t *testing.T, answer func(ctx context.Context, prompt string) agent.AgentEvent) (*App, *fakeAgent) {
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+
+	coder := &fakeAgent{broker: pubsub.NewBroker[agent.AgentEvent](), answer: answer, started: make(chan string, 10)}
+	t.Cleanup(coder.broker.Shutdown)
+	return &App{
+		Sessions:    session.NewService(q),
+		Messages:    message.NewService(q, nil),
+		Permissions: permission.NewPermissionService(t.TempDir(), true, nil, nil, nil),
+		CoderAgent:  coder,
+	}, coder
+}
+
+func answerWith(prefix string) func(context.Context, string) agent.AgentEvent {
+	return func(_ context.Context, prompt string) agent.AgentEvent {
+		return agent.AgentEvent{Type: agent.AgentEventTypeResponse, Message: assistantMessage("m-"+prompt, prefix+prompt)}
+	}
+}
+
+func TestRunNonInteractiveBatch(t *testing.T) {
+	t.Parallel()
+
+	app, coder := newTestApp(t, answerWith("answer to "))
+	var out strings.Builder
+	require.NoError(t, app.RunNonInteractive(t.Context(), []string{"one", "two"}, NonInteractiveOptions{Out: &out}))
+	require.Equal(t, "--- 1 ---\nanswer to one\n\n--- 2 ---\nanswer to two\n", out.String())
+
+	require.Len(t, coder.sessions, 2)
+	require.Equal(t, coder.sessions[0], coder.sessions[1], "the prompts run in one session")
+	sess, err := app.Sessions.Get(t.Context(), coder.sessions[0])
+	require.NoError(t, err)
+	require.Equal(t, "Non-interactive: one", sess.Title)
+}
+
+func TestRunNonInteractiveJSON(t *testing.T) {
+	t.Parallel()
+
+	events := func(out string) []map[string]any {
+		var events []map[string]any
+		for line := range strings.Lines(out) {
+			var event map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &event))
+			events = append(events, event)
+		}
+		return events
+	}
+
+	t.Run("result", func(t *testing.T) {
+		t.Parallel()
+
+		app, coder := newTestApp(t, answerWith("answer to "))
+		var out strings.Builder
+		require.NoError(t, app.RunNonInteractive(t.Context(), []string{"one"}, NonInteractiveOptions{Out: &out, Output: OutputJSON}))
+		require.Equal(t, []map[string]any{
+			{"type": "delta", "session_id": coder.sessions[0], "message_id": "m-one", "content": "answer to one"},
+			{
+				"type":       "result",
+				"session_id": coder.sessions[0],
+				"message_id": "m-one",
+				"content":    "answer to one",
+				"usage":      map[string]any{"prompt_tokens": 0.0, "completion_tokens": 0.0, "cost": 0.0},
+			},
+		}, events(out.String()))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+
+		app, coder := newTestApp(t, func(context.Context, string) agent.AgentEvent {
+			return agent.AgentEvent{Type: agent.AgentEventTypeError, Error: errors.New("overloaded")}
+		})
+		var out strings.Builder
+		err := app.RunNonInteractive(t.Context(), []string{"one", "two"}, NonInteractiveOptions{Out: &out, Output: OutputJSON})
+		require.EqualError(t, err, "prompt 1 of 2: agent processing failed: overloaded")
+		require.Equal(t, []map[string]any{
+			{"type": "error", "session_id": coder.sessions[0], "prompt": 1.0, "error": "overloaded"},
+		}, events(out.String()))
+		require.Len(t, coder.sessions, 1, "the batch stops at the failed prompt")
+	})
+}
+
+// TestRunNonInteractiveInterrupted interrupts the test process, it may not be
+// parallel.
+func TestRunNonInteractiveInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts can't be sent to a process on Windows")
+	}
+
+	// The agent answers until the run is canceled, then hands back the
+	// partial response.
+	app, coder := newTestApp(t, func(ctx context.Context, prompt string) agent.AgentEvent {
+		<-ctx.Done()
+		return agent.AgentEvent{Type: agent.AgentEventTypeError, Message: assistantMessage("m-"+prompt, "Half an ans"), Error: agent.ErrRequestCancelled}
+	})
+	go func() {
+		<-coder.started
+		process, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			_ = process.Signal(os.Interrupt)
+		}
+	}()
+
+	var out strings.Builder
+	err := app.RunNonInteractive(t.Context(), []string{"one", "two"}, NonInteractiveOptions{Out: &out})
+	require.ErrorIs(t, err, ErrInterrupted)
+	require.Equal(t, "--- 1 ---\nHalf an ans\n", out.String(), "the partial response is flushed")
+	require.Len(t, coder.sessions, 1, "the batch stops at the interrupted prompt")
+}
@@ -2,34 +2,158 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/csync"
 	"github.com/tulpa-code/tulpa/internal/lsp"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+)
+
+const (
+	// lspHealthInterval is how often LSP clients are checked for a server
+	// that exited.
+	lspHealthInterval = 15 * time.Second
+	// maxLSPRestarts is how many times in a row a server that exited is
+	// restarted before giving up.
+	maxLSPRestarts = 5
 )
 
 // initLSPClients initializes LSP clients.
 func (app *App) initLSPClients(ctx context.Context) {
+	ctx, app.stopLSPWatch = context.WithCancel(ctx)
 	for name, clientConfig := range app.config.LSP {
 		if clientConfig.Disabled {
 			slog.Info("Skipping disabled LSP client", "name", name)
 			continue
 		}
-		go app.createAndStartLSPClient(ctx, name, clientConfig)
+		go func() {
+			_ = app.createAndStartLSPClient(ctx, name, clientConfig)
+			app.watchLSPClient(ctx, name)
+		}()
 	}
 	slog.Info("LSP clients initialization started in background")
 }
 
+// StartLSPClients starts the LSP clients configured in cfg outside of an
+// App, for one-off commands, and waits until they are initialized. The
+// caller must close the returned clients.
+func StartLSPClients(ctx context.Context, cfg *config.Config) *csync.Map[string, *lsp.Client] {
+	app := &App{
+		LSPClients: csync.NewMap[string, *lsp.Client](),
+		config:     cfg,
+		globalCtx:  ctx,
+	}
+	var wg sync.WaitGroup
+	for name, clientConfig := range cfg.LSP {
+		if clientConfig.Disabled {
+			continue
+		}
+		wg.Go(func() {
+			_ = app.createAndStartLSPClient(ctx, name, clientConfig)
+		})
+	}
+	wg.Wait()
+	return app.LSPClients
+}
+
+// RestartLSP closes the named LSP client, if it is running, and starts it
+// again.
+func (app *App) RestartLSP(name string) error {
+	cfg, ok := app.config.LSP[name]
+	if !ok {
+		return fmt.Errorf("lsp %q is not configured", name)
+	}
+	if cfg.Disabled {
+		return fmt.Errorf("lsp %q is disabled", name)
+	}
+
+	app.lspRestartMu.Lock()
+	defer app.lspRestartMu.Unlock()
+
+	slog.Info("Restarting LSP client", "name", name)
+	lspBroker.Publish(pubsub.UpdatedEvent, LSPEvent{
+		Type:  LSPEventRestarting,
+		Name:  name,
+		State: lsp.StateStarting,
+	})
+	if client, ok := app.LSPClients.Take(name); ok {
+		closeCtx, cancel := context.WithTimeout(app.globalCtx, 5*time.Second)
+		if err := client.Close(closeCtx); err != nil {
+			slog.Debug("Failed to close LSP client before restarting", "name", name, "error", err)
+		}
+		cancel()
+	}
+	return app.createAndStartLSPClient(app.globalCtx, name, cfg)
+}
+
+// watchLSPClient restarts the named LSP client with backoff when its server
+// exits. After maxLSPRestarts failed restarts in a row the client is left
+// stopped until it is restarted with RestartLSP.
+func (app *App) watchLSPClient(ctx context.Context, name string) {
+	ticker := time.NewTicker(lspHealthInterval)
+	defer ticker.Stop()
+
+	var restarts int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client, ok := app.LSPClients.Get(name)
+		if !ok {
+			continue
+		}
+		err := client.Ping(ctx)
+		if err == nil {
+			restarts = 0
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		restarted := false
+		for !restarted && restarts < maxLSPRestarts {
+			delay := time.Second << restarts
+			restarts++
+			slog.Warn("LSP server exited, restarting", "name", name, "attempt", restarts, "delay", delay, "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if err := app.RestartLSP(name); err != nil {
+				slog.Error("Failed to restart LSP client", "name", name, "error", err)
+				continue
+			}
+			restarted = true
+		}
+		if !restarted {
+			slog.Error("Giving up restarting LSP client", "name", name, "restarts", restarts)
+			if client, ok := app.LSPClients.Take(name); ok {
+				_ = client.Close(ctx)
+			}
+			updateLSPState(name, lsp.StateError, fmt.Errorf("server exited, gave up after %d restarts", restarts), nil, 0)
+			restarts = 0
+		}
+	}
+}
+
 // createAndStartLSPClient creates a new LSP client, initializes it, and starts its workspace watcher
-func (app *App) createAndStartLSPClient(ctx context.Context, name string, config config.LSPConfig) {
+func (app *App) createAndStartLSPClient(ctx context.Context, name string, config config.LSPConfig) error {
 	slog.Info("Creating LSP client", "name", name, "command", config.Command, "fileTypes", config.FileTypes, "args", config.Args)
 
 	// Check if any root markers exist in the working directory (config now has defaults)
 	if !lsp.HasRootMarkers(app.config.WorkingDir(), config.RootMarkers) {
 		slog.Info("Skipping LSP client - no root markers found", "name", name, "rootMarkers", config.RootMarkers)
 		updateLSPState(name, lsp.StateDisabled, nil, nil, 0)
-		return
+		return nil
 	}
 
 	// Update state to starting
@@ -40,7 +164,7 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, config
 	if err != nil {
 		slog.Error("Failed to create LSP client for", name, err)
 		updateLSPState(name, lsp.StateError, err, nil, 0)
-		return
+		return err
 	}
 
 	// Set diagnostics callback
@@ -56,7 +180,7 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, config
 		slog.Error("Initialize failed", "name", name, "error", err)
 		updateLSPState(name, lsp.StateError, err, lspClient, 0)
 		lspClient.Close(ctx)
-		return
+		return err
 	}
 
 	// Wait for the server to be ready.
@@ -77,4 +201,5 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, config
 
 	// Add to map with mutex protection before starting goroutine
 	app.LSPClients.Set(name, lspClient)
+	return nil
 }
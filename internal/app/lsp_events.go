@@ -16,6 +16,7 @@ type LSPEventType string
 const (
 	LSPEventStateChanged       LSPEventType = "state_changed"
 	LSPEventDiagnosticsChanged LSPEventType = "diagnostics_changed"
+	LSPEventRestarting         LSPEventType = "restarting"
 )
 
 // LSPEvent represents an event in the LSP system
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/lsp"
+)
+
+// fakeLSPLogEnv names the file the fake LSP server logs the methods it's
+// called with to, as lines of its process ID and the method.
+const fakeLSPLogEnv = "TULPA_FAKE_LSP_LOG"
+
+// TestFakeLSPServer isn't a test: it's the fake LSP server the tests start,
+// running the test binary.
+func TestFakeLSPServer(t *testing.T) {
+	logPath := os.Getenv(fakeLSPLogEnv)
+	if logPath == "" {
+		t.Skip("only run as the fake LSP server")
+	}
+	log, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		os.Exit(1)
+	}
+	in := textproto.NewReader(bufio.NewReader(os.Stdin))
+	for {
+		header, err := in.ReadMIMEHeader()
+		if err != nil {
+			os.Exit(0)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(in.R, body); err != nil {
+			os.Exit(0)
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			os.Exit(1)
+		}
+		fmt.Fprintf(log, "%d %s\n", os.Getpid(), msg.Method)
+		if msg.Method == "exit" {
+			os.Exit(0)
+		}
+		if msg.ID == nil {
+			continue
+		}
+		result := "null"
+		if msg.Method == "initialize" {
+			result = `{"capabilities":{}}`
+		}
+		reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, msg.ID, result)
+		fmt.Fprintf(os.Stdout, "Content-Length: %d\r\n\r\n%s", len(reply), reply)
+	}
+}
+
+// fakeLSPCalls returns the methods the fake LSP servers were called with,
+// by process, in the order the processes started.
+func fakeLSPCalls(t *testing.T, logPath string) [][]string {
+	t.Helper()
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	var pids []string
+	calls := make(map[string][]string)
+	for line := range strings.Lines(string(data)) {
+		pid, method, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if _, ok := calls[pid]; !ok {
+			pids = append(pids, pid)
+		}
+		calls[pid] = append(calls[pid], method)
+	}
+	var byProcess [][]string
+	for _, pid := range pids {
+		byProcess = append(byProcess, calls[pid])
+	}
+	return byProcess
+}
+
+func TestRestartLSP(t *testing.T) {
+	cfg, err := config.Init(t.TempDir(), t.TempDir(), false)
+	require.NoError(t, err)
+	logPath := filepath.Join(t.TempDir(), "lsp.log")
+	cfg.LSP = map[string]config.LSPConfig{
+		"fake": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=^TestFakeLSPServer$"},
+			Env:     map[string]string{fakeLSPLogEnv: logPath},
+		},
+		"off": {Command: os.Args[0], Disabled: true},
+	}
+	app := &App{
+		LSPClients: csync.NewMap[string, *lsp.Client](),
+		config:     cfg,
+		globalCtx:  t.Context(),
+	}
+	t.Cleanup(func() {
+		for client := range app.LSPClients.Seq() {
+			_ = client.Close(t.Context())
+		}
+	})
+
+	require.NoError(t, app.createAndStartLSPClient(t.Context(), "fake", cfg.LSP["fake"]))
+	first, ok := app.LSPClients.Get("fake")
+	require.True(t, ok)
+
+	require.NoError(t, app.RestartLSP("fake"))
+	second, ok := app.LSPClients.Get("fake")
+	require.True(t, ok)
+	require.NotSame(t, first, second, "a new client replaces the closed one")
+	require.NoError(t, second.Ping(t.Context()))
+
+	calls := fakeLSPCalls(t, logPath)
+	require.Len(t, calls, 2, "a new server is started")
+	require.Equal(t, []string{"initialize", "initialized"}, calls[0][:2])
+	require.Contains(t, calls[0], "shutdown", "the first server is shut down")
+	require.Equal(t, []string{"initialize", "initialized"}, calls[1][:2])
+	require.NotContains(t, calls[1], "shutdown")
+
+	require.EqualError(t, app.RestartLSP("off"), `lsp "off" is disabled`)
+	require.EqualError(t, app.RestartLSP("missing"), `lsp "missing" is not configured`)
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/tulpa-code/tulpa/internal/fsext"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// Limits of the files attached to a prompt through @path mentions.
+const (
+	maxMentionFiles     = 20
+	maxMentionFileBytes = 256 * 1024
+	maxMentionBytes     = 1024 * 1024
+)
+
+// mentionPattern matches the @path mentions of a prompt, at its start or
+// after a space so email addresses aren't taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// ExpandMentions returns prompt with the text files it mentions as @path
+// appended, like text attachments. Paths are resolved against the workspace
+// roots, and globs like @internal/**/*.go are expanded. Only files inside the
+// workspace roots are attached: like the file tools, mentions can't read
+// files outside of them. Mentions that match no file, or files that are too
+// large or not text, are left out and reported in the returned warnings.
+func (app *App) ExpandMentions(prompt string) (string, []string) {
+	return expandMentions(prompt, app.config.WorkspaceRoots())
+}
+
+func expandMentions(prompt string, roots []string) (string, []string) {
+	var (
+		warnings []string
+		files    []string
+	)
+	for _, match := range mentionPattern.FindAllStringSubmatch(prompt, -1) {
+		mention := strings.TrimRight(match[1], ".,;:!?)]}'\"")
+		if mention == "" {
+			continue
+		}
+		paths, err := resolveMention(mention, roots)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+		for _, path := range paths {
+			if !slices.Contains(files, path) {
+				files = append(files, path)
+			}
+		}
+	}
+	if len(files) > maxMentionFiles {
+		warnings = append(warnings, fmt.Sprintf("only the first %d mentioned files were attached, %d were left out", maxMentionFiles, len(files)-maxMentionFiles))
+		files = files[:maxMentionFiles]
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	remaining := maxMentionBytes
+	for _, path := range files {
+		attachment, err := message.ReadAttachment(path, int64(min(maxMentionFileBytes, remaining)))
+		switch {
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("%s was not attached: %v", path, err))
+		case !attachment.IsText():
+			warnings = append(warnings, fmt.Sprintf("%s was not attached: %s files are not supported, only text", path, attachment.MimeType))
+		default:
+			remaining -= len(attachment.Content)
+			writeTextAttachment(&b, attachment)
+		}
+	}
+	return b.String(), warnings
+}
+
+// resolveMention returns the files a mention refers to: the file of the first
+// root it exists in, or the files matching it in all roots when it's a glob.
+// Files outside of the roots, including through symlinks, are refused.
+func resolveMention(mention string, roots []string) ([]string, error) {
+	path := filepath.FromSlash(mention)
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return nil, fmt.Errorf("@%s is outside the workspace, mention paths relative to it", mention)
+	}
+	if !strings.ContainsAny(path, "*?[{") {
+		for _, root := range roots {
+			full := filepath.Join(root, path)
+			info, err := os.Stat(full)
+			switch {
+			case err != nil:
+				continue
+			case !inRoots(roots, full):
+				return nil, fmt.Errorf("@%s is outside the workspace, mention paths relative to it", mention)
+			case info.IsDir():
+				return nil, fmt.Errorf("@%s is a directory, mention its files or a glob like @%s/*", mention, strings.TrimSuffix(mention, "/"))
+			default:
+				return []string{full}, nil
+			}
+		}
+		return nil, fmt.Errorf("@%s matches no file", mention)
+	}
+
+	if !doublestar.ValidatePattern(filepath.ToSlash(path)) {
+		return nil, fmt.Errorf("@%s is not a valid glob", mention)
+	}
+	var matches []string
+	for _, root := range roots {
+		found, _, err := fsext.GlobWithDoubleStar(path, root, 0)
+		if err != nil {
+			return nil, fmt.Errorf("@%s could not be expanded: %w", mention, err)
+		}
+		for _, file := range found {
+			if info, err := os.Stat(file); err == nil && !info.IsDir() && inRoots(roots, file) {
+				matches = append(matches, file)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("@%s matches no file", mention)
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// inRoots reports whether path is inside one of roots once the symlinks of
+// both are followed.
+func inRoots(roots []string, path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range roots {
+		root, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMentions(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	other := t.TempDir()
+	write := func(path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write(filepath.Join(root, "internal", "app", "app.go"), "package app\n")
+	write(filepath.Join(root, "internal", "db", "db.go"), "package db\n")
+	write(filepath.Join(root, "internal", "db", "schema.sql"), "CREATE TABLE t;\n")
+	write(filepath.Join(root, "logo.png"), "\x89PNG\r\n\x1a\n")
+	write(filepath.Join(root, "big.txt"), strings.Repeat("a", maxMentionFileBytes+1))
+	write(filepath.Join(other, "README.md"), "# API\n")
+	outside := t.TempDir()
+	write(filepath.Join(outside, "credentials"), "secret\n")
+	require.NoError(t, os.Symlink(filepath.Join(outside, "credentials"), filepath.Join(root, "link")))
+	roots := []string{root, other}
+
+	attachment := func(path, content string) string {
+		return "\n\n<attachment path=\"" + path + "\">\n" + content + "\n</attachment>"
+	}
+
+	tests := []struct {
+		name     string
+		prompt   string
+		want     string
+		warnings []string
+	}{
+		{
+			name:   "file",
+			prompt: "explain @internal/app/app.go.",
+			want:   "explain @internal/app/app.go." + attachment(filepath.Join(root, "internal", "app", "app.go"), "package app"),
+		},
+		{
+			name:   "other root",
+			prompt: "@README.md",
+			want:   "@README.md" + attachment(filepath.Join(other, "README.md"), "# API"),
+		},
+		{
+			name:   "glob",
+			prompt: "review @internal/**/*.go and @internal/app/app.go",
+			want: "review @internal/**/*.go and @internal/app/app.go" +
+				attachment(filepath.Join(root, "internal", "app", "app.go"), "package app") +
+				attachment(filepath.Join(root, "internal", "db", "db.go"), "package db"),
+		},
+		{
+			name:   "email addresses are not mentions",
+			prompt: "mail team@tulpa.dev",
+			want:   "mail team@tulpa.dev",
+		},
+		{
+			name:   "outside of the workspace",
+			prompt: "@" + filepath.Join(outside, "credentials") + " @~/.aws/credentials @../credentials @link @../*/credentials",
+			want:   "@" + filepath.Join(outside, "credentials") + " @~/.aws/credentials @../credentials @link @../*/credentials",
+			warnings: []string{
+				"@" + filepath.Join(outside, "credentials") + " is outside the workspace, mention paths relative to it",
+				"@~/.aws/credentials is outside the workspace, mention paths relative to it",
+				"@../credentials matches no file",
+				"@link is outside the workspace, mention paths relative to it",
+				"@../*/credentials matches no file",
+			},
+		},
+		{
+			name:   "unresolved",
+			prompt: "ask @someone about @internal/db and @logo.png @big.txt",
+			want:   "ask @someone about @internal/db and @logo.png @big.txt",
+			warnings: []string{
+				"@someone matches no file",
+				"@internal/db is a directory, mention its files or a glob like @internal/db/*",
+				filepath.Join(root, "logo.png") + " was not attached: image/png files are not supported, only text",
+				filepath.Join(root, "big.txt") + " was not attached: attachment " + filepath.Join(root, "big.txt") + " is too large: 262145 bytes, max 262144",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, warnings := expandMentions(tt.prompt, roots)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.warnings, warnings)
+		})
+	}
+}
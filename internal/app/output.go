@@ -0,0 +1,315 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/tulpa-code/tulpa/internal/llm/agent"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+// Output formats of non-interactive runs.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// OutputFormatter writes what happens during a non-interactive run.
+type OutputFormatter interface {
+	// OnPrompt is called before each prompt of the run, with its 1-based
+	// index and the number of prompts.
+	OnPrompt(index, total int)
+	// OnDelta is called each time an assistant message grows while it
+	// streams, with its full content so far.
+	OnDelta(msg message.Message) error
+	// OnToolCall is called once for each tool call of msg when the call is
+	// complete.
+	OnToolCall(msg message.Message, call message.ToolCall)
+	// OnToolResult is called once for each tool result of msg.
+	OnToolResult(msg message.Message, result message.ToolResult)
+	// OnStatus is called when the agent retries a request, falls back to
+	// another model, approaches its tool iteration limit, compacts the
+	// conversation or starts a step of its plan.
+	OnStatus(event agent.AgentEvent)
+	// OnResult is called when the response to a prompt is complete, or was
+	// canceled.
+	OnResult(result RunResult) error
+	// OnError is called when the response to a prompt failed.
+	OnError(sessionID string, err error)
+}
+
+// RunResult is the response to a prompt of a non-interactive run.
+type RunResult struct {
+	// The session with its usage so far
+	Session session.Session
+	Message message.Message
+	// The parsed response of agents using the json response format
+	JSON json.RawMessage
+	// Why the response was cut short, nil when it is complete
+	Err error
+}
+
+// deltaTracker tracks how much of each message was written, since messages
+// are published with their full content every time they grow.
+type deltaTracker map[string]int
+
+// next returns the content of msg that wasn't written yet and marks it
+// written.
+func (t deltaTracker) next(msg message.Message) (string, error) {
+	content := msg.Content().String()
+	written := t[msg.ID]
+	if len(content) < written {
+		slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(content), "read_bytes", written)
+		return "", fmt.Errorf("message content is shorter than read bytes: %d < %d", len(content), written)
+	}
+	t[msg.ID] = len(content)
+	return content[written:], nil
+}
+
+// PlainFormatter writes the responses as they stream, as plain text.
+type PlainFormatter struct {
+	w      io.Writer
+	deltas deltaTracker
+}
+
+// NewPlainFormatter returns a formatter writing plain text to w.
+func NewPlainFormatter(w io.Writer) *PlainFormatter {
+	return &PlainFormatter{w: w, deltas: make(deltaTracker)}
+}
+
+func (f *PlainFormatter) OnPrompt(index, total int) {
+	if total < 2 {
+		return
+	}
+	if index > 1 {
+		fmt.Fprintln(f.w)
+	}
+	fmt.Fprintf(f.w, "--- %d ---\n", index)
+}
+
+func (f *PlainFormatter) OnDelta(msg message.Message) error {
+	part, err := f.deltas.next(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(f.w, part)
+	return nil
+}
+
+func (f *PlainFormatter) OnToolCall(message.Message, message.ToolCall) {}
+
+func (f *PlainFormatter) OnToolResult(message.Message, message.ToolResult) {}
+
+func (f *PlainFormatter) OnStatus(agent.AgentEvent) {}
+
+func (f *PlainFormatter) OnResult(result RunResult) error {
+	part, err := f.deltas.next(result.Message)
+	if err != nil {
+		return err
+	}
+	if result.Err != nil && result.Message.Content().String() == "" {
+		return nil
+	}
+	fmt.Fprintln(f.w, part)
+	return nil
+}
+
+func (f *PlainFormatter) OnError(string, error) {}
+
+const (
+	runEventDelta      = "delta"
+	runEventToolCall   = "tool_call"
+	runEventToolResult = "tool_result"
+	runEventResult     = "result"
+	runEventError      = "error"
+	runEventRetry      = "retry"
+	runEventFallback   = "fallback"
+	runEventToolLimit  = "tool_limit"
+	runEventCompaction = "compaction"
+	runEventStep       = "step"
+)
+
+// runEvent is one line of the newline-delimited JSON written by
+// [JSONFormatter].
+type runEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	// 1-based index of the prompt in batch runs
+	Prompt    int    `json:"prompt,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolInput  string `json:"tool_input,omitempty"`
+	IsError    bool   `json:"is_error,omitempty"`
+
+	// Retries of provider requests that failed with a transient error
+	Attempt    int `json:"attempt,omitempty"`
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// The model answering after a fallback
+	Model string `json:"model,omitempty"`
+
+	// Tool iterations the agent used in the turn, and how many it may use
+	ToolIterations    int `json:"tool_iterations,omitempty"`
+	MaxToolIterations int `json:"max_tool_iterations,omitempty"`
+
+	// Estimated tokens of a compacted conversation, the threshold they
+	// crossed, and the messages summarized and kept
+	Tokens             int64 `json:"tokens,omitempty"`
+	CompactThreshold   int64 `json:"compact_threshold,omitempty"`
+	SummarizedMessages int   `json:"summarized_messages,omitempty"`
+	KeptMessages       int   `json:"kept_messages,omitempty"`
+
+	// The step of its plan the agent started, and how many it has
+	Step       int `json:"step,omitempty"`
+	TotalSteps int `json:"total_steps,omitempty"`
+
+	// The parsed final response of agents using the json response format
+	JSON  json.RawMessage `json:"json,omitempty"`
+	Usage *runUsage       `json:"usage,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+type runUsage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// JSONFormatter writes what happens during the run as newline-delimited JSON
+// events.
+type JSONFormatter struct {
+	enc    *json.Encoder
+	deltas deltaTracker
+	prompt int
+}
+
+// NewJSONFormatter returns a formatter writing JSON events to w.
+func NewJSONFormatter(w io.Writer) *JSONFormatter {
+	return &JSONFormatter{enc: json.NewEncoder(w), deltas: make(deltaTracker)}
+}
+
+func (f *JSONFormatter) write(event runEvent) {
+	event.Prompt = f.prompt
+	if err := f.enc.Encode(event); err != nil {
+		slog.Error("Failed to write run event", "type", event.Type, "error", err)
+	}
+}
+
+func (f *JSONFormatter) delta(msg message.Message) error {
+	part, err := f.deltas.next(msg)
+	if err != nil || part == "" {
+		return err
+	}
+	f.write(runEvent{Type: runEventDelta, SessionID: msg.SessionID, MessageID: msg.ID, Content: part})
+	return nil
+}
+
+func (f *JSONFormatter) OnPrompt(index, total int) {
+	if total > 1 {
+		f.prompt = index
+	}
+}
+
+func (f *JSONFormatter) OnDelta(msg message.Message) error {
+	return f.delta(msg)
+}
+
+func (f *JSONFormatter) OnToolCall(msg message.Message, call message.ToolCall) {
+	f.write(runEvent{
+		Type:       runEventToolCall,
+		SessionID:  msg.SessionID,
+		MessageID:  msg.ID,
+		ToolCallID: call.ID,
+		ToolName:   call.Name,
+		ToolInput:  call.Input,
+	})
+}
+
+func (f *JSONFormatter) OnToolResult(msg message.Message, result message.ToolResult) {
+	f.write(runEvent{
+		Type:       runEventToolResult,
+		SessionID:  msg.SessionID,
+		MessageID:  msg.ID,
+		ToolCallID: result.ToolCallID,
+		ToolName:   result.Name,
+		Content:    result.Content,
+		IsError:    result.IsError,
+	})
+}
+
+func (f *JSONFormatter) OnStatus(event agent.AgentEvent) {
+	switch {
+	case event.Type == agent.AgentEventTypeFallback && event.Fallback != nil:
+		f.write(runEvent{
+			Type:      runEventFallback,
+			SessionID: event.SessionID,
+			Model:     event.Fallback.To.ID,
+			Error:     event.Fallback.Err.Error(),
+		})
+	case event.Type == agent.AgentEventTypeRetry && event.Retry != nil:
+		f.write(runEvent{
+			Type:       runEventRetry,
+			SessionID:  event.SessionID,
+			Error:      event.Retry.Err.Error(),
+			Attempt:    event.Retry.Attempt,
+			MaxRetries: event.Retry.MaxRetries,
+		})
+	case event.Type == agent.AgentEventTypeToolLimit && event.ToolLimit != nil:
+		f.write(runEvent{
+			Type:              runEventToolLimit,
+			SessionID:         event.SessionID,
+			ToolIterations:    event.ToolLimit.Used,
+			MaxToolIterations: event.ToolLimit.Max,
+		})
+	case event.Type == agent.AgentEventTypeCompaction && event.Compaction != nil:
+		f.write(runEvent{
+			Type:               runEventCompaction,
+			SessionID:          event.SessionID,
+			Tokens:             event.Compaction.Tokens,
+			CompactThreshold:   event.Compaction.Threshold,
+			SummarizedMessages: event.Compaction.Summarized,
+			KeptMessages:       event.Compaction.Kept,
+		})
+	case event.Type == agent.AgentEventTypeStep && event.Step != nil:
+		f.write(runEvent{
+			Type:       runEventStep,
+			SessionID:  event.SessionID,
+			Content:    event.Step.Description,
+			Step:       event.Step.Step,
+			TotalSteps: event.Step.Total,
+		})
+	}
+}
+
+func (f *JSONFormatter) OnResult(result RunResult) error {
+	if err := f.delta(result.Message); err != nil {
+		return err
+	}
+	if result.Err != nil {
+		f.write(runEvent{Type: runEventError, SessionID: result.Session.ID, Error: result.Err.Error()})
+		return nil
+	}
+	f.write(runEvent{
+		Type:      runEventResult,
+		SessionID: result.Session.ID,
+		MessageID: result.Message.ID,
+		Content:   result.Message.Content().String(),
+		JSON:      result.JSON,
+		Usage: &runUsage{
+			PromptTokens:     result.Session.PromptTokens,
+			CompletionTokens: result.Session.CompletionTokens,
+			Cost:             result.Session.Cost,
+		},
+	})
+	return nil
+}
+
+func (f *JSONFormatter) OnError(sessionID string, err error) {
+	f.write(runEvent{Type: runEventError, SessionID: sessionID, Error: err.Error()})
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/llm/agent"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+func assistantMessage(id, text string) message.Message {
+	return message.Message{
+		ID:        id,
+		SessionID: "s1",
+		Role:      message.Assistant,
+		Parts:     []message.ContentPart{message.TextContent{Text: text}},
+	}
+}
+
+func TestPlainFormatter(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	f := NewPlainFormatter(&b)
+	f.OnPrompt(1, 2)
+	require.NoError(t, f.OnDelta(assistantMessage("m1", "Hel")))
+	require.NoError(t, f.OnDelta(assistantMessage("m1", "Hello")))
+	f.OnStatus(agent.AgentEvent{Type: agent.AgentEventTypeRetry, Retry: &provider.RetryInfo{Attempt: 1, Err: errors.New("overloaded")}})
+	require.NoError(t, f.OnResult(RunResult{Message: assistantMessage("m1", "Hello!")}))
+	f.OnPrompt(2, 2)
+	require.NoError(t, f.OnResult(RunResult{Message: assistantMessage("m2", ""), Err: context.Canceled}))
+	require.Equal(t, "--- 1 ---\nHello!\n\n--- 2 ---\n", b.String())
+
+	require.EqualError(t, f.OnDelta(assistantMessage("m1", "Hi")), "message content is shorter than read bytes: 2 < 6")
+}
+
+func TestJSONFormatter(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	f := NewJSONFormatter(&b)
+	f.OnPrompt(1, 1)
+	require.NoError(t, f.OnDelta(assistantMessage("m1", "Let me")))
+	call := message.ToolCall{ID: "c1", Name: "ls", Input: `{}`, Finished: true}
+	f.OnToolCall(assistantMessage("m1", "Let me look"), call)
+	f.OnToolResult(message.Message{ID: "m2", SessionID: "s1", Role: message.Tool}, message.ToolResult{ToolCallID: "c1", Name: "ls", Content: "main.go"})
+	f.OnStatus(agent.AgentEvent{Type: agent.AgentEventTypeRetry, SessionID: "s1", Retry: &provider.RetryInfo{Attempt: 1, MaxRetries: 3, Err: errors.New("overloaded")}})
+	f.OnStatus(agent.AgentEvent{Type: agent.AgentEventTypeToolLimit, SessionID: "s1", ToolLimit: &agent.ToolLimitInfo{Used: 8, Max: 10}})
+	f.OnStatus(agent.AgentEvent{Type: agent.AgentEventTypeCompaction, SessionID: "s1", Compaction: &agent.CompactionInfo{Tokens: 170000, Threshold: 160000, Summarized: 40, Kept: 6}})
+	f.OnStatus(agent.AgentEvent{Type: agent.AgentEventTypeStep, SessionID: "s1", Step: &agent.StepInfo{Step: 2, Total: 5, Description: "Update the tests"}})
+	require.NoError(t, f.OnResult(RunResult{
+		Session: session.Session{ID: "s1", PromptTokens: 10, CompletionTokens: 2},
+		Message: assistantMessage("m1", "Let me look"),
+	}))
+	f.OnError("s1", errors.New("boom"))
+
+	require.Equal(t, strings.Join([]string{
+		`{"type":"delta","session_id":"s1","message_id":"m1","content":"Let me"}`,
+		`{"type":"tool_call","session_id":"s1","message_id":"m1","tool_call_id":"c1","tool_name":"ls","tool_input":"{}"}`,
+		`{"type":"tool_result","session_id":"s1","message_id":"m2","content":"main.go","tool_call_id":"c1","tool_name":"ls"}`,
+		`{"type":"retry","session_id":"s1","attempt":1,"max_retries":3,"error":"overloaded"}`,
+		`{"type":"tool_limit","session_id":"s1","tool_iterations":8,"max_tool_iterations":10}`,
+		`{"type":"compaction","session_id":"s1","tokens":170000,"compact_threshold":160000,"summarized_messages":40,"kept_messages":6}`,
+		`{"type":"step","session_id":"s1","content":"Update the tests","step":2,"total_steps":5}`,
+		`{"type":"delta","session_id":"s1","message_id":"m1","content":" look"}`,
+		`{"type":"result","session_id":"s1","message_id":"m1","content":"Let me look","usage":{"prompt_tokens":10,"completion_tokens":2,"cost":0}}`,
+		`{"type":"error","session_id":"s1","error":"boom"}`,
+		``,
+	}, "\n"), b.String())
+}
+
+func TestJSONFormatterBatch(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	f := NewJSONFormatter(&b)
+	f.OnPrompt(2, 3)
+	require.NoError(t, f.OnResult(RunResult{Session: session.Session{ID: "s1"}, Message: assistantMessage("m1", ""), Err: context.Canceled}))
+	require.Equal(t, `{"type":"error","session_id":"s1","prompt":2,"error":"context canceled"}`+"\n", b.String())
+}
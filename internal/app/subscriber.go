@@ -0,0 +1,271 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/tulpa-code/tulpa/internal/history"
+	"github.com/tulpa-code/tulpa/internal/llm/agent"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+// SubscriptionStats counts what happened to the events of a subscription on
+// their way to the interface.
+type SubscriptionStats struct {
+	// Events the interface received
+	Delivered int64 `json:"delivered"`
+	// Events replaced by a newer one before the interface received them
+	Coalesced int64 `json:"coalesced"`
+	// Events waiting for the interface, now and at most
+	Pending    int64 `json:"pending"`
+	MaxPending int64 `json:"max_pending"`
+	// Events the interface took longer than the slow consumer timeout to
+	// receive
+	Slow int64 `json:"slow"`
+}
+
+type subscriptionCounters struct {
+	delivered  atomic.Int64
+	coalesced  atomic.Int64
+	pending    atomic.Int64
+	maxPending atomic.Int64
+	slow       atomic.Int64
+}
+
+func (c *subscriptionCounters) stats() SubscriptionStats {
+	return SubscriptionStats{
+		Delivered:  c.delivered.Load(),
+		Coalesced:  c.coalesced.Load(),
+		Pending:    c.pending.Load(),
+		MaxPending: c.maxPending.Load(),
+		Slow:       c.slow.Load(),
+	}
+}
+
+// String implements [expvar.Var].
+func (c *subscriptionCounters) String() string {
+	b, _ := json.Marshal(c.stats())
+	return string(b)
+}
+
+// eventStats holds the counters of each subscription by name. It's served
+// at /debug/vars by the pprof server started with TULPA_PROFILE.
+var eventStats = expvar.NewMap("tulpa_events")
+
+func countersFor(name string) *subscriptionCounters {
+	if c, ok := eventStats.Get(name).(*subscriptionCounters); ok {
+		return c
+	}
+	c := new(subscriptionCounters)
+	eventStats.Set(name, c)
+	return c
+}
+
+// EventStats returns the counters of the subscriptions forwarding events to
+// the interface, by name.
+func EventStats() map[string]SubscriptionStats {
+	stats := make(map[string]SubscriptionStats)
+	eventStats.Do(func(kv expvar.KeyValue) {
+		if c, ok := kv.Value.(*subscriptionCounters); ok {
+			stats[kv.Key] = c.stats()
+		}
+	})
+	return stats
+}
+
+// coalesceFunc is the policy of a subscriber for events waiting for a slow
+// consumer. It returns a key and true for events that only report progress,
+// so that a newer event with the same key replaces the waiting one. Events it
+// returns false for, like completions and errors, are all delivered, in
+// order. A nil coalesceFunc delivers every event.
+type coalesceFunc[T any] func(event pubsub.Event[T]) (key string, ok bool)
+
+// coalesceUpdated coalesces the updates of the same item, identified by id.
+func coalesceUpdated[T any](id func(T) string) coalesceFunc[T] {
+	return func(event pubsub.Event[T]) (string, bool) {
+		if event.Type != pubsub.UpdatedEvent {
+			return "", false
+		}
+		return id(event.Payload), true
+	}
+}
+
+var (
+	// Messages are published with their full content each time they grow
+	// while streaming.
+	coalesceMessages = coalesceUpdated(func(msg message.Message) string { return msg.ID })
+	coalesceSessions = coalesceUpdated(func(sess session.Session) string { return sess.ID })
+	coalesceHistory  = coalesceUpdated(func(file history.File) string { return file.ID })
+)
+
+// coalesceAgentEvents coalesces the summarization progress and the retries
+// of a session. Responses, errors and the end of summarizations are always
+// delivered.
+func coalesceAgentEvents(event pubsub.Event[agent.AgentEvent]) (string, bool) {
+	e := event.Payload
+	switch {
+	case e.Type == agent.AgentEventTypeSummarize && !e.Done && e.Error == nil:
+		return "summarize:" + e.SessionID, true
+	case e.Type == agent.AgentEventTypeRetry:
+		return "retry:" + e.SessionID, true
+	}
+	return "", false
+}
+
+// setupSubscriber forwards the events of subscriber to outputCh. Events
+// waiting for a slow consumer are buffered rather than dropped, and coalesce
+// decides which of them a newer event may replace. Consumers taking longer
+// than slowAfter to receive an event are logged, unless it's zero.
+func setupSubscriber[T any](
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	name string,
+	subscriber func(context.Context) <-chan pubsub.Event[T],
+	coalesce coalesceFunc[T],
+	slowAfter time.Duration,
+	outputCh chan<- tea.Msg,
+) {
+	counters := countersFor(name)
+	wg.Go(func() {
+		subCh := subscriber(ctx)
+		pending := newPendingEvents(coalesce)
+		// Whether the current stall of the consumer was logged
+		warned := false
+		// checkSlow logs the first time in a stall that an event waited
+		// longer than slowAfter, and reports whether it did.
+		checkSlow := func(waited time.Duration) bool {
+			if slowAfter <= 0 || waited <= slowAfter {
+				return false
+			}
+			if !warned {
+				slog.Warn("Slow event consumer", "name", name, "waited", waited.Round(time.Millisecond), "pending", pending.len())
+				warned = true
+			}
+			return true
+		}
+		for {
+			// Only send when an event is waiting, a nil channel never is
+			// ready.
+			var out chan<- tea.Msg
+			var next tea.Msg
+			if event, ok := pending.peek(); ok {
+				out = outputCh
+				next = event
+			} else if subCh == nil {
+				return
+			}
+
+			select {
+			case event, ok := <-subCh:
+				if !ok {
+					slog.Debug("subscription channel closed", "name", name)
+					// Deliver what is still waiting.
+					subCh = nil
+					continue
+				}
+				if !pending.push(event, time.Now()) {
+					counters.coalesced.Add(1)
+				}
+				n := int64(pending.len())
+				counters.pending.Store(n)
+				if n > counters.maxPending.Load() {
+					counters.maxPending.Store(n)
+				}
+				checkSlow(pending.waited())
+			case out <- next:
+				if checkSlow(pending.waited()) {
+					counters.slow.Add(1)
+				}
+				pending.pop()
+				counters.delivered.Add(1)
+				counters.pending.Store(int64(pending.len()))
+				if pending.len() == 0 {
+					warned = false
+				}
+			case <-ctx.Done():
+				slog.Debug("subscription cancelled", "name", name)
+				return
+			}
+		}
+	})
+}
+
+type pendingEvent[T any] struct {
+	event pubsub.Event[T]
+	key   string
+	keyed bool
+	// When the first event of the key was pushed
+	since time.Time
+}
+
+// pendingEvents is the queue of events waiting for a slow consumer.
+type pendingEvents[T any] struct {
+	coalesce coalesceFunc[T]
+	events   []pendingEvent[T]
+	// Position of the waiting events by key, counted from the first event
+	// ever pushed
+	keys   map[string]int
+	popped int
+}
+
+func newPendingEvents[T any](coalesce coalesceFunc[T]) *pendingEvents[T] {
+	return &pendingEvents[T]{coalesce: coalesce, keys: make(map[string]int)}
+}
+
+func (q *pendingEvents[T]) len() int {
+	return len(q.events)
+}
+
+// push queues event, or replaces the waiting event with the same key and
+// returns false.
+func (q *pendingEvents[T]) push(event pubsub.Event[T], now time.Time) bool {
+	if q.coalesce == nil {
+		q.events = append(q.events, pendingEvent[T]{event: event, since: now})
+		return true
+	}
+	key, ok := q.coalesce(event)
+	if !ok {
+		q.events = append(q.events, pendingEvent[T]{event: event, since: now})
+		return true
+	}
+	if i, waiting := q.keys[key]; waiting {
+		q.events[i-q.popped].event = event
+		return false
+	}
+	q.keys[key] = q.popped + len(q.events)
+	q.events = append(q.events, pendingEvent[T]{event: event, key: key, keyed: true, since: now})
+	return true
+}
+
+// waited returns how long the first waiting event has been waiting.
+func (q *pendingEvents[T]) waited() time.Duration {
+	if len(q.events) == 0 {
+		return 0
+	}
+	return time.Since(q.events[0].since)
+}
+
+func (q *pendingEvents[T]) peek() (pubsub.Event[T], bool) {
+	if len(q.events) == 0 {
+		return pubsub.Event[T]{}, false
+	}
+	return q.events[0].event, true
+}
+
+func (q *pendingEvents[T]) pop() {
+	first := q.events[0]
+	if first.keyed {
+		delete(q.keys, first.key)
+	}
+	q.events[0] = pendingEvent[T]{}
+	q.events = q.events[1:]
+	q.popped++
+}
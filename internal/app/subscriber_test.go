@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/llm/agent"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+)
+
+func TestSubscriberStalledConsumerGetsFinalEvent(t *testing.T) {
+	t.Parallel()
+
+	var events []pubsub.Event[agent.AgentEvent]
+	for i := range 100 {
+		events = append(events, pubsub.Event[agent.AgentEvent]{
+			Type:    pubsub.CreatedEvent,
+			Payload: agent.AgentEvent{Type: agent.AgentEventTypeRetry, SessionID: "s1", Retry: &provider.RetryInfo{Attempt: i + 1}},
+		})
+	}
+	events = append(events, pubsub.Event[agent.AgentEvent]{
+		Type:    pubsub.CreatedEvent,
+		Payload: agent.AgentEvent{Type: agent.AgentEventTypeResponse, Message: assistantMessage("m1", "done")},
+	})
+
+	got := collect(t, coalesceAgentEvents, events)
+	require.Len(t, got, 2)
+	retry := got[0].(pubsub.Event[agent.AgentEvent]).Payload
+	require.Equal(t, agent.AgentEventTypeRetry, retry.Type)
+	require.Equal(t, 100, retry.Retry.Attempt, "retries are coalesced to the latest")
+	final := got[1].(pubsub.Event[agent.AgentEvent]).Payload
+	require.Equal(t, agent.AgentEventTypeResponse, final.Type)
+	require.Equal(t, "done", final.Message.Content().String())
+}
+
+func TestSubscriberCoalescesMessageUpdates(t *testing.T) {
+	t.Parallel()
+
+	event := func(typ pubsub.EventType, id, text string) pubsub.Event[message.Message] {
+		return pubsub.Event[message.Message]{Type: typ, Payload: assistantMessage(id, text)}
+	}
+	events := []pubsub.Event[message.Message]{
+		event(pubsub.CreatedEvent, "m1", ""),
+		event(pubsub.UpdatedEvent, "m1", "H"),
+		event(pubsub.UpdatedEvent, "m1", "He"),
+		event(pubsub.CreatedEvent, "m2", ""),
+		event(pubsub.UpdatedEvent, "m1", "Hello"),
+		event(pubsub.DeletedEvent, "m2", ""),
+	}
+
+	tests := []struct {
+		name     string
+		coalesce coalesceFunc[message.Message]
+		want     []pubsub.Event[message.Message]
+	}{
+		{
+			name:     "coalesced",
+			coalesce: coalesceMessages,
+			want:     []pubsub.Event[message.Message]{events[0], events[4], events[3], events[5]},
+		},
+		{
+			name: "no policy",
+			want: events,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := collect(t, tt.coalesce, events)
+			require.Len(t, got, len(tt.want))
+			for i, msg := range got {
+				require.Equal(t, tt.want[i], msg)
+			}
+		})
+	}
+}
+
+// collect publishes events to a subscriber whose consumer doesn't read until
+// all of them were published, then returns all it reads.
+func collect[T any](t *testing.T, coalesce coalesceFunc[T], events []pubsub.Event[T]) []tea.Msg {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	in := make(chan pubsub.Event[T])
+	out := make(chan tea.Msg)
+	subscriber := func(context.Context) <-chan pubsub.Event[T] { return in }
+	setupSubscriber(t.Context(), &wg, t.Name(), subscriber, coalesce, 0, out)
+	for _, event := range events {
+		in <- event
+	}
+	close(in)
+
+	// The subscriber returns once the closed subscription is drained.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	var got []tea.Msg
+	for {
+		select {
+		case msg := <-out:
+			got = append(got, msg)
+		case <-done:
+			return got
+		}
+	}
+}
+
+func TestSubscriberStats(t *testing.T) {
+	t.Parallel()
+
+	events := []pubsub.Event[message.Message]{
+		{Type: pubsub.CreatedEvent, Payload: assistantMessage("m1", "")},
+		{Type: pubsub.UpdatedEvent, Payload: assistantMessage("m1", "H")},
+		{Type: pubsub.UpdatedEvent, Payload: assistantMessage("m1", "Hi")},
+	}
+	// The counters of a name add up over runs with -count.
+	before := EventStats()[t.Name()]
+	collect(t, coalesceMessages, events)
+
+	stats := EventStats()[t.Name()]
+	require.Equal(t, int64(2), stats.Delivered-before.Delivered)
+	require.Equal(t, int64(1), stats.Coalesced-before.Coalesced, "the first update was replaced")
+	require.Equal(t, int64(2), stats.MaxPending)
+	require.Zero(t, stats.Pending)
+}
@@ -0,0 +1,174 @@
+// Package backup keeps timestamped copies of files before they are
+// overwritten, so they can be restored independently of git.
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxPerFile is the number of backups kept per file when no limit
+	// is configured.
+	DefaultMaxPerFile = 10
+
+	pathFile  = "path"
+	backupExt = ".bak"
+)
+
+// Backup is a copy of a file taken at a given time.
+type Backup struct {
+	// Path is the original file path.
+	Path string
+	// File is where the copy is stored.
+	File      string
+	CreatedAt time.Time
+	// Mode holds the permission bits of the original file.
+	Mode os.FileMode
+}
+
+// Dir returns the directory backups are stored in for the given data
+// directory.
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "backups")
+}
+
+// fileDir returns the directory holding the backups of a single file.
+func fileDir(dataDir, path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(Dir(dataDir), hex.EncodeToString(sum[:8]))
+}
+
+// Create copies the file at path, with its permission bits, into the backups
+// directory and prunes the oldest backups so at most maxPerFile are kept.
+func Create(dataDir, path string, maxPerFile int) (Backup, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	dir := fileDir(dataDir, path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Backup{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, pathFile), []byte(path), 0o600); err != nil {
+		return Backup{}, fmt.Errorf("failed to write backup metadata: %w", err)
+	}
+
+	now := time.Now()
+	backup := Backup{
+		Path:      path,
+		File:      filepath.Join(dir, strconv.FormatInt(now.UnixNano(), 10)+backupExt),
+		CreatedAt: now,
+		Mode:      info.Mode().Perm(),
+	}
+	if err := os.WriteFile(backup.File, content, 0o600); err != nil {
+		return Backup{}, fmt.Errorf("failed to write backup: %w", err)
+	}
+	// The backup directory is private, the copy can keep the mode to restore.
+	if err := os.Chmod(backup.File, backup.Mode); err != nil {
+		return Backup{}, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if maxPerFile <= 0 {
+		maxPerFile = DefaultMaxPerFile
+	}
+	backups, err := List(dataDir, path)
+	if err != nil {
+		return backup, err
+	}
+	for _, old := range backups[min(maxPerFile, len(backups)):] {
+		if err := os.Remove(old.File); err != nil {
+			return backup, fmt.Errorf("failed to prune backup: %w", err)
+		}
+	}
+	return backup, nil
+}
+
+// List returns the backups of the file at path, newest first.
+func List(dataDir, path string) ([]Backup, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	dir := fileDir(dataDir, path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), backupExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		nanos, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Path:      path,
+			File:      filepath.Join(dir, entry.Name()),
+			CreatedAt: time.Unix(0, nanos),
+			Mode:      info.Mode().Perm(),
+		})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return backups, nil
+}
+
+// Restore writes the content and permission bits of the backup back to its
+// original path. The current content, if any, is backed up first so the
+// restore can be undone.
+func Restore(dataDir string, backup Backup, maxPerFile int) error {
+	content, err := os.ReadFile(backup.File)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if _, err := os.Stat(backup.Path); err == nil {
+		if _, err := Create(dataDir, backup.Path, maxPerFile); err != nil {
+			return fmt.Errorf("failed to back up current file: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(backup.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	mode := backup.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := os.WriteFile(backup.Path, content, mode); err != nil {
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
+	// WriteFile leaves the mode of an existing file as is.
+	if err := os.Chmod(backup.Path, mode); err != nil {
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAndList(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	for _, content := range []string{"v1", "v2", "v3"} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := Create(dataDir, path, 2)
+		require.NoError(t, err)
+	}
+
+	backups, err := List(dataDir, path)
+	require.NoError(t, err)
+	require.Len(t, backups, 2, "oldest backups should be pruned")
+
+	newest, err := os.ReadFile(backups[0].File)
+	require.NoError(t, err)
+	require.Equal(t, "v3", string(newest))
+
+	oldest, err := os.ReadFile(backups[1].File)
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(oldest))
+	require.Equal(t, path, backups[0].Path)
+}
+
+func TestListWithoutBackups(t *testing.T) {
+	t.Parallel()
+
+	backups, err := List(t.TempDir(), filepath.Join(t.TempDir(), "missing.go"))
+	require.NoError(t, err)
+	require.Empty(t, backups)
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	require.NoError(t, os.WriteFile(path, []byte("original"), 0o644))
+	b, err := Create(dataDir, path, DefaultMaxPerFile)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("overwritten"), 0o644))
+	require.NoError(t, Restore(dataDir, b, DefaultMaxPerFile))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "original", string(content))
+
+	// The overwritten content is kept so the restore can be undone.
+	backups, err := List(dataDir, path)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	latest, err := os.ReadFile(backups[0].File)
+	require.NoError(t, err)
+	require.Equal(t, "overwritten", string(latest))
+}
+
+func TestRestoreKeepsMode(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "build.sh")
+
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.Chmod(path, 0o755))
+	b, err := Create(dataDir, path, DefaultMaxPerFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o755), b.Mode)
+
+	backups, err := List(dataDir, path)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.Equal(t, os.FileMode(0o755), backups[0].Mode)
+
+	// The file is restored with its mode, whether it was removed or its
+	// mode changed since.
+	for _, overwrite := range []func(){
+		func() { require.NoError(t, os.Remove(path)) },
+		func() { require.NoError(t, os.Chmod(path, 0o600)) },
+	} {
+		overwrite()
+		require.NoError(t, Restore(dataDir, backups[0], DefaultMaxPerFile))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+	}
+}
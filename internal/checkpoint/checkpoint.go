@@ -0,0 +1,223 @@
+// Package checkpoint commits the changes made to the workspace during a
+// session to a git branch of its own, so they can be diffed, cherry-picked or
+// discarded with git. The current branch, the index and the working tree of
+// the user are left alone.
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	authorName  = "Tulpa"
+	authorEmail = "team@tulpa.dev"
+
+	// maxSubjectLength is how long the subject of a checkpoint commit may be
+	// before it's cut.
+	maxSubjectLength = 72
+)
+
+// Branch returns the name of the branch the checkpoints of a session are
+// committed to.
+func Branch(sessionID string) string {
+	return "tulpa/session-" + sessionID
+}
+
+// Message returns the message of the checkpoint of a turn started with
+// prompt: its first line as subject and the whole prompt as body.
+func Message(sessionID, prompt string) string {
+	prompt = strings.TrimSpace(prompt)
+	subject, _, _ := strings.Cut(prompt, "\n")
+	if runes := []rune(subject); len(runes) > maxSubjectLength {
+		subject = string(runes[:maxSubjectLength-1]) + "…"
+	}
+	if subject == "" {
+		subject = "Checkpoint"
+	}
+	var b strings.Builder
+	b.WriteString(subject)
+	if subject != prompt && prompt != "" {
+		b.WriteString("\n\n" + prompt)
+	}
+	b.WriteString("\n\nTulpa-Session: " + sessionID + "\n")
+	return b.String()
+}
+
+// Save commits the working tree of the git repository dir is in to the
+// branch of the session, on top of its last checkpoint, or of HEAD for the
+// first one. Files ignored by git and the paths in exclude are left out.
+// It returns the new commit, or an empty string when nothing changed since
+// the last checkpoint or dir is not in a repository.
+func Save(ctx context.Context, dir, sessionID, message string, exclude ...string) (string, error) {
+	repo, err := openRepo(ctx, dir)
+	if err != nil || repo == nil {
+		return "", err
+	}
+
+	ref := "refs/heads/" + Branch(sessionID)
+	parent, err := repo.git(ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	branchExists := err == nil
+	if !branchExists {
+		// The first checkpoint, on top of HEAD unless the repository has no
+		// commits yet.
+		parent, _ = repo.git(ctx, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	}
+
+	// Each session has an index of its own, kept between checkpoints so
+	// unchanged files aren't hashed again.
+	repo.index = repo.sessionIndex(sessionID)
+	if _, err := os.Stat(repo.index); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(repo.index), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create the checkpoint index: %w", err)
+		}
+		if parent != "" {
+			if _, err := repo.git(ctx, "read-tree", parent); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	args := []string{"add", "--all", "--", "."}
+	for _, path := range exclude {
+		if rel, ok := repo.relative(dir, path); ok {
+			args = append(args, ":(top,exclude)"+rel)
+		}
+	}
+	if _, err := repo.git(ctx, args...); err != nil {
+		return "", err
+	}
+	tree, err := repo.git(ctx, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	args = []string{"commit-tree", tree, "-m", message}
+	if parent != "" {
+		parentTree, err := repo.git(ctx, "rev-parse", parent+"^{tree}")
+		if err != nil {
+			return "", err
+		}
+		if parentTree == tree {
+			return "", nil
+		}
+		args = append(args, "-p", parent)
+	}
+	commit, err := repo.git(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+	// Only moves the branch if no other checkpoint moved it meanwhile.
+	oldValue := ""
+	if branchExists {
+		oldValue = parent
+	}
+	if _, err := repo.git(ctx, "update-ref", "-m", "tulpa: checkpoint", ref, commit, oldValue); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// Remove deletes the indexes the checkpoints of the given sessions were saved
+// with, once the sessions are deleted. Their branches are left for the user to
+// delete. Sessions without checkpoints are skipped.
+func Remove(ctx context.Context, dir string, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	repo, err := openRepo(ctx, dir)
+	if err != nil || repo == nil {
+		return err
+	}
+	var errs []error
+	for _, id := range sessionIDs {
+		if err := os.Remove(repo.sessionIndex(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove the checkpoint index: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type repo struct {
+	root   string
+	gitDir string
+	// The index the commands run with, the one of the user when empty
+	index string
+}
+
+// openRepo returns the git repository dir is in, or nil when it's not in
+// one or git is not installed.
+func openRepo(ctx context.Context, dir string) (*repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil
+	}
+	r := &repo{root: dir}
+	out, err := r.git(ctx, "rev-parse", "--show-toplevel", "--absolute-git-dir")
+	if err != nil {
+		return nil, nil
+	}
+	root, gitDir, ok := strings.Cut(out, "\n")
+	if !ok {
+		return nil, fmt.Errorf("unexpected output of git rev-parse: %q", out)
+	}
+	r.root, r.gitDir = root, gitDir
+	return r, nil
+}
+
+// sessionIndex returns the path of the index the checkpoints of a session
+// are saved with.
+func (r *repo) sessionIndex(sessionID string) string {
+	return filepath.Join(r.gitDir, "tulpa", "session-"+sessionID+".index")
+}
+
+// git runs a git command at the root of the repository and returns its
+// trimmed output.
+func (r *repo) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.root
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+authorName,
+		"GIT_AUTHOR_EMAIL="+authorEmail,
+		"GIT_COMMITTER_NAME="+authorName,
+		"GIT_COMMITTER_EMAIL="+authorEmail,
+	)
+	if r.index != "" {
+		cmd.Env = append(cmd.Env, "GIT_INDEX_FILE="+r.index)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// relative returns path, resolved against dir when relative, relative to
+// the root of the repository, and false when it's outside of it.
+func (r *repo) relative(dir, path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	root := r.root
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,128 @@
+package checkpoint
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSave(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	git("init", "--quiet", "--initial-branch", "main")
+	write("main.go", "package main\n")
+	write(".gitignore", "*.log\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "Initial commit")
+	head := git("rev-parse", "HEAD")
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("pkg/new.go", "package pkg\n")
+	write("debug.log", "ignored\n")
+	write(".tulpa/tulpa.db", "data")
+	git("add", "main.go")
+	status := git("status", "--porcelain")
+
+	first, err := Save(t.Context(), dir, "s1", Message("s1", "Add a main function"), ".tulpa")
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+	require.Equal(t, first, git("rev-parse", Branch("s1")))
+	require.Equal(t, head, git("rev-parse", first+"^"))
+	require.Equal(t, "main.go\npkg/new.go", git("diff", "--name-only", head, first))
+	require.Equal(t, "Add a main function\n\nTulpa-Session: s1", git("log", "-1", "--format=%B", first))
+	require.Equal(t, "Tulpa <team@tulpa.dev>", git("log", "-1", "--format=%an <%ae>", first))
+
+	// The branch, the index and the working tree of the user are untouched.
+	require.Equal(t, "main", git("branch", "--show-current"))
+	require.Equal(t, head, git("rev-parse", "HEAD"))
+	require.Equal(t, status, git("status", "--porcelain"))
+
+	second, err := Save(t.Context(), dir, "s1", Message("s1", "Nothing to do"), ".tulpa")
+	require.NoError(t, err)
+	require.Empty(t, second)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "pkg/new.go")))
+	second, err = Save(t.Context(), dir, "s1", Message("s1", "Remove the package"), ".tulpa")
+	require.NoError(t, err)
+	require.Equal(t, first, git("rev-parse", second+"^"))
+	require.Equal(t, "pkg/new.go", git("diff", "--name-only", first, second))
+
+	// Sessions have branches of their own.
+	other, err := Save(t.Context(), dir, "s2", Message("s2", "Other"), filepath.Join(dir, ".tulpa"))
+	require.NoError(t, err)
+	require.Equal(t, head, git("rev-parse", other+"^"))
+	require.Equal(t, "main.go", git("diff", "--name-only", head, other))
+
+	commit, err := Save(t.Context(), t.TempDir(), "s1", "Not a repository")
+	require.NoError(t, err)
+	require.Empty(t, commit)
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "Fix the build\n\nTulpa-Session: s1\n", Message("s1", "Fix the build\n"))
+	require.Equal(t, "Fix the build\n\nFix the build\nIt fails on Windows.\n\nTulpa-Session: s1\n", Message("s1", "Fix the build\nIt fails on Windows."))
+	require.Equal(t, "Checkpoint\n\nTulpa-Session: s1\n", Message("s1", ""))
+
+	long := Message("s1", strings.Repeat("a", 100))
+	subject, _, _ := strings.Cut(long, "\n")
+	require.Equal(t, strings.Repeat("a", 71)+"…", subject)
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "--quiet")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+
+	for _, id := range []string{"s1", "s2"} {
+		commit, err := Save(t.Context(), dir, id, Message(id, "Add main.go"))
+		require.NoError(t, err)
+		require.NotEmpty(t, commit)
+	}
+	index := func(id string) string {
+		return filepath.Join(dir, ".git", "tulpa", "session-"+id+".index")
+	}
+	require.FileExists(t, index("s1"))
+	require.FileExists(t, index("s2"))
+
+	require.NoError(t, Remove(t.Context(), dir, "s1", "without-checkpoints"))
+	require.NoFileExists(t, index("s1"))
+	require.FileExists(t, index("s2"))
+	cmd = exec.Command("git", "rev-parse", "--verify", "--quiet", Branch("s1"))
+	cmd.Dir = dir
+	require.NoError(t, cmd.Run(), "the branch is kept")
+
+	require.NoError(t, Remove(t.Context(), t.TempDir(), "s2"), "not a repository")
+}
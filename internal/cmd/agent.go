@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Manage agent configurations",
+	Long:  `Manage the YAML agent configurations stored in the agents directory.`,
+}
+
+var agentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured agents",
+	Long: `List the agents loaded from the agents directory and from the .tulpa/agents
+directory of the project with their model type, number of allowed tools,
+whether they are disabled and where they come from. Project agents replace
+the global ones with the same ID.`,
+	Example: `
+# List all agents
+tulpa agent list
+
+# Print the loaded agent configurations as JSON
+tulpa agent list --json
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		loaded, _, err := config.LoadAgentsFromDirectory(cwd)
+		if err != nil {
+			return err
+		}
+		agents := make([]config.Agent, 0, len(loaded))
+		for _, id := range slices.Sorted(maps.Keys(loaded)) {
+			agents = append(agents, loaded[id])
+		}
+
+		if asJSON {
+			bts, err := json.MarshalIndent(agents, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal agents: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(bts))
+			return nil
+		}
+
+		toolCount := func(a config.Agent) string {
+			if a.AllowedTools == nil {
+				return "all"
+			}
+			return strconv.Itoa(len(a.AllowedTools))
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			// We're in a TTY: make it fancy.
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("ID", "Name", "Model", "Tools", "Disabled", "Source")
+			for _, a := range agents {
+				t.Row(a.ID, a.Name, string(a.Model), toolCount(a), strconv.FormatBool(a.Disabled), a.Source)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+		// Not a TTY.
+		for _, a := range agents {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%s\t%t\t%s\n", a.ID, a.Name, a.Model, toolCount(a), a.Disabled, a.Source)
+		}
+		return nil
+	},
+}
+
+var agentNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create a new agent configuration",
+	Long: `Create a new agent configuration in the agents directory. Settings not given
+as flags are asked for when running in a terminal. The generated file is
+validated against the agent schema before it is written.`,
+	Example: `
+# Create an agent, answering questions for the remaining settings
+tulpa agent new "Code Reviewer"
+
+# Create a read-only agent on the small model without any questions
+tulpa agent new Searcher --model small --tools view,grep,glob --description "Finds code"
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		description, _ := cmd.Flags().GetString("description")
+		model, _ := cmd.Flags().GetString("model")
+		tools, _ := cmd.Flags().GetStringSlice("tools")
+
+		agentCfg := &config.AgentYAMLConfig{Name: strings.TrimSpace(args[0])}
+		if agentCfg.GenerateID() == "" {
+			return fmt.Errorf("agent name must not be empty")
+		}
+		path, err := config.NewAgentConfigPath(agentCfg.GenerateID(), force)
+		if errors.Is(err, config.ErrAgentExists) {
+			return fmt.Errorf("%w, use --force to overwrite it", err)
+		}
+		if err != nil {
+			return err
+		}
+
+		if term.IsTerminal(os.Stdin.Fd()) {
+			in := bufio.NewReader(cmd.InOrStdin())
+			ask := func(flag, question, current string) string {
+				if cmd.Flags().Changed(flag) {
+					return current
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s [%s]: ", question, current)
+				answer, _ := in.ReadString('\n')
+				if answer = strings.TrimSpace(answer); answer != "" {
+					return answer
+				}
+				return current
+			}
+			description = ask("description", "Description", description)
+			model = ask("model", "Model type (large, small)", model)
+			if answer := ask("tools", "Allowed tools, comma separated", strings.Join(tools, ",")); answer != "" {
+				tools = strings.Split(answer, ",")
+			}
+		}
+
+		agentCfg.Description = description
+		agentCfg.Prompt = fmt.Sprintf("You are %s, an agent for Tulpa.\n", agentCfg.Name)
+		if description != "" {
+			agentCfg.Prompt += description + "\n"
+		}
+		agentCfg.Model.Type = model
+		for _, tool := range tools {
+			if tool = strings.TrimSpace(tool); tool != "" {
+				agentCfg.Tools.Allowed = append(agentCfg.Tools.Allowed, tool)
+			}
+		}
+
+		data, err := yaml.Marshal(agentCfg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal agent config: %w", err)
+		}
+		if err := config.ValidateAgentConfig(data); err != nil {
+			return err
+		}
+		if err := config.SaveAgentConfig(path, agentCfg); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), path)
+		return nil
+	},
+}
+
+var agentValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Validate agent configuration files",
+	Long: `Validate agent configuration files against the agent schema, reporting
+unknown fields, invalid values and unknown tool names with their line numbers.
+Without arguments, every file in the agents directory is validated.`,
+	Example: `
+# Validate all agents in the agents directory
+tulpa agent validate
+
+# Validate a single file
+tulpa agent validate ~/.config/tulpa/agents/reviewer.yaml
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			var err error
+			files, err = agentConfigFiles(config.AgentsConfigDir())
+			if err != nil {
+				return err
+			}
+		}
+
+		invalid := 0
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err == nil {
+				err = config.ValidateAgentConfig(data)
+			}
+			if err == nil {
+				fmt.Fprintf(cmd.OutOrStdout(), "✓ %s\n", file)
+				continue
+			}
+
+			invalid++
+			fmt.Fprintf(cmd.OutOrStdout(), "✗ %s\n", file)
+			var validationErr *config.AgentConfigValidationError
+			if errors.As(err, &validationErr) {
+				for _, issue := range validationErr.Issues {
+					fmt.Fprintf(cmd.OutOrStdout(), "    %s\n", issue)
+				}
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "    %v\n", err)
+			}
+		}
+
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d agent configs are invalid", invalid, len(files))
+		}
+		return nil
+	},
+}
+
+var agentSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON schema of agent configuration files",
+	Long: `Print the JSON schema agent configuration files are validated against, for
+use with editors and other tools. It is the same schema used by the validate
+command.`,
+	Example: `
+# Save the schema for editor completion
+tulpa agent schema > agent-schema.json
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bts, err := json.MarshalIndent(config.AgentConfigSchema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal schema: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(bts))
+		return nil
+	},
+}
+
+// agentConfigFiles returns the YAML files in the given agents directory.
+func agentConfigFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agents directory %s: %w", dir, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+func init() {
+	agentListCmd.Flags().Bool("json", false, "Output the agents as JSON")
+	agentNewCmd.Flags().String("description", "", "Description of the agent")
+	agentNewCmd.Flags().String("model", string(config.SelectedModelTypeLarge), "Model tier to use: large, small or a tier defined in the models config")
+	agentNewCmd.Flags().StringSlice("tools", nil, "Allowed tools, may be glob patterns (default all tools)")
+	agentNewCmd.Flags().BoolP("force", "f", false, "Overwrite an existing agent configuration")
+	agentCmd.AddCommand(agentListCmd, agentNewCmd, agentValidateCmd, agentSchemaCmd)
+}
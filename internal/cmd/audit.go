@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log/v2"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/permission"
+)
+
+const defaultAuditTailLines = 100
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "View the permission audit log",
+	Long: `View the permission audit log. Every time a tool asks for permission,
+Tulpa records the tool, its arguments and the decision, including requests
+approved automatically with --yolo or in non-interactive runs.`,
+	Example: `
+# Show the last 100 decisions
+tulpa audit
+
+# Follow new decisions as raw JSON lines
+tulpa audit --follow --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		follow, _ := cmd.Flags().GetBool("follow")
+		tailLines, _ := cmd.Flags().GetInt("tail")
+		raw, _ := cmd.Flags().GetBool("json")
+
+		cfg, err := config.Load(cwd, dataDir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+		auditFile := permission.AuditLogPath(cfg.Options.DataDirectory)
+		if _, err := os.Stat(auditFile); os.IsNotExist(err) {
+			log.Warn("No permission decisions recorded yet.")
+			return nil
+		}
+
+		printLine := func(line string) {
+			if raw {
+				fmt.Fprintln(cmd.OutOrStdout(), line)
+				return
+			}
+			printAuditLine(cmd, line)
+		}
+		return tailAudit(cmd.Context(), auditFile, tailLines, follow, printLine)
+	},
+}
+
+func init() {
+	auditCmd.Flags().BoolP("follow", "f", false, "Follow new decisions")
+	auditCmd.Flags().IntP("tail", "t", defaultAuditTailLines, "Show only the last N decisions")
+	auditCmd.Flags().Bool("json", false, "Print the raw JSON lines")
+}
+
+func tailAudit(ctx context.Context, auditFile string, tailLines int, follow bool, printLine func(string)) error {
+	lines, err := lastLines(auditFile, tailLines)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		printLine(line)
+	}
+	if !follow {
+		return nil
+	}
+	return followFile(ctx, auditFile, printLine)
+}
+
+func printAuditLine(cmd *cobra.Command, line string) {
+	var entry permission.AuditEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return
+	}
+	decision := "denied"
+	if entry.Granted {
+		decision = "granted"
+	}
+	params, _ := json.Marshal(entry.Params)
+	fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s:%s\t%s (%s)\t%s\n",
+		entry.Time.Local().Format(time.DateTime),
+		entry.SessionID,
+		entry.ToolName,
+		entry.Action,
+		decision,
+		entry.Reason,
+		params,
+	)
+}
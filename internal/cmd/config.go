@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the Tulpa configuration",
+}
+
+var configSourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "Show where each configuration setting comes from",
+	Long: `Show the resolved value of each configuration setting together with its
+source: the config file that set it, the environment variable it was read
+from, a command line flag, or the built-in default.`,
+	Example: `
+# Show all settings and their sources
+tulpa config sources
+
+# Find out why an agent uses a given model
+tulpa config sources | grep model
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		debug, _ := cmd.Flags().GetBool("debug")
+
+		cfg, err := config.Load(cwd, dataDir, debug)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+
+		sources, err := cfg.Sources()
+		if err != nil {
+			return err
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			// We're in a TTY: make it fancy.
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("Setting", "Value", "Source")
+			for _, s := range sources {
+				t.Row(s.Key, s.Value, s.Source)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+		// Not a TTY.
+		for _, s := range sources {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+		}
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configSourcesCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/app"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+)
+
+var diagnosticsCmd = &cobra.Command{
+	Use:   "diagnostics [path]",
+	Short: "Print the diagnostics reported by the LSP servers",
+	Long: `Start the configured LSP servers and print the diagnostics they report for a
+file, or for the whole project when no path is given, grouped by severity.`,
+	Example: `
+# Print the diagnostics of the project
+tulpa diagnostics
+
+# Print the diagnostics of a single file
+tulpa diagnostics internal/app/app.go
+  `,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+
+		cfg, err := config.Load(cwd, dataDir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+		if len(cfg.LSP) == 0 {
+			return fmt.Errorf("no lsp servers configured")
+		}
+
+		var path string
+		if len(args) == 1 {
+			if path, err = filepath.Abs(args[0]); err != nil {
+				return err
+			}
+		}
+
+		ctx := cmd.Context()
+		clients := app.StartLSPClients(ctx, cfg)
+		defer func() {
+			for name, client := range clients.Seq2() {
+				closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := client.Close(closeCtx); err != nil {
+					slog.Debug("Failed to close LSP client", "name", name, "error", err)
+				}
+				cancel()
+			}
+		}()
+		if clients.Len() == 0 {
+			return fmt.Errorf("no lsp server could be started")
+		}
+
+		if path == "" {
+			// The servers only just started: give them time to analyze the
+			// project.
+			for client := range clients.Seq() {
+				client.WaitForDiagnostics(ctx, 5*time.Second)
+			}
+		}
+		fmt.Fprint(cmd.OutOrStdout(), tools.Diagnostics(ctx, clients, path))
+		return nil
+	},
+}
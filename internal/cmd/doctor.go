@@ -0,0 +1,643 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/lsp"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check whether Tulpa will work well in the current environment",
+	Long: `Check whether Tulpa will work well in the current environment.
+Without flags, check that the configuration loads, that every agent file is
+valid, that at least one provider answers with its API key, that the LSP
+servers agents may use are in PATH and that the enabled MCP servers connect.
+Failed checks come with a hint to fix them, and the command fails when a
+critical one does.
+
+With --repo, report on the current working directory: git status, detected
+project types and toolchains, available agents, LSP servers that would start,
+configured MCP servers and provider authentication. The OpenAI-compatible
+endpoints of agents are contacted to check that they answer.`,
+	Example: `
+# Check the environment
+tulpa doctor
+
+# Report on the current repository
+tulpa doctor --repo
+
+# Report on another repository as JSON
+tulpa doctor --repo --json -c /path/to/project
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, _ := cmd.Flags().GetBool("repo")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		if !repo {
+			return runDoctorChecks(cmd, cwd, dataDir, asJSON)
+		}
+
+		cfg, err := config.Load(cwd, dataDir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+
+		report := buildRepoReport(cmd.Context(), cwd, cfg)
+		if asJSON {
+			bts, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal report: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(bts))
+			return nil
+		}
+		report.print(cmd.OutOrStdout())
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().Bool("repo", false, "Report on the current working directory")
+	doctorCmd.Flags().Bool("json", false, "Output the report as JSON")
+}
+
+type checkStatus string
+
+const (
+	checkPass checkStatus = "pass"
+	// A problem Tulpa works around, like an LSP server that won't start
+	checkWarn checkStatus = "warn"
+	// A problem Tulpa can't work with, failing the command
+	checkFail checkStatus = "fail"
+)
+
+type check struct {
+	Name   string      `json:"name"`
+	Status checkStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+	// How to fix a failed check
+	Hint string `json:"hint,omitempty"`
+}
+
+type checkGroup struct {
+	Title  string  `json:"title"`
+	Checks []check `json:"checks"`
+}
+
+// runDoctorChecks checks the environment, printing the checks by group, and
+// returns an error when a critical one fails.
+func runDoctorChecks(cmd *cobra.Command, cwd, dataDir string, asJSON bool) error {
+	configCheck := check{Name: "configuration and agents loaded", Status: checkPass}
+	cfg, err := config.Load(cwd, dataDir, false)
+	if err != nil {
+		configCheck = check{
+			Name:   "configuration",
+			Status: checkFail,
+			Detail: err.Error(),
+			Hint:   "fix the reported problem; tulpa schema prints the options of tulpa.json",
+		}
+	}
+	groups := []checkGroup{
+		{Title: "Configuration", Checks: []check{configCheck}},
+		{Title: "Agent files", Checks: agentFileChecks(cwd)},
+	}
+	if cfg != nil {
+		groups = append(groups,
+			checkGroup{Title: "Providers", Checks: providerChecks(cfg)},
+			checkGroup{Title: "LSP", Checks: lspChecks(cfg)},
+			checkGroup{Title: "MCP", Checks: mcpChecks(cmd, cfg)},
+		)
+	}
+
+	failed := 0
+	for _, group := range groups {
+		for _, c := range group.Checks {
+			if c.Status == checkFail {
+				failed++
+			}
+		}
+	}
+	if asJSON {
+		bts, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal checks: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(bts))
+	} else {
+		printChecks(cmd.OutOrStdout(), groups)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d critical checks failed", failed)
+	}
+	return nil
+}
+
+// agentFileChecks loads each file of the global and project agents
+// directories on its own, so that every invalid one is reported.
+func agentFileChecks(cwd string) []check {
+	var checks []check
+	for _, dir := range []struct {
+		path, label string
+		load        func(string) (*config.AgentYAMLConfig, error)
+	}{
+		{config.AgentsConfigDir(), "", config.LoadAgentConfig},
+		{config.ProjectAgentsDir(cwd), filepath.Join(".tulpa", "agents"), config.LoadProjectAgentConfig},
+	} {
+		files, err := agentConfigFiles(dir.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			checks = append(checks, check{Name: dir.path, Status: checkFail, Detail: err.Error()})
+			continue
+		}
+		for _, file := range files {
+			name := file
+			if dir.label != "" {
+				name = filepath.Join(dir.label, filepath.Base(file))
+			}
+			c := check{Name: name, Status: checkPass}
+			if _, err := dir.load(file); err != nil {
+				c.Status, c.Detail = checkFail, err.Error()
+				c.Hint = "fix the reported fields; tulpa agent schema prints the valid ones"
+			}
+			checks = append(checks, c)
+		}
+	}
+	if len(checks) == 0 {
+		checks = append(checks, check{Name: "agent files", Status: checkWarn, Detail: "none found", Hint: "run tulpa once to create the default agents"})
+	}
+	return checks
+}
+
+// providerChecks checks the API key of each enabled provider by listing its
+// models, and fails when none works.
+func providerChecks(cfg *config.Config) []check {
+	var providers []config.ProviderConfig
+	for p := range cfg.Providers.Seq() {
+		if !p.Disable {
+			providers = append(providers, p)
+		}
+	}
+	slices.SortFunc(providers, func(a, b config.ProviderConfig) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	checks := make([]check, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		checks[i] = check{Name: p.ID, Status: checkPass}
+		key, err := cfg.Resolve(p.APIKey)
+		switch {
+		case p.APIKey == "" && (p.Type == catwalk.TypeBedrock || p.Type == catwalk.TypeVertexAI):
+			checks[i].Detail = "uses environment credentials, not checked"
+		case (p.APIKey != "" && (err != nil || key == "")) || (p.APIKey == "" && p.BaseURL == ""):
+			checks[i].Status, checks[i].Detail = checkWarn, "API key not set"
+			checks[i].Hint = fmt.Sprintf("set the api_key of providers.%s in tulpa.json", p.ID)
+			if env, ok := strings.CutPrefix(p.APIKey, "$"); ok {
+				checks[i].Hint = fmt.Sprintf("set the %s environment variable", strings.Trim(env, "{}"))
+			}
+		case p.Type == catwalk.TypeOpenAI || p.Type == catwalk.TypeAnthropic || p.Type == catwalk.TypeGemini:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := p.TestConnection(cfg.Resolver()); err != nil {
+					checks[i].Status, checks[i].Detail = checkWarn, err.Error()
+					checks[i].Hint = "check the API key and base URL of the provider"
+				}
+			}()
+		default:
+			checks[i].Detail = "not checked"
+		}
+	}
+	wg.Wait()
+
+	if !slices.ContainsFunc(checks, func(c check) bool { return c.Status == checkPass }) {
+		checks = append(checks, check{
+			Name:   "a provider with a working API key",
+			Status: checkFail,
+			Hint:   "set the API key of a provider, like ANTHROPIC_API_KEY or OPENAI_API_KEY, or run tulpa to configure one",
+		})
+	}
+	return checks
+}
+
+// lspChecks checks that the command of each LSP server enabled agents may
+// use is in PATH. Agents allowing no server in particular may use them all.
+func lspChecks(cfg *config.Config) []check {
+	allowedBy := make(map[string][]string)
+	for _, id := range slices.Sorted(maps.Keys(cfg.Agents())) {
+		agent := cfg.Agents()[id]
+		if agent.Disabled {
+			continue
+		}
+		allowed := agent.AllowedLSP
+		if allowed == nil {
+			for name, l := range cfg.LSP {
+				if !l.Disabled {
+					allowed = append(allowed, name)
+				}
+			}
+		}
+		for _, name := range allowed {
+			allowedBy[name] = append(allowedBy[name], id)
+		}
+	}
+
+	var checks []check
+	for _, name := range slices.Sorted(maps.Keys(allowedBy)) {
+		c := check{Name: name, Status: checkPass}
+		l, ok := cfg.LSP[name]
+		switch {
+		case !ok:
+			c.Status, c.Detail = checkWarn, "not configured"
+			c.Hint = fmt.Sprintf("configure it under lsp in tulpa.json, or remove it from lsp.allowed of %s", strings.Join(allowedBy[name], ", "))
+		case l.Disabled:
+			c.Detail = "disabled"
+		default:
+			path, err := exec.LookPath(l.Command)
+			if err != nil {
+				c.Status, c.Detail = checkWarn, fmt.Sprintf("%s not found in PATH", l.Command)
+				c.Hint = fmt.Sprintf("install %s, or set lsp.%s.command to its path", l.Command, name)
+			} else {
+				c.Detail = path
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// mcpChecks connects to each enabled MCP server.
+func mcpChecks(cmd *cobra.Command, cfg *config.Config) []check {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(cfg.MCP)) {
+		if !cfg.MCP[name].Disabled {
+			names = append(names, name)
+		}
+	}
+
+	checks := make([]check, len(names))
+	for i, result := range probeMCPs(cmd, cfg, names) {
+		checks[i] = check{Name: names[i], Status: checkPass}
+		if result.err != nil {
+			checks[i].Status, checks[i].Detail = checkWarn, result.err.Error()
+			checks[i].Hint = fmt.Sprintf("check the command or URL of mcp.%s, or set its disabled option", names[i])
+			continue
+		}
+		checks[i].Detail = fmt.Sprintf("%d tools in %s", len(result.probe.Tools), result.probe.Latency.Round(time.Millisecond))
+	}
+	return checks
+}
+
+func printChecks(w io.Writer, groups []checkGroup) {
+	symbols := map[checkStatus]string{checkPass: "✓", checkWarn: "!", checkFail: "✗"}
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, group.Title)
+		if len(group.Checks) == 0 {
+			fmt.Fprintln(w, "  - nothing to check")
+		}
+		for _, c := range group.Checks {
+			detail, more, _ := strings.Cut(c.Detail, "\n")
+			if detail != "" {
+				fmt.Fprintf(w, "  %s %s: %s\n", symbols[c.Status], c.Name, detail)
+			} else {
+				fmt.Fprintf(w, "  %s %s\n", symbols[c.Status], c.Name)
+			}
+			for line := range strings.Lines(more) {
+				fmt.Fprintf(w, "      %s", line)
+			}
+			if more != "" && !strings.HasSuffix(more, "\n") {
+				fmt.Fprintln(w)
+			}
+			if c.Hint != "" {
+				fmt.Fprintf(w, "      → %s\n", c.Hint)
+			}
+		}
+	}
+}
+
+type repoReport struct {
+	WorkingDir string           `json:"working_dir"`
+	Git        gitReport        `json:"git"`
+	Projects   []projectReport  `json:"projects"`
+	Agents     []agentReport    `json:"agents"`
+	LSP        []lspReport      `json:"lsp"`
+	MCP        []mcpReport      `json:"mcp"`
+	Providers  []providerReport `json:"providers"`
+}
+
+type gitReport struct {
+	IsRepo  bool   `json:"is_repo"`
+	Root    string `json:"root,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+	Changes int    `json:"changes"`
+}
+
+type projectReport struct {
+	Type      string `json:"type"`
+	Marker    string `json:"marker"`
+	Toolchain string `json:"toolchain"`
+	Path      string `json:"path,omitempty"`
+}
+
+type agentReport struct {
+	ID        string `json:"id"`
+	Model     string `json:"model"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type lspReport struct {
+	Name      string `json:"name"`
+	Command   string `json:"command"`
+	WillStart bool   `json:"will_start"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type mcpReport struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Target  string `json:"target"`
+	Enabled bool   `json:"enabled"`
+}
+
+type providerReport struct {
+	ID            string `json:"id"`
+	Authenticated bool   `json:"authenticated"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// projectMarkers maps files found at the root of a project to its type and
+// the toolchain binary it needs.
+var projectMarkers = []struct {
+	marker, kind, toolchain string
+}{
+	{"go.mod", "Go", "go"},
+	{"Cargo.toml", "Rust", "cargo"},
+	{"package.json", "Node.js", "node"},
+	{"deno.json", "Deno", "deno"},
+	{"pyproject.toml", "Python", "python3"},
+	{"requirements.txt", "Python", "python3"},
+	{"Gemfile", "Ruby", "ruby"},
+	{"pom.xml", "Java", "mvn"},
+	{"build.gradle", "Java", "gradle"},
+	{"build.gradle.kts", "Kotlin", "gradle"},
+	{"composer.json", "PHP", "php"},
+	{"mix.exs", "Elixir", "mix"},
+	{"Package.swift", "Swift", "swift"},
+}
+
+func buildRepoReport(ctx context.Context, cwd string, cfg *config.Config) repoReport {
+	report := repoReport{
+		WorkingDir: cwd,
+		Git:        gitStatus(ctx, cwd),
+		Projects:   []projectReport{},
+		Agents:     []agentReport{},
+		LSP:        []lspReport{},
+		MCP:        []mcpReport{},
+		Providers:  []providerReport{},
+	}
+
+	for _, pm := range projectMarkers {
+		if _, err := os.Stat(filepath.Join(cwd, pm.marker)); err != nil {
+			continue
+		}
+		if slices.ContainsFunc(report.Projects, func(p projectReport) bool { return p.Type == pm.kind }) {
+			continue
+		}
+		path, _ := exec.LookPath(pm.toolchain)
+		report.Projects = append(report.Projects, projectReport{
+			Type:      pm.kind,
+			Marker:    pm.marker,
+			Toolchain: pm.toolchain,
+			Path:      path,
+		})
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(cfg.Agents())) {
+		agent := cfg.Agents()[id]
+		ar := agentReport{ID: id, Model: string(agent.Model), Available: true}
+		switch {
+		case agent.Disabled:
+			ar.Available, ar.Reason = false, "disabled"
+		case cfg.GetProviderForModel(agent.Model) == nil:
+			ar.Available, ar.Reason = false, fmt.Sprintf("no provider configured for the %s model", agent.Model)
+		case cfg.GetModelByType(agent.Model) == nil:
+			ar.Available, ar.Reason = false, fmt.Sprintf("%s model not found", agent.Model)
+		case agent.Endpoint != nil:
+			ar.Endpoint = agent.Endpoint.BaseURL
+			if err := probeEndpoint(ctx, cfg, *agent.Endpoint); err != nil {
+				ar.Available, ar.Reason = false, err.Error()
+			}
+		}
+		report.Agents = append(report.Agents, ar)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(cfg.LSP)) {
+		l := cfg.LSP[name]
+		lr := lspReport{Name: name, Command: l.Command, WillStart: true}
+		switch {
+		case l.Disabled:
+			lr.WillStart, lr.Reason = false, "disabled"
+		case !lsp.HasRootMarkers(cwd, l.RootMarkers):
+			lr.WillStart, lr.Reason = false, "no root markers found"
+		default:
+			if _, err := exec.LookPath(l.Command); err != nil {
+				lr.WillStart, lr.Reason = false, fmt.Sprintf("%s not found in PATH", l.Command)
+			}
+		}
+		report.LSP = append(report.LSP, lr)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(cfg.MCP)) {
+		m := cfg.MCP[name]
+		target := m.URL
+		if m.Type == config.MCPStdio {
+			target = strings.TrimSpace(m.Command + " " + strings.Join(m.Args, " "))
+		}
+		report.MCP = append(report.MCP, mcpReport{
+			Name:    name,
+			Type:    string(m.Type),
+			Target:  target,
+			Enabled: !m.Disabled,
+		})
+	}
+
+	for p := range cfg.Providers.Seq() {
+		pr := providerReport{ID: p.ID, Authenticated: true}
+		switch {
+		case p.Disable:
+			pr.Authenticated, pr.Reason = false, "disabled"
+		case p.APIKey == "" && (p.Type == catwalk.TypeBedrock || p.Type == catwalk.TypeVertexAI):
+			pr.Reason = "uses environment credentials"
+		default:
+			if key, err := cfg.Resolve(p.APIKey); err != nil || key == "" {
+				pr.Authenticated, pr.Reason = false, "API key not set"
+			}
+		}
+		report.Providers = append(report.Providers, pr)
+	}
+	slices.SortFunc(report.Providers, func(a, b providerReport) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return report
+}
+
+// endpointTimeout is how long the OpenAI-compatible endpoint of an agent
+// has to list its models.
+const endpointTimeout = 5 * time.Second
+
+// probeEndpoint checks that the endpoint answers the request listing its
+// models.
+func probeEndpoint(ctx context.Context, cfg *config.Config, e config.AgentEndpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(e.BaseURL, "/")+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if e.APIKeyEnv != "" {
+		key, err := cfg.Resolve("$" + e.APIKeyEnv)
+		if err != nil || key == "" {
+			return fmt.Errorf("%s not set", e.APIKeyEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("endpoint unreachable: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+func gitStatus(ctx context.Context, cwd string) gitReport {
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = cwd
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return gitReport{}
+	}
+	report := gitReport{IsRepo: true, Root: root}
+	report.Branch, _ = git("branch", "--show-current")
+	if status, err := git("status", "--porcelain"); err == nil && status != "" {
+		report.Changes = len(strings.Split(status, "\n"))
+	}
+	return report
+}
+
+func (r repoReport) print(w io.Writer) {
+	check := func(ok bool) string {
+		if ok {
+			return "✓"
+		}
+		return "✗"
+	}
+	line := func(ok bool, format string, args ...any) {
+		fmt.Fprintf(w, "  %s %s\n", check(ok), fmt.Sprintf(format, args...))
+	}
+	withReason := func(s, reason string) string {
+		if reason == "" {
+			return s
+		}
+		return fmt.Sprintf("%s (%s)", s, reason)
+	}
+
+	fmt.Fprintf(w, "Repository: %s\n\n", r.WorkingDir)
+
+	fmt.Fprintln(w, "Git")
+	if r.Git.IsRepo {
+		line(true, "root %s, branch %s, %d uncommitted changes", r.Git.Root, r.Git.Branch, r.Git.Changes)
+	} else {
+		line(false, "not a git repository, file walks will be limited")
+	}
+
+	fmt.Fprintln(w, "\nProject")
+	if len(r.Projects) == 0 {
+		line(false, "no known project type detected")
+	}
+	for _, p := range r.Projects {
+		if p.Path != "" {
+			line(true, "%s (%s), %s at %s", p.Type, p.Marker, p.Toolchain, p.Path)
+		} else {
+			line(false, "%s (%s), %s not found in PATH", p.Type, p.Marker, p.Toolchain)
+		}
+	}
+
+	fmt.Fprintln(w, "\nAgents")
+	if len(r.Agents) == 0 {
+		line(false, "no agents loaded")
+	}
+	for _, a := range r.Agents {
+		desc := fmt.Sprintf("%s [%s]", a.ID, a.Model)
+		if a.Endpoint != "" {
+			desc += " at " + a.Endpoint
+		}
+		line(a.Available, "%s", withReason(desc, a.Reason))
+	}
+
+	fmt.Fprintln(w, "\nLSP")
+	if len(r.LSP) == 0 {
+		line(false, "no LSP servers configured")
+	}
+	for _, l := range r.LSP {
+		line(l.WillStart, "%s", withReason(fmt.Sprintf("%s (%s)", l.Name, l.Command), l.Reason))
+	}
+
+	fmt.Fprintln(w, "\nMCP")
+	if len(r.MCP) == 0 {
+		fmt.Fprintln(w, "  - no MCP servers configured")
+	}
+	for _, m := range r.MCP {
+		reason := ""
+		if !m.Enabled {
+			reason = "disabled"
+		}
+		line(m.Enabled, "%s", withReason(fmt.Sprintf("%s [%s] %s", m.Name, m.Type, m.Target), reason))
+	}
+
+	fmt.Fprintln(w, "\nProviders")
+	if len(r.Providers) == 0 {
+		line(false, "no providers configured")
+	}
+	for _, p := range r.Providers {
+		line(p.Authenticated, "%s", withReason(p.ID, p.Reason))
+	}
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/csync"
+)
+
+// isolateDoctorConfig keeps the files loading the config writes out of the
+// user's, and the providers it loads to the embedded ones. It sets the
+// environment, so its tests may not be parallel.
+func isolateDoctorConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("TULPA_DISABLE_PROVIDER_AUTO_UPDATE", "1")
+}
+
+// initDoctorConfig loads a config without providers for a new working
+// directory. It sets the global config, so its tests may not be parallel.
+func initDoctorConfig(t *testing.T) (*config.Config, string) {
+	isolateDoctorConfig(t)
+	cwd := t.TempDir()
+	cfg, err := config.Init(cwd, t.TempDir(), false)
+	require.NoError(t, err)
+	cfg.Providers = csync.NewMap[string, config.ProviderConfig]()
+	return cfg, cwd
+}
+
+func TestPrintChecks(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	printChecks(&out, []checkGroup{
+		{Title: "Configuration", Checks: []check{{Name: "configuration and agents loaded", Status: checkPass}}},
+		{Title: "Agent files", Checks: []check{{
+			Name:   "coder.yaml",
+			Status: checkFail,
+			Detail: "invalid agent\ntools.allowed: unknown tool \"viewr\"",
+			Hint:   "fix the reported fields",
+		}}},
+		{Title: "Providers", Checks: []check{{Name: "openai", Status: checkWarn, Detail: "API key not set", Hint: "set the OPENAI_API_KEY environment variable"}}},
+		{Title: "MCP"},
+	})
+	require.Equal(t, "Configuration\n"+
+		"  ✓ configuration and agents loaded\n"+
+		"\nAgent files\n"+
+		"  ✗ coder.yaml: invalid agent\n"+
+		"      tools.allowed: unknown tool \"viewr\"\n"+
+		"      → fix the reported fields\n"+
+		"\nProviders\n"+
+		"  ! openai: API key not set\n"+
+		"      → set the OPENAI_API_KEY environment variable\n"+
+		"\nMCP\n"+
+		"  - nothing to check\n", out.String())
+}
+
+func TestAgentFileChecks(t *testing.T) {
+	globalDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", globalDir)
+	agentsDir := filepath.Join(globalDir, "tulpa", "agents")
+	cwd := t.TempDir()
+
+	require.Equal(t, []check{{Name: "agent files", Status: checkWarn, Detail: "none found", Hint: "run tulpa once to create the default agents"}}, agentFileChecks(cwd))
+
+	require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "broken.yaml"), []byte("name: [\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "coder.yaml"), []byte("name: Coder\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "notes.txt"), []byte("not an agent"), 0o644))
+	projectDir := config.ProjectAgentsDir(cwd)
+	require.NoError(t, os.MkdirAll(projectDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "reviewer.yml"), []byte("name: Reviewer\n"), 0o644))
+
+	checks := agentFileChecks(cwd)
+	require.Len(t, checks, 3)
+	require.Equal(t, filepath.Join(agentsDir, "broken.yaml"), checks[0].Name)
+	require.Equal(t, checkFail, checks[0].Status)
+	require.NotEmpty(t, checks[0].Detail)
+	require.NotEmpty(t, checks[0].Hint)
+	require.Equal(t, check{Name: filepath.Join(agentsDir, "coder.yaml"), Status: checkPass}, checks[1])
+	require.Equal(t, check{Name: filepath.Join(".tulpa", "agents", "reviewer.yml"), Status: checkPass}, checks[2])
+}
+
+func TestProviderChecks(t *testing.T) {
+	cfg, _ := initDoctorConfig(t)
+	t.Setenv("TULPA_DOCTOR_TEST_KEY", "")
+
+	cfg.Providers.Set("bedrock", config.ProviderConfig{ID: "bedrock", Type: catwalk.TypeBedrock})
+	cfg.Providers.Set("keyless", config.ProviderConfig{ID: "keyless", Type: catwalk.TypeOpenAI, APIKey: "$TULPA_DOCTOR_TEST_KEY"})
+	cfg.Providers.Set("off", config.ProviderConfig{ID: "off", Type: catwalk.TypeOpenAI, Disable: true})
+	require.Equal(t, []check{
+		{Name: "bedrock", Status: checkPass, Detail: "uses environment credentials, not checked"},
+		{Name: "keyless", Status: checkWarn, Detail: "API key not set", Hint: "set the TULPA_DOCTOR_TEST_KEY environment variable"},
+	}, providerChecks(cfg))
+
+	// Without a working provider, the check fails.
+	cfg.Providers.Del("bedrock")
+	checks := providerChecks(cfg)
+	require.Len(t, checks, 2)
+	require.Equal(t, checkWarn, checks[0].Status)
+	require.Equal(t, "a provider with a working API key", checks[1].Name)
+	require.Equal(t, checkFail, checks[1].Status)
+}
+
+func TestLSPChecks(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		LSP: config.LSPs{
+			"gopls":   {Command: os.Args[0]},
+			"pyright": {Command: "tulpa-no-such-lsp"},
+			"old":     {Command: "tulpa-no-such-lsp", Disabled: true},
+		},
+	}
+	cfg.SetAgents(map[string]config.Agent{
+		"coder":    {ID: "coder"},
+		"reviewer": {ID: "reviewer", AllowedLSP: []string{"gopls", "missing"}},
+		"off":      {ID: "off", Disabled: true, AllowedLSP: []string{"other"}},
+	}, nil)
+	require.Equal(t, []check{
+		{Name: "gopls", Status: checkPass, Detail: os.Args[0]},
+		{
+			Name:   "missing",
+			Status: checkWarn,
+			Detail: "not configured",
+			Hint:   "configure it under lsp in tulpa.json, or remove it from lsp.allowed of reviewer",
+		},
+		{
+			Name:   "pyright",
+			Status: checkWarn,
+			Detail: "tulpa-no-such-lsp not found in PATH",
+			Hint:   "install tulpa-no-such-lsp, or set lsp.pyright.command to its path",
+		},
+	}, lspChecks(cfg))
+}
+
+func TestRunDoctorChecks(t *testing.T) {
+	isolateDoctorConfig(t)
+	cwd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "tulpa.json"), []byte(`{"options": "invalid"}`), 0o644))
+
+	for _, asJSON := range []bool{false, true} {
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		cmd.SetContext(t.Context())
+
+		err := runDoctorChecks(cmd, cwd, t.TempDir(), asJSON)
+		require.EqualError(t, err, "1 critical checks failed")
+		if !asJSON {
+			require.Contains(t, out.String(), "Configuration\n  ✗ configuration: ")
+			continue
+		}
+
+		var groups []checkGroup
+		require.NoError(t, json.Unmarshal(out.Bytes(), &groups))
+		require.Len(t, groups, 2, "the checks needing the config are skipped")
+		require.Equal(t, "Configuration", groups[0].Title)
+		require.Len(t, groups[0].Checks, 1)
+		require.Equal(t, "configuration", groups[0].Checks[0].Name)
+		require.Equal(t, checkFail, groups[0].Checks[0].Status)
+		require.NotEmpty(t, groups[0].Checks[0].Hint)
+		require.Equal(t, "Agent files", groups[1].Title)
+	}
+}
+
+func TestBuildRepoReport(t *testing.T) {
+	cfg, cwd := initDoctorConfig(t)
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "go.mod"), []byte("module example.com/m\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "package.json"), []byte("{}"), 0o644))
+
+	cfg.Providers.Set("fake", config.ProviderConfig{
+		ID:     "fake",
+		Type:   catwalk.TypeOpenAI,
+		APIKey: "key",
+		Models: []catwalk.Model{{ID: "fake-model"}},
+	})
+	cfg.Providers.Set("keyless", config.ProviderConfig{ID: "keyless", Type: catwalk.TypeAnthropic})
+	cfg.Models[config.SelectedModelTypeLarge] = config.SelectedModel{Provider: "fake", Model: "fake-model"}
+	cfg.SetAgents(map[string]config.Agent{
+		"coder": {ID: "coder", Model: config.SelectedModelTypeLarge},
+		"off":   {ID: "off", Model: config.SelectedModelTypeLarge, Disabled: true},
+	}, nil)
+	cfg.LSP = config.LSPs{
+		"gopls": {Command: os.Args[0], RootMarkers: []string{"go.mod"}},
+		"rust":  {Command: os.Args[0], RootMarkers: []string{"Cargo.toml"}},
+	}
+	cfg.MCP = config.MCPs{
+		"fs":  {Type: config.MCPStdio, Command: "npx", Args: []string{"fs-server"}},
+		"web": {Type: config.MCPHttp, URL: "http://localhost:3000/mcp", Disabled: true},
+	}
+
+	report := buildRepoReport(t.Context(), cwd, cfg)
+	require.False(t, report.Git.IsRepo)
+	require.Len(t, report.Projects, 2)
+	require.Equal(t, "Go", report.Projects[0].Type)
+	require.Equal(t, "Node.js", report.Projects[1].Type)
+
+	bts, err := json.Marshal(report)
+	require.NoError(t, err)
+	command, err := json.Marshal(os.Args[0])
+	require.NoError(t, err)
+	var sections map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(bts, &sections))
+	require.JSONEq(t, `{"is_repo": false, "changes": 0}`, string(sections["git"]))
+	require.JSONEq(t, `[
+		{"id": "coder", "model": "large", "available": true},
+		{"id": "off", "model": "large", "available": false, "reason": "disabled"}
+	]`, string(sections["agents"]))
+	require.JSONEq(t, `[
+		{"name": "gopls", "command": `+string(command)+`, "will_start": true},
+		{"name": "rust", "command": `+string(command)+`, "will_start": false, "reason": "no root markers found"}
+	]`, string(sections["lsp"]))
+	require.JSONEq(t, `[
+		{"name": "fs", "type": "stdio", "target": "npx fs-server", "enabled": true},
+		{"name": "web", "type": "http", "target": "http://localhost:3000/mcp", "enabled": false}
+	]`, string(sections["mcp"]))
+	require.JSONEq(t, `[
+		{"id": "fake", "authenticated": true},
+		{"id": "keyless", "authenticated": false, "reason": "API key not set"}
+	]`, string(sections["providers"]))
+}
@@ -71,27 +71,11 @@ func init() {
 }
 
 func followLogs(ctx context.Context, logsFile string, tailLines int) error {
-	t, err := tail.TailFile(logsFile, tail.Config{
-		Follow: false,
-		ReOpen: false,
-		Logger: tail.DiscardingLogger,
-	})
+	lines, err := lastLines(logsFile, tailLines)
 	if err != nil {
-		return fmt.Errorf("failed to tail log file: %v", err)
+		return err
 	}
 
-	var lines []string
-	for line := range t.Lines {
-		if line.Err != nil {
-			continue
-		}
-		lines = append(lines, line.Text)
-		if len(lines) > tailLines {
-			lines = lines[len(lines)-tailLines:]
-		}
-	}
-	t.Stop()
-
 	for _, line := range lines {
 		printLogLine(line)
 	}
@@ -101,39 +85,35 @@ func followLogs(ctx context.Context, logsFile string, tailLines int) error {
 		fmt.Fprintf(os.Stderr, "Following new log entries...\n\n")
 	}
 
-	t, err = tail.TailFile(logsFile, tail.Config{
-		Follow:   true,
-		ReOpen:   true,
-		Logger:   tail.DiscardingLogger,
-		Location: &tail.SeekInfo{Offset: 0, Whence: io.SeekEnd},
-	})
+	return followFile(ctx, logsFile, printLogLine)
+}
+
+func showLogs(logsFile string, tailLines int) error {
+	lines, err := lastLines(logsFile, tailLines)
 	if err != nil {
-		return fmt.Errorf("failed to tail log file: %v", err)
+		return err
 	}
-	defer t.Stop()
 
-	for {
-		select {
-		case line := <-t.Lines:
-			if line.Err != nil {
-				continue
-			}
-			printLogLine(line.Text)
-		case <-ctx.Done():
-			return nil
-		}
+	for _, line := range lines {
+		printLogLine(line)
 	}
+
+	if len(lines) == tailLines {
+		fmt.Fprintf(os.Stderr, "\nShowing last %d lines. Full logs available at: %s\n", tailLines, logsFile)
+	}
+
+	return nil
 }
 
-func showLogs(logsFile string, tailLines int) error {
-	t, err := tail.TailFile(logsFile, tail.Config{
-		Follow:      false,
-		ReOpen:      false,
-		Logger:      tail.DiscardingLogger,
-		MaxLineSize: 0,
+// lastLines returns the last n lines of the file at path.
+func lastLines(path string, n int) ([]string, error) {
+	t, err := tail.TailFile(path, tail.Config{
+		Follow: false,
+		ReOpen: false,
+		Logger: tail.DiscardingLogger,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to tail log file: %v", err)
+		return nil, fmt.Errorf("failed to tail %s: %v", path, err)
 	}
 	defer t.Stop()
 
@@ -143,20 +123,38 @@ func showLogs(logsFile string, tailLines int) error {
 			continue
 		}
 		lines = append(lines, line.Text)
-		if len(lines) > tailLines {
-			lines = lines[len(lines)-tailLines:]
+		if len(lines) > n {
+			lines = lines[len(lines)-n:]
 		}
 	}
+	return lines, nil
+}
 
-	for _, line := range lines {
-		printLogLine(line)
+// followFile calls printLine with every line appended to the file at path,
+// until ctx is done.
+func followFile(ctx context.Context, path string, printLine func(string)) error {
+	t, err := tail.TailFile(path, tail.Config{
+		Follow:   true,
+		ReOpen:   true,
+		Logger:   tail.DiscardingLogger,
+		Location: &tail.SeekInfo{Offset: 0, Whence: io.SeekEnd},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tail %s: %v", path, err)
 	}
+	defer t.Stop()
 
-	if len(lines) == tailLines {
-		fmt.Fprintf(os.Stderr, "\nShowing last %d lines. Full logs available at: %s\n", tailLines, logsFile)
+	for {
+		select {
+		case line := <-t.Lines:
+			if line.Err != nil {
+				continue
+			}
+			printLine(line.Text)
+		case <-ctx.Done():
+			return nil
+		}
 	}
-
-	return nil
 }
 
 func printLogLine(lineText string) {
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/llm/agent"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Inspect the configured MCP servers",
+	Long:  `Inspect the MCP servers configured for the current project.`,
+}
+
+var mcpListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured MCP servers",
+	Long: `List the configured MCP servers with their transport, connection state and
+the tools they expose. Every enabled server is connected to in order to list
+its tools.`,
+	Example: `
+# List the MCP servers and their tools
+tulpa mcp list
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadMCPConfig(cmd)
+		if err != nil {
+			return err
+		}
+		names := slices.Sorted(maps.Keys(cfg.MCP))
+		results := probeMCPs(cmd, cfg, names)
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			// We're in a TTY: make it fancy.
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("Name", "Type", "State", "Tools")
+			for i, name := range names {
+				t.Row(name, string(cfg.MCP[name].Type), results[i].state(), strings.Join(results[i].probe.Tools, ", "))
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+		// Not a TTY.
+		for i, name := range names {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%s\n", name, cfg.MCP[name].Type, results[i].state(), strings.Join(results[i].probe.Tools, ","))
+		}
+		return nil
+	},
+}
+
+var mcpPingCmd = &cobra.Command{
+	Use:   "ping [name...]",
+	Short: "Check the connection to MCP servers",
+	Long: `Connect to the given MCP servers, or to every enabled one, and report how
+long it took to list their tools or why it failed.`,
+	Example: `
+# Ping every enabled MCP server
+tulpa mcp ping
+
+# Ping a single server
+tulpa mcp ping filesystem
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadMCPConfig(cmd)
+		if err != nil {
+			return err
+		}
+		names := args
+		if len(names) == 0 {
+			for _, name := range slices.Sorted(maps.Keys(cfg.MCP)) {
+				if !cfg.MCP[name].Disabled {
+					names = append(names, name)
+				}
+			}
+		}
+		for _, name := range names {
+			if _, ok := cfg.MCP[name]; !ok {
+				return fmt.Errorf("mcp server %q is not configured", name)
+			}
+		}
+
+		var failed int
+		for i, result := range probeMCPs(cmd, cfg, names) {
+			if result.err != nil {
+				failed++
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %v\n", names[i], result.err)
+				continue
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: ok in %s, %d tools\n", names[i], result.probe.Latency.Round(time.Millisecond), len(result.probe.Tools))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d mcp servers failed", failed, len(names))
+		}
+		return nil
+	},
+}
+
+type mcpProbeResult struct {
+	disabled bool
+	probe    agent.MCPProbe
+	err      error
+}
+
+func (r mcpProbeResult) state() string {
+	switch {
+	case r.disabled:
+		return agent.MCPStateDisabled.String()
+	case r.err != nil:
+		return fmt.Sprintf("%s: %v", agent.MCPStateError, r.err)
+	default:
+		return agent.MCPStateConnected.String()
+	}
+}
+
+// probeMCPs connects to the named servers concurrently, skipping the
+// disabled ones, and returns the results in the order of names.
+func probeMCPs(cmd *cobra.Command, cfg *config.Config, names []string) []mcpProbeResult {
+	results := make([]mcpProbeResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		m := cfg.MCP[name]
+		if m.Disabled {
+			results[i].disabled = true
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].probe, results[i].err = agent.ProbeMCP(cmd.Context(), name, m, cfg.Resolver())
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func loadMCPConfig(cmd *cobra.Command) (*config.Config, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+
+	cfg, err := config.Load(cwd, dataDir, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if len(cfg.MCP) == 0 {
+		return nil, fmt.Errorf("no mcp servers configured")
+	}
+	return cfg, nil
+}
+
+func init() {
+	mcpCmd.AddCommand(mcpListCmd, mcpPingCmd)
+}
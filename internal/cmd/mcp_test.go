@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+// serveFakeMCP serves an MCP server exposing the "echo" and "sum" tools over
+// HTTP and returns its URL.
+func serveFakeMCP(t *testing.T) string {
+	t.Helper()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "fake"}, nil)
+	for _, name := range []string{"echo", "sum"} {
+		mcp.AddTool(server, &mcp.Tool{Name: name}, func(context.Context, *mcp.CallToolRequest, struct{}) (*mcp.CallToolResult, any, error) {
+			return &mcp.CallToolResult{}, nil, nil
+		})
+	}
+	httpServer := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil))
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+// closedMCPURL returns the URL of an HTTP server which is no longer
+// listening.
+func closedMCPURL() string {
+	httpServer := httptest.NewServer(http.NotFoundHandler())
+	httpServer.Close()
+	return httpServer.URL
+}
+
+// runMCPCommand runs command in a new working directory configuring the
+// given MCP servers. It sets the environment and changes the working
+// directory, so its tests may not be parallel.
+func runMCPCommand(t *testing.T, command *cobra.Command, servers map[string]config.MCPConfig, args ...string) (string, error) {
+	t.Helper()
+
+	isolateDoctorConfig(t)
+	cwd := t.TempDir()
+	data, err := json.Marshal(map[string]any{"mcp": servers})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "tulpa.json"), data, 0o644))
+	t.Chdir(cwd)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.Flags().String("cwd", cwd, "")
+	cmd.Flags().String("data-dir", t.TempDir(), "")
+	cmd.SetOut(&out)
+	cmd.SetContext(t.Context())
+	err = command.RunE(cmd, args)
+	return out.String(), err
+}
+
+func TestMCPList(t *testing.T) {
+	out, err := runMCPCommand(t, mcpListCmd, map[string]config.MCPConfig{
+		"fake":   {Type: config.MCPHttp, URL: serveFakeMCP(t)},
+		"off":    {Type: config.MCPHttp, URL: closedMCPURL(), Disabled: true},
+		"broken": {Type: config.MCPHttp, URL: closedMCPURL()},
+	})
+	require.NoError(t, err, "listing doesn't fail when a server can't be reached")
+	require.Regexp(t, regexp.MustCompile(`\Abroken\thttp\terror: .+\t\n`+
+		`fake\thttp\tconnected\techo,sum\n`+
+		`off\thttp\tdisabled\t\n\z`), out)
+}
+
+func TestMCPPing(t *testing.T) {
+	t.Run("all servers answer", func(t *testing.T) {
+		out, err := runMCPCommand(t, mcpPingCmd, map[string]config.MCPConfig{
+			"fake":  {Type: config.MCPHttp, URL: serveFakeMCP(t)},
+			"other": {Type: config.MCPHttp, URL: serveFakeMCP(t)},
+			"off":   {Type: config.MCPHttp, URL: closedMCPURL(), Disabled: true},
+		})
+		require.NoError(t, err)
+		require.Regexp(t, regexp.MustCompile(`\Afake: ok in \d+(\.\d+)?m?s, 2 tools\n`+
+			`other: ok in \d+(\.\d+)?m?s, 2 tools\n\z`), out, "disabled servers are skipped")
+	})
+
+	t.Run("reports the servers which fail", func(t *testing.T) {
+		out, err := runMCPCommand(t, mcpPingCmd, map[string]config.MCPConfig{
+			"fake":   {Type: config.MCPHttp, URL: serveFakeMCP(t)},
+			"broken": {Type: config.MCPHttp, URL: closedMCPURL()},
+		})
+		require.EqualError(t, err, "1 of 2 mcp servers failed")
+		require.Regexp(t, regexp.MustCompile(`\Abroken: .+\n`+
+			`fake: ok in \d+(\.\d+)?m?s, 2 tools\n\z`), out)
+	})
+
+	t.Run("pings only the given servers", func(t *testing.T) {
+		out, err := runMCPCommand(t, mcpPingCmd, map[string]config.MCPConfig{
+			"fake":   {Type: config.MCPHttp, URL: serveFakeMCP(t)},
+			"broken": {Type: config.MCPHttp, URL: closedMCPURL()},
+		}, "fake")
+		require.NoError(t, err)
+		require.Regexp(t, regexp.MustCompile(`\Afake: ok in \d+(\.\d+)?m?s, 2 tools\n\z`), out)
+	})
+
+	t.Run("rejects unknown servers", func(t *testing.T) {
+		out, err := runMCPCommand(t, mcpPingCmd, map[string]config.MCPConfig{
+			"fake": {Type: config.MCPHttp, URL: serveFakeMCP(t)},
+		}, "fake", "missing")
+		require.EqualError(t, err, `mcp server "missing" is not configured`)
+		require.Empty(t, out, "nothing is pinged")
+	})
+
+	t.Run("requires configured servers", func(t *testing.T) {
+		_, err := runMCPCommand(t, mcpPingCmd, nil)
+		require.EqualError(t, err, "no mcp servers configured")
+	})
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/checkpoint"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old sessions",
+	Long: `Delete the sessions kept past the limits of options.retention, with their
+messages and file history, then vacuum the database. The flags override the
+configured limits. Starred sessions and sessions with pinned messages are
+never deleted.
+
+The limits also apply when Tulpa starts.`,
+	Example: `
+# Show what the configured limits would delete
+tulpa prune --dry-run
+
+# Keep the 100 most recent sessions
+tulpa prune --max-sessions 100
+
+# Delete the sessions not updated for 30 days
+tulpa prune --max-age 30
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		cfg, conn, err := openSessionDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		var retention session.Retention
+		retention.MaxSessions, retention.MaxAge, retention.MaxSize = cfg.Options.RetentionLimits()
+		if cmd.Flags().Changed("max-sessions") {
+			retention.MaxSessions, _ = cmd.Flags().GetInt("max-sessions")
+		}
+		if cmd.Flags().Changed("max-age") {
+			days, _ := cmd.Flags().GetInt("max-age")
+			retention.MaxAge = time.Duration(days) * 24 * time.Hour
+		}
+		if cmd.Flags().Changed("max-size") {
+			mib, _ := cmd.Flags().GetInt("max-size")
+			retention.MaxSize = int64(mib) * 1024 * 1024
+		}
+
+		result, err := session.Prune(cmd.Context(), conn, retention, dryRun)
+		if err != nil {
+			return err
+		}
+		if err := checkpoint.Remove(cmd.Context(), cfg.WorkingDir(), result.DeletedIDs...); err != nil {
+			slog.Error("Failed to remove the checkpoints of pruned sessions", "error", err)
+		}
+		printPrunedSessions(cmd, result.Sessions)
+
+		switch {
+		case len(result.Sessions) == 0:
+			fmt.Fprintf(cmd.OutOrStdout(), "No session to delete, the database takes %s\n", formatMiB(result.SizeBefore))
+		case dryRun:
+			fmt.Fprintf(cmd.OutOrStdout(), "Would delete %d sessions, the database takes %s\n", len(result.Sessions), formatMiB(result.SizeBefore))
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted %d sessions, the database went from %s to %s\n", len(result.Sessions), formatMiB(result.SizeBefore), formatMiB(result.SizeAfter))
+		}
+		return nil
+	},
+}
+
+func printPrunedSessions(cmd *cobra.Command, sessions []session.PrunedSession) {
+	if len(sessions) == 0 {
+		return
+	}
+	if term.IsTerminal(os.Stdout.Fd()) {
+		// We're in a TTY: make it fancy.
+		t := table.New().
+			Border(lipgloss.RoundedBorder()).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				return lipgloss.NewStyle().Padding(0, 2)
+			}).
+			Headers("ID", "Title", "Updated", "Reason")
+		for _, s := range sessions {
+			t.Row(s.ID, s.Title, unixTime(s.UpdatedAt).Format(time.DateTime), s.Reason)
+		}
+		lipgloss.Println(t)
+		return
+	}
+	// Not a TTY.
+	for _, s := range sessions {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%s\n", s.ID, s.Title, unixTime(s.UpdatedAt).Format(time.RFC3339), s.Reason)
+	}
+}
+
+func formatMiB(bytes int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1024*1024))
+}
+
+func init() {
+	pruneCmd.Flags().Bool("dry-run", false, "List the sessions that would be deleted without deleting them")
+	pruneCmd.Flags().Int("max-sessions", 0, "Most sessions kept (0 keeps all)")
+	pruneCmd.Flags().Int("max-age", 0, "Delete the sessions not updated for this many days (0 keeps all)")
+	pruneCmd.Flags().Int("max-size", 0, "Delete the oldest sessions until the database takes less than this many MiB (0 disables)")
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/backup"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Restore a file from its backups",
+	Long: `Restore a file from the backups made by the write and edit tools.
+Backups are only made when tools.backup.enabled is set in the configuration.
+The current content of the file is backed up before it is replaced.`,
+	Example: `
+# Restore the most recent backup of a file
+tulpa restore main.go
+
+# List the available backups
+tulpa restore main.go --list
+
+# Restore the second most recent backup
+tulpa restore main.go -n 2
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		list, _ := cmd.Flags().GetBool("list")
+		number, _ := cmd.Flags().GetInt("number")
+
+		cfg, err := config.Load(cwd, dataDir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+
+		path := args[0]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+
+		backups, err := backup.List(cfg.Options.DataDirectory, path)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf("no backups found for %s", path)
+		}
+
+		if list {
+			printBackups(cmd, backups)
+			return nil
+		}
+
+		if number < 1 || number > len(backups) {
+			return fmt.Errorf("backup number must be between 1 and %d", len(backups))
+		}
+		b := backups[number-1]
+		if err := backup.Restore(cfg.Options.DataDirectory, b, cfg.Tools.Backup.Limit()); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Restored %s from backup of %s\n", path, b.CreatedAt.Format(time.DateTime))
+		return nil
+	},
+}
+
+func printBackups(cmd *cobra.Command, backups []backup.Backup) {
+	if term.IsTerminal(os.Stdout.Fd()) {
+		// We're in a TTY: make it fancy.
+		t := table.New().
+			Border(lipgloss.RoundedBorder()).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				return lipgloss.NewStyle().Padding(0, 2)
+			}).
+			Headers("#", "Created", "Backup")
+		for i, b := range backups {
+			t.Row(strconv.Itoa(i+1), b.CreatedAt.Format(time.DateTime), b.File)
+		}
+		lipgloss.Println(t)
+		return
+	}
+	// Not a TTY.
+	for i, b := range backups {
+		fmt.Fprintf(cmd.OutOrStdout(), "%d\t%s\t%s\n", i+1, b.CreatedAt.Format(time.RFC3339), b.File)
+	}
+}
+
+func init() {
+	restoreCmd.Flags().BoolP("list", "l", false, "List the available backups instead of restoring")
+	restoreCmd.Flags().IntP("number", "n", 1, "Backup to restore, 1 being the most recent")
+}
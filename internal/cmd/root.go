@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io"
@@ -19,17 +21,23 @@ import (
 	"github.com/charmbracelet/x/term"
 	"github.com/spf13/cobra"
 	"github.com/tulpa-code/tulpa/internal/app"
+	"github.com/tulpa-code/tulpa/internal/checkpoint"
 	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/db"
 	"github.com/tulpa-code/tulpa/internal/event"
+	"github.com/tulpa-code/tulpa/internal/home"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/session"
 	"github.com/tulpa-code/tulpa/internal/tui"
 	"github.com/tulpa-code/tulpa/internal/version"
 )
 
 func init() {
-	rootCmd.PersistentFlags().StringP("cwd", "c", "", "Current working directory")
+	rootCmd.PersistentFlags().StringP("cwd", "c", "", "Directory to work in instead of the current one")
 	rootCmd.PersistentFlags().StringP("data-dir", "D", "", "Custom tulpa data directory")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	rootCmd.PersistentFlags().String("record", "", "Record provider requests and responses to a file (or set TULPA_RECORD)")
+	rootCmd.PersistentFlags().String("replay", "", "Answer provider requests from a recording instead of calling the provider (or set TULPA_REPLAY)")
 
 	rootCmd.Flags().BoolP("help", "h", false, "Help")
 	rootCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
@@ -40,6 +48,16 @@ func init() {
 		updateProvidersCmd,
 		logsCmd,
 		schemaCmd,
+		configCmd,
+		restoreCmd,
+		doctorCmd,
+		agentCmd,
+		sessionCmd,
+		auditCmd,
+		mcpCmd,
+		diagnosticsCmd,
+		searchCmd,
+		pruneCmd,
 	)
 }
 
@@ -142,10 +160,19 @@ func Execute() {
 		fang.WithVersion(version.Version),
 		fang.WithNotifySignal(os.Interrupt),
 	); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
+// exitCode returns the status Tulpa exits with after failing with err: 130,
+// like shells, when a run was interrupted.
+func exitCode(err error) int {
+	if errors.Is(err, app.ErrInterrupted) {
+		return 130
+	}
+	return 1
+}
+
 // setupApp handles the common setup logic for both interactive and non-interactive modes.
 // It returns the app instance, config, cleanup function, and any error.
 func setupApp(cmd *cobra.Command) (*app.App, error) {
@@ -178,10 +205,17 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	if err != nil {
 		return nil, err
 	}
+	pruneSessions(ctx, conn, cfg)
 
+	// The agents of the app create their providers, which are recorded from
+	// then on. Shutting the app down stops recording.
+	if err := setupRecording(cmd); err != nil {
+		return nil, err
+	}
 	appInstance, err := app.New(ctx, conn, cfg)
 	if err != nil {
 		slog.Error("Failed to create app instance", "error", err)
+		_ = provider.StopRecording()
 		return nil, err
 	}
 
@@ -192,6 +226,27 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	return appInstance, nil
 }
 
+// setupRecording starts recording or replaying provider requests when asked
+// to by the record and replay flags or the TULPA_RECORD and TULPA_REPLAY
+// environment variables.
+func setupRecording(cmd *cobra.Command) error {
+	record, _ := cmd.Flags().GetString("record")
+	replay, _ := cmd.Flags().GetString("replay")
+	record = cmp.Or(record, os.Getenv("TULPA_RECORD"))
+	replay = cmp.Or(replay, os.Getenv("TULPA_REPLAY"))
+	switch {
+	case record != "" && replay != "":
+		return fmt.Errorf("cannot record and replay at the same time")
+	case record != "":
+		slog.Info("Recording provider requests", "path", record)
+		return provider.StartRecording(record)
+	case replay != "":
+		slog.Info("Replaying provider requests", "path", replay)
+		return provider.StartReplay(replay)
+	}
+	return nil
+}
+
 func shouldEnableMetrics() bool {
 	if v, _ := strconv.ParseBool(os.Getenv("TULPA_DISABLE_METRICS")); v {
 		return false
@@ -226,8 +281,18 @@ func MaybePrependStdin(prompt string) (string, error) {
 func ResolveCwd(cmd *cobra.Command) (string, error) {
 	cwd, _ := cmd.Flags().GetString("cwd")
 	if cwd != "" {
-		err := os.Chdir(cwd)
+		// Resolve the directory before changing to it so relative paths
+		// don't end up resolved twice.
+		cwd, err := filepath.Abs(home.Long(cwd))
 		if err != nil {
+			return "", fmt.Errorf("failed to resolve working directory: %v", err)
+		}
+		if info, err := os.Stat(cwd); err != nil {
+			return "", fmt.Errorf("failed to change directory: %v", err)
+		} else if !info.IsDir() {
+			return "", fmt.Errorf("failed to change directory: %s is not a directory", cwd)
+		}
+		if err := os.Chdir(cwd); err != nil {
 			return "", fmt.Errorf("failed to change directory: %v", err)
 		}
 		return cwd, nil
@@ -253,3 +318,24 @@ func createDotTulpaDir(dir string) error {
 
 	return nil
 }
+
+// pruneSessions deletes the sessions kept past the configured retention
+// limits. Failing to is only logged.
+func pruneSessions(ctx context.Context, conn *sql.DB, cfg *config.Config) {
+	var retention session.Retention
+	retention.MaxSessions, retention.MaxAge, retention.MaxSize = cfg.Options.RetentionLimits()
+	if !retention.Enabled() {
+		return
+	}
+	result, err := session.Prune(ctx, conn, retention, false)
+	if err != nil {
+		slog.Error("Failed to prune sessions", "error", err)
+		return
+	}
+	if len(result.Sessions) > 0 {
+		slog.Info("Pruned sessions", "sessions", len(result.Sessions), "size_before", result.SizeBefore, "size_after", result.SizeAfter)
+	}
+	if err := checkpoint.Remove(ctx, cfg.WorkingDir(), result.DeletedIDs...); err != nil {
+		slog.Error("Failed to remove the checkpoints of pruned sessions", "error", err)
+	}
+}
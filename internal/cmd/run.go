@@ -2,17 +2,30 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
 	"strings"
 
+	"github.com/charmbracelet/x/term"
 	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/app"
+	"github.com/tulpa-code/tulpa/internal/format"
+	"github.com/tulpa-code/tulpa/internal/message"
 )
 
 var runCmd = &cobra.Command{
 	Use:   "run [prompt...]",
 	Short: "Run a single non-interactive prompt",
 	Long: `Run a single prompt in non-interactive mode and exit.
-The prompt can be provided as arguments or piped from stdin.`,
+The prompt can be provided as arguments, read from a file with --file, or
+read from stdin with "-". Input piped to stdin is otherwise prepended to the
+prompt. The text files of the workspace mentioned in the arguments as @path
+or as a glob like @internal/**/*.go are sent with the prompt.
+
+Pressing Ctrl+C once stops the agent, prints the partial response and exits
+with status 130. Pressing it again exits immediately.`,
 	Example: `
 # Run a simple prompt
 tulpa run Explain the use of context in Go
@@ -20,11 +33,138 @@ tulpa run Explain the use of context in Go
 # Pipe input from stdin
 echo "What is this code doing?" | tulpa run
 
+# Read a multi-line prompt from a file
+tulpa run -f prompt.txt
+
+# Read the prompt from a heredoc
+tulpa run - <<'EOF'
+Rename the Config type to Settings.
+Keep the JSON field names unchanged.
+EOF
+
 # Run with quiet mode (no spinner)
 tulpa run -q "Generate a README for this project"
+
+# Show another label next to the spinner
+tulpa run --spinner-label "Reviewing" "Review the latest commit"
+
+# Run several prompts in one session
+printf 'Summarize main.go\n---\nList its exported functions\n' | tulpa run --batch
+
+# Save the response to a file while still showing it
+tulpa run --output-file review.md --tee "Review the latest commit"
+
+# Stream newline-delimited JSON events for scripts
+tulpa run --output json "List the TODOs in this project" | jq -r 'select(.type == "result") | .content'
+
+# Send the files of a package with the prompt
+tulpa run "Explain how @internal/app/*.go starts the agents"
+
+# Send a screenshot and a log file with the prompt
+tulpa run --attach screenshot.png --attach build.log "Why does the build fail?"
+
+# Set environment variables for the bash commands of the run only
+tulpa run --env GOFLAGS=-race --env 'TOKEN=$CI_TOKEN' "Run the tests and report the failures"
+
+# Forbid tools for the run, whatever the agents allow
+tulpa run --disable-tool bash --disable-tool fetch "Review the error handling of main.go"
+
+# Print the prompt, messages and tools the task agent would send
+tulpa run --dry-run --agent task "Find the config loader"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		spinnerLabel, _ := cmd.Flags().GetString("spinner-label")
+		output, _ := cmd.Flags().GetString("output")
+		batch, _ := cmd.Flags().GetBool("batch")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+		tee, _ := cmd.Flags().GetBool("tee")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		agentID, _ := cmd.Flags().GetString("agent")
+		attachPaths, _ := cmd.Flags().GetStringArray("attach")
+		promptFile, _ := cmd.Flags().GetString("file")
+		envPairs, _ := cmd.Flags().GetStringArray("env")
+		disabledTools, _ := cmd.Flags().GetStringSlice("disable-tool")
+		// A lone "-" reads the prompt from stdin.
+		fromStdin := len(args) == 1 && args[0] == "-"
+		if fromStdin {
+			args = nil
+		}
+		if output != app.OutputText && output != app.OutputJSON {
+			return fmt.Errorf("invalid output format %q, expected %s or %s", output, app.OutputText, app.OutputJSON)
+		}
+		if tee && outputFile == "" {
+			return fmt.Errorf("--tee requires --output-file")
+		}
+		if dryRun && batch {
+			return fmt.Errorf("--dry-run does not support --batch")
+		}
+		if cmd.Flags().Changed("agent") && !dryRun {
+			return fmt.Errorf("--agent requires --dry-run")
+		}
+		if dryRun && len(attachPaths) > 0 {
+			return fmt.Errorf("--dry-run does not support --attach")
+		}
+		if slices.Contains(args, "-") {
+			return fmt.Errorf(`"-" reads the prompt from stdin and cannot be combined with a prompt`)
+		}
+		if promptFile != "" && (len(args) > 0 || fromStdin) {
+			return fmt.Errorf("--file cannot be combined with a prompt")
+		}
+		opts := app.NonInteractiveOptions{Quiet: quiet, SpinnerLabel: spinnerLabel, Output: output, DisabledTools: disabledTools}
+		for _, pair := range envPairs {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok || name == "" {
+				return fmt.Errorf("invalid --env %q, expected NAME=VALUE", pair)
+			}
+			if opts.Env == nil {
+				opts.Env = make(map[string]string, len(envPairs))
+			}
+			opts.Env[name] = value
+		}
+		for _, path := range attachPaths {
+			attachment, err := message.ReadAttachment(path, message.MaxAttachmentSize)
+			if err != nil {
+				return err
+			}
+			opts.Attachments = append(opts.Attachments, attachment)
+		}
+
+		var prompts []string
+		if batch {
+			if len(args) > 0 {
+				return fmt.Errorf("--batch reads prompts from stdin or --file, not from arguments")
+			}
+			var bts []byte
+			var err error
+			if promptFile != "" {
+				bts, err = os.ReadFile(promptFile)
+				if err != nil {
+					return fmt.Errorf("failed to read prompts: %w", err)
+				}
+			} else {
+				if term.IsTerminal(os.Stdin.Fd()) {
+					return fmt.Errorf("--batch needs prompts on stdin or in --file")
+				}
+				bts, err = io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read prompts from stdin: %w", err)
+				}
+			}
+			prompts = splitBatchPrompts(string(bts))
+			if len(prompts) == 0 {
+				return fmt.Errorf("no prompt provided")
+			}
+		}
+
+		if outputFile != "" {
+			f, out, err := openOutputFile(outputFile, tee, os.Stdout)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			opts.Out = out
+		}
 
 		app, err := setupApp(cmd)
 		if err != nil {
@@ -35,24 +175,139 @@ tulpa run -q "Generate a README for this project"
 		if !app.Config().IsConfigured() {
 			return fmt.Errorf("no providers configured - please run 'tulpa' to set up a provider interactively")
 		}
-
-		prompt := strings.Join(args, " ")
-
-		prompt, err = MaybePrependStdin(prompt)
-		if err != nil {
-			slog.Error("Failed to read from stdin", "error", err)
-			return err
+		if err := app.Config().CheckToolNames(disabledTools); err != nil {
+			return fmt.Errorf("invalid --disable-tool: %w", err)
 		}
 
-		if prompt == "" {
-			return fmt.Errorf("no prompt provided")
+		if !batch {
+			prompt := strings.Join(args, " ")
+			switch {
+			case fromStdin:
+				prompt, err = readPrompt("-", os.Stdin)
+				if err != nil {
+					return err
+				}
+			case promptFile != "":
+				prompt, err = readPrompt(promptFile, os.Stdin)
+				if err != nil {
+					return err
+				}
+				prompt, err = MaybePrependStdin(prompt)
+				if err != nil {
+					slog.Error("Failed to read from stdin", "error", err)
+					return err
+				}
+			default:
+				// Only the mentions typed as arguments are expanded.
+				prompt = app.ExpandTypedMentions(prompt)
+				prompt, err = MaybePrependStdin(prompt)
+				if err != nil {
+					slog.Error("Failed to read from stdin", "error", err)
+					return err
+				}
+			}
+
+			if dryRun {
+				return app.DryRun(cmd.Context(), agentID, prompt, opts)
+			}
+			if prompt == "" {
+				return fmt.Errorf("no prompt provided")
+			}
+			prompts = []string{prompt}
 		}
 
 		// Run non-interactive flow using the App method
-		return app.RunNonInteractive(cmd.Context(), prompt, quiet)
+		return app.RunNonInteractive(cmd.Context(), prompts, opts)
 	},
 }
 
+// splitBatchPrompts splits batch input into prompts. When the input has
+// lines consisting of "---", those separate the prompts and each prompt may
+// span several lines; otherwise every line is a prompt.
+func splitBatchPrompts(input string) []string {
+	lines := strings.Split(strings.ReplaceAll(input, "\r\n", "\n"), "\n")
+
+	var prompts []string
+	if !slices.ContainsFunc(lines, isBatchSeparator) {
+		for _, line := range lines {
+			if line = strings.TrimSpace(line); line != "" {
+				prompts = append(prompts, line)
+			}
+		}
+		return prompts
+	}
+
+	var current []string
+	flush := func() {
+		if prompt := strings.TrimSpace(strings.Join(current, "\n")); prompt != "" {
+			prompts = append(prompts, prompt)
+		}
+		current = nil
+	}
+	for _, line := range lines {
+		if isBatchSeparator(line) {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return prompts
+}
+
+// readPrompt reads the prompt from the file at path, or from stdin when path
+// is "-", without the newline ending it.
+func readPrompt(path string, stdin io.Reader) (string, error) {
+	if path == "-" {
+		bts, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt from stdin: %w", err)
+		}
+		return trimTrailingNewline(string(bts)), nil
+	}
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt: %w", err)
+	}
+	return trimTrailingNewline(string(bts)), nil
+}
+
+// openOutputFile creates the file the response is written to, replacing it,
+// and returns the writer of the response: the file, and stdout too with tee.
+func openOutputFile(path string, tee bool, stdout io.Writer) (*os.File, io.Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	if tee {
+		return f, io.MultiWriter(stdout, f), nil
+	}
+	return f, f, nil
+}
+
+// trimTrailingNewline removes the newline editors put at the end of files.
+func trimTrailingNewline(s string) string {
+	if s, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(s, "\r")
+	}
+	return s
+}
+
+func isBatchSeparator(line string) bool {
+	return strings.TrimSpace(line) == "---"
+}
+
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().String("spinner-label", format.DefaultSpinnerLabel, "Label shown next to the spinner")
+	runCmd.Flags().StringP("output", "o", app.OutputText, "Output format: text or json (newline-delimited events)")
+	runCmd.Flags().String("output-file", "", "Write the response to this file instead of stdout")
+	runCmd.Flags().Bool("tee", false, "With --output-file, write the response to stdout as well")
+	runCmd.Flags().Bool("batch", false, "Read several prompts from stdin, one per line or separated by --- lines, and run them in one session")
+	runCmd.Flags().Bool("dry-run", false, "Print the system prompt, messages and tools that would be sent, without calling the model")
+	runCmd.Flags().String("agent", "", "With --dry-run, the agent whose request is printed (default: the default agent)")
+	runCmd.Flags().StringP("file", "f", "", "Read the prompt from this file, or the prompts with --batch")
+	runCmd.Flags().StringArray("attach", nil, "Send a text or image file with the first prompt, can be repeated (max 5MB each)")
+	runCmd.Flags().StringSlice("disable-tool", nil, "Forbid a tool in the run and its subagents, whatever the agents allow, can be repeated")
+	runCmd.Flags().StringArray("env", nil, "Set an environment variable for the bash commands of the run as NAME=VALUE, can be repeated; $NAME in VALUE reads the variable of this environment")
 }
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/app"
+)
+
+func TestReadPrompt(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	multiline := "Rename the Config type to Settings.\nKeep the JSON field names unchanged.\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prompt.txt"), []byte(multiline), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crlf.txt"), []byte("Explain main.go\r\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blank.txt"), []byte("Explain main.go\n\n"), 0o644))
+
+	tests := []struct {
+		name  string
+		path  string
+		stdin string
+		want  string
+	}{
+		{name: "file", path: filepath.Join(dir, "prompt.txt"), want: strings.TrimSuffix(multiline, "\n")},
+		{name: "windows file", path: filepath.Join(dir, "crlf.txt"), want: "Explain main.go"},
+		{name: "only the last newline", path: filepath.Join(dir, "blank.txt"), want: "Explain main.go\n"},
+		{name: "file ignores stdin", path: filepath.Join(dir, "crlf.txt"), stdin: "other", want: "Explain main.go"},
+		{name: "stdin", path: "-", stdin: multiline, want: strings.TrimSuffix(multiline, "\n")},
+		{name: "stdin without newline", path: "-", stdin: "Explain main.go", want: "Explain main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			prompt, err := readPrompt(tt.path, strings.NewReader(tt.stdin))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, prompt)
+		})
+	}
+
+	_, err := readPrompt(filepath.Join(dir, "missing.txt"), strings.NewReader(""))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = readPrompt("-", io.MultiReader(strings.NewReader("Explain"), iotest.ErrReader(errors.New("broken pipe"))))
+	require.EqualError(t, err, "failed to read prompt from stdin: broken pipe")
+}
+
+func TestSplitBatchPrompts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "one per line", input: "Summarize main.go\n\n  List its exported functions  \n", want: []string{"Summarize main.go", "List its exported functions"}},
+		{name: "windows lines", input: "one\r\ntwo\r\n", want: []string{"one", "two"}},
+		{
+			name:  "separated",
+			input: "Rename Config.\nKeep the JSON names.\n---\nRun the tests\n --- \n",
+			want:  []string{"Rename Config.\nKeep the JSON names.", "Run the tests"},
+		},
+		{name: "empty prompts dropped", input: "---\none\n---\n\n---\ntwo", want: []string{"one", "two"}},
+		{name: "empty", input: "\n \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, splitBatchPrompts(tt.input))
+		})
+	}
+}
+
+func TestOpenOutputFile(t *testing.T) {
+	t.Parallel()
+
+	for _, tee := range []bool{false, true} {
+		t.Run(fmt.Sprintf("tee=%t", tee), func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "review.md")
+			require.NoError(t, os.WriteFile(path, []byte("an older and longer review"), 0o644))
+
+			var stdout strings.Builder
+			f, out, err := openOutputFile(path, tee, &stdout)
+			require.NoError(t, err)
+			_, err = io.WriteString(out, "LGTM\n")
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, "LGTM\n", string(content), "the file is replaced")
+			if tee {
+				require.Equal(t, "LGTM\n", stdout.String())
+			} else {
+				require.Empty(t, stdout.String())
+			}
+		})
+	}
+
+	_, _, err := openOutputFile(filepath.Join(t.TempDir(), "missing", "review.md"), false, io.Discard)
+	require.ErrorContains(t, err, "failed to create output file")
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 130, exitCode(app.ErrInterrupted))
+	require.Equal(t, 130, exitCode(fmt.Errorf("prompt 2 of 3: %w", app.ErrInterrupted)))
+	require.Equal(t, 1, exitCode(errors.New("agent processing failed")))
+}
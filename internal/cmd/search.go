@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/exp/charmtone"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the messages of all sessions",
+	Long: `Search the text, tool calls and tool results of the messages of the current
+project, ignoring case. Hits are listed newest first with the text around the
+match.`,
+	Example: `
+# Find where the retry policy was discussed
+tulpa search "retry policy"
+
+# Only search what you wrote last week
+tulpa search config --role user --since 2025-06-01 --until 2025-06-08
+
+# Search one session and print the hits as JSON
+tulpa search panic --session 3f2a9c1e-... --json
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessionID, _ := cmd.Flags().GetString("session")
+		role, _ := cmd.Flags().GetString("role")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		untilFlag, _ := cmd.Flags().GetString("until")
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		switch message.MessageRole(role) {
+		case "", message.User, message.Assistant, message.Tool:
+		default:
+			return fmt.Errorf("invalid role %q: must be user, assistant or tool", role)
+		}
+		since, err := parseSearchTime(sinceFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		until, err := parseSearchTime(untilFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+
+		sessions, messages, closeDB, err := openSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		hits, err := messages.Search(cmd.Context(), args[0], message.SearchOpts{
+			SessionID: sessionID,
+			Role:      message.MessageRole(role),
+			Since:     since,
+			Until:     until,
+			Limit:     limit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to search messages: %w", err)
+		}
+
+		if asJSON {
+			data, err := json.MarshalIndent(hits, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		}
+		if len(hits) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No messages found")
+			return nil
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			// We're in a TTY: make it fancy.
+			titles := make(map[string]string)
+			match := lipgloss.NewStyle().Foreground(charmtone.Zest).Bold(true)
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("Session", "Role", "Created", "Match")
+			for _, hit := range hits {
+				title, ok := titles[hit.SessionID]
+				if !ok {
+					title = hit.SessionID
+					if s, err := sessions.Get(cmd.Context(), hit.SessionID); err == nil {
+						title = s.Title
+					}
+					titles[hit.SessionID] = title
+				}
+				snippet := hit.Snippet[:hit.MatchStart] + match.Render(hit.Snippet[hit.MatchStart:hit.MatchEnd]) + hit.Snippet[hit.MatchEnd:]
+				t.Row(title, string(hit.Role), unixTime(hit.CreatedAt).Format(time.DateTime), snippet)
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+		// Not a TTY.
+		for _, hit := range hits {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%s\t%s\n", hit.SessionID, hit.MessageID, hit.Role, unixTime(hit.CreatedAt).Format(time.RFC3339), hit.Snippet)
+		}
+		return nil
+	},
+}
+
+// parseSearchTime parses a date or a date and time in the local time zone,
+// or an RFC 3339 timestamp. An empty value is the zero time.
+func parseSearchTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.DateTime} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (2006-01-02), date and time (2006-01-02 15:04:05) or RFC 3339 timestamp", value)
+	}
+	return t, nil
+}
+
+func init() {
+	searchCmd.Flags().String("session", "", "Only search the session with this ID")
+	searchCmd.Flags().String("role", "", "Only search messages with this role: user, assistant or tool")
+	searchCmd.Flags().String("since", "", "Only search messages created at or after this date")
+	searchCmd.Flags().String("until", "", "Only search messages created before this date")
+	searchCmd.Flags().Int("limit", message.DefaultSearchLimit, "Maximum number of hits")
+	searchCmd.Flags().Bool("json", false, "Print the hits as JSON")
+}
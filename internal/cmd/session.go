@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/lipgloss/v2/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/db"
+	"github.com/tulpa-code/tulpa/internal/export"
+	"github.com/tulpa-code/tulpa/internal/history"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Manage sessions",
+	Long: `List the sessions of the current project, export their conversations and
+revert the files they changed.`,
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	Example: `
+# List the sessions of the current project
+tulpa session list
+
+# List them as JSON
+tulpa session list --json
+
+# List the starred sessions
+tulpa session list --starred
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		starred, _ := cmd.Flags().GetBool("starred")
+
+		sessions, _, closeDB, err := openSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		list, err := sessions.List(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if starred {
+			list = slices.DeleteFunc(list, func(s session.Session) bool { return !s.Starred })
+		}
+
+		if asJSON {
+			out := make([]exportedSession, 0, len(list))
+			for _, s := range list {
+				out = append(out, newExportedSession(s))
+			}
+			data, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		}
+
+		if term.IsTerminal(os.Stdout.Fd()) {
+			// We're in a TTY: make it fancy.
+			t := table.New().
+				Border(lipgloss.RoundedBorder()).
+				StyleFunc(func(row, col int) lipgloss.Style {
+					return lipgloss.NewStyle().Padding(0, 2)
+				}).
+				Headers("ID", "Title", "Created", "Messages")
+			for _, s := range list {
+				title := s.Title
+				if s.Starred {
+					title = "★ " + title
+				}
+				t.Row(s.ID, title, unixTime(s.CreatedAt).Format(time.DateTime), strconv.FormatInt(s.MessageCount, 10))
+			}
+			lipgloss.Println(t)
+			return nil
+		}
+		// Not a TTY.
+		for _, s := range list {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%d\n", s.ID, s.Title, unixTime(s.CreatedAt).Format(time.RFC3339), s.MessageCount)
+		}
+		return nil
+	},
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export <id>",
+	Short: "Export the messages of a session",
+	Long: `Export the full message history of a session, including tool calls, their
+results and the diffs of the files they changed, as Markdown, a self-contained
+HTML page or JSON.`,
+	Example: `
+# Print a session as Markdown
+tulpa session export 3f2a9c1e-...
+
+# Write it to a file as JSON
+tulpa session export 3f2a9c1e-... --output json --output-file session.json
+
+# Share it as a web page
+tulpa session export 3f2a9c1e-... --format html --output-file session.html
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+		if format != "markdown" && format != "html" && format != "json" {
+			return fmt.Errorf("invalid output format %q: must be markdown, html or json", format)
+		}
+
+		sessions, messages, closeDB, err := openSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		sess, err := sessions.Get(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("session %s not found: %w", args[0], err)
+		}
+		msgs, err := messages.List(cmd.Context(), sess.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		var out io.Writer = cmd.OutOrStdout()
+		if outputFile != "" {
+			f, err := os.Create(outputFile)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		switch format {
+		case "json":
+			return writeSessionJSON(out, sess, msgs)
+		case "html":
+			return export.HTML(out, sess, msgs)
+		default:
+			return export.Markdown(out, sess, msgs)
+		}
+	},
+}
+
+var sessionRevertCmd = &cobra.Command{
+	Use:   "revert <id> [path...]",
+	Short: "Revert the files changed in a session",
+	Long: `Restore the files changed by the tools of a session and of the subagents it
+started to what they were before the session first changed them. Files created
+in the session are removed and files deleted since are written back. Pass paths
+to only revert those files.
+
+Files changed since the session last changed them are not reverted, unless
+--force is given, as their later changes would be lost.`,
+	Example: `
+# Revert everything a session changed
+tulpa session revert 3f2a9c1e-...
+
+# Only revert one file
+tulpa session revert 3f2a9c1e-... internal/app/app.go
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		_, conn, err := openSessionDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		q := db.New(conn)
+		files := history.NewService(q, conn)
+		force, _ := cmd.Flags().GetBool("force")
+
+		sess, err := session.NewService(q).Get(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("session %s not found: %w", args[0], err)
+		}
+
+		var reverted []history.File
+		if len(args) == 1 {
+			reverted, err = files.Revert(cmd.Context(), sess.ID, force)
+		} else {
+			for _, path := range args[1:] {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(cwd, path)
+				}
+				var file history.File
+				if file, err = files.RevertFile(cmd.Context(), sess.ID, path, force); err != nil {
+					break
+				}
+				reverted = append(reverted, file)
+			}
+		}
+		for _, file := range reverted {
+			if file.IsNew {
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", file.Path)
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "Restored %s\n", file.Path)
+			}
+		}
+		if errors.Is(err, history.ErrFileChanged) {
+			return fmt.Errorf("%w, pass --force to revert them anyway", err)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "The session didn't change any file")
+		}
+		return nil
+	},
+}
+
+// openSessionServices opens the database of the current project. The
+// returned function closes it.
+func openSessionServices(cmd *cobra.Command) (session.Service, message.Service, func() error, error) {
+	_, conn, err := openSessionDB(cmd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	q := db.New(conn)
+	return session.NewService(q), message.NewService(q, nil), conn.Close, nil
+}
+
+// openSessionDB loads the configuration of the current project and opens
+// its database.
+func openSessionDB(cmd *cobra.Command) (*config.Config, *sql.DB, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+
+	cfg, err := config.Load(cwd, dataDir, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if _, err := os.Stat(cfg.Options.DataDirectory); errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("no sessions found: %s does not exist", cfg.Options.DataDirectory)
+	}
+	conn, err := db.Connect(cmd.Context(), cfg.Options.DataDirectory)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, conn, nil
+}
+
+type exportedSession struct {
+	ID               string    `json:"id"`
+	Title            string    `json:"title"`
+	MessageCount     int64     `json:"message_count"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	Starred          bool      `json:"starred"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func newExportedSession(s session.Session) exportedSession {
+	return exportedSession{
+		ID:               s.ID,
+		Title:            s.Title,
+		MessageCount:     s.MessageCount,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		Cost:             s.Cost,
+		Starred:          s.Starred,
+		CreatedAt:        unixTime(s.CreatedAt),
+		UpdatedAt:        unixTime(s.UpdatedAt),
+	}
+}
+
+type exportedMessage struct {
+	ID           string               `json:"id"`
+	Role         message.MessageRole  `json:"role"`
+	Model        string               `json:"model,omitempty"`
+	Provider     string               `json:"provider,omitempty"`
+	Content      string               `json:"content,omitempty"`
+	Reasoning    string               `json:"reasoning,omitempty"`
+	ToolCalls    []message.ToolCall   `json:"tool_calls,omitempty"`
+	ToolResults  []message.ToolResult `json:"tool_results,omitempty"`
+	FinishReason message.FinishReason `json:"finish_reason,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
+
+func writeSessionJSON(w io.Writer, sess session.Session, msgs []message.Message) error {
+	export := struct {
+		Session  exportedSession   `json:"session"`
+		Messages []exportedMessage `json:"messages"`
+	}{
+		Session:  newExportedSession(sess),
+		Messages: make([]exportedMessage, 0, len(msgs)),
+	}
+	for _, msg := range msgs {
+		export.Messages = append(export.Messages, exportedMessage{
+			ID:           msg.ID,
+			Role:         msg.Role,
+			Model:        msg.Model,
+			Provider:     msg.Provider,
+			Content:      msg.Content().Text,
+			Reasoning:    msg.ReasoningContent().Thinking,
+			ToolCalls:    msg.ToolCalls(),
+			ToolResults:  msg.ToolResults(),
+			FinishReason: msg.FinishReason(),
+			CreatedAt:    unixTime(msg.CreatedAt),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+func unixTime(seconds int64) time.Time {
+	return time.Unix(seconds, 0)
+}
+
+func init() {
+	sessionListCmd.Flags().Bool("json", false, "Print the sessions as JSON")
+	sessionListCmd.Flags().Bool("starred", false, "Only list the starred sessions")
+	sessionExportCmd.Flags().StringP("output", "o", "markdown", "Output format: markdown, html or json")
+	// --format reads better than --output next to --output-file.
+	sessionExportCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "format" {
+			name = "output"
+		}
+		return pflag.NormalizedName(name)
+	})
+	sessionExportCmd.Flags().String("output-file", "", "Write the export to this file instead of stdout")
+	sessionRevertCmd.Flags().Bool("force", false, "Revert files changed since the session last changed them")
+	sessionCmd.AddCommand(sessionListCmd, sessionExportCmd, sessionRevertCmd)
+}
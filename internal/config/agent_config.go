@@ -1,60 +1,257 @@
 package config
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type AgentYAMLConfig struct {
-	Name         string           `yaml:"name"`
-	Description  string           `yaml:"description"`
-	Prompt       string           `yaml:"prompt"`
-	Model        AgentModelConfig `yaml:"model"`
-	Tools        AgentToolsConfig `yaml:"tools,omitempty"`
-	MCP          AgentMCPConfig   `yaml:"mcp,omitempty"`
-	LSP          AgentLSPConfig   `yaml:"lsp,omitempty"`
-	ContextPaths []string         `yaml:"context_paths,omitempty"`
-	Disabled     bool             `yaml:"disabled,omitempty"`
+	ID                string               `yaml:"id,omitempty" jsonschema:"description=ID of the agent; lowercase letters and digits separated by dashes (default the lowercased name with spaces replaced by dashes),pattern=^[a-z0-9]+(-[a-z0-9]+)*$,example=reviewer"`
+	Name              string               `yaml:"name" jsonschema:"description=Name of the agent; its ID unless id is set is the lowercased name with spaces replaced by dashes,example=Code Reviewer"`
+	Description       string               `yaml:"description" jsonschema:"description=What the agent does"`
+	Prompt            string               `yaml:"prompt" jsonschema:"description=System prompt of the agent; may use Go template fields such as {{.WorkingDir}}"`
+	PromptFile        string               `yaml:"prompt_file,omitempty" jsonschema:"description=File to read the system prompt from instead of prompt; relative paths are resolved from the agent file,example=prompts/reviewer.md"`
+	Model             AgentModelConfig     `yaml:"model" jsonschema:"description=Model used by the agent"`
+	Tools             AgentToolsConfig     `yaml:"tools,omitempty" jsonschema:"description=Tools available to the agent"`
+	MCP               AgentMCPConfig       `yaml:"mcp,omitempty" jsonschema:"description=MCP servers available to the agent"`
+	LSP               AgentLSPConfig       `yaml:"lsp,omitempty" jsonschema:"description=LSP servers available to the agent"`
+	Subagents         AgentSubagentsConfig `yaml:"subagents,omitempty" jsonschema:"description=Agents this agent may delegate tasks to with the agent tool"`
+	ContextPaths      []string             `yaml:"context_paths,omitempty" jsonschema:"description=Files added to the context of the agent,example=TULPA.md"`
+	ContextPathsMode  string               `yaml:"context_paths_mode,omitempty" jsonschema:"description=Whether context_paths replace the global context paths or are added to them,enum=replace,enum=merge,default=replace"`
+	Disabled          bool                 `yaml:"disabled,omitempty" jsonschema:"description=Whether the agent is disabled,default=false"`
+	InactivityTimeout int                  `yaml:"inactivity_timeout,omitempty" jsonschema:"description=Cancel a run after this many seconds without activity; overrides options.inactivity_timeout,example=120"`
+	MaxToolIterations int                  `yaml:"max_tool_iterations,omitempty" jsonschema:"description=How many times the agent may call tools in a turn before it must answer; overrides options.max_tool_iterations and a negative value removes the limit,example=25"`
+	ResponseFormat    string               `yaml:"response_format,omitempty" jsonschema:"description=Format of the final response,enum=text,enum=json,default=text"`
+	BashEnv           map[string]string    `yaml:"bash_env,omitempty" jsonschema:"description=Environment variables set for the commands of the agent's bash tool; overrides options.bash_env per variable"`
+	Extends           string               `yaml:"extends,omitempty" jsonschema:"description=ID of an agent this one inherits its settings from,example=coder"`
+	PromptMode        string               `yaml:"prompt_mode,omitempty" jsonschema:"description=Whether the prompt replaces the prompt of the extended agent or is appended to it,enum=replace,enum=append,default=replace"`
 }
 
+// Modes of combining the context paths of an agent with the global ones.
+const (
+	ContextPathsModeReplace = "replace"
+	ContextPathsModeMerge   = "merge"
+)
+
 type AgentModelConfig struct {
-	Type     string `yaml:"type,omitempty"`
-	Provider string `yaml:"provider,omitempty"`
-	Model    string `yaml:"model,omitempty"`
+	Type      string               `yaml:"type,omitempty" jsonschema:"description=Model tier to use: large or small or a tier defined in the models config,default=large,example=fast"`
+	Provider  string               `yaml:"provider,omitempty" jsonschema:"description=Provider of the model; overrides the provider of the model tier,example=anthropic"`
+	Model     string               `yaml:"model,omitempty" jsonschema:"description=Model ID; overrides the model of the model tier,example=claude-sonnet-4-20250514"`
+	Fallback  []AgentFallbackModel `yaml:"fallback,omitempty" jsonschema:"description=Models tried in order when the previous one fails with an authentication or availability error"`
+	BaseURL   string               `yaml:"base_url,omitempty" jsonschema:"description=Base URL of an OpenAI-compatible API serving model; like a local Ollama or vLLM server. Requires model and replaces type,format=uri,example=http://localhost:11434/v1"`
+	APIKeyEnv string               `yaml:"api_key_env,omitempty" jsonschema:"description=Environment variable holding the API key of base_url; local servers usually need none,pattern=^[A-Za-z_][A-Za-z0-9_]*$,example=VLLM_API_KEY"`
+}
+
+// validateEndpoint checks the settings of the OpenAI-compatible API serving
+// the model, if any.
+func (m AgentModelConfig) validateEndpoint() error {
+	if m.BaseURL == "" {
+		if m.APIKeyEnv != "" {
+			return errors.New("model.api_key_env requires model.base_url")
+		}
+		return nil
+	}
+	u, err := url.Parse(m.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("model.base_url %q is not an http or https URL", m.BaseURL)
+	}
+	if m.Model == "" {
+		return errors.New("model.model is required with model.base_url")
+	}
+	return nil
+}
+
+// endpointProviderID returns the ID of the provider registered for an
+// OpenAI-compatible API when the agent names none, from the host of its URL.
+func endpointProviderID(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	return strings.NewReplacer(".", "-", ":", "-").Replace(strings.ToLower(u.Host))
+}
+
+// AgentFallbackModel is a model an agent falls back to, given either as a
+// model tier or as a provider and model pair.
+type AgentFallbackModel struct {
+	Type     string `yaml:"type,omitempty" jsonschema:"description=Model tier to fall back to,example=small"`
+	Provider string `yaml:"provider,omitempty" jsonschema:"description=Provider of the model to fall back to; used with model instead of type,example=openai"`
+	Model    string `yaml:"model,omitempty" jsonschema:"description=Model ID to fall back to; used with provider instead of type,example=gpt-4o"`
+}
+
+// tier returns the model tier of the fallback. Provider and model pairs are
+// named provider/model and registered as tiers when the agents are loaded.
+func (f AgentFallbackModel) tier() SelectedModelType {
+	if f.Type != "" {
+		return SelectedModelType(f.Type)
+	}
+	return SelectedModelType(f.Provider + "/" + f.Model)
 }
 
 type AgentToolsConfig struct {
-	Allowed  []string `yaml:"allowed,omitempty"`
-	Disabled []string `yaml:"disabled,omitempty"`
+	Allowed  []string         `yaml:"allowed,omitempty" jsonschema:"description=Tools the agent may use; names or glob patterns (default all tools)"`
+	Disabled []string         `yaml:"disabled,omitempty" jsonschema:"description=Tools removed from the allowed ones; names or glob patterns"`
+	Bash     BashCommandRules `yaml:"bash,omitempty" jsonschema:"description=Commands the agent may run with the bash tool"`
+}
+
+// BashCommandRules restricts the commands an agent runs with the bash tool.
+// Entries are command prefixes matched word by word, so go test matches
+// go test ./... but not go testify, or regular expressions between slashes
+// matched against the whole command, like /^make( |$)/.
+type BashCommandRules struct {
+	Allowed []string `yaml:"allowed,omitempty" json:"allowed,omitempty" jsonschema:"description=Commands the agent may run; any other command is refused. Command prefixes or regular expressions between slashes,example=go test,example=/^make( |$)/"`
+	Denied  []string `yaml:"denied,omitempty" json:"denied,omitempty" jsonschema:"description=Commands the agent may never run, even when allowed. Command prefixes or regular expressions between slashes,example=git push"`
+}
+
+// CommandRegex returns the regular expression of a bash command rule entry
+// written between slashes.
+func CommandRegex(entry string) (string, bool) {
+	if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+		return entry[1 : len(entry)-1], true
+	}
+	return "", false
+}
+
+func (r BashCommandRules) validate() error {
+	for _, entry := range slices.Concat(r.Allowed, r.Denied) {
+		if pattern, ok := CommandRegex(entry); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("tools.bash: invalid regular expression %s: %w", entry, err)
+			}
+		}
+	}
+	return nil
 }
 
 type AgentMCPConfig struct {
-	Allowed map[string][]string `yaml:"allowed,omitempty"`
+	Allowed map[string][]string `yaml:"allowed,omitempty" jsonschema:"description=MCP servers the agent may use mapped to their allowed tools; an empty list allows all tools of the server"`
 }
 
 type AgentLSPConfig struct {
-	Allowed []string `yaml:"allowed,omitempty"`
+	Allowed []string `yaml:"allowed,omitempty" jsonschema:"description=LSP servers the agent may use,example=gopls"`
 }
 
-// LoadAgentConfig loads an agent configuration from a YAML file.
+type AgentSubagentsConfig struct {
+	Allowed []string `yaml:"allowed,omitempty" jsonschema:"description=IDs of the agents the agent tool may start (default task when the agent tool is allowed),example=task"`
+	Default string   `yaml:"default,omitempty" jsonschema:"description=ID of the agent started when the agent tool names none (default the first allowed one),example=task"`
+}
+
+// LoadAgentConfig loads an agent configuration from a YAML file, validates it
+// against [AgentConfigSchema], reads the prompt from prompt_file if set and
+// expands ${VAR} references in the prompt and model fields.
 func LoadAgentConfig(path string) (*AgentYAMLConfig, error) {
+	return loadAgentConfig(path, true)
+}
+
+// LoadProjectAgentConfig loads an agent configuration shipped with a project
+// like [LoadAgentConfig], without trusting it: its ${VAR} references are left
+// as written, so it can't read the environment of the user, and its
+// prompt_file must be in the directory of the config.
+func LoadProjectAgentConfig(path string) (*AgentYAMLConfig, error) {
+	return loadAgentConfig(path, false)
+}
+
+func loadAgentConfig(path string, trusted bool) (*AgentYAMLConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read agent config: %w", err)
 	}
 
+	if err := ValidateAgentConfig(data); err != nil {
+		return nil, err
+	}
+
 	var config AgentYAMLConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse agent config: %w", err)
 	}
 
+	if config.PromptFile != "" {
+		if config.Prompt != "" {
+			return nil, fmt.Errorf("agent config %s sets both prompt and prompt_file", path)
+		}
+		prompt, err := readPromptFile(path, config.PromptFile, trusted)
+		if err != nil {
+			return nil, err
+		}
+		config.Prompt = prompt
+	}
+
+	for i, fallback := range config.Model.Fallback {
+		if (fallback.Type == "") == (fallback.Provider == "" || fallback.Model == "") {
+			return nil, fmt.Errorf("agent config %s: model.fallback[%d] must set either type or both provider and model", path, i)
+		}
+	}
+
+	if trusted {
+		if err := expandAgentEnv(&config, os.LookupEnv); err != nil {
+			return nil, fmt.Errorf("failed to expand agent config %s: %w", path, err)
+		}
+	}
+
+	if err := config.Model.validateEndpoint(); err != nil {
+		return nil, fmt.Errorf("agent config %s: %w", path, err)
+	}
+
+	if err := config.Tools.Bash.validate(); err != nil {
+		return nil, fmt.Errorf("agent config %s: %w", path, err)
+	}
+
+	if err := validatePromptTemplate(path, config.Prompt); err != nil {
+		return nil, fmt.Errorf("invalid prompt template in %s: %w", path, err)
+	}
+
 	return &config, nil
 }
 
+// readPromptFile reads the prompt file of the agent config at path,
+// resolving relative paths from its directory. Untrusted configs may only
+// read the files in their directory, symbolic links included.
+func readPromptFile(path, promptFile string, trusted bool) (string, error) {
+	dir := filepath.Dir(path)
+	if !trusted {
+		if !filepath.IsLocal(promptFile) {
+			return "", fmt.Errorf("agent config %s: prompt_file %s must be in the directory of the agent config", path, promptFile)
+		}
+		f, err := os.OpenInRoot(dir, promptFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt file %s: %w", filepath.Join(dir, promptFile), err)
+		}
+		defer f.Close()
+		prompt, err := io.ReadAll(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt file %s: %w", filepath.Join(dir, promptFile), err)
+		}
+		return string(prompt), nil
+	}
+
+	promptPath := promptFile
+	if !filepath.IsAbs(promptPath) {
+		promptPath = filepath.Join(dir, promptPath)
+	}
+	if abs, err := filepath.Abs(promptPath); err == nil {
+		promptPath = abs
+	}
+	prompt, err := os.ReadFile(promptPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file %s: %w", promptPath, err)
+	}
+	return string(prompt), nil
+}
+
 // SaveAgentConfig saves an agent configuration to a YAML file.
 func SaveAgentConfig(path string, config *AgentYAMLConfig) error {
 	data, err := yaml.Marshal(config)
@@ -77,13 +274,29 @@ func (a *AgentYAMLConfig) GenerateID() string {
 	return strings.ToLower(strings.ReplaceAll(a.Name, " ", "-"))
 }
 
+// AgentID returns the ID of the agent: the id field when set, or else the ID
+// generated from its name.
+func (a *AgentYAMLConfig) AgentID() string {
+	if a.ID != "" {
+		return a.ID
+	}
+	return a.GenerateID()
+}
+
 func (a *AgentYAMLConfig) ToAgent() Agent {
 	agent := Agent{
-		ID:           a.GenerateID(),
+		ID:           a.AgentID(),
 		Name:         a.Name,
 		Description:  a.Description,
 		Disabled:     a.Disabled,
 		ContextPaths: a.ContextPaths,
+
+		ContextPathsMode:  a.ContextPathsMode,
+		BashCommands:      a.Tools.Bash,
+		InactivityTimeout: a.InactivityTimeout,
+		MaxToolIterations: a.MaxToolIterations,
+		ResponseFormat:    a.ResponseFormat,
+		BashEnv:           a.BashEnv,
 	}
 
 	// Set model type - default to large if not specified
@@ -93,9 +306,23 @@ func (a *AgentYAMLConfig) ToAgent() Agent {
 		agent.Model = SelectedModelTypeLarge
 	}
 
-	// Set allowed tools
-	if len(a.Tools.Allowed) > 0 {
-		agent.AllowedTools = a.Tools.Allowed
+	if a.Model.BaseURL != "" {
+		agent.Endpoint = &AgentEndpoint{
+			Provider:  cmp.Or(a.Model.Provider, endpointProviderID(a.Model.BaseURL)),
+			BaseURL:   a.Model.BaseURL,
+			APIKeyEnv: a.Model.APIKeyEnv,
+			Model:     a.Model.Model,
+		}
+		agent.Model = agent.Endpoint.tier()
+	}
+
+	for _, fallback := range a.Model.Fallback {
+		agent.FallbackModels = append(agent.FallbackModels, fallback.tier())
+	}
+
+	// Set allowed tools, expanding patterns and removing disabled ones
+	if len(a.Tools.Allowed) > 0 || len(a.Tools.Disabled) > 0 {
+		agent.AllowedTools = resolveAgentTools(a.Name, a.Tools.Allowed, a.Tools.Disabled)
 	}
 
 	// Set MCP configuration
@@ -108,6 +335,12 @@ func (a *AgentYAMLConfig) ToAgent() Agent {
 		agent.AllowedLSP = a.LSP.Allowed
 	}
 
+	agent.Subagents = a.Subagents.Allowed
+	agent.DefaultSubagent = a.Subagents.Default
+	if agent.DefaultSubagent != "" && !slices.Contains(agent.Subagents, agent.DefaultSubagent) {
+		agent.Subagents = append(agent.Subagents, agent.DefaultSubagent)
+	}
+
 	return agent
 }
 
@@ -125,10 +358,64 @@ func AgentsConfigDir() string {
 	return filepath.Join(homeDir, ".config", appName, "agents")
 }
 
-func LoadAgentsFromDirectory() (map[string]Agent, map[string]string, error) {
-	agentsDir := AgentsConfigDir()
+// ProjectAgentsDir returns the directory of the agent configs shipped with
+// the project in workingDir.
+func ProjectAgentsDir(workingDir string) string {
+	return filepath.Join(workingDir, defaultDataDirectory, "agents")
+}
 
-	// Create directory if it doesn't exist
+// ErrAgentExists is returned when creating an agent whose ID is taken.
+var ErrAgentExists = errors.New("already exists")
+
+// NewAgentConfigPath returns the path of the file of a new agent with the
+// given ID in [AgentsConfigDir]. It fails when a file of the directory has the
+// name of that file or holds an agent with that ID, unless force is set: the
+// file is then returned, to be replaced.
+func NewAgentConfigPath(id string, force bool) (string, error) {
+	dir := AgentsConfigDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to read agents directory %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if !isAgentConfigEntry(entry) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if entry.Name() != id+".yaml" {
+			config, err := LoadAgentConfig(path)
+			if err != nil || config.AgentID() != id {
+				continue
+			}
+		}
+		if !force {
+			return "", fmt.Errorf("agent %q %w in %s", id, ErrAgentExists, path)
+		}
+		return path, nil
+	}
+	return filepath.Join(dir, id+".yaml"), nil
+}
+
+// Where an agent config was loaded from.
+const (
+	AgentSourceGlobal  = "global"
+	AgentSourceProject = "project"
+)
+
+// LoadAgentsFromDirectory loads the agent configs of [AgentsConfigDir] and,
+// unless workingDir is empty, the ones of [ProjectAgentsDir] on top of them,
+// strictly when TULPA_STRICT_CONFIG is set.
+func LoadAgentsFromDirectory(workingDir string) (map[string]Agent, map[string]string, error) {
+	return loadAgentsFromDirectory(workingDir, AgentModelConfig{Type: string(SelectedModelTypeLarge)}, strictConfigEnv())
+}
+
+// loadAgentsFromDirectory loads the agent configs, creating the defaults with
+// the given model settings when the directory has no YAML files yet. The
+// configs of the project in workingDir replace the global ones with the same
+// ID. When strict, the problems that are otherwise logged, like tool patterns
+// matching no tool, fail it too.
+func loadAgentsFromDirectory(workingDir string, defaultModel AgentModelConfig, strict bool) (map[string]Agent, map[string]string, error) {
+	agentsDir := AgentsConfigDir()
 
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(agentsDir, 0o755); err != nil {
@@ -141,19 +428,9 @@ func LoadAgentsFromDirectory() (map[string]Agent, map[string]string, error) {
 		return nil, nil, fmt.Errorf("failed to read agents directory %s: %w", agentsDir, err)
 	}
 
-	// Count YAML files
-
-	// Count YAML files
-	yamlFiles := []string{}
-	for _, entry := range entries {
-		if !entry.IsDir() && (filepath.Ext(entry.Name()) == ".yaml" || filepath.Ext(entry.Name()) == ".yml") {
-			yamlFiles = append(yamlFiles, entry.Name())
-		}
-	}
-
 	// If no YAML files exist, create defaults (unless in test mode)
-	if len(yamlFiles) == 0 && os.Getenv("TULPA_SKIP_DEFAULT_AGENTS") == "" {
-		if err := createDefaultAgentConfigs(agentsDir); err != nil {
+	if !slices.ContainsFunc(entries, isAgentConfigEntry) && os.Getenv("TULPA_SKIP_DEFAULT_AGENTS") == "" {
+		if err := createDefaultAgentConfigs(agentsDir, defaultModel); err != nil {
 			return nil, nil, fmt.Errorf("failed to create default agent configs in %s: %w", agentsDir, err)
 		}
 		// Re-read directory
@@ -165,53 +442,151 @@ func LoadAgentsFromDirectory() (map[string]Agent, map[string]string, error) {
 
 	agents := make(map[string]Agent)
 	prompts := make(map[string]string)
-	var loadErrors []string
+	set := newAgentConfigSet(strict)
+	set.add(agentsDir, "", AgentSourceGlobal, entries)
+
+	dirs := []string{agentsDir}
+	if workingDir != "" {
+		projectDir := ProjectAgentsDir(workingDir)
+		entries, err := os.ReadDir(projectDir)
+		switch {
+		case err == nil:
+			dirs = append(dirs, projectDir)
+			set.add(projectDir, filepath.Join(defaultDataDirectory, "agents")+string(filepath.Separator), AgentSourceProject, entries)
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, nil, fmt.Errorf("failed to read project agents directory %s: %w", projectDir, err)
+		}
+	}
+	loadErrors := set.errors
+
+	resolved, inheritErrs := resolveInheritance(set.configs)
+	for _, agentID := range slices.Sorted(maps.Keys(inheritErrs)) {
+		loadErrors = append(loadErrors, fmt.Sprintf("  - %s: %v", set.labels[agentID], inheritErrs[agentID]))
+	}
+	for agentID, config := range resolved {
+		agent := config.ToAgent()
+		agent.ConfigPath = set.paths[agentID]
+		agent.Source = set.sources[agentID]
+		agents[agentID] = agent
+		prompts[agentID] = config.Prompt
+	}
+
+	// If we found YAML files but couldn't load any, return detailed error
+	if len(loadErrors) > 0 && len(agents) == 0 {
+		return nil, nil, fmt.Errorf("failed to load agent configurations from %s:\n%s\n\nPlease fix the YAML syntax errors and restart Tulpa.",
+			strings.Join(dirs, " and "),
+			formatErrorList(loadErrors))
+	}
+
+	// If we loaded some but not all, return partial error
+	if len(loadErrors) > 0 {
+		return nil, nil, fmt.Errorf("some agent configurations failed to load from %s:\n%s\n\nPlease fix the YAML syntax errors and restart Tulpa.",
+			strings.Join(dirs, " and "),
+			formatErrorList(loadErrors))
+	}
+
+	return agents, prompts, nil
+}
+
+func isAgentConfigEntry(entry os.DirEntry) bool {
+	return !entry.IsDir() && isAgentConfigFile(entry.Name())
+}
+
+// agentConfigSet collects the agent configs of the directories they are
+// loaded from, by ID, before their inheritance is resolved.
+type agentConfigSet struct {
+	strict  bool
+	configs map[string]*AgentYAMLConfig
+	paths   map[string]string
+	sources map[string]string
+	// How errors name the file of each config
+	labels map[string]string
+	errors []string
+}
 
-	// Load all YAML files
+func newAgentConfigSet(strict bool) *agentConfigSet {
+	return &agentConfigSet{
+		strict:  strict,
+		configs: make(map[string]*AgentYAMLConfig),
+		paths:   make(map[string]string),
+		sources: make(map[string]string),
+		labels:  make(map[string]string),
+	}
+}
+
+// add loads the YAML configs among the entries of dir. They replace the
+// configs with the same ID loaded from previous directories, but not the
+// ones of dir itself. Errors name the files with prefix before their name.
+func (s *agentConfigSet) add(dir, prefix, source string, entries []os.DirEntry) {
+	added := make(map[string]string)
 	for _, entry := range entries {
-		if entry.IsDir() {
+		if !isAgentConfigEntry(entry) {
 			continue
 		}
 
-		ext := filepath.Ext(entry.Name())
-		if ext != ".yaml" && ext != ".yml" {
-			continue
+		label := prefix + entry.Name()
+		path := filepath.Join(dir, entry.Name())
+		load := LoadAgentConfig
+		if source == AgentSourceProject {
+			load = LoadProjectAgentConfig
 		}
-
-		path := filepath.Join(agentsDir, entry.Name())
-		config, err := LoadAgentConfig(path)
+		config, err := load(path)
 		if err != nil {
 			// Collect detailed error information
-			loadErrors = append(loadErrors, fmt.Sprintf("  - %s: %v", entry.Name(), err))
+			s.errors = append(s.errors, fmt.Sprintf("  - %s: %v", label, err))
 			continue
 		}
 
 		if config.Name == "" {
-			fmt.Printf("DEBUG: Config name is empty for %s\n", entry.Name())
-			loadErrors = append(loadErrors, fmt.Sprintf("  - %s: missing required field 'name'", entry.Name()))
+			slog.Debug("Agent config has no name", "path", path)
+			s.errors = append(s.errors, fmt.Sprintf("  - %s: missing required field 'name'", label))
 			continue
 		}
 
-		agentID := config.GenerateID()
-		agents[agentID] = config.ToAgent()
-		prompts[agentID] = config.Prompt
-	}
+		if source == AgentSourceProject {
+			for _, problem := range config.untrustedSettings() {
+				s.errors = append(s.errors, fmt.Sprintf("  - %s: %s", label, problem))
+			}
+		}
 
-	// If we found YAML files but couldn't load any, return detailed error
-	if len(loadErrors) > 0 && len(agents) == 0 {
-		return nil, nil, fmt.Errorf("failed to load agent configurations from %s:\n%s\n\nPlease fix the YAML syntax errors and restart Tulpa.",
-			agentsDir,
-			formatErrorList(loadErrors))
-	}
+		if s.strict {
+			for _, problem := range toolProblems(config.Tools.Allowed, config.Tools.Disabled) {
+				s.errors = append(s.errors, fmt.Sprintf("  - %s: %s", label, problem))
+			}
+		}
 
-	// If we loaded some but not all, return partial error
-	if len(loadErrors) > 0 {
-		return nil, nil, fmt.Errorf("some agent configurations failed to load from %s:\n%s\n\nPlease fix the YAML syntax errors and restart Tulpa.",
-			agentsDir,
-			formatErrorList(loadErrors))
+		agentID := config.AgentID()
+		if other, ok := added[agentID]; ok {
+			s.errors = append(s.errors, fmt.Sprintf("  - %s: agent ID %q is already used by %s", label, agentID, other))
+			continue
+		}
+		if other, ok := s.paths[agentID]; ok {
+			slog.Debug("Agent config overrides another one with the same ID", "id", agentID, "path", path, "overridden", other)
+		}
+		added[agentID] = entry.Name()
+		s.configs[agentID] = config
+		s.paths[agentID] = path
+		s.sources[agentID] = source
+		s.labels[agentID] = label
 	}
+}
 
-	return agents, prompts, nil
+// untrustedSettings returns the problems of the settings a project agent may
+// not set: anyone can ship them with a repository, so they may not send the
+// API keys of the user to another server nor change the environment of the
+// commands it runs. They belong in the global agents.
+func (a *AgentYAMLConfig) untrustedSettings() []string {
+	var problems []string
+	if a.Model.BaseURL != "" {
+		problems = append(problems, "model.base_url is not allowed in project agents, set it in a global agent")
+	}
+	if a.Model.APIKeyEnv != "" {
+		problems = append(problems, "model.api_key_env is not allowed in project agents, set it in a global agent")
+	}
+	if len(a.BashEnv) > 0 {
+		problems = append(problems, "bash_env is not allowed in project agents, set it in a global agent")
+	}
+	return problems
 }
 
 func formatErrorList(errors []string) string {
@@ -222,15 +597,13 @@ func formatErrorList(errors []string) string {
 	return result
 }
 
-func createDefaultAgentConfigs(agentsDir string) error {
+func createDefaultAgentConfigs(agentsDir string, model AgentModelConfig) error {
 	defaults := []AgentYAMLConfig{
 		{
 			Name:        "Coder",
 			Description: "An agent that helps with executing coding tasks.",
 			Prompt:      getDefaultCoderPrompt(),
-			Model: AgentModelConfig{
-				Type: "large",
-			},
+			Model:       model,
 			Tools: AgentToolsConfig{
 				Allowed: allToolNames(),
 			},
@@ -240,9 +613,7 @@ func createDefaultAgentConfigs(agentsDir string) error {
 			Name:        "Task",
 			Description: "An agent that helps with searching for context and finding implementation details.",
 			Prompt:      getDefaultTaskPrompt(),
-			Model: AgentModelConfig{
-				Type: "large",
-			},
+			Model:       model,
 			Tools: AgentToolsConfig{
 				Allowed: []string{"glob", "grep", "ls", "sourcegraph", "view"},
 			},
@@ -257,7 +628,7 @@ func createDefaultAgentConfigs(agentsDir string) error {
 	}
 
 	for _, config := range defaults {
-		agentID := config.GenerateID()
+		agentID := config.AgentID()
 		path := filepath.Join(agentsDir, fmt.Sprintf("%s.yaml", agentID))
 		if err := SaveAgentConfig(path, &config); err != nil {
 			return err
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expandAgentEnv expands the environment variable references in the agent
+// config fields that support them: prompt, the base URL of the model and the
+// provider and model of the model and its fallbacks.
+func expandAgentEnv(config *AgentYAMLConfig, lookup func(string) (string, bool)) error {
+	type field struct {
+		name  string
+		value *string
+	}
+	fields := []field{
+		{"prompt", &config.Prompt},
+		{"model.provider", &config.Model.Provider},
+		{"model.model", &config.Model.Model},
+		{"model.base_url", &config.Model.BaseURL},
+	}
+	for i := range config.Model.Fallback {
+		fallback := &config.Model.Fallback[i]
+		fields = append(fields,
+			field{fmt.Sprintf("model.fallback[%d].provider", i), &fallback.Provider},
+			field{fmt.Sprintf("model.fallback[%d].model", i), &fallback.Model},
+		)
+	}
+	for _, f := range fields {
+		expanded, err := expandEnv(*f.value, lookup)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.value = expanded
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references in s using lookup.
+// Defaults may contain references themselves, $$ yields a literal $ and any
+// other $ is left as is. Referencing an unset variable without a default is
+// an error.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := closingBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference %q", s[i:])
+			}
+			value, err := expandVariable(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func expandVariable(ref string, lookup func(string) (string, bool)) (string, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	if !isEnvName(name) {
+		return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+	}
+	if value, ok := lookup(name); ok && (value != "" || !hasDefault) {
+		return value, nil
+	}
+	if hasDefault {
+		return expandEnv(def, lookup)
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
+
+// closingBrace returns the index of the brace closing the one at open, or -1.
+func closingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{
+		"PROVIDER": "openai",
+		"EMPTY":    "",
+		"FALLBACK": "anthropic",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   string
+	}{
+		{name: "no references", input: "plain text", want: "plain text"},
+		{name: "variable", input: "use ${PROVIDER}", want: "use openai"},
+		{name: "default unused", input: "${PROVIDER:-x}", want: "openai"},
+		{name: "default for unset", input: "${MISSING:-local}", want: "local"},
+		{name: "default for empty", input: "${EMPTY:-local}", want: "local"},
+		{name: "empty without default", input: "[${EMPTY}]", want: "[]"},
+		{name: "nested default", input: "${MISSING:-${FALLBACK}}", want: "anthropic"},
+		{name: "deeply nested default", input: "${A:-${B:-${C:-deep}}}", want: "deep"},
+		{name: "escaped dollar", input: "costs $$5 or $${PROVIDER}", want: "costs $5 or ${PROVIDER}"},
+		{name: "bare dollar kept", input: "echo $HOME $", want: "echo $HOME $"},
+		{name: "missing variable", input: "${MISSING}", err: "environment variable MISSING is not set"},
+		{name: "missing in nested default", input: "${A:-${B}}", err: "environment variable B is not set"},
+		{name: "unterminated", input: "${PROVIDER", err: `unterminated variable reference "${PROVIDER"`},
+		{name: "invalid name", input: "${1ABC}", err: "invalid variable reference ${1ABC}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := expandEnv(tt.input, lookup)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadAgentConfigExpandsEnv(t *testing.T) {
+	t.Setenv("TULPA_TEST_PROVIDER", "openrouter")
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "agent.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`name: Agent
+prompt: Talk to ${TULPA_TEST_PROVIDER}, it costs $$1.
+model:
+  provider: ${TULPA_TEST_PROVIDER}
+  model: ${TULPA_TEST_MODEL:-gpt-4o}
+`), 0o644))
+
+	cfg, err := LoadAgentConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, "Talk to openrouter, it costs $1.", cfg.Prompt)
+	require.Equal(t, AgentModelConfig{Provider: "openrouter", Model: "gpt-4o"}, cfg.Model)
+
+	missing := filepath.Join(dir, "missing.yaml")
+	require.NoError(t, os.WriteFile(missing, []byte(`name: Agent
+prompt: Hi
+model:
+  model: ${TULPA_TEST_UNSET_MODEL}
+`), 0o644))
+	_, err = LoadAgentConfig(missing)
+	require.EqualError(t, err, "failed to expand agent config "+missing+": model.model: environment variable TULPA_TEST_UNSET_MODEL is not set")
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	PromptModeReplace = "replace"
+	PromptModeAppend  = "append"
+)
+
+// resolveInheritance applies `extends` to the given agent configs, keyed by
+// agent ID. Every config in the result has its parents merged in; configs
+// that could not be resolved are reported in the returned error map instead.
+func resolveInheritance(configs map[string]*AgentYAMLConfig) (map[string]*AgentYAMLConfig, map[string]error) {
+	resolved := make(map[string]*AgentYAMLConfig, len(configs))
+	errs := make(map[string]error)
+
+	var resolve func(id string, chain []string) (*AgentYAMLConfig, error)
+	resolve = func(id string, chain []string) (*AgentYAMLConfig, error) {
+		if cfg, ok := resolved[id]; ok {
+			return cfg, nil
+		}
+		chain = append(slices.Clip(chain), id)
+
+		cfg := configs[id]
+		if cfg.Extends == "" {
+			resolved[id] = cfg
+			return cfg, nil
+		}
+		if slices.Contains(chain, cfg.Extends) {
+			return nil, fmt.Errorf("inheritance cycle: %s -> %s", strings.Join(chain, " -> "), cfg.Extends)
+		}
+		if _, ok := configs[cfg.Extends]; !ok {
+			return nil, fmt.Errorf("extends unknown agent %q", cfg.Extends)
+		}
+
+		parent, err := resolve(cfg.Extends, chain)
+		if err != nil {
+			return nil, err
+		}
+		merged := mergeAgentConfig(parent, cfg)
+		resolved[id] = merged
+		return merged, nil
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(configs)) {
+		if _, err := resolve(id, nil); err != nil {
+			errs[id] = err
+		}
+	}
+	return resolved, errs
+}
+
+// mergeAgentConfig returns child laid over parent. Fields set in the child
+// win, tool lists are combined, and the prompt either replaces or is appended
+// to the parent's depending on prompt_mode. Disabled is never inherited so a
+// disabled base config can be used as a template.
+func mergeAgentConfig(parent, child *AgentYAMLConfig) *AgentYAMLConfig {
+	merged := *child
+	merged.Description = cmp.Or(child.Description, parent.Description)
+	merged.Model = AgentModelConfig{
+		Type:      cmp.Or(child.Model.Type, parent.Model.Type),
+		Provider:  cmp.Or(child.Model.Provider, parent.Model.Provider),
+		Model:     cmp.Or(child.Model.Model, parent.Model.Model),
+		BaseURL:   cmp.Or(child.Model.BaseURL, parent.Model.BaseURL),
+		APIKeyEnv: cmp.Or(child.Model.APIKeyEnv, parent.Model.APIKeyEnv),
+		Fallback:  child.Model.Fallback,
+	}
+	if merged.Model.Fallback == nil {
+		merged.Model.Fallback = parent.Model.Fallback
+	}
+	merged.ResponseFormat = cmp.Or(child.ResponseFormat, parent.ResponseFormat)
+	if merged.InactivityTimeout == 0 {
+		merged.InactivityTimeout = parent.InactivityTimeout
+	}
+	if merged.MaxToolIterations == 0 {
+		merged.MaxToolIterations = parent.MaxToolIterations
+	}
+	if len(parent.BashEnv) > 0 {
+		merged.BashEnv = maps.Clone(parent.BashEnv)
+		maps.Copy(merged.BashEnv, child.BashEnv)
+	}
+
+	switch {
+	case child.Prompt == "":
+		merged.Prompt = parent.Prompt
+	case child.PromptMode == PromptModeAppend && parent.Prompt != "":
+		merged.Prompt = strings.TrimRight(parent.Prompt, "\n") + "\n\n" + child.Prompt
+	}
+
+	merged.Tools = AgentToolsConfig{
+		Allowed:  mergeNames(parent.Tools.Allowed, child.Tools.Allowed),
+		Disabled: mergeNames(parent.Tools.Disabled, child.Tools.Disabled),
+		Bash: BashCommandRules{
+			Allowed: mergeNames(parent.Tools.Bash.Allowed, child.Tools.Bash.Allowed),
+			Denied:  mergeNames(parent.Tools.Bash.Denied, child.Tools.Bash.Denied),
+		},
+	}
+
+	if child.MCP.Allowed == nil {
+		merged.MCP.Allowed = parent.MCP.Allowed
+	} else if parent.MCP.Allowed != nil {
+		merged.MCP.Allowed = maps.Clone(parent.MCP.Allowed)
+		maps.Copy(merged.MCP.Allowed, child.MCP.Allowed)
+	}
+	if child.LSP.Allowed == nil {
+		merged.LSP.Allowed = parent.LSP.Allowed
+	}
+	if len(child.ContextPaths) == 0 {
+		merged.ContextPaths = parent.ContextPaths
+	}
+	merged.ContextPathsMode = cmp.Or(child.ContextPathsMode, parent.ContextPathsMode)
+	merged.Subagents = AgentSubagentsConfig{
+		Allowed: mergeNames(parent.Subagents.Allowed, child.Subagents.Allowed),
+		Default: cmp.Or(child.Subagents.Default, parent.Subagents.Default),
+	}
+
+	return &merged
+}
+
+func mergeNames(parent, child []string) []string {
+	if len(parent) == 0 {
+		return child
+	}
+	if len(child) == 0 {
+		return parent
+	}
+	merged := slices.Clone(parent)
+	for _, name := range child {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
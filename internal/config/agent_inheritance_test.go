@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInheritance(t *testing.T) {
+	t.Parallel()
+
+	base := &AgentYAMLConfig{
+		Name:         "Base",
+		Description:  "Shared settings",
+		Prompt:       "Follow the house style.\n",
+		Model:        AgentModelConfig{Type: "small", Provider: "openai"},
+		Tools:        AgentToolsConfig{Allowed: []string{"view", "grep"}},
+		MCP:          AgentMCPConfig{Allowed: map[string][]string{"docs": {}}},
+		ContextPaths: []string{"STYLE.md"},
+		BashEnv:      map[string]string{"GOFLAGS": "-mod=mod", "CI": "1"},
+		Disabled:     true,
+	}
+
+	t.Run("inherits and merges fields", func(t *testing.T) {
+		t.Parallel()
+
+		child := &AgentYAMLConfig{
+			Name:       "Reviewer",
+			Extends:    "base",
+			Prompt:     "Review the diff.",
+			PromptMode: PromptModeAppend,
+			Model:      AgentModelConfig{Type: "large"},
+			Tools:      AgentToolsConfig{Allowed: []string{"grep", "bash"}},
+			MCP:        AgentMCPConfig{Allowed: map[string][]string{"github": {"pr"}}},
+			BashEnv:    map[string]string{"CI": "0"},
+		}
+		resolved, errs := resolveInheritance(map[string]*AgentYAMLConfig{"base": base, "reviewer": child})
+		require.Empty(t, errs)
+
+		got := resolved["reviewer"]
+		require.Equal(t, "Reviewer", got.Name)
+		require.Equal(t, "Shared settings", got.Description)
+		require.Equal(t, "Follow the house style.\n\nReview the diff.", got.Prompt)
+		require.Equal(t, AgentModelConfig{Type: "large", Provider: "openai"}, got.Model)
+		require.Equal(t, []string{"view", "grep", "bash"}, got.Tools.Allowed)
+		require.Equal(t, map[string][]string{"docs": {}, "github": {"pr"}}, got.MCP.Allowed)
+		require.Equal(t, []string{"STYLE.md"}, got.ContextPaths)
+		require.Equal(t, map[string]string{"GOFLAGS": "-mod=mod", "CI": "0"}, got.BashEnv)
+		require.False(t, got.Disabled)
+
+		require.Same(t, base, resolved["base"])
+		require.Len(t, base.MCP.Allowed, 1)
+		require.Equal(t, "1", base.BashEnv["CI"])
+	})
+
+	t.Run("prompt modes", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			name   string
+			child  AgentYAMLConfig
+			prompt string
+		}{
+			{"empty inherits", AgentYAMLConfig{Name: "Child", Extends: "base"}, base.Prompt},
+			{"replace by default", AgentYAMLConfig{Name: "Child", Extends: "base", Prompt: "Own."}, "Own."},
+			{"explicit replace", AgentYAMLConfig{Name: "Child", Extends: "base", Prompt: "Own.", PromptMode: PromptModeReplace}, "Own."},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+				resolved, errs := resolveInheritance(map[string]*AgentYAMLConfig{"base": base, "child": &tt.child})
+				require.Empty(t, errs)
+				require.Equal(t, tt.prompt, resolved["child"].Prompt)
+			})
+		}
+	})
+
+	t.Run("multi level chain", func(t *testing.T) {
+		t.Parallel()
+
+		resolved, errs := resolveInheritance(map[string]*AgentYAMLConfig{
+			"base": base,
+			"mid":  {Name: "Mid", Extends: "base", Tools: AgentToolsConfig{Allowed: []string{"edit"}}},
+			"leaf": {Name: "Leaf", Extends: "mid", Tools: AgentToolsConfig{Disabled: []string{"bash"}}},
+		})
+		require.Empty(t, errs)
+		require.Equal(t, []string{"view", "grep", "edit"}, resolved["leaf"].Tools.Allowed)
+		require.Equal(t, []string{"bash"}, resolved["leaf"].Tools.Disabled)
+		require.Equal(t, "small", resolved["leaf"].Model.Type)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		t.Parallel()
+
+		resolved, errs := resolveInheritance(map[string]*AgentYAMLConfig{
+			"a":    {Name: "A", Extends: "b"},
+			"b":    {Name: "B", Extends: "a"},
+			"self": {Name: "Self", Extends: "self"},
+			"ok":   {Name: "Ok"},
+		})
+		require.Len(t, errs, 3)
+		require.EqualError(t, errs["a"], "inheritance cycle: a -> b -> a")
+		require.EqualError(t, errs["b"], "inheritance cycle: b -> a -> b")
+		require.EqualError(t, errs["self"], "inheritance cycle: self -> self")
+		require.Contains(t, resolved, "ok")
+		require.NotContains(t, resolved, "a")
+	})
+
+	t.Run("unknown parent", func(t *testing.T) {
+		t.Parallel()
+
+		_, errs := resolveInheritance(map[string]*AgentYAMLConfig{
+			"child": {Name: "Child", Extends: "missing"},
+		})
+		require.EqualError(t, errs["child"], `extends unknown agent "missing"`)
+	})
+}
+
+func TestLoadAgentsFromDirectoryInheritance(t *testing.T) {
+	tmpDir := t.TempDir()
+	agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+	require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "base.yaml"), []byte(`name: Base
+prompt: Be careful.
+model:
+  type: small
+tools:
+  allowed: [view]
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "child.yaml"), []byte(`name: Child
+extends: base
+prompt: Then be quick.
+prompt_mode: append
+tools:
+  allowed: [bash]
+`), 0o644))
+
+	agents, prompts, err := LoadAgentsFromDirectory("")
+	require.NoError(t, err)
+	require.Equal(t, SelectedModelTypeSmall, agents["child"].Model)
+	require.Equal(t, []string{"view", "bash"}, agents["child"].AllowedTools)
+	require.Equal(t, "Be careful.\n\nThen be quick.", prompts["child"])
+	require.Equal(t, filepath.Join(agentsDir, "child.yaml"), agents["child"].ConfigPath)
+
+	require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "base.yaml"), []byte(`name: Base
+prompt: Be careful.
+extends: child
+`), 0o644))
+	_, _, err = LoadAgentsFromDirectory("")
+	require.ErrorContains(t, err, "base.yaml: inheritance cycle: base -> child -> base")
+	require.ErrorContains(t, err, "child.yaml: inheritance cycle: child -> base -> child")
+}
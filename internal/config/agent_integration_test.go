@@ -1,11 +1,17 @@
 package config
 
 import (
+	"bytes"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/csync"
 )
 
 func TestSetupAgents(t *testing.T) {
@@ -59,19 +65,19 @@ context_paths:
 		err = cfg.SetupAgents()
 		require.NoError(t, err)
 
-		require.NotNil(t, cfg.Agents)
-		require.NotNil(t, cfg.AgentPrompts)
+		require.NotNil(t, cfg.Agents())
+		require.NotNil(t, cfg.AgentPrompts())
 
 		// Verify custom agent was loaded
-		require.Contains(t, cfg.Agents, "custom-agent")
-		customAgentConfig := cfg.Agents["custom-agent"]
+		require.Contains(t, cfg.Agents(), "custom-agent")
+		customAgentConfig := cfg.Agents()["custom-agent"]
 		require.Equal(t, "Custom Agent", customAgentConfig.Name)
 		require.Equal(t, SelectedModelTypeSmall, customAgentConfig.Model)
 		require.Equal(t, []string{"view", "grep"}, customAgentConfig.AllowedTools)
 
 		// Verify prompt was loaded
-		require.Contains(t, cfg.AgentPrompts, "custom-agent")
-		require.Equal(t, "Custom prompt for testing", cfg.AgentPrompts["custom-agent"])
+		require.Contains(t, cfg.AgentPrompts(), "custom-agent")
+		require.Equal(t, "Custom prompt for testing", cfg.AgentPrompts()["custom-agent"])
 	})
 
 	t.Run("applies disabled tools filter", func(t *testing.T) {
@@ -118,7 +124,7 @@ tools:
 		err = cfg.SetupAgents()
 		require.NoError(t, err)
 
-		filteredAgent := cfg.Agents["filtered-agent"]
+		filteredAgent := cfg.Agents()["filtered-agent"]
 		require.NotContains(t, filteredAgent.AllowedTools, "bash")
 		require.Contains(t, filteredAgent.AllowedTools, "edit")
 		require.Contains(t, filteredAgent.AllowedTools, "view")
@@ -163,7 +169,7 @@ prompt: Test
 		err = cfg.SetupAgents()
 		require.NoError(t, err)
 
-		noContextAgent := cfg.Agents["no-context-agent"]
+		noContextAgent := cfg.Agents()["no-context-agent"]
 		require.Equal(t, []string{".cursorrules", "TULPA.md"}, noContextAgent.ContextPaths)
 	})
 
@@ -209,10 +215,57 @@ context_paths:
 		err = cfg.SetupAgents()
 		require.NoError(t, err)
 
-		customContextAgent := cfg.Agents["custom-context-agent"]
+		customContextAgent := cfg.Agents()["custom-context-agent"]
 		require.Equal(t, []string{"custom1.md", "custom2.md"}, customContextAgent.ContextPaths)
 	})
 
+	t.Run("merges context paths with the global ones in merge mode", func(t *testing.T) {
+
+		// Save original env and restore after test
+		originalXDG := os.Getenv("XDG_CONFIG_HOME")
+		t.Cleanup(func() {
+			if originalXDG != "" {
+				os.Setenv("XDG_CONFIG_HOME", originalXDG)
+			} else {
+				os.Unsetenv("XDG_CONFIG_HOME")
+			}
+		})
+
+		tmpDir := t.TempDir()
+		agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+		os.Setenv("XDG_CONFIG_HOME", tmpDir)
+		os.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+		t.Cleanup(func() {
+			os.Unsetenv("TULPA_SKIP_DEFAULT_AGENTS")
+		})
+
+		err := os.MkdirAll(agentsDir, 0o755)
+		require.NoError(t, err)
+
+		agent := `name: Merged Context Agent
+prompt: Test
+context_paths:
+  - agent.md
+  - TULPA.md
+  - agent.md
+context_paths_mode: merge
+`
+		err = os.WriteFile(filepath.Join(agentsDir, "merged-context.yaml"), []byte(agent), 0o644)
+		require.NoError(t, err)
+
+		cfg := &Config{
+			Options: &Options{
+				ContextPaths: []string{".cursorrules", "TULPA.md"},
+			},
+		}
+
+		err = cfg.SetupAgents()
+		require.NoError(t, err)
+
+		mergedContextAgent := cfg.Agents()["merged-context-agent"]
+		require.Equal(t, []string{".cursorrules", "TULPA.md", "agent.md"}, mergedContextAgent.ContextPaths)
+	})
+
 	t.Run("returns error when YAML configs fail to load", func(t *testing.T) {
 
 		// Save original env and restore after test
@@ -255,4 +308,143 @@ invalid: yaml: syntax: [[[
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "agent configuration error")
 	})
+
+	t.Run("resolves named model tiers", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+		t.Setenv("XDG_CONFIG_HOME", tmpDir)
+		t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+		require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "quick.yaml"), []byte("name: Quick\nprompt: Be quick\nmodel:\n  type: fast\n"), 0o644))
+
+		cfg := &Config{
+			Options: &Options{},
+			Models: map[SelectedModelType]SelectedModel{
+				"fast": {Provider: "openai", Model: "gpt-4o-mini"},
+			},
+		}
+		require.NoError(t, cfg.SetupAgents())
+		require.Equal(t, SelectedModelType("fast"), cfg.Agents()["quick"].Model)
+
+		delete(cfg.Models, "fast")
+		err := cfg.SetupAgents()
+		require.EqualError(t, err, `agent configuration error: agent "quick" uses unknown model tier "fast", known tiers: large, small`)
+	})
+
+	t.Run("resolves fallback models", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+		t.Setenv("XDG_CONFIG_HOME", tmpDir)
+		t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+		require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+		agent := `name: Sturdy
+prompt: Keep going
+model:
+  fallback:
+    - type: small
+    - provider: openai
+      model: gpt-4o
+`
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "sturdy.yaml"), []byte(agent), 0o644))
+
+		cfg := &Config{
+			Options: &Options{},
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"openai": {ID: "openai", Models: []catwalk.Model{{ID: "gpt-4o", DefaultMaxTokens: 4096}}},
+			}),
+		}
+		require.NoError(t, cfg.SetupAgents())
+		require.Equal(t, []SelectedModelType{"small", "openai/gpt-4o"}, cfg.Agents()["sturdy"].FallbackModels)
+		model, ok := cfg.SelectedModel("openai/gpt-4o")
+		require.True(t, ok)
+		require.Equal(t, SelectedModel{Provider: "openai", Model: "gpt-4o", MaxTokens: 4096}, model)
+		require.NotContains(t, cfg.Models, SelectedModelType("openai/gpt-4o"), "the models config is left unchanged")
+
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "sturdy.yaml"), []byte(strings.Replace(agent, "gpt-4o", "gpt-5", 1)), 0o644))
+		err := cfg.SetupAgents()
+		require.EqualError(t, err, `agent configuration error: agent "sturdy" fallback: model "gpt-5" not found for provider "openai"`)
+		require.NotNil(t, cfg.GetModelByType("openai/gpt-4o"), "a failed load keeps the previous tiers")
+
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "sturdy.yaml"), []byte("name: Sturdy\nmodel:\n  fallback:\n    - provider: openai\n"), 0o644))
+		err = cfg.SetupAgents()
+		require.ErrorContains(t, err, "model.fallback[0] must set either type or both provider and model")
+	})
+
+	t.Run("reloads endpoints while their models are looked up", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+		t.Setenv("XDG_CONFIG_HOME", tmpDir)
+		t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+		require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+		agent := "name: Local\nprompt: Local\nmodel:\n  model: llama3\n  base_url: http://localhost:11434/v1\n  fallback:\n    - provider: openai\n      model: gpt-4o\n"
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "local.yaml"), []byte(agent), 0o644))
+
+		cfg := &Config{
+			Options: &Options{},
+			Models:  map[SelectedModelType]SelectedModel{},
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"openai": {ID: "openai", Models: []catwalk.Model{{ID: "gpt-4o", DefaultMaxTokens: 4096}}},
+			}),
+		}
+		require.NoError(t, cfg.SetupAgents())
+		tier := cfg.Agents()["local"].Model
+
+		// The agents look up their models while the watcher reloads them.
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			for range 100 {
+				require.NoError(t, cfg.SetupAgents())
+			}
+		})
+		for range 100 {
+			require.NotNil(t, cfg.GetModelByType(tier))
+			require.NotNil(t, cfg.GetModelByType("openai/gpt-4o"))
+		}
+		wg.Wait()
+		require.Empty(t, cfg.Models, "the models config is left unchanged")
+	})
+
+	t.Run("resolves subagents", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+		t.Setenv("XDG_CONFIG_HOME", tmpDir)
+		t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+		require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+		files := map[string]string{
+			"coder.yaml":    "name: Coder\nprompt: Code\ntools:\n  allowed: [agent, view]\n",
+			"task.yaml":     "name: Task\nprompt: Find\ntools:\n  allowed: [view]\n",
+			"reviewer.yaml": "name: Reviewer\nprompt: Review\ntools:\n  allowed: [agent, view]\nsubagents:\n  allowed: [task]\n  default: coder\n",
+		}
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(agentsDir, name), []byte(content), 0o644))
+		}
+
+		cfg := &Config{Options: &Options{}}
+		require.NoError(t, cfg.SetupAgents())
+		require.Equal(t, []string{"task"}, cfg.Agents()["coder"].Subagents)
+		require.Empty(t, cfg.Agents()["task"].Subagents)
+		require.Equal(t, []string{"task", "coder"}, cfg.Agents()["reviewer"].Subagents)
+		require.Equal(t, "coder", cfg.Agents()["reviewer"].DefaultSubagent)
+
+		// Dangling references are left out with a warning, or fail in strict
+		// mode.
+		var logs bytes.Buffer
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+		t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "reviewer.yaml"), []byte("name: Reviewer\nprompt: Review\nsubagents:\n  allowed: [task, tester]\n  default: missing\n"), 0o644))
+		require.NoError(t, cfg.SetupAgents())
+		require.Equal(t, []string{"task"}, cfg.Agents()["reviewer"].Subagents)
+		require.Empty(t, cfg.Agents()["reviewer"].DefaultSubagent)
+		require.Contains(t, logs.String(), `msg="Agent allows an unknown subagent, leaving it out" agent=reviewer subagent=tester`)
+		require.Contains(t, logs.String(), `msg="Agent has an unknown default subagent, leaving it out" agent=reviewer subagent=missing`)
+
+		cfg.Options.StrictConfig = true
+		err := cfg.SetupAgents()
+		require.EqualError(t, err, `agent configuration error: agent "reviewer" allows unknown subagent "tester"`)
+
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, "reviewer.yaml"), []byte("name: Reviewer\nprompt: Review\nsubagents:\n  allowed: [task]\n  default: missing\n"), 0o644))
+		err = cfg.SetupAgents()
+		require.EqualError(t, err, `agent configuration error: agent "reviewer" has unknown default subagent "missing"`)
+	})
 }
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// AgentConfigIssue is a single problem found while validating an agent
+// config file.
+type AgentConfigIssue struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (i AgentConfigIssue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", i.Line, i.Path, i.Message)
+}
+
+// AgentConfigValidationError lists every problem found in an agent config
+// file.
+type AgentConfigValidationError struct {
+	Issues []AgentConfigIssue
+}
+
+func (e *AgentConfigValidationError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		lines[i] = "    " + issue.String()
+	}
+	return "invalid agent config:\n" + strings.Join(lines, "\n")
+}
+
+// AgentConfigSchema returns the JSON schema agent YAML files are validated
+// against. Tool entries must be built-in tool names or glob patterns.
+func AgentConfigSchema() *jsonschema.Schema {
+	reflector := &jsonschema.Reflector{
+		FieldNameTag:               "yaml",
+		DoNotReference:             true,
+		RequiredFromJSONSchemaTags: true,
+	}
+	schema := reflector.Reflect(&AgentYAMLConfig{})
+
+	toolNames := make([]any, 0, len(allToolNames()))
+	for _, name := range allToolNames() {
+		toolNames = append(toolNames, name)
+	}
+	if tools, ok := schema.Properties.Get("tools"); ok {
+		for _, key := range []string{"allowed", "disabled"} {
+			if list, ok := tools.Properties.Get(key); ok && list.Items != nil {
+				list.Items = &jsonschema.Schema{AnyOf: []*jsonschema.Schema{
+					{Type: "string", Enum: toolNames},
+					{Type: "string", Pattern: `[*?\[]`},
+				}}
+			}
+		}
+	}
+	return schema
+}
+
+// ValidateAgentConfig validates the YAML content of an agent config file
+// against [AgentConfigSchema]. All problems are reported at once in an
+// [AgentConfigValidationError].
+func ValidateAgentConfig(data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse agent config: %w", err)
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	var issues []AgentConfigIssue
+	validateAgentNode(AgentConfigSchema(), root.Content[0], "", &issues)
+	if len(issues) > 0 {
+		return &AgentConfigValidationError{Issues: issues}
+	}
+	return nil
+}
+
+func validateAgentNode(schema *jsonschema.Schema, node *yaml.Node, path string, issues *[]AgentConfigIssue) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	report := func(n *yaml.Node, path, format string, args ...any) {
+		*issues = append(*issues, AgentConfigIssue{
+			Line:    n.Line,
+			Column:  n.Column,
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(schema.AnyOf) > 0 {
+		// Valid if any alternative matches; otherwise report the problems
+		// with the first one.
+		var first []AgentConfigIssue
+		for i, alt := range schema.AnyOf {
+			var altIssues []AgentConfigIssue
+			validateAgentNode(alt, node, path, &altIssues)
+			if len(altIssues) == 0 {
+				return
+			}
+			if i == 0 {
+				first = altIssues
+			}
+		}
+		*issues = append(*issues, first...)
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			report(node, path, "expected a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			var prop *jsonschema.Schema
+			if schema.Properties != nil {
+				prop, _ = schema.Properties.Get(key.Value)
+			}
+			if prop == nil && schema.AdditionalProperties != jsonschema.FalseSchema {
+				prop = schema.AdditionalProperties
+			}
+			if prop == nil {
+				report(key, keyPath, "unknown field")
+				continue
+			}
+			validateAgentNode(prop, value, keyPath, issues)
+		}
+		return
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			report(node, path, "expected a list")
+			return
+		}
+		if schema.Items == nil {
+			return
+		}
+		for i, item := range node.Content {
+			validateAgentNode(schema.Items, item, fmt.Sprintf("%s[%d]", path, i), issues)
+		}
+		return
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		report(node, path, "expected a %s", schema.Type)
+		return
+	}
+	switch schema.Type {
+	case "integer":
+		if node.Tag != "!!int" {
+			report(node, path, "expected an integer, got %q", node.Value)
+			return
+		}
+	case "boolean":
+		if node.Tag != "!!bool" {
+			report(node, path, "expected true or false, got %q", node.Value)
+			return
+		}
+	}
+	if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(node.Value) {
+		report(node, path, "invalid value %q, expected to match %s", node.Value, schema.Pattern)
+		return
+	}
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, any(node.Value)) {
+		allowed := make([]string, len(schema.Enum))
+		for i, v := range schema.Enum {
+			allowed[i] = fmt.Sprint(v)
+		}
+		report(node, path, "invalid value %q, expected one of: %s", node.Value, strings.Join(allowed, ", "))
+	}
+}
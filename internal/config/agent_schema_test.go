@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAgentConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("accepts a valid config", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte(`name: Reviewer
+description: Reviews code
+prompt: |
+  You review code.
+model:
+  type: small
+tools:
+  allowed: [view, grep, "*"]
+  disabled: [bash, "multi?dit"]
+mcp:
+  allowed:
+    github: [search]
+lsp:
+  allowed: [gopls]
+context_paths:
+  - TULPA.md
+inactivity_timeout: 60
+response_format: json
+disabled: false
+`)
+		require.NoError(t, ValidateAgentConfig(data))
+	})
+
+	t.Run("accepts the default agent configs", func(t *testing.T) {
+		t.Parallel()
+
+		tmpDir := t.TempDir()
+		require.NoError(t, createDefaultAgentConfigs(tmpDir, AgentModelConfig{Type: "large"}))
+		for _, name := range []string{"coder.yaml", "task.yaml"} {
+			data, err := os.ReadFile(filepath.Join(tmpDir, name))
+			require.NoError(t, err)
+			require.NoError(t, ValidateAgentConfig(data), name)
+		}
+	})
+
+	t.Run("reports every problem with line numbers", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte(`name: Broken
+prompt: hi
+modle:
+  type: large
+model:
+  type: medium
+  flavor: spicy
+tools:
+  allowed:
+    - view
+    - bsh
+disabled: sometimes
+inactivity_timeout: soon
+`)
+		err := ValidateAgentConfig(data)
+		require.Error(t, err)
+
+		var validationErr *AgentConfigValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []AgentConfigIssue{
+			{Line: 3, Column: 1, Path: "modle", Message: "unknown field"},
+			{Line: 7, Column: 3, Path: "model.flavor", Message: "unknown field"},
+			{Line: 11, Column: 7, Path: "tools.allowed[1]", Message: `invalid value "bsh", expected one of: agent, bash, diagnostics, download, edit, multiedit, fetch, glob, grep, jobs, ls, progress, sourcegraph, view, write`},
+			{Line: 12, Column: 11, Path: "disabled", Message: `expected true or false, got "sometimes"`},
+			{Line: 13, Column: 21, Path: "inactivity_timeout", Message: `expected an integer, got "soon"`},
+		}, validationErr.Issues)
+		require.Contains(t, err.Error(), "line 3: modle: unknown field")
+	})
+
+	t.Run("rejects a non mapping document", func(t *testing.T) {
+		t.Parallel()
+
+		err := ValidateAgentConfig([]byte("- just\n- a list\n"))
+		require.ErrorContains(t, err, "line 1: expected a mapping")
+	})
+
+	t.Run("returns yaml syntax errors", func(t *testing.T) {
+		t.Parallel()
+
+		err := ValidateAgentConfig([]byte("name: [unclosed\n"))
+		require.ErrorContains(t, err, "failed to parse agent config")
+	})
+}
+
+func TestLoadAgentConfigValidates(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: Typo\nmodel:\n  tpye: small\n"), 0o644))
+
+	_, err := LoadAgentConfig(path)
+	require.ErrorContains(t, err, "line 3: model.tpye: unknown field")
+}
+
+func TestAgentConfigSchemaDescribesEveryField(t *testing.T) {
+	t.Parallel()
+
+	var check func(path string, schema *jsonschema.Schema)
+	check = func(path string, schema *jsonschema.Schema) {
+		if schema.Properties == nil {
+			return
+		}
+		for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			keyPath := pair.Key
+			if path != "" {
+				keyPath = path + "." + pair.Key
+			}
+			require.NotEmpty(t, pair.Value.Description, "%s has no description", keyPath)
+			check(keyPath, pair.Value)
+		}
+	}
+	check("", AgentConfigSchema())
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"io"
+	"log/slog"
+	"text/template"
+)
+
+// PromptData holds the values agent prompts can reference as template
+// placeholders, e.g. {{.WorkingDir}}. They are filled in when the system
+// prompt is built.
+type PromptData struct {
+	WorkingDir string
+	Platform   string
+	Date       string
+	GitBranch  string
+	AgentName  string
+}
+
+// ParsePromptTemplate parses an agent prompt as a text/template.
+func ParsePromptTemplate(name, prompt string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(prompt)
+}
+
+// validatePromptTemplate reports references to unknown placeholders in
+// prompt, so they fail at load time rather than rendering as empty strings.
+// A prompt that doesn't parse as a template, like one with literal braces,
+// isn't rendered: it's used as written.
+func validatePromptTemplate(path, prompt string) error {
+	tmpl, err := ParsePromptTemplate("prompt", prompt)
+	if err != nil {
+		slog.Debug("Agent prompt is not a template, using it as written", "path", path, "error", err)
+		return nil
+	}
+	return tmpl.Execute(io.Discard, PromptData{})
+}
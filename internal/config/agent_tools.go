@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+)
+
+// isToolPattern reports whether a tools.allowed or tools.disabled entry is a
+// glob pattern rather than a tool name.
+func isToolPattern(entry string) bool {
+	return strings.ContainsAny(entry, "*?[")
+}
+
+// resolveAgentTools expands the glob patterns in an agent's allowed tools
+// against the built-in tools and removes the disabled ones. An empty allowed
+// list stands for all tools. Unknown tool names are logged and left out, and
+// so are patterns that match no tool.
+func resolveAgentTools(agentName string, allowed, disabled []string) []string {
+	if len(allowed) == 0 {
+		allowed = []string{"*"}
+	}
+	for _, problem := range toolProblems(allowed, disabled) {
+		slog.Warn("Agent tools config problem", "agent", agentName, "problem", problem)
+	}
+
+	resolved := []string{}
+	for _, entry := range allowed {
+		var matches []string
+		switch {
+		case isToolPattern(entry):
+			matches = matchToolNames(entry)
+		case slices.Contains(allToolNames(), entry):
+			matches = []string{entry}
+		}
+		for _, name := range matches {
+			if !slices.Contains(resolved, name) {
+				resolved = append(resolved, name)
+			}
+		}
+	}
+
+	return slices.DeleteFunc(resolved, func(name string) bool {
+		return slices.ContainsFunc(disabled, func(entry string) bool {
+			matched, _ := path.Match(entry, name)
+			return matched
+		})
+	})
+}
+
+// toolProblems describes the entries of an agent's tool lists that stand for
+// no tool: unknown tool names and patterns matching no tool.
+func toolProblems(allowed, disabled []string) []string {
+	var problems []string
+	valid := strings.Join(allToolNames(), ", ")
+	for _, list := range []struct {
+		field   string
+		entries []string
+	}{{"allowed", allowed}, {"disabled", disabled}} {
+		for _, name := range unknownToolNames(list.entries) {
+			problems = append(problems, fmt.Sprintf("tools.%s: unknown tool %q, valid tools are %s", list.field, name, valid))
+		}
+		for _, entry := range list.entries {
+			if isToolPattern(entry) && len(matchToolNames(entry)) == 0 {
+				problems = append(problems, fmt.Sprintf("tools.%s: pattern %q matches no tools", list.field, entry))
+			}
+		}
+	}
+	return problems
+}
+
+// CheckToolNames returns an error for the first of names that is neither a
+// built-in tool nor a tool of a configured MCP server, named
+// mcp_<server>_<tool>.
+func (c *Config) CheckToolNames(names []string) error {
+	for _, name := range names {
+		if slices.Contains(allToolNames(), name) {
+			continue
+		}
+		isMCPTool := false
+		for server := range c.MCP {
+			if strings.HasPrefix(name, "mcp_"+server+"_") {
+				isMCPTool = true
+				break
+			}
+		}
+		if !isMCPTool {
+			return fmt.Errorf("unknown tool %q, valid tools are %s, and mcp_<server>_<tool> for the tools of the MCP servers", name, strings.Join(allToolNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// unknownToolNames returns the entries of a tools list that are neither a
+// built-in tool nor a pattern.
+func unknownToolNames(entries []string) []string {
+	var unknown []string
+	for _, entry := range entries {
+		if !isToolPattern(entry) && !slices.Contains(allToolNames(), entry) {
+			unknown = append(unknown, entry)
+		}
+	}
+	return unknown
+}
+
+func matchToolNames(pattern string) []string {
+	var matches []string
+	for _, name := range allToolNames() {
+		if matched, _ := path.Match(pattern, name); matched {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
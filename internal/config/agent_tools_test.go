@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAgentTools(t *testing.T) {
+	t.Parallel()
+
+	withoutBash := slices.DeleteFunc(allToolNames(), func(name string) bool { return name == "bash" })
+
+	tests := []struct {
+		name     string
+		allowed  []string
+		disabled []string
+		want     []string
+	}{
+		{name: "names", allowed: []string{"view", "grep"}, want: []string{"view", "grep"}},
+		{name: "all", allowed: []string{"*"}, want: allToolNames()},
+		{name: "all minus bash", allowed: []string{"*"}, disabled: []string{"bash"}, want: withoutBash},
+		{name: "empty allowed minus bash", disabled: []string{"bash"}, want: withoutBash},
+		{name: "prefix glob", allowed: []string{"view", "*edit"}, want: []string{"view", "edit", "multiedit"}},
+		{name: "duplicates dropped", allowed: []string{"grep", "g*"}, want: []string{"grep", "glob"}},
+		{name: "disabled pattern", allowed: []string{"*"}, disabled: []string{"*edit", "write"}, want: []string{"agent", "bash", "diagnostics", "download", "fetch", "glob", "grep", "jobs", "ls", "progress", "sourcegraph", "view"}},
+		{name: "pattern matching nothing", allowed: []string{"view", "mcp_*"}, want: []string{"view"}},
+		{name: "unknown name dropped", allowed: []string{"viewr", "grep"}, disabled: []string{"bsh"}, want: []string{"grep"}},
+		{name: "everything disabled", allowed: []string{"view"}, disabled: []string{"*"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, resolveAgentTools("Test", tt.allowed, tt.disabled))
+		})
+	}
+}
+
+func TestUnknownToolNames(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []string{"viewr", "mcp_fs"}, unknownToolNames([]string{"view", "viewr", "*edit", "mcp_fs"}))
+	require.Empty(t, unknownToolNames(allToolNames()))
+}
+
+func TestToolProblems(t *testing.T) {
+	t.Parallel()
+
+	problems := toolProblems([]string{"view", "viewr", "mcp_*"}, []string{"bash", "x*"})
+	require.Len(t, problems, 3)
+	require.True(t, strings.HasPrefix(problems[0], `tools.allowed: unknown tool "viewr", valid tools are agent, bash,`))
+	require.Equal(t, `tools.allowed: pattern "mcp_*" matches no tools`, problems[1])
+	require.Equal(t, `tools.disabled: pattern "x*" matches no tools`, problems[2])
+	require.Empty(t, toolProblems([]string{"*"}, nil))
+}
+
+func TestCheckToolNames(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{MCP: MCPs{"fs": {Type: MCPStdio}}}
+	tests := []struct {
+		name  string
+		tools []string
+		err   string
+	}{
+		{name: "none"},
+		{name: "built-in tools", tools: []string{"bash", "fetch"}},
+		{name: "MCP tool", tools: []string{"view", "mcp_fs_read"}},
+		{name: "unknown tool", tools: []string{"view", "bsh"}, err: `unknown tool "bsh", valid tools are agent, bash,`},
+		{name: "unknown MCP server", tools: []string{"mcp_git_log"}, err: `unknown tool "mcp_git_log"`},
+		{name: "pattern", tools: []string{"*edit"}, err: `unknown tool "*edit"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := cfg.CheckToolNames(tt.tools)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestAgentYAMLConfigToAgentToolPatterns(t *testing.T) {
+	t.Parallel()
+
+	agent := (&AgentYAMLConfig{
+		Name:  "No Shell",
+		Tools: AgentToolsConfig{Allowed: []string{"*"}, Disabled: []string{"bash"}},
+	}).ToAgent()
+	require.NotContains(t, agent.AllowedTools, "bash")
+	require.Len(t, agent.AllowedTools, len(allToolNames())-1)
+
+	// Disabling every allowed tool leaves none, rather than falling back to
+	// all tools.
+	locked := (&AgentYAMLConfig{
+		Name:  "Locked",
+		Tools: AgentToolsConfig{Allowed: []string{"view"}, Disabled: []string{"view"}},
+	}).ToAgent()
+	require.NotNil(t, locked.AllowedTools)
+	require.Empty(t, locked.AllowedTools)
+}
+
+func TestLoadAgentConfigBashCommands(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tester.yaml")
+	content := "name: Tester\nprompt: Test\ntools:\n  allowed: [bash]\n  bash:\n    allowed: [go test, \"/^make( |$)/\"]\n    denied: [go test -exec]\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	config, err := LoadAgentConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, BashCommandRules{
+		Allowed: []string{"go test", "/^make( |$)/"},
+		Denied:  []string{"go test -exec"},
+	}, config.ToAgent().BashCommands)
+
+	require.NoError(t, os.WriteFile(path, []byte("name: Tester\nprompt: Test\ntools:\n  bash:\n    denied: [\"/(/\"]\n"), 0o644))
+	_, err = LoadAgentConfig(path)
+	require.ErrorContains(t, err, "tools.bash: invalid regular expression /(/")
+}
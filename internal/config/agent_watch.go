@@ -0,0 +1,241 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// agentReloadDelay batches the bursts of events editors produce when saving
+// a file into a single reload.
+const agentReloadDelay = 200 * time.Millisecond
+
+// AgentChangeEvent is sent by [Config.WatchAgents] after the agent configs
+// were reloaded. It lists the agent IDs that differ from the previous load
+// and carries the complete new set of agents and prompts.
+type AgentChangeEvent struct {
+	Added   []string
+	Removed []string
+	Changed []string
+
+	Agents  map[string]Agent
+	Prompts map[string]string
+}
+
+// WatchAgents reloads the agent configs whenever a YAML file in the agents
+// directory or in the one of the project, or a prompt_file they read, is
+// created, written, renamed or removed. The project agents directory is
+// picked up when it is created after the watch started. It replaces the
+// agents of the config with the reloaded ones and sends what changed on the
+// returned channel. A reload that fails is logged and skipped, so the last
+// good configs stay in effect. The channel is closed when ctx is done.
+func (c *Config) WatchAgents(ctx context.Context) (<-chan AgentChangeEvent, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent config watcher: %w", err)
+	}
+	watch := &agentWatch{watcher: watcher, workingDir: c.workingDir}
+	if err := watch.sync(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	events := make(chan AgentChangeEvent)
+	go func() {
+		defer close(events)
+		defer watcher.Close()
+
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !watch.reloads(event) {
+					continue
+				}
+				reload = time.After(agentReloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Agent config watcher error", "error", err)
+			case <-reload:
+				reload = nil
+				// Watch the directories and prompt files which appeared
+				// first, so the changes made while loading aren't missed.
+				if err := watch.sync(); err != nil {
+					slog.Warn("Agent config watcher error", "error", err)
+				}
+				set, err := c.loadAgents()
+				if err != nil {
+					slog.Error("Failed to reload agent configs, keeping the previous ones", "error", err)
+					continue
+				}
+				change := diffAgents(c.Agents(), c.AgentPrompts(), set.agents, set.prompts)
+				c.storeAgents(set)
+				if len(change.Added)+len(change.Removed)+len(change.Changed) == 0 {
+					continue
+				}
+				slog.Info("Reloaded agent configs", "added", change.Added, "removed", change.Removed, "changed", change.Changed)
+				select {
+				case events <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func diffAgents(oldAgents map[string]Agent, oldPrompts map[string]string, agents map[string]Agent, prompts map[string]string) AgentChangeEvent {
+	change := AgentChangeEvent{Agents: agents, Prompts: prompts}
+	for _, id := range slices.Sorted(maps.Keys(agents)) {
+		old, ok := oldAgents[id]
+		switch {
+		case !ok:
+			change.Added = append(change.Added, id)
+		case !reflect.DeepEqual(old, agents[id]) || oldPrompts[id] != prompts[id]:
+			change.Changed = append(change.Changed, id)
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(oldAgents)) {
+		if _, ok := agents[id]; !ok {
+			change.Removed = append(change.Removed, id)
+		}
+	}
+	return change
+}
+
+func isAgentConfigFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// agentWatch decides which directories [Config.WatchAgents] watches and which
+// of their changes reload the agents.
+type agentWatch struct {
+	watcher    *fsnotify.Watcher
+	workingDir string
+
+	// The directories the agent configs are loaded from
+	agentDirs map[string]bool
+	// The other paths whose changes reload the agents: the prompt files and
+	// the missing directories of the project agents
+	paths map[string]bool
+}
+
+// sync watches the agent directories that exist, the nearest existing parent
+// of the project agents directory while it is missing and the directories
+// of the prompt files, and stops watching the others. Only failing to watch
+// an agent directory is an error.
+func (w *agentWatch) sync() error {
+	w.agentDirs = map[string]bool{AgentsConfigDir(): true}
+	w.paths = make(map[string]bool)
+	dirs := map[string]bool{AgentsConfigDir(): true}
+	if w.workingDir != "" {
+		projectDir := ProjectAgentsDir(w.workingDir)
+		switch {
+		case isDir(projectDir):
+			w.agentDirs[projectDir] = true
+			dirs[projectDir] = true
+		case isDir(filepath.Dir(projectDir)):
+			w.paths[projectDir] = true
+			dirs[filepath.Dir(projectDir)] = true
+		default:
+			w.paths[projectDir] = true
+			w.paths[filepath.Dir(projectDir)] = true
+			dirs[w.workingDir] = true
+		}
+	}
+	for dir := range w.agentDirs {
+		for _, path := range agentPromptFiles(dir, dir != AgentsConfigDir()) {
+			w.paths[path] = true
+			if isDir(filepath.Dir(path)) {
+				dirs[filepath.Dir(path)] = true
+			}
+		}
+	}
+
+	watched := make(map[string]bool)
+	for _, dir := range w.watcher.WatchList() {
+		watched[dir] = true
+		if !dirs[dir] {
+			_ = w.watcher.Remove(dir)
+		}
+	}
+	for _, dir := range slices.Sorted(maps.Keys(dirs)) {
+		if watched[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			if w.agentDirs[dir] {
+				return fmt.Errorf("failed to watch agents directory %s: %w", dir, err)
+			}
+			slog.Warn("Failed to watch agent config directory", "dir", dir, "error", err)
+		}
+	}
+	return nil
+}
+
+// reloads reports whether the agents must be reloaded after event.
+func (w *agentWatch) reloads(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if w.paths[event.Name] || w.agentDirs[event.Name] {
+		return true
+	}
+	return w.agentDirs[filepath.Dir(event.Name)] && isAgentConfigFile(event.Name)
+}
+
+// agentPromptFiles returns the paths of the prompt files the agent configs of
+// dir read. Unlike loading them, it ignores the configs that fail to parse.
+// Project configs only read the files in their directory.
+func agentPromptFiles(dir string, project bool) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !isAgentConfigEntry(entry) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var config struct {
+			PromptFile string `yaml:"prompt_file"`
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil || config.PromptFile == "" {
+			continue
+		}
+		switch {
+		case filepath.IsAbs(config.PromptFile) && !project:
+			paths = append(paths, filepath.Clean(config.PromptFile))
+		case filepath.IsLocal(config.PromptFile) || !project:
+			paths = append(paths, filepath.Join(dir, config.PromptFile))
+		}
+	}
+	return paths
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
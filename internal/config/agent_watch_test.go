@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffAgents(t *testing.T) {
+	t.Parallel()
+
+	oldAgents := map[string]Agent{
+		"coder":  {ID: "coder", Model: SelectedModelTypeLarge},
+		"task":   {ID: "task", Model: SelectedModelTypeSmall},
+		"legacy": {ID: "legacy"},
+		"docs":   {ID: "docs"},
+	}
+	oldPrompts := map[string]string{"coder": "code", "task": "find", "docs": "write"}
+
+	agents := map[string]Agent{
+		"coder":    {ID: "coder", Model: SelectedModelTypeLarge},
+		"task":     {ID: "task", Model: SelectedModelTypeLarge},
+		"docs":     {ID: "docs"},
+		"reviewer": {ID: "reviewer"},
+	}
+	prompts := map[string]string{"coder": "code", "task": "find", "docs": "write better"}
+
+	change := diffAgents(oldAgents, oldPrompts, agents, prompts)
+	require.Equal(t, []string{"reviewer"}, change.Added)
+	require.Equal(t, []string{"legacy"}, change.Removed)
+	require.Equal(t, []string{"docs", "task"}, change.Changed)
+	require.Equal(t, agents, change.Agents)
+	require.Equal(t, prompts, change.Prompts)
+}
+
+func TestWatchAgents(t *testing.T) {
+	tmpDir := t.TempDir()
+	agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+	require.NoError(t, os.MkdirAll(agentsDir, 0o755))
+
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(agentsDir, name), []byte(content), 0o644))
+	}
+	write("coder.yaml", "name: Coder\nprompt: Code.\n")
+	cfg := &Config{Options: &Options{}}
+	require.NoError(t, cfg.SetupAgents())
+
+	events, err := cfg.WatchAgents(t.Context())
+	require.NoError(t, err)
+
+	write("reviewer.yaml", "name: Reviewer\nprompt: Review.\n")
+	change := nextAgentChange(t, events)
+	require.Equal(t, []string{"reviewer"}, change.Added)
+	require.Contains(t, change.Agents, "coder")
+	require.Equal(t, "Review.", change.Prompts["reviewer"])
+	require.Equal(t, change.Agents, cfg.Agents(), "the config has the reloaded agents")
+
+	// An invalid file is skipped and the next good reload is diffed
+	// against the last good one.
+	write("coder.yaml", "name: Coder\nprompt: Code.\nmodle: large\n")
+	time.Sleep(2 * agentReloadDelay)
+	write("coder.yaml", "name: Coder\nprompt: Code faster.\n")
+	change = nextAgentChange(t, events)
+	require.Empty(t, change.Added)
+	require.Equal(t, []string{"coder"}, change.Changed)
+	require.Equal(t, "Code faster.", change.Prompts["coder"])
+
+	require.NoError(t, os.Remove(filepath.Join(agentsDir, "reviewer.yaml")))
+	change = nextAgentChange(t, events)
+	require.Equal(t, []string{"reviewer"}, change.Removed)
+	require.NotContains(t, change.Agents, "reviewer")
+}
+
+func TestWatchAgentsPromptFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	agentsDir := filepath.Join(tmpDir, "tulpa", "agents")
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+	require.NoError(t, os.MkdirAll(filepath.Join(agentsDir, "prompts"), 0o755))
+	sharedDir := t.TempDir()
+
+	write := func(path, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write(filepath.Join(agentsDir, "coder.yaml"), "name: Coder\nprompt_file: prompts/coder.md\n")
+	write(filepath.Join(agentsDir, "prompts", "coder.md"), "Code.")
+	write(filepath.Join(agentsDir, "docs.yaml"), "name: Docs\nprompt_file: "+filepath.Join(sharedDir, "docs.md")+"\n")
+	write(filepath.Join(sharedDir, "docs.md"), "Write.")
+
+	cfg := &Config{Options: &Options{}}
+	require.NoError(t, cfg.SetupAgents())
+	events, err := cfg.WatchAgents(t.Context())
+	require.NoError(t, err)
+
+	write(filepath.Join(agentsDir, "prompts", "coder.md"), "Code faster.")
+	change := nextAgentChange(t, events)
+	require.Equal(t, []string{"coder"}, change.Changed)
+	require.Equal(t, "Code faster.", change.Prompts["coder"])
+
+	write(filepath.Join(sharedDir, "docs.md"), "Write better.")
+	change = nextAgentChange(t, events)
+	require.Equal(t, []string{"docs"}, change.Changed)
+	require.Equal(t, "Write better.", change.Prompts["docs"])
+
+	// The prompt file of a new config is watched too.
+	write(filepath.Join(agentsDir, "reviewer.yaml"), "name: Reviewer\nprompt_file: prompts/reviewer.md\n")
+	write(filepath.Join(agentsDir, "prompts", "reviewer.md"), "Review.")
+	change = nextAgentChange(t, events)
+	require.Equal(t, []string{"reviewer"}, change.Added)
+	write(filepath.Join(agentsDir, "prompts", "reviewer.md"), "Review carefully.")
+	change = nextAgentChange(t, events)
+	require.Equal(t, []string{"reviewer"}, change.Changed)
+	require.Equal(t, "Review carefully.", change.Prompts["reviewer"])
+}
+
+func TestWatchAgentsNewProjectDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("TULPA_SKIP_DEFAULT_AGENTS", "1")
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "tulpa", "agents"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "tulpa", "agents", "coder.yaml"), []byte("name: Coder\nprompt: Code.\n"), 0o644))
+
+	workingDir := t.TempDir()
+	cfg := &Config{Options: &Options{}, workingDir: workingDir}
+	require.NoError(t, cfg.SetupAgents())
+	events, err := cfg.WatchAgents(t.Context())
+	require.NoError(t, err)
+
+	projectDir := ProjectAgentsDir(workingDir)
+	require.NoError(t, os.MkdirAll(projectDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "reviewer.yaml"), []byte("name: Reviewer\nprompt: Review.\n"), 0o644))
+	change := nextAgentChange(t, events)
+	require.Equal(t, []string{"reviewer"}, change.Added)
+
+	// Once it exists, the project directory is watched like the global one.
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "reviewer.yaml"), []byte("name: Reviewer\nprompt: Review again.\n"), 0o644))
+	change = nextAgentChange(t, events)
+	require.Equal(t, []string{"reviewer"}, change.Changed)
+	require.Equal(t, "Review again.", change.Prompts["reviewer"])
+}
+
+func nextAgentChange(t *testing.T, events <-chan AgentChangeEvent) AgentChangeEvent {
+	t.Helper()
+	select {
+	case change := <-events:
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for agent change")
+		return AgentChangeEvent{}
+	}
+}
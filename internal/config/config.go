@@ -1,20 +1,27 @@
 package config
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/tidwall/sjson"
+	"github.com/tulpa-code/tulpa/internal/backup"
 	"github.com/tulpa-code/tulpa/internal/csync"
 	"github.com/tulpa-code/tulpa/internal/env"
+	"github.com/tulpa-code/tulpa/internal/home"
 )
 
 const (
@@ -43,6 +50,9 @@ var defaultContextPaths = []string{
 
 type SelectedModelType string
 
+// ResponseFormatJSON makes an agent answer with a single JSON value.
+const ResponseFormatJSON = "json"
+
 const (
 	SelectedModelTypeLarge SelectedModelType = "large"
 	SelectedModelTypeSmall SelectedModelType = "small"
@@ -112,6 +122,9 @@ type MCPConfig struct {
 	Disabled bool              `json:"disabled,omitempty" jsonschema:"description=Whether this MCP server is disabled,default=false"`
 	Timeout  int               `json:"timeout,omitempty" jsonschema:"description=Timeout in seconds for MCP server connections,default=15,example=30,example=60,example=120"`
 
+	CallTimeout        int `json:"call_timeout,omitempty" jsonschema:"description=Timeout in seconds for MCP tool calls,default=300,example=60"`
+	MaxConcurrentCalls int `json:"max_concurrent_calls,omitempty" jsonschema:"description=Maximum number of tool calls running on the server at once (0 for no limit),default=0,example=1"`
+
 	// TODO: maybe make it possible to get the value from the env
 	Headers map[string]string `json:"headers,omitempty" jsonschema:"description=HTTP headers for HTTP/SSE MCP servers"`
 }
@@ -130,8 +143,7 @@ type LSPConfig struct {
 type TUIOptions struct {
 	CompactMode bool   `json:"compact_mode,omitempty" jsonschema:"description=Enable compact mode for the TUI interface,default=false"`
 	DiffMode    string `json:"diff_mode,omitempty" jsonschema:"description=Diff mode for the TUI interface,enum=unified,enum=split"`
-	// Here we can add themes later or any TUI related options
-	//
+	Theme       string `json:"theme,omitempty" jsonschema:"description=Name of the color theme; custom themes are loaded from the themes directory next to the global config,default=charmtone,example=charmtone"`
 
 	Completions Completions `json:"completions,omitzero" jsonschema:"description=Completions UI options"`
 }
@@ -147,8 +159,27 @@ func (c Completions) Limits() (depth, items int) {
 }
 
 type Permissions struct {
-	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
-	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+	AllowedTools []string         `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
+	Rules        []PermissionRule `json:"rules,omitempty" jsonschema:"description=Rules evaluated in order when a tool asks for permission; the first matching rule decides"`
+	SkipRequests bool             `json:"-"` // Automatically accept all permissions (YOLO mode)
+}
+
+type PermissionAction string
+
+const (
+	PermissionAllow PermissionAction = "allow"
+	PermissionDeny  PermissionAction = "deny"
+	PermissionAsk   PermissionAction = "ask"
+)
+
+// PermissionRule decides permission requests of a tool whose argument (the
+// bash command, the file path or the URL) matches the rule's pattern. A rule
+// without a pattern matches every request of its tool.
+type PermissionRule struct {
+	Tool   string           `json:"tool" jsonschema:"required,description=Name of the tool the rule applies to or * for all tools,example=bash,example=view"`
+	Glob   string           `json:"glob,omitempty" jsonschema:"description=Glob the tool argument must match; * matches any run of characters,example=/tmp/*"`
+	Regex  string           `json:"regex,omitempty" jsonschema:"description=Regular expression the tool argument must match,example=^(go|git) "`
+	Action PermissionAction `json:"action" jsonschema:"required,description=What to do with matching requests,enum=allow,enum=deny,enum=ask"`
 }
 
 type Attribution struct {
@@ -157,16 +188,232 @@ type Attribution struct {
 }
 
 type Options struct {
-	ContextPaths              []string     `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=TULPA.md"`
-	TUI                       *TUIOptions  `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
-	Debug                     bool         `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
-	DebugLSP                  bool         `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
-	DisableAutoSummarize      bool         `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
-	DataDirectory             string       `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.tulpa,example=.tulpa"` // Relative to the cwd
-	DisabledTools             []string     `json:"disabled_tools" jsonschema:"description=Tools to disable"`
-	DisableProviderAutoUpdate bool         `json:"disable_provider_auto_update,omitempty" jsonschema:"description=Disable providers auto-update,default=false"`
-	Attribution               *Attribution `json:"attribution,omitempty" jsonschema:"description=Attribution settings for generated content"`
-	DisableMetrics            bool         `json:"disable_metrics,omitempty" jsonschema:"description=Disable sending metrics,default=false"`
+	ContextPaths              []string          `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=TULPA.md"`
+	TUI                       *TUIOptions       `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
+	Debug                     bool              `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
+	LogLevel                  string            `json:"log_level,omitempty" jsonschema:"description=Minimum level of the messages written to the log file; TULPA_LOG_LEVEL and --debug override it and it overrides debug,enum=debug,enum=info,enum=warn,enum=error,default=info"`
+	DebugLSP                  bool              `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
+	DisableAutoSummarize      bool              `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
+	CompactThreshold          *float64          `json:"compact_threshold,omitempty" jsonschema:"description=Fraction of the context window the conversation may fill before its older messages are replaced with a summary (0 disables),default=0.8,minimum=0,maximum=1,example=0.7"`
+	CompactKeepTurns          *int              `json:"compact_keep_turns,omitempty" jsonschema:"description=How many of the latest turns compacting the conversation keeps as they are; a turn starts with a user message,default=2,minimum=1,example=4"`
+	DataDirectory             string            `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.tulpa,example=.tulpa"` // Relative to the cwd
+	DisabledTools             []string          `json:"disabled_tools" jsonschema:"description=Tools to disable"`
+	DisableProviderAutoUpdate bool              `json:"disable_provider_auto_update,omitempty" jsonschema:"description=Disable providers auto-update,default=false"`
+	Attribution               *Attribution      `json:"attribution,omitempty" jsonschema:"description=Attribution settings for generated content"`
+	DisableMetrics            bool              `json:"disable_metrics,omitempty" jsonschema:"description=Disable sending metrics,default=false"`
+	DefaultAgentModel         SelectedModelType `json:"default_agent_model,omitempty" jsonschema:"description=Model tier used by the default agents created on first run: large or small or a tier defined in models,default=large"`
+	DefaultAgent              string            `json:"default_agent,omitempty" jsonschema:"description=Agent new sessions start with; it must be one of the configured agents,default=coder,example=task"`
+	InactivityTimeout         int               `json:"inactivity_timeout,omitempty" jsonschema:"description=Cancel a run when no tokens or tool events arrive for this many seconds (0 disables),default=0,example=120"`
+	MaxToolIterations         int               `json:"max_tool_iterations,omitempty" jsonschema:"description=How many times an agent may call tools in a turn before it is asked for a final answer; the run fails if it calls tools again (0 disables),default=0,example=50"`
+	ContextMaxFileBytes       *int              `json:"context_max_file_bytes,omitempty" jsonschema:"description=Maximum bytes included from each context file; longer files are truncated (0 disables),default=65536,example=32768"`
+	ContextMaxTotalBytes      *int              `json:"context_max_total_bytes,omitempty" jsonschema:"description=Maximum bytes included from all context files together; files past it are skipped (0 disables),default=262144,example=131072"`
+	RedactPatterns            []string          `json:"redact_patterns,omitempty" jsonschema:"description=Regular expressions whose matches are replaced with [REDACTED] in tool output before it is stored; common API key formats are always redacted,example=internal-[0-9a-f]{32}"`
+	Retry                     *RetryOptions     `json:"retry,omitempty" jsonschema:"description=Retries of provider requests that fail with rate limits or server errors"`
+	BashEnv                   map[string]string `json:"bash_env,omitempty" jsonschema:"description=Environment variables set for the commands of the bash tool only; $NAME or ${NAME} in a value is replaced with the variable of the environment Tulpa runs in"`
+	BashTimeout               *int              `json:"bash_timeout,omitempty" jsonschema:"description=Longest a command of the bash tool may run in seconds before its processes are killed; longer timeouts asked by the model are capped at it,default=600,minimum=1,example=1800"`
+	BashMaxOutput             *int              `json:"bash_max_output,omitempty" jsonschema:"description=Most bytes of the output and of the errors of a bash command kept for the model; the middle of longer output is left out,default=30000,minimum=1,example=60000"`
+	StreamStallTimeout        *int              `json:"stream_stall_timeout,omitempty" jsonschema:"description=Cancel a run when the provider stream sends nothing for this many seconds (0 disables),default=120,example=60"`
+	PromptTokenWarning        *float64          `json:"prompt_token_warning,omitempty" jsonschema:"description=Warn when the system prompt with its context files takes more than this fraction of the context window (0 disables),default=0.25,minimum=0,maximum=1,example=0.1"`
+	MaxSubagentDepth          *int              `json:"max_subagent_depth,omitempty" jsonschema:"description=How many levels deep agents may delegate to subagents with the agent tool,default=2,minimum=1,example=1"`
+	MaxConcurrentSubagents    *int              `json:"max_concurrent_subagents,omitempty" jsonschema:"description=Maximum number of subagents an agent runs at once; further ones wait for a free slot,default=4,minimum=1,example=2"`
+	MaxSessionCost            *float64          `json:"max_session_cost,omitempty" jsonschema:"description=Stop the agent before its next provider request once the session cost this many dollars, subagents and summaries included (0 disables),default=0,minimum=0,example=5"`
+	MaxSessionTokens          *int64            `json:"max_session_tokens,omitempty" jsonschema:"description=Stop the agent before its next provider request once the session used this many input and output tokens since Tulpa started (0 disables),default=0,minimum=0,example=2000000"`
+	SlowConsumerTimeout       *float64          `json:"slow_consumer_timeout,omitempty" jsonschema:"description=Warn when the interface takes more than this many seconds to receive an event; the events waiting meanwhile are counted in the tulpa_events expvar (0 disables),default=2,minimum=0,example=0.5"`
+	EventBufferSize           *int              `json:"event_buffer_size,omitempty" jsonschema:"description=How many events may queue for the interface before the streaming updates waiting behind them are coalesced; a larger buffer absorbs bursts of tool output but lets the interface fall further behind,default=100,minimum=1,maximum=10000,example=500"`
+	StrictConfig              bool              `json:"strict_config,omitempty" jsonschema:"description=Fail to start on any agent config problem, like tool patterns matching no tool, instead of logging it; TULPA_STRICT_CONFIG=1 sets it too,default=false"`
+	WorkspaceRoots            []string          `json:"workspace_roots,omitempty" jsonschema:"description=Other directories that are part of the workspace besides the working directory; relative paths are resolved against the working directory,example=../api"`
+	AllowOutsideWorkspace     bool              `json:"allow_outside_workspace,omitempty" jsonschema:"description=Let file tools access paths outside the workspace roots after asking for permission instead of refusing them,default=false"`
+	Retention                 *RetentionOptions `json:"retention,omitempty" jsonschema:"description=Limits of the sessions kept in the database; older sessions are deleted when Tulpa starts and with tulpa prune. Starred sessions and sessions with pinned messages are kept"`
+	RejectBusyPrompts         bool              `json:"reject_busy_prompts,omitempty" jsonschema:"description=Refuse the prompts sent to a session while it runs a turn instead of queueing them behind it,default=false"`
+	GitCheckpoints            bool              `json:"git_checkpoints,omitempty" jsonschema:"description=Commit the changes made in each turn of a session to its tulpa/session-<id> git branch when the working directory is in a git repository; the current branch and the index are left alone,default=false"`
+}
+
+// Default byte budgets for the context files included in the system prompt.
+const (
+	DefaultContextMaxFileBytes  = 64 * 1024
+	DefaultContextMaxTotalBytes = 256 * 1024
+)
+
+// ContextLimits returns the per-file and total byte budgets for the context
+// files included in the system prompt. Zero means no limit.
+func (o *Options) ContextLimits() (perFile, total int) {
+	if o == nil {
+		return DefaultContextMaxFileBytes, DefaultContextMaxTotalBytes
+	}
+	return max(ptrValOr(o.ContextMaxFileBytes, DefaultContextMaxFileBytes), 0),
+		max(ptrValOr(o.ContextMaxTotalBytes, DefaultContextMaxTotalBytes), 0)
+}
+
+// Defaults of the limits of the commands of the bash tool.
+const (
+	DefaultBashTimeout   = 10 * time.Minute
+	DefaultBashMaxOutput = 30000
+)
+
+// BashLimits returns the longest a command of the bash tool may run and the
+// most bytes of its output and of its errors kept for the model. Values that
+// aren't positive use the defaults, a command is never left unbounded.
+func (o *Options) BashLimits() (timeout time.Duration, maxOutput int) {
+	if o == nil {
+		return DefaultBashTimeout, DefaultBashMaxOutput
+	}
+	timeout = DefaultBashTimeout
+	if seconds := ptrValOr(o.BashTimeout, 0); seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	maxOutput = DefaultBashMaxOutput
+	if bytes := ptrValOr(o.BashMaxOutput, 0); bytes > 0 {
+		maxOutput = bytes
+	}
+	return timeout, maxOutput
+}
+
+// DefaultStreamStallTimeout is how long a provider stream may send nothing
+// before the run is canceled.
+const DefaultStreamStallTimeout = 120 * time.Second
+
+// StallTimeout returns how long a provider stream may send nothing before
+// the run is canceled. Zero disables the check.
+func (o *Options) StallTimeout() time.Duration {
+	if o == nil || o.StreamStallTimeout == nil {
+		return DefaultStreamStallTimeout
+	}
+	return time.Duration(max(*o.StreamStallTimeout, 0)) * time.Second
+}
+
+// DefaultSlowConsumerTimeout is how long the interface may take to receive
+// an event before it's considered slow.
+const DefaultSlowConsumerTimeout = 2 * time.Second
+
+// SlowConsumerWait returns how long the interface may take to receive an
+// event before it's considered slow. Zero disables the warning.
+func (o *Options) SlowConsumerWait() time.Duration {
+	if o == nil || o.SlowConsumerTimeout == nil {
+		return DefaultSlowConsumerTimeout
+	}
+	return time.Duration(max(*o.SlowConsumerTimeout, 0) * float64(time.Second))
+}
+
+// Bounds of the buffer of events queued for the interface.
+const (
+	DefaultEventBufferSize = 100
+	MaxEventBufferSize     = 10000
+)
+
+// EventBuffer returns how many events may queue for the interface. Once it's
+// full, events wait in their subscription, where the updates of the same
+// item are coalesced and the waits longer than [Options.SlowConsumerWait]
+// are logged. It fails when the configured size is out of bounds.
+func (o *Options) EventBuffer() (int, error) {
+	if o == nil || o.EventBufferSize == nil {
+		return DefaultEventBufferSize, nil
+	}
+	size := *o.EventBufferSize
+	if size < 1 || size > MaxEventBufferSize {
+		return 0, fmt.Errorf("event_buffer_size must be between 1 and %d, got %d", MaxEventBufferSize, size)
+	}
+	return size, nil
+}
+
+// DefaultPromptTokenWarning is the fraction of the context window the system
+// prompt may take before a warning is shown.
+const DefaultPromptTokenWarning = 0.25
+
+// PromptTokenWarningRatio returns the fraction of the context window the
+// system prompt may take before a warning is shown. Zero disables it.
+func (o *Options) PromptTokenWarningRatio() float64 {
+	if o == nil || o.PromptTokenWarning == nil {
+		return DefaultPromptTokenWarning
+	}
+	return max(*o.PromptTokenWarning, 0)
+}
+
+// Default limits of the subagents started with the agent tool.
+const (
+	DefaultMaxSubagentDepth       = 2
+	DefaultMaxConcurrentSubagents = 4
+)
+
+// SubagentLimits returns how many levels deep agents may delegate to
+// subagents and how many subagents an agent runs at once.
+func (o *Options) SubagentLimits() (depth, concurrent int) {
+	if o == nil {
+		return DefaultMaxSubagentDepth, DefaultMaxConcurrentSubagents
+	}
+	return max(ptrValOr(o.MaxSubagentDepth, DefaultMaxSubagentDepth), 1),
+		max(ptrValOr(o.MaxConcurrentSubagents, DefaultMaxConcurrentSubagents), 1)
+}
+
+// Default compaction of conversations filling the context window.
+const (
+	DefaultCompactThreshold = 0.8
+	DefaultCompactKeepTurns = 2
+)
+
+// Compaction returns the fraction of the context window a conversation may
+// fill before it is compacted, zero when it never is, and how many of its
+// latest turns compaction keeps.
+func (o *Options) Compaction() (threshold float64, keepTurns int) {
+	if o == nil {
+		return DefaultCompactThreshold, DefaultCompactKeepTurns
+	}
+	if o.DisableAutoSummarize {
+		return 0, 0
+	}
+	threshold = min(max(ptrValOr(o.CompactThreshold, DefaultCompactThreshold), 0), 1)
+	return threshold, max(ptrValOr(o.CompactKeepTurns, DefaultCompactKeepTurns), 1)
+}
+
+// SessionBudget returns how many dollars and tokens a session may spend
+// before the agent stops. Zero means no limit.
+func (o *Options) SessionBudget() (maxCost float64, maxTokens int64) {
+	if o == nil {
+		return 0, 0
+	}
+	return max(ptrValOr(o.MaxSessionCost, 0), 0), max(ptrValOr(o.MaxSessionTokens, 0), 0)
+}
+
+// Default retry policy for provider requests that fail with transient
+// errors.
+const (
+	DefaultMaxRetries     = 3
+	DefaultRetryBaseDelay = 2 * time.Second
+)
+
+type RetryOptions struct {
+	MaxRetries  *int `json:"max_retries,omitempty" jsonschema:"description=How many times a request is retried before the run fails (0 disables retries),default=3,example=5"`
+	BaseDelayMs *int `json:"base_delay_ms,omitempty" jsonschema:"description=Delay before the first retry in milliseconds; it doubles with every retry,default=2000,example=1000"`
+}
+
+// RetryPolicy returns how many times provider requests failing with
+// transient errors are retried and the delay before the first retry.
+func (o *Options) RetryPolicy() (maxRetries int, baseDelay time.Duration) {
+	if o == nil || o.Retry == nil {
+		return DefaultMaxRetries, DefaultRetryBaseDelay
+	}
+	maxRetries = max(ptrValOr(o.Retry.MaxRetries, DefaultMaxRetries), 0)
+	baseDelay = DefaultRetryBaseDelay
+	if o.Retry.BaseDelayMs != nil {
+		baseDelay = time.Duration(max(*o.Retry.BaseDelayMs, 0)) * time.Millisecond
+	}
+	return maxRetries, baseDelay
+}
+
+type RetentionOptions struct {
+	MaxSessions  int `json:"max_sessions,omitempty" jsonschema:"description=Most sessions kept; the least recently updated ones are deleted (0 keeps all),default=0,minimum=0,example=500"`
+	MaxAgeDays   int `json:"max_age_days,omitempty" jsonschema:"description=Delete the sessions not updated for this many days (0 keeps all),default=0,minimum=0,example=90"`
+	MaxDBSizeMiB int `json:"max_db_size_mib,omitempty" jsonschema:"description=Delete the least recently updated sessions until the database takes less than this many MiB (0 disables),default=0,minimum=0,example=256"`
+}
+
+// RetentionLimits returns the most sessions kept in the database, how long
+// they are kept after their last update and the most bytes the database may
+// take. Zero disables a limit.
+func (o *Options) RetentionLimits() (maxSessions int, maxAge time.Duration, maxSize int64) {
+	if o == nil || o.Retention == nil {
+		return 0, 0, 0
+	}
+	r := o.Retention
+	return max(r.MaxSessions, 0), time.Duration(max(r.MaxAgeDays, 0)) * 24 * time.Hour, int64(max(r.MaxDBSizeMiB, 0)) * 1024 * 1024
 }
 
 type MCPs map[string]MCPConfig
@@ -239,7 +486,10 @@ type Agent struct {
 	// This is the id of the system prompt used by the agent
 	Disabled bool `json:"disabled,omitempty"`
 
-	Model SelectedModelType `json:"model" jsonschema:"required,description=The model type to use for this agent,enum=large,enum=small,default=large"`
+	Model SelectedModelType `json:"model" jsonschema:"required,description=The model tier to use for this agent: large or small or a tier defined in models,default=large"`
+
+	// Model tiers tried in order when the previous model is unavailable
+	FallbackModels []SelectedModelType `json:"fallback_models,omitempty"`
 
 	// The available tools for the agent
 	//  if this is nil, all tools are available
@@ -255,12 +505,47 @@ type Agent struct {
 	//  if this is nil, all LSPs are available
 	AllowedLSP []string `json:"allowed_lsp,omitempty"`
 
-	// Overrides the context paths for this agent
-	ContextPaths []string `json:"context_paths,omitempty"`
+	// Restricts the commands this agent runs with the bash tool
+	BashCommands BashCommandRules `json:"bash_commands,omitzero"`
+
+	// The agents this agent may start with the agent tool, and the one
+	// started when the tool names none
+	Subagents       []string `json:"subagents,omitempty"`
+	DefaultSubagent string   `json:"default_subagent,omitempty"`
+
+	// Overrides the context paths for this agent, or is added to them when
+	// ContextPathsMode is merge
+	ContextPaths     []string `json:"context_paths,omitempty"`
+	ContextPathsMode string   `json:"context_paths_mode,omitempty"`
+
+	// Overrides the inactivity timeout in seconds for this agent, a negative
+	// value disables it
+	InactivityTimeout int `json:"inactivity_timeout,omitempty"`
+
+	// Overrides how many times this agent may call tools in a turn, a
+	// negative value removes the limit
+	MaxToolIterations int `json:"max_tool_iterations,omitempty"`
+
+	// Environment variables set for the commands of this agent's bash tool,
+	// over those of the options
+	BashEnv map[string]string `json:"bash_env,omitempty"`
+
+	// The format of the agent's final response, text or json
+	ResponseFormat string `json:"response_format,omitempty"`
+
+	// The OpenAI-compatible API serving the model of the agent, if any
+	Endpoint *AgentEndpoint `json:"endpoint,omitempty"`
+
+	// The YAML file the agent was loaded from, if any
+	ConfigPath string `json:"-"`
+	// Whether that file is global or ships with the project:
+	// [AgentSourceGlobal] or [AgentSourceProject]
+	Source string `json:"source,omitempty"`
 }
 
 type Tools struct {
-	Ls ToolLs `json:"ls,omitzero"`
+	Ls     ToolLs     `json:"ls,omitzero"`
+	Backup ToolBackup `json:"backup,omitzero"`
 }
 
 type ToolLs struct {
@@ -272,12 +557,21 @@ func (t ToolLs) Limits() (depth, items int) {
 	return ptrValOr(t.MaxDepth, -1), ptrValOr(t.MaxItems, -1)
 }
 
+type ToolBackup struct {
+	Enabled    bool `json:"enabled,omitempty" jsonschema:"description=Back up files under the data directory before the write and edit tools overwrite them,default=false"`
+	MaxPerFile *int `json:"max_per_file,omitempty" jsonschema:"description=Maximum number of backups kept per file,default=10,example=5"`
+}
+
+func (t ToolBackup) Limit() int {
+	return ptrValOr(t.MaxPerFile, backup.DefaultMaxPerFile)
+}
+
 // Config holds the configuration for tulpa.
 type Config struct {
 	Schema string `json:"$schema,omitempty"`
 
 	// We currently only support large/small as values here.
-	Models map[SelectedModelType]SelectedModel `json:"models,omitempty" jsonschema:"description=Model configurations for different model types,example={\"large\":{\"model\":\"gpt-4o\",\"provider\":\"openai\"}}"`
+	Models map[SelectedModelType]SelectedModel `json:"models,omitempty" jsonschema:"description=Model configurations for the large and small tiers and for any named tier agents can use,example={\"large\":{\"model\":\"gpt-4o\",\"provider\":\"openai\"}}"`
 
 	// The providers that are configured
 	Providers *csync.Map[string, ProviderConfig] `json:"providers,omitempty" jsonschema:"description=AI provider configurations"`
@@ -292,22 +586,59 @@ type Config struct {
 
 	Tools Tools `json:"tools,omitzero" jsonschema:"description=Tool configurations"`
 
+	Keybindings map[string][]string `json:"keybindings,omitempty" jsonschema:"description=Keys for TUI actions by action name; each entry replaces the default keys of that action,example={\"sessions\":[\"ctrl+o\"]}"`
+
 	// Internal
 	workingDir string `json:"-"`
-	// TODO: most likely remove this concept when I come back to it
-	Agents map[string]Agent `json:"-"`
-	// Agent prompts loaded from YAML configs
-	AgentPrompts map[string]string `json:"-"`
+	// Agents and their prompts loaded from YAML configs, replaced as a whole
+	// when the configs are reloaded
+	agents atomic.Pointer[agentSet] `json:"-"`
 	// TODO: find a better way to do this this should probably not be part of the config
 	resolver       VariableResolver
 	dataConfigDir  string             `json:"-"`
 	knownProviders []catwalk.Provider `json:"-"`
+	// Config files considered while loading, lowest priority first
+	configPaths []string `json:"-"`
+	// Settings overridden outside of the config files
+	overrides map[string]SettingSource `json:"-"`
+}
+
+// defaultAgentID is the agent new sessions start with when the config names
+// none.
+const defaultAgentID = "coder"
+
+// DefaultAgentID returns the ID of the agent new sessions start with.
+func (c *Config) DefaultAgentID() string {
+	if c.Options == nil {
+		return defaultAgentID
+	}
+	return cmp.Or(c.Options.DefaultAgent, defaultAgentID)
 }
 
 func (c *Config) WorkingDir() string {
 	return c.workingDir
 }
 
+// WorkspaceRoots returns the working directory followed by the other
+// workspace roots, made absolute and without duplicates.
+func (c *Config) WorkspaceRoots() []string {
+	roots := []string{c.workingDir}
+	if c.Options == nil {
+		return roots
+	}
+	for _, root := range c.Options.WorkspaceRoots {
+		root = home.Long(root)
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(c.workingDir, root)
+		}
+		root = filepath.Clean(root)
+		if !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
 func (c *Config) EnabledProviders() []ProviderConfig {
 	var enabled []ProviderConfig
 	for p := range c.Providers.Seq() {
@@ -335,7 +666,7 @@ func (c *Config) GetModel(provider, model string) *catwalk.Model {
 }
 
 func (c *Config) GetProviderForModel(modelType SelectedModelType) *ProviderConfig {
-	model, ok := c.Models[modelType]
+	model, ok := c.SelectedModel(modelType)
 	if !ok {
 		return nil
 	}
@@ -346,7 +677,7 @@ func (c *Config) GetProviderForModel(modelType SelectedModelType) *ProviderConfi
 }
 
 func (c *Config) GetModelByType(modelType SelectedModelType) *catwalk.Model {
-	model, ok := c.Models[modelType]
+	model, ok := c.SelectedModel(modelType)
 	if !ok {
 		return nil
 	}
@@ -460,13 +791,16 @@ func allToolNames() []string {
 	return []string{
 		"agent",
 		"bash",
+		"diagnostics",
 		"download",
 		"edit",
 		"multiedit",
 		"fetch",
 		"glob",
 		"grep",
+		"jobs",
 		"ls",
+		"progress",
 		"sourcegraph",
 		"view",
 		"write",
@@ -481,8 +815,6 @@ func resolveAllowedTools(allTools []string, disabledTools []string) []string {
 	return filterSlice(allTools, disabledTools, false)
 }
 
-
-
 func filterSlice(data []string, mask []string, include bool) []string {
 	filtered := []string{}
 	for _, s := range data {
@@ -495,37 +827,382 @@ func filterSlice(data []string, mask []string, include bool) []string {
 	return filtered
 }
 
+// strictConfig reports whether agent config problems that are otherwise
+// logged fail loading the agents.
+func (c *Config) strictConfig() bool {
+	return (c.Options != nil && c.Options.StrictConfig) || strictConfigEnv()
+}
+
+func strictConfigEnv() bool {
+	strict, _ := strconv.ParseBool(os.Getenv("TULPA_STRICT_CONFIG"))
+	return strict
+}
+
+// defaultAgentModel returns the model settings written into the default agent
+// configs, honoring the configured default tier and the provider selected for
+// it.
+func (c *Config) defaultAgentModel() AgentModelConfig {
+	modelType := SelectedModelTypeLarge
+	if c.Options != nil && c.Options.DefaultAgentModel != "" {
+		if c.hasModelTier(c.agents.Load(), c.Options.DefaultAgentModel) {
+			modelType = c.Options.DefaultAgentModel
+		} else {
+			slog.Warn("Invalid default agent model, using large", "model", c.Options.DefaultAgentModel)
+		}
+	}
+
+	model := AgentModelConfig{Type: string(modelType)}
+	if selected, ok := c.SelectedModel(modelType); ok {
+		model.Provider = selected.Provider
+	}
+	return model
+}
+
+// SelectedModel returns the model of a tier: one of the models config, or
+// one the agents registered for their endpoints and fallback models.
+func (c *Config) SelectedModel(tier SelectedModelType) (SelectedModel, bool) {
+	return c.selectedModel(c.agents.Load(), tier)
+}
+
+// selectedModel returns the model of a tier of the models config or of the
+// given set of agents, which may be nil.
+func (c *Config) selectedModel(set *agentSet, tier SelectedModelType) (SelectedModel, bool) {
+	if model, ok := c.Models[tier]; ok {
+		return model, true
+	}
+	if set != nil {
+		model, ok := set.models[tier]
+		return model, ok
+	}
+	return SelectedModel{}, false
+}
+
+// hasModelTier reports whether agents may use the given model tier: large,
+// small, one of the tiers defined in the models config or one registered by
+// the given set of agents.
+func (c *Config) hasModelTier(set *agentSet, tier SelectedModelType) bool {
+	if tier == SelectedModelTypeLarge || tier == SelectedModelTypeSmall {
+		return true
+	}
+	_, ok := c.selectedModel(set, tier)
+	return ok
+}
+
+// agentProvider returns the provider the given set of agents registered
+// with the ID, or else the configured one.
+func (c *Config) agentProvider(set *agentSet, id string) (ProviderConfig, bool) {
+	if provider, ok := set.providers[id]; ok {
+		return provider, true
+	}
+	if c.Providers == nil {
+		return ProviderConfig{}, false
+	}
+	return c.Providers.Get(id)
+}
+
+// ensureFallbackTier checks that a fallback model tier exists, registering
+// provider/model pairs as tiers of their own in the set of agents.
+func (c *Config) ensureFallbackTier(set *agentSet, tier SelectedModelType) error {
+	if c.hasModelTier(set, tier) {
+		return nil
+	}
+	providerID, modelID, ok := strings.Cut(string(tier), "/")
+	if !ok {
+		return fmt.Errorf("unknown model tier %q, known tiers: %s", tier, strings.Join(c.modelTiers(set), ", "))
+	}
+	provider, _ := c.agentProvider(set, providerID)
+	i := slices.IndexFunc(provider.Models, func(m catwalk.Model) bool { return m.ID == modelID })
+	if i < 0 {
+		return fmt.Errorf("model %q not found for provider %q", modelID, providerID)
+	}
+	set.models[tier] = SelectedModel{
+		Provider:  providerID,
+		Model:     modelID,
+		MaxTokens: provider.Models[i].DefaultMaxTokens,
+	}
+	return nil
+}
+
+// AgentEndpoint is an OpenAI-compatible API serving the model of an agent,
+// registered as a provider when the agents are loaded.
+type AgentEndpoint struct {
+	Provider  string `json:"provider"`
+	BaseURL   string `json:"base_url"`
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	Model     string `json:"model"`
+}
+
+func (e AgentEndpoint) tier() SelectedModelType {
+	return SelectedModelType(e.Provider + "/" + e.Model)
+}
+
+// Model settings assumed for the models of agent endpoints, which don't
+// report theirs.
+const (
+	DefaultEndpointContextWindow = 128_000
+	DefaultEndpointMaxTokens     = 4096
+)
+
+// registerEndpoint adds the endpoint of an agent to the set of agents as an
+// OpenAI provider, or adds its model to the provider with the same ID and
+// base URL, and registers the model tier the agent uses.
+func (c *Config) registerEndpoint(set *agentSet, e AgentEndpoint) error {
+	provider, ok := c.agentProvider(set, e.Provider)
+	switch {
+	case !ok:
+		provider = ProviderConfig{ID: e.Provider, Name: e.Provider, Type: catwalk.TypeOpenAI, BaseURL: e.BaseURL}
+	case strings.TrimSuffix(provider.BaseURL, "/") != strings.TrimSuffix(e.BaseURL, "/"):
+		return fmt.Errorf("provider %q is already configured with base URL %s, not %s", e.Provider, provider.BaseURL, e.BaseURL)
+	}
+	if e.APIKeyEnv != "" {
+		if key := "$" + e.APIKeyEnv; provider.APIKey != "" && provider.APIKey != key {
+			return fmt.Errorf("provider %q is already configured with another API key than %s", e.Provider, key)
+		}
+		provider.APIKey = "$" + e.APIKeyEnv
+	}
+	i := slices.IndexFunc(provider.Models, func(m catwalk.Model) bool { return m.ID == e.Model })
+	if i < 0 {
+		provider.Models = append(provider.Models, catwalk.Model{
+			ID:               e.Model,
+			Name:             e.Model,
+			ContextWindow:    DefaultEndpointContextWindow,
+			DefaultMaxTokens: DefaultEndpointMaxTokens,
+		})
+		i = len(provider.Models) - 1
+	}
+	set.providers[e.Provider] = provider
+	set.models[e.tier()] = SelectedModel{
+		Provider:  e.Provider,
+		Model:     e.Model,
+		MaxTokens: provider.Models[i].DefaultMaxTokens,
+	}
+	return nil
+}
+
+// modelTiers returns the names of the model tiers agents may use, sorted.
+func (c *Config) modelTiers(set *agentSet) []string {
+	tiers := []string{string(SelectedModelTypeLarge), string(SelectedModelTypeSmall)}
+	for tier := range c.Models {
+		if !slices.Contains(tiers, string(tier)) {
+			tiers = append(tiers, string(tier))
+		}
+	}
+	if set != nil {
+		for tier := range set.models {
+			if !slices.Contains(tiers, string(tier)) {
+				tiers = append(tiers, string(tier))
+			}
+		}
+	}
+	slices.Sort(tiers)
+	return tiers
+}
+
+// InactivityTimeout returns how long a run of the given agent may go without
+// any streaming or tool event before it is canceled. Zero means no limit.
+func (c *Config) InactivityTimeout(agentCfg Agent) time.Duration {
+	seconds := 0
+	if c.Options != nil {
+		seconds = c.Options.InactivityTimeout
+	}
+	if agentCfg.InactivityTimeout != 0 {
+		seconds = agentCfg.InactivityTimeout
+	}
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// MaxToolIterations returns how many times the given agent may call tools in
+// a turn. Zero means no limit.
+func (c *Config) MaxToolIterations(agentCfg Agent) int {
+	limit := 0
+	if c.Options != nil {
+		limit = c.Options.MaxToolIterations
+	}
+	if agentCfg.MaxToolIterations != 0 {
+		limit = agentCfg.MaxToolIterations
+	}
+	return max(limit, 0)
+}
+
+// BashEnv returns the environment variables set for the commands of the bash
+// tool of the given agent: those of the options overridden by the agent's.
+func (c *Config) BashEnv(agentCfg Agent) map[string]string {
+	var env map[string]string
+	if c.Options != nil && len(c.Options.BashEnv) > 0 {
+		env = maps.Clone(c.Options.BashEnv)
+	}
+	if len(agentCfg.BashEnv) > 0 {
+		if env == nil {
+			env = make(map[string]string, len(agentCfg.BashEnv))
+		}
+		maps.Copy(env, agentCfg.BashEnv)
+	}
+	return env
+}
+
+// agentSet is a loaded set of agents with their prompts, and the model
+// tiers and providers registered for their endpoints and fallback models.
+type agentSet struct {
+	agents    map[string]Agent
+	prompts   map[string]string
+	models    map[SelectedModelType]SelectedModel
+	providers map[string]ProviderConfig
+}
+
+func newAgentSet() *agentSet {
+	return &agentSet{
+		models:    make(map[SelectedModelType]SelectedModel),
+		providers: make(map[string]ProviderConfig),
+	}
+}
+
+// Agents returns the loaded agents by ID. Reloading the agent configs
+// replaces the map rather than modifying it, so it must not be modified.
+func (c *Config) Agents() map[string]Agent {
+	if set := c.agents.Load(); set != nil {
+		return set.agents
+	}
+	return nil
+}
+
+// AgentPrompts returns the prompts of the loaded agents by agent ID, the
+// same way as [Config.Agents].
+func (c *Config) AgentPrompts() map[string]string {
+	if set := c.agents.Load(); set != nil {
+		return set.prompts
+	}
+	return nil
+}
+
+// SetAgents replaces the agents and their prompts at once, so concurrent
+// readers see either the previous or the new ones. The model tiers the
+// previous agents registered are kept.
+func (c *Config) SetAgents(agents map[string]Agent, prompts map[string]string) {
+	set := newAgentSet()
+	if prev := c.agents.Load(); prev != nil {
+		set.models = prev.models
+	}
+	set.agents, set.prompts = agents, prompts
+	c.agents.Store(set)
+}
+
+// storeAgents publishes a loaded set of agents. The providers it registered
+// are added first, so readers of the new agents find them.
+func (c *Config) storeAgents(set *agentSet) {
+	if len(set.providers) > 0 && c.Providers == nil {
+		c.Providers = csync.NewMap[string, ProviderConfig]()
+	}
+	for id, provider := range set.providers {
+		c.Providers.Set(id, provider)
+	}
+	c.agents.Store(set)
+}
+
 func (c *Config) SetupAgents() error {
+	set, err := c.loadAgents()
+	if err != nil {
+		return err
+	}
+
+	c.storeAgents(set)
+	return nil
+}
+
+// loadAgents loads the agents from their YAML configs and applies the global
+// tool and context path settings to them. It leaves the config unchanged:
+// the model tiers and providers the agents need are registered in the
+// returned set, to be published with them.
+func (c *Config) loadAgents() (*agentSet, error) {
+	strict := c.strictConfig()
+	set := newAgentSet()
+	if strict && c.Options.DefaultAgentModel != "" && !c.hasModelTier(set, c.Options.DefaultAgentModel) {
+		return nil, fmt.Errorf("agent configuration error: default_agent_model %q is not a model tier, known tiers: %s", c.Options.DefaultAgentModel, strings.Join(c.modelTiers(set), ", "))
+	}
+
 	// Try to load agents from YAML configs
-	agents, prompts, err := LoadAgentsFromDirectory()
+	agents, prompts, err := loadAgentsFromDirectory(c.workingDir, c.defaultAgentModel(), strict)
 	if err != nil {
 		// Do NOT fall back to hardcoded agents
 		// If YAML files exist but are invalid, the user must fix them
-		return fmt.Errorf("agent configuration error: %w", err)
+		return nil, fmt.Errorf("agent configuration error: %w", err)
 	}
 
 	// Apply disabled tools filter and context paths to all agents
 	allTools := allToolNames()
 	for id, agent := range agents {
+		if agent.Endpoint != nil {
+			if err := c.registerEndpoint(set, *agent.Endpoint); err != nil {
+				return nil, fmt.Errorf("agent configuration error: agent %q: %w", id, err)
+			}
+		}
+		if !c.hasModelTier(set, agent.Model) {
+			return nil, fmt.Errorf("agent configuration error: agent %q uses unknown model tier %q, known tiers: %s", id, agent.Model, strings.Join(c.modelTiers(set), ", "))
+		}
+		for _, tier := range agent.FallbackModels {
+			if err := c.ensureFallbackTier(set, tier); err != nil {
+				return nil, fmt.Errorf("agent configuration error: agent %q fallback: %w", id, err)
+			}
+		}
+
 		// Apply disabled tools filter if AllowedTools is set
-		if len(agent.AllowedTools) > 0 {
+		if agent.AllowedTools != nil {
 			agent.AllowedTools = resolveAllowedTools(agent.AllowedTools, c.Options.DisabledTools)
 		} else {
 			// If no tools specified, use all tools minus disabled
 			agent.AllowedTools = resolveAllowedTools(allTools, c.Options.DisabledTools)
 		}
 
-		// Override context paths if not set in YAML
-		if agent.ContextPaths == nil || len(agent.ContextPaths) == 0 {
+		// Agents allowed to use the agent tool delegate to the task agent
+		// unless they name their subagents
+		if len(agent.Subagents) == 0 && slices.Contains(agent.AllowedTools, "agent") {
+			if _, ok := agents["task"]; ok {
+				agent.Subagents = []string{"task"}
+			}
+		}
+		// Unknown subagents are left out unless strict, the agent delegates
+		// to the others.
+		var subagents []string
+		for _, subagent := range agent.Subagents {
+			if _, ok := agents[subagent]; ok {
+				subagents = append(subagents, subagent)
+				continue
+			}
+			if subagent == agent.DefaultSubagent {
+				if strict {
+					return nil, fmt.Errorf("agent configuration error: agent %q has unknown default subagent %q", id, subagent)
+				}
+				slog.Warn("Agent has an unknown default subagent, leaving it out", "agent", id, "subagent", subagent)
+				continue
+			}
+			if strict {
+				return nil, fmt.Errorf("agent configuration error: agent %q allows unknown subagent %q", id, subagent)
+			}
+			slog.Warn("Agent allows an unknown subagent, leaving it out", "agent", id, "subagent", subagent)
+		}
+		agent.Subagents = subagents
+		if !slices.Contains(subagents, agent.DefaultSubagent) {
+			agent.DefaultSubagent = ""
+		}
+
+		// Use the global context paths if not set in YAML, or add them
+		switch {
+		case len(agent.ContextPaths) == 0:
 			agent.ContextPaths = c.Options.ContextPaths
+		case agent.ContextPathsMode == ContextPathsModeMerge:
+			agent.ContextPaths = mergeNames(c.Options.ContextPaths, agent.ContextPaths)
 		}
 
 		agents[id] = agent
 	}
 
-	c.Agents = agents
-	c.AgentPrompts = prompts
-	return nil
+	if _, ok := agents[c.DefaultAgentID()]; c.Options.DefaultAgent != "" && !ok {
+		return nil, fmt.Errorf("agent configuration error: default_agent %q is not a configured agent, known agents: %s", c.Options.DefaultAgent, strings.Join(slices.Sorted(maps.Keys(agents)), ", "))
+	}
+	set.agents, set.prompts = agents, prompts
+	return set, nil
 }
 
 func (c *Config) Resolver() VariableResolver {
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"slices"
 	"strconv"
@@ -38,9 +39,58 @@ func LoadReader(fd io.Reader) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, warning := range unknownLSPKeys(data) {
+		slog.Warn(warning)
+	}
 	return &config, err
 }
 
+// lspKeyHints maps keys often used by mistake in LSP configurations to the
+// ones meant.
+var lspKeyHints = map[string]string{
+	"initializationOptions":  "init_options",
+	"initialization_options": "init_options",
+	"initOptions":            "init_options",
+	"settings":               "options",
+	"fileTypes":              "filetypes",
+	"file_types":             "filetypes",
+	"rootMarkers":            "root_markers",
+}
+
+// unknownLSPKeys returns a warning for every key of the LSP configurations
+// in data that LSPConfig doesn't define. Those keys are ignored: settings
+// for the server itself belong in init_options or options.
+func unknownLSPKeys(data []byte) []string {
+	var raw struct {
+		LSP map[string]map[string]json.RawMessage `json:"lsp"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	t := reflect.TypeFor[LSPConfig]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		known[name] = true
+	}
+
+	var warnings []string
+	for _, name := range slices.Sorted(maps.Keys(raw.LSP)) {
+		for _, key := range slices.Sorted(maps.Keys(raw.LSP[name])) {
+			if known[key] {
+				continue
+			}
+			warning := fmt.Sprintf("lsp %q: ignoring unknown key %q", name, key)
+			if hint, ok := lspKeyHints[key]; ok {
+				warning += fmt.Sprintf(", did you mean %q?", hint)
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
 // Load loads the configuration from the default paths.
 func Load(workingDir, dataDir string, debug bool) (*Config, error) {
 	configPaths := lookupConfigs(workingDir)
@@ -51,17 +101,27 @@ func Load(workingDir, dataDir string, debug bool) (*Config, error) {
 	}
 
 	cfg.dataConfigDir = GlobalConfigData()
+	cfg.configPaths = configPaths
 
 	cfg.setDefaults(workingDir, dataDir)
 
+	if dataDir != "" {
+		cfg.recordOverride("options.data_directory", dataDir, "flag --data-dir")
+	}
+	configDebug := cfg.Options.Debug
 	if debug {
 		cfg.Options.Debug = true
+		cfg.recordOverride("options.debug", "true", "flag --debug")
 	}
 
 	// Setup logs
+	level, err := log.ResolveLevel(debug, cfg.Options.LogLevel, configDebug, slog.LevelInfo)
+	if err != nil {
+		return nil, err
+	}
 	log.Setup(
 		filepath.Join(cfg.Options.DataDirectory, "logs", fmt.Sprintf("%s.log", appName)),
-		cfg.Options.Debug,
+		level,
 	)
 
 	if !isInsideWorktree() {
@@ -351,6 +411,11 @@ func (c *Config) setDefaults(workingDir, dataDir string) {
 
 	if str, ok := os.LookupEnv("TULPA_DISABLE_PROVIDER_AUTO_UPDATE"); ok {
 		c.Options.DisableProviderAutoUpdate, _ = strconv.ParseBool(str)
+		c.recordOverride(
+			"options.disable_provider_auto_update",
+			strconv.FormatBool(c.Options.DisableProviderAutoUpdate),
+			"env TULPA_DISABLE_PROVIDER_AUTO_UPDATE",
+		)
 	}
 }
 
@@ -529,6 +594,28 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 	}
 	c.Models[SelectedModelTypeLarge] = large
 	c.Models[SelectedModelTypeSmall] = small
+
+	// Other tiers are named shortcuts to a model, so unlike large and small
+	// they have no default to fall back to.
+	for tier, selected := range c.Models {
+		if tier == SelectedModelTypeLarge || tier == SelectedModelTypeSmall {
+			continue
+		}
+		if selected.Model == "" {
+			return fmt.Errorf("model tier %q has no model", tier)
+		}
+		if selected.Provider == "" {
+			selected.Provider = large.Provider
+		}
+		model := c.GetModel(selected.Provider, selected.Model)
+		if model == nil {
+			return fmt.Errorf("model tier %q: model %q not found for provider %q", tier, selected.Model, selected.Provider)
+		}
+		if selected.MaxTokens <= 0 {
+			selected.MaxTokens = model.DefaultMaxTokens
+		}
+		c.Models[tier] = selected
+	}
 	return nil
 }
 
@@ -633,6 +720,11 @@ func GlobalConfig() string {
 	return filepath.Join(home.Dir(), ".config", appName, fmt.Sprintf("%s.json", appName))
 }
 
+// ThemesConfigDir returns the directory custom color themes are loaded from.
+func ThemesConfigDir() string {
+	return filepath.Join(filepath.Dir(GlobalConfig()), "themes")
+}
+
 // GlobalConfigData returns the path to the main data directory for the application.
 // this config is used when the app overrides configurations instead of updating the global config.
 func GlobalConfigData() string {
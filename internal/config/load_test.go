@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/stretchr/testify/assert"
@@ -463,11 +464,11 @@ func TestConfig_setupAgentsWithNoDisabledTools(t *testing.T) {
 
 	err := cfg.SetupAgents()
 	require.NoError(t, err)
-	coderAgent, ok := cfg.Agents["coder"]
+	coderAgent, ok := cfg.Agents()["coder"]
 	require.True(t, ok)
 	assert.Equal(t, allToolNames(), coderAgent.AllowedTools)
 
-	taskAgent, ok := cfg.Agents["task"]
+	taskAgent, ok := cfg.Agents()["task"]
 	require.True(t, ok)
 	assert.Equal(t, []string{"glob", "grep", "ls", "sourcegraph", "view"}, taskAgent.AllowedTools)
 }
@@ -485,11 +486,11 @@ func TestConfig_setupAgentsWithDisabledTools(t *testing.T) {
 
 	err := cfg.SetupAgents()
 	require.NoError(t, err)
-	coderAgent, ok := cfg.Agents["coder"]
+	coderAgent, ok := cfg.Agents()["coder"]
 	require.True(t, ok)
-	assert.Equal(t, []string{"agent", "bash", "multiedit", "fetch", "glob", "ls", "sourcegraph", "view", "write"}, coderAgent.AllowedTools)
+	assert.Equal(t, []string{"agent", "bash", "diagnostics", "multiedit", "fetch", "glob", "jobs", "ls", "progress", "sourcegraph", "view", "write"}, coderAgent.AllowedTools)
 
-	taskAgent, ok := cfg.Agents["task"]
+	taskAgent, ok := cfg.Agents()["task"]
 	require.True(t, ok)
 	assert.Equal(t, []string{"glob", "ls", "sourcegraph", "view"}, taskAgent.AllowedTools)
 }
@@ -509,11 +510,11 @@ func TestConfig_setupAgentsWithEveryReadOnlyToolDisabled(t *testing.T) {
 
 	err := cfg.SetupAgents()
 	require.NoError(t, err)
-	coderAgent, ok := cfg.Agents["coder"]
+	coderAgent, ok := cfg.Agents()["coder"]
 	require.True(t, ok)
-	assert.Equal(t, []string{"agent", "bash", "download", "edit", "multiedit", "fetch", "write"}, coderAgent.AllowedTools)
+	assert.Equal(t, []string{"agent", "bash", "diagnostics", "download", "edit", "multiedit", "fetch", "jobs", "progress", "write"}, coderAgent.AllowedTools)
 
-	taskAgent, ok := cfg.Agents["task"]
+	taskAgent, ok := cfg.Agents()["task"]
 	require.True(t, ok)
 	assert.Equal(t, []string{}, taskAgent.AllowedTools)
 }
@@ -1248,4 +1249,112 @@ func TestConfig_configureSelectedModels(t *testing.T) {
 		require.Equal(t, "openai", large.Provider)
 		require.Equal(t, int64(100), large.MaxTokens)
 	})
+
+	t.Run("should configure named tiers", func(t *testing.T) {
+		knownProviders := []catwalk.Provider{
+			{
+				ID:                  "openai",
+				APIKey:              "abc",
+				DefaultLargeModelID: "large-model",
+				DefaultSmallModelID: "small-model",
+				Models: []catwalk.Model{
+					{
+						ID:               "large-model",
+						DefaultMaxTokens: 1000,
+					},
+					{
+						ID:               "small-model",
+						DefaultMaxTokens: 500,
+					},
+					{
+						ID:               "fast-model",
+						DefaultMaxTokens: 200,
+					},
+				},
+			},
+		}
+
+		cfg := &Config{
+			Models: map[SelectedModelType]SelectedModel{
+				"fast": {
+					Model: "fast-model",
+				},
+			},
+		}
+		cfg.setDefaults("/tmp", "")
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, knownProviders)
+		require.NoError(t, err)
+
+		err = cfg.configureSelectedModels(knownProviders)
+		require.NoError(t, err)
+		fast := cfg.Models["fast"]
+		require.Equal(t, "fast-model", fast.Model)
+		require.Equal(t, "openai", fast.Provider)
+		require.Equal(t, int64(200), fast.MaxTokens)
+		require.Equal(t, "large-model", cfg.Models[SelectedModelTypeLarge].Model)
+
+		cfg.Models["broken"] = SelectedModel{Model: "missing-model"}
+		err = cfg.configureSelectedModels(knownProviders)
+		require.EqualError(t, err, `model tier "broken": model "missing-model" not found for provider "openai"`)
+	})
+}
+
+func TestOptionsEventBuffer(t *testing.T) {
+	t.Parallel()
+
+	size := func(n int) *int { return &n }
+
+	tests := []struct {
+		name    string
+		size    *int
+		want    int
+		wantErr string
+	}{
+		{name: "default", want: DefaultEventBufferSize},
+		{name: "set", size: size(500), want: 500},
+		{name: "zero", size: size(0), wantErr: "event_buffer_size must be between 1 and 10000, got 0"},
+		{name: "too large", size: size(MaxEventBufferSize + 1), wantErr: "event_buffer_size must be between 1 and 10000, got 10001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := (&Options{EventBufferSize: tt.size}).EventBuffer()
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOptionsBashLimits(t *testing.T) {
+	t.Parallel()
+
+	value := func(n int) *int { return &n }
+
+	tests := []struct {
+		name        string
+		options     *Options
+		wantTimeout time.Duration
+		wantOutput  int
+	}{
+		{name: "nil options", wantTimeout: DefaultBashTimeout, wantOutput: DefaultBashMaxOutput},
+		{name: "default", options: &Options{}, wantTimeout: DefaultBashTimeout, wantOutput: DefaultBashMaxOutput},
+		{name: "set", options: &Options{BashTimeout: value(30), BashMaxOutput: value(1000)}, wantTimeout: 30 * time.Second, wantOutput: 1000},
+		{name: "not positive", options: &Options{BashTimeout: value(0), BashMaxOutput: value(-1)}, wantTimeout: DefaultBashTimeout, wantOutput: DefaultBashMaxOutput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			timeout, maxOutput := tt.options.BashLimits()
+			require.Equal(t, tt.wantTimeout, timeout)
+			require.Equal(t, tt.wantOutput, maxOutput)
+		})
+	}
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -33,3 +34,33 @@ func TestApplyLSPDefaults(t *testing.T) {
 	customConfig := config.LSP["custom"]
 	require.Equal(t, []string{"custom.toml"}, customConfig.RootMarkers, "custom LSP should keep its explicit root markers")
 }
+
+func TestUnknownLSPKeys(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{
+		"lsp": {
+			"gopls": {
+				"command": "gopls",
+				"init_options": {"buildFlags": ["-tags=integration"]},
+				"settings": {"gopls": {"staticcheck": true}}
+			},
+			"rust-analyzer": {
+				"command": "rust-analyzer",
+				"initializationOptions": {"cargo": {"features": "all"}},
+				"restart": true
+			}
+		}
+	}`)
+
+	require.Equal(t, []string{
+		`lsp "gopls": ignoring unknown key "settings", did you mean "options"?`,
+		`lsp "rust-analyzer": ignoring unknown key "initializationOptions", did you mean "init_options"?`,
+		`lsp "rust-analyzer": ignoring unknown key "restart"`,
+	}, unknownLSPKeys(data))
+
+	cfg, err := LoadReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"buildFlags": []any{"-tags=integration"}}, cfg.LSP["gopls"].InitOptions)
+	require.Empty(t, unknownLSPKeys([]byte(`{"lsp": {"gopls": {"command": "gopls", "options": {}}}}`)))
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	sourceDefault = "default"
+	maskedValue   = "********"
+)
+
+// SettingSource describes the resolved value of a setting and where it came
+// from: a config file, an environment variable, a command line flag or the
+// built-in defaults.
+type SettingSource struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Sources returns the provenance of every setting found in the config files,
+// plus the significant settings that were filled in by defaults, flags or the
+// environment. The result is sorted by key.
+func (c *Config) Sources() ([]SettingSource, error) {
+	sources := make(map[string]SettingSource)
+
+	// Later files have more priority, so they overwrite earlier entries.
+	for _, path := range c.configPaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+
+		var raw map[string]any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		delete(raw, "$schema")
+
+		flattenSettings("", raw, func(key string, value any) {
+			sources[key] = c.fileSource(key, value, path)
+		})
+	}
+
+	maps.Copy(sources, c.overrides)
+	c.addDefaultSources(sources)
+
+	for id, agent := range c.Agents() {
+		source := agent.ConfigPath
+		if source == "" {
+			source = sourceDefault
+		}
+		key := "agents." + id + ".model"
+		sources[key] = SettingSource{Key: key, Value: string(agent.Model), Source: source}
+	}
+
+	result := slices.Collect(maps.Values(sources))
+	slices.SortFunc(result, func(a, b SettingSource) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result, nil
+}
+
+func (c *Config) recordOverride(key, value, source string) {
+	if c.overrides == nil {
+		c.overrides = make(map[string]SettingSource)
+	}
+	c.overrides[key] = SettingSource{Key: key, Value: value, Source: source}
+}
+
+// fileSource builds the source of a value read from the given config file,
+// resolving environment references and masking secrets.
+func (c *Config) fileSource(key string, value any, path string) SettingSource {
+	source := SettingSource{Key: key, Source: path}
+
+	str, ok := value.(string)
+	if !ok {
+		bts, _ := json.Marshal(value)
+		source.Value = string(bts)
+		return source
+	}
+
+	source.Value = str
+	if strings.HasPrefix(str, "$") {
+		source.Source = fmt.Sprintf("env %s (%s)", str, path)
+		if c.resolver != nil && !isSecretSetting(key) {
+			if resolved, err := c.resolver.ResolveValue(str); err == nil {
+				source.Value = resolved
+			}
+		}
+		return source
+	}
+
+	if isSecretSetting(key) && str != "" {
+		source.Value = maskedValue
+	}
+	return source
+}
+
+// addDefaultSources reports the significant settings that were not set by any
+// config file, flag or environment variable.
+func (c *Config) addDefaultSources(sources map[string]SettingSource) {
+	addDefault := func(key, value string) {
+		for k := range sources {
+			if k == key || strings.HasPrefix(k, key+".") {
+				return
+			}
+		}
+		sources[key] = SettingSource{Key: key, Value: value, Source: sourceDefault}
+	}
+
+	if c.Options != nil {
+		addDefault("options.data_directory", c.Options.DataDirectory)
+		addDefault("options.default_agent_model", c.defaultAgentModel().Type)
+	}
+	for _, tp := range []SelectedModelType{SelectedModelTypeLarge, SelectedModelTypeSmall} {
+		if model, ok := c.Models[tp]; ok {
+			addDefault("models."+string(tp), model.Provider+"/"+model.Model)
+		}
+	}
+}
+
+// flattenSettings walks a decoded JSON object and calls fn with the dotted
+// key of every leaf value. Arrays are treated as leaves.
+func flattenSettings(prefix string, value any, fn func(key string, value any)) {
+	obj, ok := value.(map[string]any)
+	if !ok || (len(obj) == 0 && prefix != "") {
+		fn(prefix, value)
+		return
+	}
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flattenSettings(key, v, fn)
+	}
+}
+
+func isSecretSetting(key string) bool {
+	return strings.HasSuffix(key, ".api_key") ||
+		strings.Contains(key, ".headers.") ||
+		strings.Contains(key, ".extra_headers.") ||
+		strings.Contains(key, ".env.")
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/env"
+)
+
+func TestConfigSources(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.json")
+	project := filepath.Join(dir, "project.json")
+	require.NoError(t, os.WriteFile(global, []byte(`{
+		"$schema": "https://example.com/schema.json",
+		"options": {"debug": true, "disabled_tools": ["bash"]},
+		"providers": {"openai": {"api_key": "sk-secret", "base_url": "$OPENAI_BASE_URL"}}
+	}`), 0o644))
+	require.NoError(t, os.WriteFile(project, []byte(`{
+		"options": {"debug": false}
+	}`), 0o644))
+
+	cfg := &Config{
+		Options: &Options{DataDirectory: ".tulpa"},
+		Models: map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {Provider: "openai", Model: "gpt-4o"},
+		},
+		resolver:    NewEnvironmentVariableResolver(env.NewFromMap(map[string]string{"OPENAI_BASE_URL": "https://proxy.local"})),
+		configPaths: []string{global, filepath.Join(dir, "missing.json"), project},
+	}
+	cfg.SetAgents(map[string]Agent{
+		"coder": {ID: "coder", Model: SelectedModelTypeSmall, ConfigPath: "/agents/coder.yaml"},
+	}, nil)
+	cfg.recordOverride("options.data_directory", "/custom", "flag --data-dir")
+
+	sources, err := cfg.Sources()
+	require.NoError(t, err)
+
+	byKey := make(map[string]SettingSource)
+	for _, s := range sources {
+		byKey[s.Key] = s
+	}
+
+	require.NotContains(t, byKey, "$schema")
+	require.Equal(t, SettingSource{Key: "options.debug", Value: "false", Source: project}, byKey["options.debug"])
+	require.Equal(t, SettingSource{Key: "options.disabled_tools", Value: `["bash"]`, Source: global}, byKey["options.disabled_tools"])
+	require.Equal(t, maskedValue, byKey["providers.openai.api_key"].Value)
+	require.Equal(t, "https://proxy.local", byKey["providers.openai.base_url"].Value)
+	require.Equal(t, "env $OPENAI_BASE_URL ("+global+")", byKey["providers.openai.base_url"].Source)
+	require.Equal(t, SettingSource{Key: "options.data_directory", Value: "/custom", Source: "flag --data-dir"}, byKey["options.data_directory"])
+	require.Equal(t, SettingSource{Key: "options.default_agent_model", Value: "large", Source: sourceDefault}, byKey["options.default_agent_model"])
+	require.Equal(t, SettingSource{Key: "models.large", Value: "openai/gpt-4o", Source: sourceDefault}, byKey["models.large"])
+	require.Equal(t, SettingSource{Key: "agents.coder.model", Value: "small", Source: "/agents/coder.yaml"}, byKey["agents.coder.model"])
+
+	require.IsNonDecreasing(t, func() []string {
+		keys := make([]string, len(sources))
+		for i, s := range sources {
+			keys[i] = s.Key
+		}
+		return keys
+	}())
+}
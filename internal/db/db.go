@@ -66,6 +66,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listFilesBySessionStmt, err = db.PrepareContext(ctx, listFilesBySession); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesBySession: %w", err)
 	}
+	if q.listFilesBySessionTreeStmt, err = db.PrepareContext(ctx, listFilesBySessionTree); err != nil {
+		return nil, fmt.Errorf("error preparing query ListFilesBySessionTree: %w", err)
+	}
 	if q.listLatestSessionFilesStmt, err = db.PrepareContext(ctx, listLatestSessionFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListLatestSessionFiles: %w", err)
 	}
@@ -75,9 +78,21 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listNewFilesStmt, err = db.PrepareContext(ctx, listNewFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListNewFiles: %w", err)
 	}
+	if q.listSessionUsageStmt, err = db.PrepareContext(ctx, listSessionUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionUsage: %w", err)
+	}
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.searchMessagesStmt, err = db.PrepareContext(ctx, searchMessages); err != nil {
+		return nil, fmt.Errorf("error preparing query SearchMessages: %w", err)
+	}
+	if q.setMessagePinnedStmt, err = db.PrepareContext(ctx, setMessagePinned); err != nil {
+		return nil, fmt.Errorf("error preparing query SetMessagePinned: %w", err)
+	}
+	if q.setSessionStarredStmt, err = db.PrepareContext(ctx, setSessionStarred); err != nil {
+		return nil, fmt.Errorf("error preparing query SetSessionStarred: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
@@ -159,6 +174,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listFilesBySessionStmt: %w", cerr)
 		}
 	}
+	if q.listFilesBySessionTreeStmt != nil {
+		if cerr := q.listFilesBySessionTreeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listFilesBySessionTreeStmt: %w", cerr)
+		}
+	}
 	if q.listLatestSessionFilesStmt != nil {
 		if cerr := q.listLatestSessionFilesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listLatestSessionFilesStmt: %w", cerr)
@@ -174,11 +194,31 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listNewFilesStmt: %w", cerr)
 		}
 	}
+	if q.listSessionUsageStmt != nil {
+		if cerr := q.listSessionUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionUsageStmt: %w", cerr)
+		}
+	}
 	if q.listSessionsStmt != nil {
 		if cerr := q.listSessionsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.searchMessagesStmt != nil {
+		if cerr := q.searchMessagesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing searchMessagesStmt: %w", cerr)
+		}
+	}
+	if q.setMessagePinnedStmt != nil {
+		if cerr := q.setMessagePinnedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setMessagePinnedStmt: %w", cerr)
+		}
+	}
+	if q.setSessionStarredStmt != nil {
+		if cerr := q.setSessionStarredStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setSessionStarredStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
@@ -242,10 +282,15 @@ type Queries struct {
 	getSessionByIDStmt          *sql.Stmt
 	listFilesByPathStmt         *sql.Stmt
 	listFilesBySessionStmt      *sql.Stmt
+	listFilesBySessionTreeStmt  *sql.Stmt
 	listLatestSessionFilesStmt  *sql.Stmt
 	listMessagesBySessionStmt   *sql.Stmt
 	listNewFilesStmt            *sql.Stmt
+	listSessionUsageStmt        *sql.Stmt
 	listSessionsStmt            *sql.Stmt
+	searchMessagesStmt          *sql.Stmt
+	setMessagePinnedStmt        *sql.Stmt
+	setSessionStarredStmt       *sql.Stmt
 	updateMessageStmt           *sql.Stmt
 	updateSessionStmt           *sql.Stmt
 }
@@ -268,10 +313,15 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 		getSessionByIDStmt:          q.getSessionByIDStmt,
 		listFilesByPathStmt:         q.listFilesByPathStmt,
 		listFilesBySessionStmt:      q.listFilesBySessionStmt,
+		listFilesBySessionTreeStmt:  q.listFilesBySessionTreeStmt,
 		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
 		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
 		listNewFilesStmt:            q.listNewFilesStmt,
+		listSessionUsageStmt:        q.listSessionUsageStmt,
 		listSessionsStmt:            q.listSessionsStmt,
+		searchMessagesStmt:          q.searchMessagesStmt,
+		setMessagePinnedStmt:        q.setMessagePinnedStmt,
+		setSessionStarredStmt:       q.setSessionStarredStmt,
 		updateMessageStmt:           q.updateMessageStmt,
 		updateSessionStmt:           q.updateSessionStmt,
 	}
@@ -23,7 +23,7 @@ INSERT INTO messages (
 ) VALUES (
     ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
-RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
 `
 
 type CreateMessageParams struct {
@@ -55,6 +55,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Pinned,
 	)
 	return i, err
 }
@@ -80,7 +81,7 @@ func (q *Queries) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
 FROM messages
 WHERE id = ? LIMIT 1
 `
@@ -98,12 +99,13 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Pinned,
 	)
 	return i, err
 }
 
 const listMessagesBySession = `-- name: ListMessagesBySession :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
 FROM messages
 WHERE session_id = ?
 ORDER BY created_at ASC
@@ -128,6 +130,7 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 			&i.UpdatedAt,
 			&i.FinishedAt,
 			&i.Provider,
+			&i.Pinned,
 		); err != nil {
 			return nil, err
 		}
@@ -142,6 +145,81 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 	return items, nil
 }
 
+const searchMessages = `-- name: SearchMessages :many
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, pinned
+FROM messages
+WHERE parts LIKE ?1 ESCAPE '!'
+    AND (?2 IS NULL OR session_id = ?2)
+    AND (?3 IS NULL OR role = ?3)
+    AND (?4 IS NULL OR created_at >= ?4)
+    AND (?5 IS NULL OR created_at < ?5)
+ORDER BY created_at DESC
+`
+
+type SearchMessagesParams struct {
+	Pattern   string         `json:"pattern"`
+	SessionID sql.NullString `json:"session_id"`
+	Role      sql.NullString `json:"role"`
+	Since     sql.NullInt64  `json:"since"`
+	Until     sql.NullInt64  `json:"until"`
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]Message, error) {
+	rows, err := q.query(ctx, q.searchMessagesStmt, searchMessages,
+		arg.Pattern,
+		arg.SessionID,
+		arg.Role,
+		arg.Since,
+		arg.Until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.Parts,
+			&i.Model,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+			&i.Provider,
+			&i.Pinned,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setMessagePinned = `-- name: SetMessagePinned :exec
+UPDATE messages
+SET pinned = ?
+WHERE id = ?
+`
+
+type SetMessagePinnedParams struct {
+	Pinned bool   `json:"pinned"`
+	ID     string `json:"id"`
+}
+
+func (q *Queries) SetMessagePinned(ctx context.Context, arg SetMessagePinnedParams) error {
+	_, err := q.exec(ctx, q.setMessagePinnedStmt, setMessagePinned, arg.Pinned, arg.ID)
+	return err
+}
+
 const updateMessage = `-- name: UpdateMessage :exec
 UPDATE messages
 SET
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add pinned column to messages table
+ALTER TABLE messages ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Remove pinned column from messages table
+ALTER TABLE messages DROP COLUMN pinned;
+-- +goose StatementEnd
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add is_new column to files table, set on the first version of the files
+-- created in a session
+ALTER TABLE files ADD COLUMN is_new BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Remove is_new column from files table
+ALTER TABLE files DROP COLUMN is_new;
+-- +goose StatementEnd
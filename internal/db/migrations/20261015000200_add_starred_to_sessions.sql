@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add starred column to sessions table
+ALTER TABLE sessions ADD COLUMN starred BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Remove starred column from sessions table
+ALTER TABLE sessions DROP COLUMN starred;
+-- +goose StatementEnd
@@ -23,10 +23,15 @@ type Querier interface {
 	GetSessionByID(ctx context.Context, id string) (Session, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
+	ListFilesBySessionTree(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
+	ListSessionUsage(ctx context.Context) ([]ListSessionUsageRow, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]Message, error)
+	SetMessagePinned(ctx context.Context, arg SetMessagePinnedParams) error
+	SetSessionStarred(ctx context.Context, arg SetSessionStarredParams) error
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
 }
@@ -33,7 +33,7 @@ INSERT INTO sessions (
     null,
     strftime('%s', 'now'),
     strftime('%s', 'now')
-) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, starred
 `
 
 type CreateSessionParams struct {
@@ -68,6 +68,7 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.Starred,
 	)
 	return i, err
 }
@@ -83,7 +84,7 @@ func (q *Queries) DeleteSession(ctx context.Context, id string) error {
 }
 
 const getSessionByID = `-- name: GetSessionByID :one
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, starred
 FROM sessions
 WHERE id = ? LIMIT 1
 `
@@ -102,12 +103,73 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (Session, error
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.Starred,
 	)
 	return i, err
 }
 
+const listSessionUsage = `-- name: ListSessionUsage :many
+SELECT
+    s.id,
+    s.parent_session_id,
+    s.title,
+    s.updated_at,
+    CAST(EXISTS (
+        SELECT 1 FROM messages m WHERE m.session_id = s.id AND m.pinned
+    ) AS BOOLEAN) AS pinned,
+    s.starred,
+    CAST((
+        SELECT COALESCE(SUM(LENGTH(m.parts)), 0) FROM messages m WHERE m.session_id = s.id
+    ) + (
+        SELECT COALESCE(SUM(LENGTH(f.content)), 0) FROM files f WHERE f.session_id = s.id
+    ) AS INTEGER) AS size
+FROM sessions s
+ORDER BY s.updated_at DESC, s.created_at DESC
+`
+
+type ListSessionUsageRow struct {
+	ID              string         `json:"id"`
+	ParentSessionID sql.NullString `json:"parent_session_id"`
+	Title           string         `json:"title"`
+	UpdatedAt       int64          `json:"updated_at"`
+	Pinned          bool           `json:"pinned"`
+	Starred         bool           `json:"starred"`
+	Size            int64          `json:"size"`
+}
+
+func (q *Queries) ListSessionUsage(ctx context.Context) ([]ListSessionUsageRow, error) {
+	rows, err := q.query(ctx, q.listSessionUsageStmt, listSessionUsage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSessionUsageRow{}
+	for rows.Next() {
+		var i ListSessionUsageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ParentSessionID,
+			&i.Title,
+			&i.UpdatedAt,
+			&i.Pinned,
+			&i.Starred,
+			&i.Size,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listSessions = `-- name: ListSessions :many
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, starred
 FROM sessions
 WHERE parent_session_id is NULL
 ORDER BY created_at DESC
@@ -133,6 +195,7 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 			&i.UpdatedAt,
 			&i.CreatedAt,
 			&i.SummaryMessageID,
+			&i.Starred,
 		); err != nil {
 			return nil, err
 		}
@@ -147,6 +210,22 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 	return items, nil
 }
 
+const setSessionStarred = `-- name: SetSessionStarred :exec
+UPDATE sessions
+SET starred = ?
+WHERE id = ?
+`
+
+type SetSessionStarredParams struct {
+	Starred bool   `json:"starred"`
+	ID      string `json:"id"`
+}
+
+func (q *Queries) SetSessionStarred(ctx context.Context, arg SetSessionStarredParams) error {
+	_, err := q.exec(ctx, q.setSessionStarredStmt, setSessionStarred, arg.Starred, arg.ID)
+	return err
+}
+
 const updateSession = `-- name: UpdateSession :one
 UPDATE sessions
 SET
@@ -156,7 +235,7 @@ SET
     summary_message_id = ?,
     cost = ?
 WHERE id = ?
-RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, starred
 `
 
 type UpdateSessionParams struct {
@@ -189,6 +268,7 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.Starred,
 	)
 	return i, err
 }
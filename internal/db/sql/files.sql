@@ -16,6 +16,17 @@ FROM files
 WHERE session_id = ?
 ORDER BY version ASC, created_at ASC;
 
+-- name: ListFilesBySessionTree :many
+WITH RECURSIVE tree(id) AS (
+    SELECT CAST(sqlc.arg(session_id) AS TEXT)
+    UNION ALL
+    SELECT s.id FROM sessions s INNER JOIN tree ON s.parent_session_id = tree.id
+)
+SELECT *
+FROM files
+WHERE session_id IN (SELECT id FROM tree)
+ORDER BY version ASC, created_at ASC;
+
 -- name: ListFilesByPath :many
 SELECT *
 FROM files
@@ -29,10 +40,11 @@ INSERT INTO files (
     path,
     content,
     version,
+    is_new,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
 RETURNING *;
 
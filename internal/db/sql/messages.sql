@@ -9,6 +9,16 @@ FROM messages
 WHERE session_id = ?
 ORDER BY created_at ASC;
 
+-- name: SearchMessages :many
+SELECT *
+FROM messages
+WHERE parts LIKE sqlc.arg(pattern) ESCAPE '!'
+    AND (sqlc.narg(session_id) IS NULL OR session_id = sqlc.narg(session_id))
+    AND (sqlc.narg(role) IS NULL OR role = sqlc.narg(role))
+    AND (sqlc.narg(since) IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until) IS NULL OR created_at < sqlc.narg(until))
+ORDER BY created_at DESC;
+
 -- name: CreateMessage :one
 INSERT INTO messages (
     id,
@@ -33,6 +43,11 @@ SET
 WHERE id = ?;
 
 
+-- name: SetMessagePinned :exec
+UPDATE messages
+SET pinned = ?
+WHERE id = ?;
+
 -- name: DeleteMessage :exec
 DELETE FROM messages
 WHERE id = ?;
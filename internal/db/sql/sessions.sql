@@ -28,6 +28,24 @@ SELECT *
 FROM sessions
 WHERE id = ? LIMIT 1;
 
+-- name: ListSessionUsage :many
+SELECT
+    s.id,
+    s.parent_session_id,
+    s.title,
+    s.updated_at,
+    CAST(EXISTS (
+        SELECT 1 FROM messages m WHERE m.session_id = s.id AND m.pinned
+    ) AS BOOLEAN) AS pinned,
+    s.starred,
+    CAST((
+        SELECT COALESCE(SUM(LENGTH(m.parts)), 0) FROM messages m WHERE m.session_id = s.id
+    ) + (
+        SELECT COALESCE(SUM(LENGTH(f.content)), 0) FROM files f WHERE f.session_id = s.id
+    ) AS INTEGER) AS size
+FROM sessions s
+ORDER BY s.updated_at DESC, s.created_at DESC;
+
 -- name: ListSessions :many
 SELECT *
 FROM sessions
@@ -45,6 +63,10 @@ SET
 WHERE id = ?
 RETURNING *;
 
+-- name: SetSessionStarred :exec
+UPDATE sessions
+SET starred = ?
+WHERE id = ?;
 
 -- name: DeleteSession :exec
 DELETE FROM sessions
@@ -0,0 +1,141 @@
+// Package export renders the conversation of a session as Markdown or as a
+// self-contained HTML page to share what an agent did.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/tulpa-code/tulpa/internal/diff"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// maxTargetLength is how long the target of a tool call shown next to its
+// name may be.
+const maxTargetLength = 80
+
+// A turn is a message of the conversation with the results of its tool
+// calls, which are stored in the tool message following it.
+type turn struct {
+	role  message.MessageRole
+	model string
+	text  string
+	calls []*toolCall
+}
+
+type toolCall struct {
+	name  string
+	input string
+	// What the call works on, like a file path or a command
+	target string
+	// File the call works on
+	file string
+	// Diff of the file changed by the call
+	diff   string
+	result *message.ToolResult
+}
+
+func turns(msgs []message.Message) []turn {
+	var turns []turn
+	calls := make(map[string]*toolCall)
+	for _, msg := range msgs {
+		if msg.Role == message.Tool {
+			for _, result := range msg.ToolResults() {
+				if call, ok := calls[result.ToolCallID]; ok {
+					call.setResult(result)
+					continue
+				}
+				// The call was summarized away, show the result alone.
+				call := &toolCall{name: result.Name}
+				call.setResult(result)
+				turns = append(turns, turn{role: message.Tool, calls: []*toolCall{call}})
+			}
+			continue
+		}
+
+		t := turn{
+			role:  msg.Role,
+			model: msg.Model,
+			text:  strings.TrimSpace(msg.Content().Text),
+		}
+		for _, c := range msg.ToolCalls() {
+			call := &toolCall{name: c.Name, input: c.Input}
+			call.target, call.file = callTarget(c.Input)
+			calls[c.ID] = call
+			t.calls = append(t.calls, call)
+		}
+		if t.text != "" || len(t.calls) > 0 {
+			turns = append(turns, t)
+		}
+	}
+	return turns
+}
+
+func (c *toolCall) setResult(result message.ToolResult) {
+	c.result = &result
+	if result.Metadata == "" || result.IsError {
+		return
+	}
+	// The edit tools store the diff or the file before and after the edit.
+	var meta struct {
+		Diff       string `json:"diff"`
+		OldContent string `json:"old_content"`
+		NewContent string `json:"new_content"`
+	}
+	if err := json.Unmarshal([]byte(result.Metadata), &meta); err != nil {
+		return
+	}
+	switch {
+	case meta.Diff != "":
+		c.diff = meta.Diff
+	case meta.OldContent != meta.NewContent:
+		c.diff, _, _ = diff.GenerateDiff(meta.OldContent, meta.NewContent, c.file)
+	}
+}
+
+// callTarget returns what a tool call with input works on, shortened, and
+// the file it works on.
+func callTarget(input string) (target, file string) {
+	var params map[string]any
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", ""
+	}
+	file, _ = params["file_path"].(string)
+	for _, key := range []string{"file_path", "path", "command", "pattern", "url"} {
+		if value, ok := params[key].(string); ok && value != "" {
+			value, _, _ = strings.Cut(value, "\n")
+			if runes := []rune(value); len(runes) > maxTargetLength {
+				value = string(runes[:maxTargetLength]) + "…"
+			}
+			return value, file
+		}
+	}
+	return "", file
+}
+
+// indentJSON indents the JSON of a tool call input, or returns it as is when
+// it's invalid.
+func indentJSON(input string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(input), "", "  "); err != nil {
+		return input
+	}
+	return buf.String()
+}
+
+func (t turn) heading() string {
+	switch t.role {
+	case message.User:
+		return "User"
+	case message.Assistant:
+		if t.model == "" {
+			return "Assistant"
+		}
+		return "Assistant (" + t.model + ")"
+	case message.Tool:
+		return "Tool"
+	default:
+		return string(t.role)
+	}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+var testMessages = []message.Message{
+	{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Rename <Foo> to Bar\n\n<script>alert(1)</script>"}},
+	},
+	{
+		Role:  message.Assistant,
+		Model: "gpt-5",
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "Renaming it:\n\n```go\ntype Bar struct{}\n```"},
+			message.ToolCall{ID: "c1", Name: "edit", Input: `{"file_path":"/src/foo.go","old_string":"Foo","new_string":"Bar"}`},
+			message.ToolCall{ID: "c2", Name: "bash", Input: `{"command":"go build ./..."}`},
+		},
+	},
+	{
+		Role: message.Tool,
+		Parts: []message.ContentPart{
+			message.ToolResult{
+				ToolCallID: "c1",
+				Name:       "edit",
+				Content:    "File edited",
+				Metadata:   `{"additions":1,"removals":1,"old_content":"type Foo struct{}\n","new_content":"type Bar struct{}\n"}`,
+			},
+			message.ToolResult{ToolCallID: "c2", Name: "bash", Content: "exit status 1", IsError: true},
+		},
+	},
+}
+
+func TestTurns(t *testing.T) {
+	t.Parallel()
+
+	got := turns(testMessages)
+	require.Len(t, got, 2, "tool results are part of the calls")
+	require.Equal(t, "Assistant (gpt-5)", got[1].heading())
+
+	edit := got[1].calls[0]
+	require.Equal(t, "/src/foo.go", edit.target)
+	require.Equal(t, "File edited", edit.result.Content)
+	require.Contains(t, edit.diff, "--- a/src/foo.go")
+	require.Contains(t, edit.diff, "-type Foo struct{}")
+	require.Contains(t, edit.diff, "+type Bar struct{}")
+
+	bash := got[1].calls[1]
+	require.Equal(t, "go build ./...", bash.target)
+	require.True(t, bash.result.IsError)
+	require.Empty(t, bash.diff)
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	require.NoError(t, Markdown(&b, session.Session{ID: "s1", Title: "Rename"}, testMessages))
+	out := b.String()
+	require.Contains(t, out, "# Rename\n")
+	require.Contains(t, out, "\n## User\n\nRename <Foo> to Bar\n")
+	require.Contains(t, out, "<summary><b>edit</b> <code>/src/foo.go</code></summary>")
+	require.Contains(t, out, "<summary><b>bash</b> <code>go build ./...</code> (error)</summary>")
+	require.Contains(t, out, "```diff\n--- a/src/foo.go")
+	require.Contains(t, out, "```json\n{\n  \"file_path\": \"/src/foo.go\",")
+	require.NotContains(t, out, "## Tool")
+}
+
+func TestHTML(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	require.NoError(t, HTML(&b, session.Session{ID: "s1", Title: "Rename <it>"}, testMessages))
+	out := b.String()
+	require.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+	require.Contains(t, out, "<title>Rename &lt;it&gt;</title>")
+	require.Contains(t, out, "<p>Rename &lt;Foo&gt; to Bar</p>", "HTML in messages is shown as text")
+	require.Contains(t, out, "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>")
+	require.Contains(t, out, `<details class="error">`)
+	require.Contains(t, out, "<h3>Diff</h3>")
+	// Code blocks and diffs are highlighted with inline styles.
+	require.Contains(t, out, `<span style="color:#cf222e">type</span>`)
+	require.NotContains(t, out, "```")
+}
+
+func TestCodeBlock(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "```go\nx := 1\n```", codeBlock("x := 1\n", "go"))
+	require.Equal(t, "````\nuse ```go\n````", codeBlock("use ```go", ""))
+}
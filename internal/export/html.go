@@ -0,0 +1,223 @@
+package export
+
+import (
+	"bytes"
+	"cmp"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// highlightStyle is the chroma style of code blocks and diffs.
+const highlightStyle = "github"
+
+type htmlPage struct {
+	Title   string
+	ID      string
+	Created string
+	Turns   []htmlTurn
+}
+
+type htmlTurn struct {
+	Role    message.MessageRole
+	Heading string
+	Text    template.HTML
+	Calls   []htmlCall
+}
+
+type htmlCall struct {
+	Name    string
+	Target  string
+	Input   template.HTML
+	Diff    template.HTML
+	Output  template.HTML
+	Pending bool
+	IsError bool
+}
+
+// HTML writes the conversation of sess as a self-contained HTML page.
+// Messages are rendered from Markdown, and code blocks and diffs are syntax
+// highlighted. Tool calls are collapsed, with their input, output and the
+// diffs of the files they changed.
+func HTML(w io.Writer, sess session.Session, msgs []message.Message) error {
+	page := htmlPage{
+		Title:   cmp.Or(sess.Title, "Untitled session"),
+		ID:      sess.ID,
+		Created: time.Unix(sess.CreatedAt, 0).Format(time.DateTime),
+	}
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(messageRenderer{}, 100)),
+		),
+	)
+	for _, t := range turns(msgs) {
+		ht := htmlTurn{Role: t.role, Heading: t.heading()}
+		if t.text != "" {
+			var buf bytes.Buffer
+			if err := md.Convert([]byte(t.text), &buf); err != nil {
+				return err
+			}
+			ht.Text = template.HTML(buf.String())
+		}
+		for _, call := range t.calls {
+			hc := htmlCall{
+				Name:    call.name,
+				Target:  call.target,
+				Pending: call.result == nil,
+				IsError: call.result != nil && call.result.IsError,
+			}
+			if call.input != "" {
+				hc.Input = highlight(indentJSON(call.input), "json")
+			}
+			if call.diff != "" {
+				hc.Diff = highlight(call.diff, "diff")
+			}
+			if call.result != nil {
+				hc.Output = highlight(call.result.Content, "")
+			}
+			ht.Calls = append(ht.Calls, hc)
+		}
+		page.Turns = append(page.Turns, ht)
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+// highlight renders code as HTML, highlighted for lang when it's known.
+func highlight(code, lang string) template.HTML {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err == nil {
+		var buf bytes.Buffer
+		formatter := chromahtml.New(chromahtml.TabWidth(4))
+		if err := formatter.Format(&buf, styles.Get(highlightStyle), iterator); err == nil {
+			return template.HTML(buf.String())
+		}
+	}
+	return template.HTML("<pre>" + template.HTMLEscapeString(code) + "</pre>")
+}
+
+// messageRenderer highlights the fenced code blocks of messages and shows the
+// HTML in them as text.
+type messageRenderer struct{}
+
+func (messageRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderCodeBlock)
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+}
+
+func renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.FencedCodeBlock)
+	code := linesText(block.Lines(), source)
+	_, err := w.WriteString(string(highlight(code, string(block.Language(source)))))
+	return ast.WalkSkipChildren, err
+}
+
+func renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.HTMLBlock)
+	content := linesText(block.Lines(), source)
+	if block.HasClosure() {
+		content += string(block.ClosureLine.Value(source))
+	}
+	_, err := w.WriteString("<p>" + template.HTMLEscapeString(strings.TrimSpace(content)) + "</p>\n")
+	return ast.WalkSkipChildren, err
+}
+
+func renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	raw := node.(*ast.RawHTML)
+	_, err := w.WriteString(template.HTMLEscapeString(linesText(raw.Segments, source)))
+	return ast.WalkSkipChildren, err
+}
+
+func linesText(lines *text.Segments, source []byte) string {
+	var b strings.Builder
+	for i := range lines.Len() {
+		line := lines.At(i)
+		b.Write(line.Value(source))
+	}
+	return b.String()
+}
+
+var htmlTemplate = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 960px; margin: 2rem auto; padding: 0 1rem; font: 15px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; }
+header { border-bottom: 1px solid #d1d9e0; margin-bottom: 1.5rem; }
+.meta { color: #59636e; }
+section { margin: 1.5rem 0; }
+section h2 { font-size: 1rem; margin: 0 0 .5rem; color: #59636e; }
+section.user h2 { color: #0969da; }
+section.assistant h2 { color: #8250df; }
+pre { padding: .75rem; overflow-x: auto; border-radius: 6px; font-size: 13px; background-color: #f6f8fa; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; }
+:not(pre) > code { background: #eff1f3; padding: .1rem .3rem; border-radius: 4px; }
+details { border: 1px solid #d1d9e0; border-radius: 6px; margin: .5rem 0; padding: .25rem .75rem; }
+details.error { border-color: #cf222e; }
+summary { cursor: pointer; }
+details h3 { font-size: .85rem; margin: .75rem 0 .25rem; color: #59636e; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d1d9e0; padding: .25rem .5rem; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">Session <code>{{.ID}}</code>, created {{.Created}}.</p>
+</header>
+{{- range .Turns}}
+<section class="{{.Role}}">
+<h2>{{.Heading}}</h2>
+{{.Text}}
+{{- range .Calls}}
+<details{{if .IsError}} class="error"{{end}}>
+<summary><b>{{.Name}}</b>{{with .Target}} <code>{{.}}</code>{{end}}{{if .Pending}} (no result){{else if .IsError}} (error){{end}}</summary>
+{{- with .Input}}
+<h3>Input</h3>
+{{.}}
+{{- end}}
+{{- with .Diff}}
+<h3>Diff</h3>
+{{.}}
+{{- end}}
+{{- with .Output}}
+<h3>Output</h3>
+{{.}}
+{{- end}}
+</details>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
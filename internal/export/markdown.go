@@ -0,0 +1,68 @@
+package export
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+// Markdown writes the conversation of sess as GitHub flavored Markdown. Tool
+// calls are collapsed, with their input, output and the diffs of the files
+// they changed.
+func Markdown(w io.Writer, sess session.Session, msgs []message.Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", cmp.Or(sess.Title, "Untitled session"))
+	fmt.Fprintf(&b, "Session `%s`, created %s.\n", sess.ID, time.Unix(sess.CreatedAt, 0).Format(time.DateTime))
+
+	for _, t := range turns(msgs) {
+		fmt.Fprintf(&b, "\n## %s\n", t.heading())
+		if t.text != "" {
+			fmt.Fprintf(&b, "\n%s\n", t.text)
+		}
+		for _, call := range t.calls {
+			writeMarkdownCall(&b, call)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownCall(b *strings.Builder, call *toolCall) {
+	summary := "<b>" + html.EscapeString(call.name) + "</b>"
+	if call.target != "" {
+		summary += " <code>" + html.EscapeString(call.target) + "</code>"
+	}
+	switch {
+	case call.result == nil:
+		summary += " (no result)"
+	case call.result.IsError:
+		summary += " (error)"
+	}
+	fmt.Fprintf(b, "\n<details>\n<summary>%s</summary>\n", summary)
+	if call.input != "" {
+		fmt.Fprintf(b, "\nInput:\n\n%s\n", codeBlock(indentJSON(call.input), "json"))
+	}
+	if call.diff != "" {
+		fmt.Fprintf(b, "\nDiff:\n\n%s\n", codeBlock(call.diff, "diff"))
+	}
+	if call.result != nil {
+		fmt.Fprintf(b, "\nOutput:\n\n%s\n", codeBlock(call.result.Content, ""))
+	}
+	b.WriteString("\n</details>\n")
+}
+
+// codeBlock fences code, with a fence longer than the backticks in it.
+func codeBlock(code, lang string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(code, "\n") + "\n" + fence
+}
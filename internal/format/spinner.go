@@ -4,18 +4,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
 	"github.com/tulpa-code/tulpa/internal/tui/components/anim"
 	"github.com/tulpa-code/tulpa/internal/tui/styles"
-	"github.com/charmbracelet/x/ansi"
 )
 
+// DefaultSpinnerLabel is shown next to the spinner when no label is given.
+const DefaultSpinnerLabel = "Generating"
+
 // Spinner wraps the bubbles spinner for non-interactive mode
 type Spinner struct {
 	done chan struct{}
 	prog *tea.Program
+	out  io.Writer
+}
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	return ok && term.IsTerminal(f.Fd())
 }
 
 type model struct {
@@ -26,9 +37,14 @@ type model struct {
 func (m model) Init() tea.Cmd { return m.anim.Init() }
 func (m model) View() string  { return m.anim.View() }
 
+type labelMsg string
+
 // Update implements tea.Model.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case labelMsg:
+		m.anim.SetLabel(string(msg))
+		return m, nil
 	case tea.KeyPressMsg:
 		switch msg.String() {
 		case "ctrl+c", "esc":
@@ -41,13 +57,22 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-// NewSpinner creates a new spinner with the given message
-func NewSpinner(ctx context.Context, cancel context.CancelFunc, message string) *Spinner {
+// NewSpinner creates a spinner showing label on out, or
+// [DefaultSpinnerLabel] when label is empty. It is disabled, and writes
+// nothing, when out isn't a terminal, so that redirected output doesn't get
+// escape codes.
+func NewSpinner(ctx context.Context, cancel context.CancelFunc, out io.Writer, label string) *Spinner {
+	if !IsTerminal(out) {
+		return &Spinner{}
+	}
+	if label == "" {
+		label = DefaultSpinnerLabel
+	}
 	t := styles.CurrentTheme()
 	model := model{
 		anim: anim.New(anim.Settings{
 			Size:        10,
-			Label:       message,
+			Label:       label,
 			LabelColor:  t.FgBase,
 			GradColorA:  t.Primary,
 			GradColorB:  t.Secondary,
@@ -58,31 +83,46 @@ func NewSpinner(ctx context.Context, cancel context.CancelFunc, message string)
 
 	prog := tea.NewProgram(
 		model,
-		tea.WithOutput(os.Stderr),
+		tea.WithOutput(out),
 		tea.WithContext(ctx),
 	)
 
 	return &Spinner{
 		prog: prog,
 		done: make(chan struct{}, 1),
+		out:  out,
 	}
 }
 
 // Start begins the spinner animation
 func (s *Spinner) Start() {
+	if s.prog == nil {
+		return
+	}
 	go func() {
 		defer close(s.done)
 		_, err := s.prog.Run()
 		// ensures line is cleared
-		fmt.Fprint(os.Stderr, ansi.EraseEntireLine)
+		fmt.Fprint(s.out, ansi.EraseEntireLine)
 		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, tea.ErrInterrupted) {
-			fmt.Fprintf(os.Stderr, "Error running spinner: %v\n", err)
+			fmt.Fprintf(s.out, "Error running spinner: %v\n", err)
 		}
 	}()
 }
 
 // Stop ends the spinner animation
 func (s *Spinner) Stop() {
+	if s.prog == nil {
+		return
+	}
 	s.prog.Quit()
 	<-s.done
 }
+
+// SetLabel replaces the message shown next to the spinner
+func (s *Spinner) SetLabel(label string) {
+	if s.prog == nil {
+		return
+	}
+	s.prog.Send(labelMsg(label))
+}
@@ -0,0 +1,24 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpinnerNotTerminal(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	spinner := NewSpinner(ctx, cancel, &out, "Working")
+	spinner.Start()
+	spinner.SetLabel("Retrying")
+	spinner.Stop()
+	require.Empty(t, out.Bytes())
+	require.False(t, IsTerminal(&out))
+}
@@ -215,6 +215,47 @@ func ToWindowsLineEndings(content string) (string, bool) {
 	return content, false
 }
 
+// WriteFileAtomic writes data to the file at path with the permissions perm.
+// It writes a temporary file next to it and renames it over the file, so a
+// crash can't leave the file half written. Symbolic links are followed, the
+// file they point to is written.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
 func truncate[T any](input []T, limit int) ([]T, bool) {
 	if limit > 0 && len(input) > limit {
 		return input[:limit], true
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -267,3 +269,43 @@ func TestGlobWithDoubleStar(t *testing.T) {
 		require.Equal(t, []string{oldestFile, middleDir, newestFile}, matches)
 	})
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new"), 0o600))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// The file a link points to is written, the link stays.
+	if runtime.GOOS != "windows" {
+		link := filepath.Join(dir, "link.go")
+		require.NoError(t, os.Symlink(path, link))
+		require.NoError(t, WriteFileAtomic(link, []byte("through link"), 0o600))
+		content, err = os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "through link", string(content))
+		linkInfo, err := os.Lstat(link)
+		require.NoError(t, err)
+		require.NotZero(t, linkInfo.Mode()&os.ModeSymlink)
+	}
+
+	// No temporary file is left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.False(t, strings.HasSuffix(entry.Name(), ".tmp"), entry.Name())
+	}
+
+	require.Error(t, WriteFileAtomic(filepath.Join(dir, "missing", "main.go"), []byte("new"), 0o644))
+}
@@ -0,0 +1,51 @@
+package fsext
+
+import (
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// ignoreRules holds the patterns of an ignore file in order. Unlike a
+// single [ignore.IgnoreParser] it tells paths no pattern matched apart from
+// paths re-included by a negated pattern, which lets an ignore file in a
+// subdirectory re-include what one higher up excluded.
+type ignoreRules []ignoreRule
+
+type ignoreRule struct {
+	pattern *ignore.GitIgnore
+	negate  bool
+}
+
+func compileIgnoreRules(lines []string) ignoreRules {
+	var rules ignoreRules
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		if negate {
+			line = line[1:]
+		}
+		rules = append(rules, ignoreRule{
+			pattern: ignore.CompileIgnoreLines(line),
+			negate:  negate,
+		})
+	}
+	return rules
+}
+
+// match reports whether path, relative to the directory of the ignore file,
+// is ignored. The last matching pattern decides, as in git; ok is false when
+// no pattern matched at all.
+func (r ignoreRules) match(path string) (ignored, ok bool) {
+	for i := len(r) - 1; i >= 0; i-- {
+		// Check with a trailing slash too, so patterns that only match
+		// directories apply to them.
+		if r[i].pattern.MatchesPath(path) || r[i].pattern.MatchesPath(path+"/") {
+			return !r[i].negate, true
+		}
+	}
+	return false, false
+}
@@ -80,30 +80,36 @@ var commonIgnorePatterns = sync.OnceValue(func() ignore.IgnoreParser {
 	)
 })
 
-var homeIgnore = sync.OnceValue(func() ignore.IgnoreParser {
+// homeIgnore holds the user's global ignore rules: git's and Tulpa's own,
+// which take precedence.
+var homeIgnore = sync.OnceValues(func() (git, tulpa ignoreRules) {
+	read := func(names ...string) ignoreRules {
+		var lines []string
+		for _, name := range names {
+			if bts, err := os.ReadFile(name); err == nil {
+				lines = append(lines, strings.Split(string(bts), "\n")...)
+			}
+		}
+		return compileIgnoreRules(lines)
+	}
 	home := home.Dir()
-	var lines []string
-	for _, name := range []string{
+	git = read(
 		filepath.Join(home, ".gitignore"),
 		filepath.Join(home, ".config", "git", "ignore"),
-		filepath.Join(home, ".config", "tulpa", "ignore"),
-	} {
-		if bts, err := os.ReadFile(name); err == nil {
-			lines = append(lines, strings.Split(string(bts), "\n")...)
-		}
-	}
-	return ignore.CompileIgnoreLines(lines...)
+	)
+	tulpa = read(filepath.Join(home, ".config", "tulpa", "ignore"))
+	return git, tulpa
 })
 
 type directoryLister struct {
-	ignores  *csync.Map[string, ignore.IgnoreParser]
+	ignores  *csync.Map[string, ignoreRules]
 	rootPath string
 }
 
 func NewDirectoryLister(rootPath string) *directoryLister {
 	dl := &directoryLister{
 		rootPath: rootPath,
-		ignores:  csync.NewMap[string, ignore.IgnoreParser](),
+		ignores:  csync.NewMap[string, ignoreRules](),
 	}
 	dl.getIgnore(rootPath)
 	return dl
@@ -122,6 +128,10 @@ func NewDirectoryLister(rootPath string) *directoryLister {
 // ~/.config/git/ignore
 // ~/.gitignore
 // ~/.config/tulpa/ignore
+//
+// Each ignore file's patterns are relative to its directory, and the file
+// closest to the path has the final say, so it can re-include paths with
+// negated patterns.
 func (dl *directoryLister) shouldIgnore(path string, ignorePatterns []string) bool {
 	if len(ignorePatterns) > 0 {
 		base := filepath.Base(path)
@@ -142,54 +152,36 @@ func (dl *directoryLister) shouldIgnore(path string, ignorePatterns []string) bo
 	if err != nil {
 		relPath = path
 	}
+	relPath = filepath.ToSlash(relPath)
 
 	if commonIgnorePatterns().MatchesPath(relPath) {
 		slog.Debug("ignoring common pattern", "path", relPath)
 		return true
 	}
 
-	parentDir := filepath.Dir(path)
-	ignoreParser := dl.getIgnore(parentDir)
-	if ignoreParser.MatchesPath(relPath) {
-		slog.Debug("ignoring dir pattern", "path", relPath, "dir", parentDir)
-		return true
+	gitRules, tulpaRules := homeIgnore()
+	ignored, _ := gitRules.match(relPath)
+	if match, ok := tulpaRules.match(relPath); ok {
+		ignored = match
 	}
 
-	// For directories, also check with trailing slash (gitignore convention)
-	if ignoreParser.MatchesPath(relPath + "/") {
-		slog.Debug("ignoring dir pattern with slash", "path", relPath+"/", "dir", parentDir)
-		return true
-	}
-
-	if dl.checkParentIgnores(relPath) {
-		return true
-	}
-
-	if homeIgnore().MatchesPath(relPath) {
-		slog.Debug("ignoring home dir pattern", "path", relPath)
-		return true
-	}
-
-	return false
-}
-
-func (dl *directoryLister) checkParentIgnores(path string) bool {
-	parent := filepath.Dir(filepath.Dir(path))
-	for parent != "." && path != "." {
-		if dl.getIgnore(parent).MatchesPath(path) {
-			slog.Debug("ingoring parent dir pattern", "path", path, "dir", parent)
-			return true
+	dir, rest := dl.rootPath, relPath
+	for {
+		if match, ok := dl.getIgnore(dir).match(rest); ok {
+			slog.Debug("matched dir pattern", "path", rest, "dir", dir, "ignored", match)
+			ignored = match
 		}
-		if parent == dl.rootPath {
+		next, remaining, found := strings.Cut(rest, "/")
+		if !found {
 			break
 		}
-		parent = filepath.Dir(parent)
+		dir, rest = filepath.Join(dir, next), remaining
 	}
-	return false
+	return ignored
 }
 
-func (dl *directoryLister) getIgnore(path string) ignore.IgnoreParser {
-	return dl.ignores.GetOrSet(path, func() ignore.IgnoreParser {
+func (dl *directoryLister) getIgnore(path string) ignoreRules {
+	return dl.ignores.GetOrSet(path, func() ignoreRules {
 		var lines []string
 		for _, ign := range []string{".tulpaignore", ".gitignore"} {
 			name := filepath.Join(path, ign)
@@ -197,15 +189,12 @@ func (dl *directoryLister) getIgnore(path string) ignore.IgnoreParser {
 				lines = append(lines, strings.Split(string(content), "\n")...)
 			}
 		}
-		if len(lines) == 0 {
-			// Return a no-op parser to avoid nil checks
-			return ignore.CompileIgnoreLines()
-		}
-		return ignore.CompileIgnoreLines(lines...)
+		return compileIgnoreRules(lines)
 	})
 }
 
 // ListDirectory lists files and directories in the specified path,
+// leaving out those matched by ignore files.
 func ListDirectory(initialPath string, ignorePatterns []string, depth, limit int) ([]string, bool, error) {
 	found := csync.NewSlice[string]()
 	dl := NewDirectoryLister(initialPath)
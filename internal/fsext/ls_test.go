@@ -31,11 +31,10 @@ func TestListDirectory(t *testing.T) {
 		files, truncated, err := ListDirectory(tmp, nil, -1, -1)
 		require.NoError(t, err)
 		require.False(t, truncated)
-		require.Len(t, files, 4)
+		require.Len(t, files, 3)
 		require.ElementsMatch(t, []string{
 			"regular.txt",
 			"subdir",
-			"subdir/.another",
 			"subdir/file.go",
 		}, relPaths(t, files, tmp))
 	})
@@ -47,6 +46,40 @@ func TestListDirectory(t *testing.T) {
 	})
 }
 
+func TestListDirectoryNestedGitignore(t *testing.T) {
+	tmp := t.TempDir()
+
+	testFiles := map[string]string{
+		".gitignore":              "*.gen.go\ngenerated/\n",
+		"main.go":                 "package main",
+		"main.gen.go":             "package main",
+		"web/.gitignore":          "/assets\n!keep.gen.go\n",
+		"web/keep.gen.go":         "package web",
+		"web/drop.gen.go":         "package web",
+		"web/assets/app.css":      "",
+		"web/src/assets/logo.svg": "",
+		"web/generated/types.go":  "package generated",
+	}
+	for name, content := range testFiles {
+		fp := filepath.Join(tmp, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fp), 0o755))
+		require.NoError(t, os.WriteFile(fp, []byte(content), 0o644))
+	}
+
+	files, _, err := ListDirectory(tmp, nil, -1, -1)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		".gitignore",
+		"main.go",
+		"web",
+		"web/.gitignore",
+		"web/keep.gen.go",
+		"web/src",
+		"web/src/assets",
+		"web/src/assets/logo.svg",
+	}, relPaths(t, files, tmp))
+}
+
 func relPaths(tb testing.TB, in []string, base string) []string {
 	tb.Helper()
 	out := make([]string, 0, len(in))
@@ -3,10 +3,15 @@ package history
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/tulpa-code/tulpa/internal/db"
+	"github.com/tulpa-code/tulpa/internal/fsext"
 	"github.com/tulpa-code/tulpa/internal/pubsub"
 	"github.com/google/uuid"
 )
@@ -15,12 +20,18 @@ const (
 	InitialVersion = 0
 )
 
+// ErrFileChanged is returned when reverting files changed since the session
+// last changed them, which would lose the later changes.
+var ErrFileChanged = errors.New("files changed since the session last changed them")
+
 type File struct {
 	ID        string
 	SessionID string
 	Path      string
 	Content   string
 	Version   int64
+	// Whether the file was created in the session, set on its first version
+	IsNew     bool
 	CreatedAt int64
 	UpdatedAt int64
 }
@@ -28,6 +39,9 @@ type File struct {
 type Service interface {
 	pubsub.Suscriber[File]
 	Create(ctx context.Context, sessionID, path, content string) (File, error)
+	// CreateNew records the empty first version of a file created in the
+	// session, so reverting the session removes it.
+	CreateNew(ctx context.Context, sessionID, path string) (File, error)
 	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
@@ -35,6 +49,15 @@ type Service interface {
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
+	// Revert restores the files changed in the session and in the subagent
+	// sessions it started to what they were before they were first changed,
+	// and forgets their history in those sessions. It returns the restored
+	// versions. Unless force is set, it reverts nothing and returns
+	// ErrFileChanged if a file changed since the sessions last changed it.
+	Revert(ctx context.Context, sessionID string, force bool) ([]File, error)
+	// RevertFile reverts one file changed in the session or its subagent
+	// sessions.
+	RevertFile(ctx context.Context, sessionID, path string, force bool) (File, error)
 }
 
 type service struct {
@@ -52,7 +75,11 @@ func NewService(q *db.Queries, db *sql.DB) Service {
 }
 
 func (s *service) Create(ctx context.Context, sessionID, path, content string) (File, error) {
-	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, false)
+}
+
+func (s *service) CreateNew(ctx context.Context, sessionID, path string) (File, error) {
+	return s.createWithVersion(ctx, sessionID, path, "", InitialVersion, true)
 }
 
 func (s *service) CreateVersion(ctx context.Context, sessionID, path, content string) (File, error) {
@@ -71,10 +98,10 @@ func (s *service) CreateVersion(ctx context.Context, sessionID, path, content st
 	latestFile := files[0] // Files are ordered by version DESC, created_at DESC
 	nextVersion := latestFile.Version + 1
 
-	return s.createWithVersion(ctx, sessionID, path, content, nextVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, nextVersion, false)
 }
 
-func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64) (File, error) {
+func (s *service) createWithVersion(ctx context.Context, sessionID, path, content string, version int64, isNew bool) (File, error) {
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
@@ -98,6 +125,7 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 			Path:      path,
 			Content:   content,
 			Version:   version,
+			IsNew:     isNew,
 		})
 		if txErr != nil {
 			// Rollback the transaction
@@ -197,6 +225,138 @@ func (s *service) DeleteSessionFiles(ctx context.Context, sessionID string) erro
 	return nil
 }
 
+func (s *service) Revert(ctx context.Context, sessionID string, force bool) ([]File, error) {
+	files, err := s.listBySessionTree(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !force {
+		if err := checkUnchanged(files, nil); err != nil {
+			return nil, err
+		}
+	}
+	var reverted []File
+	for _, file := range firstVersions(files) {
+		if err := s.revert(ctx, file, files); err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, file)
+	}
+	return reverted, nil
+}
+
+func (s *service) RevertFile(ctx context.Context, sessionID, path string, force bool) (File, error) {
+	files, err := s.listBySessionTree(ctx, sessionID)
+	if err != nil {
+		return File{}, err
+	}
+	for _, file := range firstVersions(files) {
+		if file.Path != path {
+			continue
+		}
+		if !force {
+			if err := checkUnchanged(files, []string{path}); err != nil {
+				return File{}, err
+			}
+		}
+		return file, s.revert(ctx, file, files)
+	}
+	return File{}, fmt.Errorf("file %s was not changed in session %s", path, sessionID)
+}
+
+// listBySessionTree lists the versions of the files changed in the session
+// and in the subagent sessions it started, sorted by version.
+func (s *service) listBySessionTree(ctx context.Context, sessionID string) ([]File, error) {
+	dbFiles, err := s.q.ListFilesBySessionTree(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, len(dbFiles))
+	for i, dbFile := range dbFiles {
+		files[i] = s.fromDBItem(dbFile)
+	}
+	return files, nil
+}
+
+// checkUnchanged returns ErrFileChanged, naming the files, if files whose
+// path is in paths, or any file when paths is nil, differ from their last
+// version in files. Deleted files haven't changed, reverting writes them
+// back.
+func checkUnchanged(files []File, paths []string) error {
+	last := make(map[string]File)
+	for _, file := range files {
+		last[file.Path] = file
+	}
+	var changed []string
+	for _, file := range firstVersions(files) {
+		if paths != nil && !slices.Contains(paths, file.Path) {
+			continue
+		}
+		content, err := os.ReadFile(file.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		// The tools record the content with Unix line endings.
+		unix, _ := fsext.ToUnixLineEndings(string(content))
+		if unix != last[file.Path].Content && string(content) != last[file.Path].Content {
+			changed = append(changed, file.Path)
+		}
+	}
+	if len(changed) > 0 {
+		slices.Sort(changed)
+		return fmt.Errorf("%w: %s", ErrFileChanged, strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// revert restores the first version of a file, removing it if the session
+// created it, then deletes the versions of the session listed in files.
+func (s *service) revert(ctx context.Context, first File, files []File) error {
+	if first.IsNew {
+		if err := os.Remove(first.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", first.Path, err)
+		}
+	} else {
+		perm := os.FileMode(0o644)
+		if info, err := os.Stat(first.Path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		// The file may have been deleted since, with its directory.
+		if err := os.MkdirAll(filepath.Dir(first.Path), 0o755); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", first.Path, err)
+		}
+		if err := fsext.WriteFileAtomic(first.Path, []byte(first.Content), perm); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", first.Path, err)
+		}
+	}
+	for _, file := range files {
+		if file.Path != first.Path {
+			continue
+		}
+		if err := s.Delete(ctx, file.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstVersions returns the oldest version of each file, in the order of
+// files, which is sorted by version.
+func firstVersions(files []File) []File {
+	seen := make(map[string]bool)
+	var first []File
+	for _, file := range files {
+		if !seen[file.Path] {
+			seen[file.Path] = true
+			first = append(first, file)
+		}
+	}
+	return first
+}
+
 func (s *service) fromDBItem(item db.File) File {
 	return File{
 		ID:        item.ID,
@@ -204,6 +364,7 @@ func (s *service) fromDBItem(item db.File) File {
 		Path:      item.Path,
 		Content:   item.Content,
 		Version:   item.Version,
+		IsNew:     item.IsNew,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}
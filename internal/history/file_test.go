@@ -0,0 +1,182 @@
+package history
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/db"
+)
+
+// newTestService returns a service storing the history of the given
+// sessions, child sessions named like parent/child, in a new database.
+func newTestService(t *testing.T, sessionIDs ...string) Service {
+	t.Helper()
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	for _, id := range sessionIDs {
+		params := db.CreateSessionParams{ID: id, Title: id}
+		if parent, _, ok := strings.Cut(id, "/"); ok {
+			params.ParentSessionID = sql.NullString{String: parent, Valid: true}
+		}
+		_, err := q.CreateSession(t.Context(), params)
+		require.NoError(t, err)
+	}
+	return NewService(q, conn)
+}
+
+// changeFile records what the tools do: the content before the first change
+// of the session, then the new content, written to the file.
+func changeFile(t *testing.T, svc Service, sessionID, path, before, after string) {
+	t.Helper()
+	if _, err := svc.GetByPathAndSession(t.Context(), path, sessionID); err != nil {
+		if before == "" {
+			_, err = svc.CreateNew(t.Context(), sessionID, path)
+		} else {
+			_, err = svc.Create(t.Context(), sessionID, path, before)
+		}
+		require.NoError(t, err)
+	}
+	_, err := svc.CreateVersion(t.Context(), sessionID, path, after)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(after), 0o600))
+}
+
+func TestRevert(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(t, "s1", "s2")
+
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited.go")
+	created := filepath.Join(dir, "pkg", "created.go")
+	deleted := filepath.Join(dir, "deleted", "deleted.go")
+	other := filepath.Join(dir, "other.go")
+
+	change := func(sessionID, path, before, after string) {
+		changeFile(t, svc, sessionID, path, before, after)
+	}
+	change("s1", edited, "v1", "v2")
+	change("s1", edited, "v2", "v3")
+	change("s1", created, "", "new")
+	change("s1", deleted, "keep me", "changed")
+	require.NoError(t, os.RemoveAll(filepath.Dir(deleted)))
+	change("s2", other, "other", "other changed")
+
+	file, err := svc.RevertFile(t.Context(), "s1", edited, false)
+	require.NoError(t, err)
+	require.Equal(t, "v1", file.Content)
+	requireContent(t, edited, "v1")
+	// Reverting keeps the mode of the file.
+	info, err := os.Stat(edited)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	_, err = svc.RevertFile(t.Context(), "s1", edited, false)
+	require.EqualError(t, err, "file "+edited+" was not changed in session s1")
+
+	reverted, err := svc.Revert(t.Context(), "s1", false)
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	require.NoFileExists(t, created)
+	requireContent(t, deleted, "keep me")
+	requireContent(t, other, "other changed")
+
+	files, err := svc.ListBySession(t.Context(), "s1")
+	require.NoError(t, err)
+	require.Empty(t, files)
+	files, err = svc.ListBySession(t.Context(), "s2")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	reverted, err = svc.Revert(t.Context(), "s1", false)
+	require.NoError(t, err)
+	require.Empty(t, reverted)
+}
+
+func TestRevertSubagentSessions(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(t, "s1", "s1/task", "s1/task/nested", "s2")
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	shared := filepath.Join(dir, "shared.go")
+	other := filepath.Join(dir, "other.go")
+
+	changeFile(t, svc, "s1", main, "main", "main changed")
+	changeFile(t, svc, "s1/task", shared, "shared", "shared by task")
+	changeFile(t, svc, "s1/task/nested", shared, "shared by task", "shared by nested")
+	changeFile(t, svc, "s1/task/nested", filepath.Join(dir, "created.go"), "", "created")
+	changeFile(t, svc, "s2", other, "other", "other changed")
+
+	// Reverting a subagent session leaves its parent alone.
+	file, err := svc.RevertFile(t.Context(), "s1/task/nested", shared, false)
+	require.NoError(t, err)
+	require.Equal(t, "shared by task", file.Content)
+	requireContent(t, shared, "shared by task")
+	requireContent(t, main, "main changed")
+
+	reverted, err := svc.Revert(t.Context(), "s1", false)
+	require.NoError(t, err)
+	require.Len(t, reverted, 3)
+	requireContent(t, main, "main")
+	requireContent(t, shared, "shared")
+	require.NoFileExists(t, filepath.Join(dir, "created.go"))
+	requireContent(t, other, "other changed")
+
+	for _, id := range []string{"s1", "s1/task", "s1/task/nested"} {
+		files, err := svc.ListBySession(t.Context(), id)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	}
+}
+
+func TestRevertChangedFile(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(t, "s1", "s1/task", "s2")
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "edited.go")
+	crlf := filepath.Join(dir, "crlf.go")
+	other := filepath.Join(dir, "other.go")
+
+	changeFile(t, svc, "s1", edited, "v1", "v2")
+	changeFile(t, svc, "s1/task", crlf, "a\nb\n", "a\nc\n")
+	// The edit tool keeps Windows line endings but records Unix ones.
+	require.NoError(t, os.WriteFile(crlf, []byte("a\r\nc\r\n"), 0o600))
+	changeFile(t, svc, "s1", other, "other", "other changed")
+	// Another session, then the user, change the files after the session.
+	changeFile(t, svc, "s2", other, "other changed", "changed by s2")
+	require.NoError(t, os.WriteFile(edited, []byte("changed by the user"), 0o600))
+
+	_, err := svc.Revert(t.Context(), "s1", false)
+	require.ErrorIs(t, err, ErrFileChanged)
+	require.EqualError(t, err, "files changed since the session last changed them: "+edited+", "+other)
+	requireContent(t, edited, "changed by the user")
+	requireContent(t, crlf, "a\r\nc\r\n")
+
+	_, err = svc.RevertFile(t.Context(), "s1", other, false)
+	require.ErrorIs(t, err, ErrFileChanged)
+	file, err := svc.RevertFile(t.Context(), "s1", crlf, false)
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\n", file.Content)
+
+	reverted, err := svc.Revert(t.Context(), "s1", true)
+	require.NoError(t, err)
+	require.Len(t, reverted, 2)
+	requireContent(t, edited, "v1")
+	requireContent(t, other, "other")
+}
+
+func requireContent(t *testing.T, path, want string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, want, string(content))
+}
@@ -4,16 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/llm/tools"
 	"github.com/tulpa-code/tulpa/internal/message"
 	"github.com/tulpa-code/tulpa/internal/session"
 )
 
 type agentTool struct {
-	agent    Service
+	agentCfg config.Agent
+	newAgent func(agentID string) (Service, error)
 	sessions session.Service
 	messages message.Service
+	maxDepth int
+	// slots caps how many subagents the agent runs at once
+	slots chan struct{}
+	// chargeMu keeps the subagents running at once from overwriting the
+	// cost each one adds to the parent session
+	chargeMu sync.Mutex
 }
 
 const (
@@ -22,6 +33,15 @@ const (
 
 type AgentParams struct {
 	Prompt string `json:"prompt"`
+	Agent  string `json:"agent,omitempty"`
+}
+
+type subagentDepthKey struct{}
+
+// subagentDepth returns how many agent tool calls deep ctx is.
+func subagentDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(subagentDepthKey{}).(int)
+	return depth
 }
 
 func (b *agentTool) Name() string {
@@ -29,19 +49,43 @@ func (b *agentTool) Name() string {
 }
 
 func (b *agentTool) Info() tools.ToolInfo {
+	var agents strings.Builder
+	for _, id := range b.agentCfg.Subagents {
+		subagent := config.Get().Agents()[id]
+		fmt.Fprintf(&agents, "- %s", id)
+		if subagent.Description != "" {
+			fmt.Fprintf(&agents, ": %s", subagent.Description)
+		}
+		if len(subagent.AllowedTools) > 0 {
+			fmt.Fprintf(&agents, " (tools: %s)", strings.Join(subagent.AllowedTools, ", "))
+		}
+		agents.WriteString("\n")
+	}
 	return tools.ToolInfo{
 		Name:        AgentToolName,
-		Description: "Launch a new agent that has access to the following tools: GlobTool, GrepTool, LS, View. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you. For example:\n\n- If you are searching for a keyword like \"config\" or \"logger\", or for questions like \"which file does X?\", the Agent tool is strongly recommended\n- If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly\n- If you are searching for a specific class definition like \"class Foo\", use the GlobTool tool instead, to find the match more quickly\n\nUsage notes:\n1. Launch multiple agents concurrently whenever possible, to maximize performance; to do that, use a single message with multiple tool uses\n2. When the agent is done, it will return a single message back to you. The result returned by the agent is not visible to the user. To show the user the result, you should send a text message back to the user with a concise summary of the result.\n3. Each agent invocation is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.\n4. The agent's outputs should generally be trusted\n5. IMPORTANT: The agent can not use Bash, Replace, Edit, so can not modify files. If you want to use these tools, use them directly instead of going through the agent.",
+		Description: "Launch a new agent to perform a task autonomously. The following agents are available:\n\n" + agents.String() + "\nWhen you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you. For example:\n\n- If you are searching for a keyword like \"config\" or \"logger\", or for questions like \"which file does X?\", the Agent tool is strongly recommended\n- If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly\n- If you are searching for a specific class definition like \"class Foo\", use the GlobTool tool instead, to find the match more quickly\n\nUsage notes:\n1. Launch multiple agents concurrently whenever possible, to maximize performance; to do that, use a single message with multiple tool uses\n2. When the agent is done, it will return a single message back to you. The result returned by the agent is not visible to the user. To show the user the result, you should send a text message back to the user with a concise summary of the result.\n3. Each agent invocation is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.\n4. The agent's outputs should generally be trusted\n5. IMPORTANT: An agent can only use the tools listed for it above. If you want to use other tools, use them directly instead of going through the agent.",
 		Parameters: map[string]any{
 			"prompt": map[string]any{
 				"type":        "string",
 				"description": "The task for the agent to perform",
 			},
+			"agent": map[string]any{
+				"type":        "string",
+				"description": "The agent to launch (default " + b.defaultSubagent() + ")",
+				"enum":        b.agentCfg.Subagents,
+			},
 		},
 		Required: []string{"prompt"},
 	}
 }
 
+func (b *agentTool) defaultSubagent() string {
+	if b.agentCfg.DefaultSubagent != "" {
+		return b.agentCfg.DefaultSubagent
+	}
+	return b.agentCfg.Subagents[0]
+}
+
 func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
 	var params AgentParams
 	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
@@ -50,6 +94,16 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	if params.Prompt == "" {
 		return tools.NewTextErrorResponse("prompt is required"), nil
 	}
+	if params.Agent == "" {
+		params.Agent = b.defaultSubagent()
+	}
+	if !slices.Contains(b.agentCfg.Subagents, params.Agent) {
+		return tools.NewTextErrorResponse(fmt.Sprintf("agent %q is not available, use one of: %s", params.Agent, strings.Join(b.agentCfg.Subagents, ", "))), nil
+	}
+	depth := subagentDepth(ctx)
+	if depth >= b.maxDepth {
+		return tools.NewTextErrorResponse(fmt.Sprintf("agents may not delegate more than %d levels deep, perform the task yourself", b.maxDepth)), nil
+	}
 
 	sessionID, messageID := tools.GetContextValues(ctx)
 	if sessionID == "" || messageID == "" {
@@ -60,12 +114,31 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error creating session: %s", err)
 	}
+	// The subagent may not use the tools its parent session forbids.
+	SetSessionDisabledTools(session.ID, SessionDisabledTools(sessionID))
+	defer SetSessionDisabledTools(session.ID, nil)
+
+	select {
+	case b.slots <- struct{}{}:
+		defer func() { <-b.slots }()
+	case <-ctx.Done():
+		return tools.ToolResponse{}, ctx.Err()
+	}
 
-	done, err := b.agent.Run(ctx, session.ID, params.Prompt)
+	subagent, err := b.newAgent(params.Agent)
+	if err != nil {
+		return tools.ToolResponse{}, fmt.Errorf("error creating agent %s: %w", params.Agent, err)
+	}
+	done, err := subagent.Run(context.WithValue(ctx, subagentDepthKey{}, depth+1), session.ID, params.Prompt)
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error generating agent: %s", err)
 	}
 	result := <-done
+	// The subagent spent on behalf of the parent session even when it failed
+	// or was canceled.
+	if err := b.chargeParent(context.WithoutCancel(ctx), sessionID, session.ID); err != nil {
+		return tools.ToolResponse{}, err
+	}
 	if result.Error != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error generating agent: %s", result.Error)
 	}
@@ -74,33 +147,49 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	if response.Role != message.Assistant {
 		return tools.NewTextErrorResponse("no response"), nil
 	}
+	return tools.NewTextResponse(response.Content().String()), nil
+}
 
-	updatedSession, err := b.sessions.Get(ctx, session.ID)
+// chargeParent adds the cost and the tokens of the ended subagent session to
+// the session that delegated to it, which in turn charges its own parent when
+// it ends.
+func (b *agentTool) chargeParent(ctx context.Context, parentID, sessionID string) error {
+	b.chargeMu.Lock()
+	defer b.chargeMu.Unlock()
+	updatedSession, err := b.sessions.Get(ctx, sessionID)
 	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error getting session: %s", err)
+		return fmt.Errorf("error getting session: %s", err)
 	}
-	parentSession, err := b.sessions.Get(ctx, sessionID)
+	parentSession, err := b.sessions.Get(ctx, parentID)
 	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error getting parent session: %s", err)
+		return fmt.Errorf("error getting parent session: %s", err)
 	}
 
 	parentSession.Cost += updatedSession.Cost
+	sessionTokens.add(parentID, sessionTokens.take(sessionID))
 
-	_, err = b.sessions.Save(ctx, parentSession)
-	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error saving parent session: %s", err)
+	if _, err := b.sessions.Save(ctx, parentSession); err != nil {
+		return fmt.Errorf("error saving parent session: %s", err)
 	}
-	return tools.NewTextResponse(response.Content().String()), nil
+	return nil
 }
 
+// NewAgentTool returns the tool an agent uses to delegate tasks to its
+// subagents. newAgent creates the subagent with the given ID for each call.
 func NewAgentTool(
-	agent Service,
+	agentCfg config.Agent,
+	newAgent func(agentID string) (Service, error),
 	sessions session.Service,
 	messages message.Service,
+	maxDepth int,
+	maxConcurrent int,
 ) tools.BaseTool {
 	return &agentTool{
+		agentCfg: agentCfg,
+		newAgent: newAgent,
 		sessions: sessions,
 		messages: messages,
-		agent:    agent,
+		maxDepth: maxDepth,
+		slots:    make(chan struct{}, maxConcurrent),
 	}
 }
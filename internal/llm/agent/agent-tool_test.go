@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/db"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+// spendingSubagent spends cost and tokens in the session it runs in, then
+// answers or fails with err.
+type spendingSubagent struct {
+	Service
+	sessions session.Service
+	cost     float64
+	tokens   int64
+	err      error
+}
+
+func (s *spendingSubagent) Run(ctx context.Context, sessionID string, _ string, _ ...message.Attachment) (<-chan AgentEvent, error) {
+	sess, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	sess.Cost += s.cost
+	if _, err := s.sessions.Save(ctx, sess); err != nil {
+		return nil, err
+	}
+	sessionTokens.add(sessionID, s.tokens)
+
+	events := make(chan AgentEvent, 1)
+	if s.err != nil {
+		events <- AgentEvent{Type: AgentEventTypeError, Error: s.err}
+	} else {
+		events <- AgentEvent{Type: AgentEventTypeResponse, Message: message.Message{
+			Role:  message.Assistant,
+			Parts: []message.ContentPart{message.TextContent{Text: "done"}},
+		}}
+	}
+	close(events)
+	return events, nil
+}
+
+func TestAgentToolRejectsDelegation(t *testing.T) {
+	t.Parallel()
+
+	newAgent := func(string) (Service, error) {
+		t.Fatal("no subagent should be created")
+		return nil, nil
+	}
+	tool := NewAgentTool(config.Agent{ID: "coder", Subagents: []string{"task"}}, newAgent, nil, nil, 2, 1)
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		input string
+		want  string
+	}{
+		{
+			name:  "unknown agent",
+			ctx:   t.Context(),
+			input: `{"prompt":"find it","agent":"coder"}`,
+			want:  `agent "coder" is not available, use one of: task`,
+		},
+		{
+			name:  "too deep",
+			ctx:   context.WithValue(t.Context(), subagentDepthKey{}, 2),
+			input: `{"prompt":"find it"}`,
+			want:  "agents may not delegate more than 2 levels deep, perform the task yourself",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			response, err := tool.Run(tt.ctx, tools.ToolCall{ID: "call_1", Name: AgentToolName, Input: tt.input})
+			require.NoError(t, err)
+			require.True(t, response.IsError)
+			require.Equal(t, tt.want, response.Content)
+		})
+	}
+}
+
+func TestAgentToolChargesParent(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	sessions := session.NewService(db.New(conn))
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "answered"},
+		{name: "failed", err: errors.New("provider unavailable")},
+		{name: "canceled", err: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			parent, err := sessions.Create(t.Context(), "Parent")
+			require.NoError(t, err)
+			t.Cleanup(func() { ClearSessionUsage(parent.ID) })
+			parent.Cost = 1
+			_, err = sessions.Save(t.Context(), parent)
+			require.NoError(t, err)
+			sessionTokens.add(parent.ID, 100)
+
+			newAgent := func(string) (Service, error) {
+				return &spendingSubagent{sessions: sessions, cost: 0.25, tokens: 40, err: tt.err}, nil
+			}
+			tool := NewAgentTool(config.Agent{ID: "coder", Subagents: []string{"task"}}, newAgent, sessions, nil, 2, 1)
+			ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, parent.ID)
+			ctx = context.WithValue(ctx, tools.MessageIDContextKey, "message")
+			response, err := tool.Run(ctx, tools.ToolCall{ID: parent.ID + "-call", Name: AgentToolName, Input: `{"prompt":"find it"}`})
+			if tt.err != nil {
+				require.ErrorContains(t, err, tt.err.Error())
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "done", response.Content)
+			}
+
+			parent, err = sessions.Get(t.Context(), parent.ID)
+			require.NoError(t, err)
+			require.InDelta(t, 1.25, parent.Cost, 1e-9)
+			require.Equal(t, int64(140), sessionTokens.get(parent.ID))
+			require.Zero(t, sessionTokens.get(parent.ID+"-call"), "the tokens of the subagent session are no longer counted")
+		})
+	}
+}
@@ -3,15 +3,19 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/tulpa-code/tulpa/internal/checkpoint"
 	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/csync"
 	"github.com/tulpa-code/tulpa/internal/event"
@@ -34,6 +38,30 @@ const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+
+	// Sent when the final output of an agent using the JSON response format
+	// could not be parsed and the model is asked to correct it.
+	AgentEventTypeJSONCorrection AgentEventType = "json_correction"
+
+	// Sent when a provider request failed with a transient error and is
+	// about to be retried.
+	AgentEventTypeRetry AgentEventType = "retry"
+
+	// Sent when the model of an agent is unavailable and a fallback model
+	// answers instead.
+	AgentEventTypeFallback AgentEventType = "fallback"
+
+	// Sent when an agent approaches or reaches the number of times it may
+	// call tools in a turn.
+	AgentEventTypeToolLimit AgentEventType = "tool_limit"
+
+	// Sent when the conversation of a session fills its context window and
+	// its older messages are about to be replaced with a summary.
+	AgentEventTypeCompaction AgentEventType = "compaction"
+
+	// Sent when an agent starts a step of the plan it announced with the
+	// progress tool.
+	AgentEventTypeStep AgentEventType = "step"
 )
 
 type AgentEvent struct {
@@ -41,10 +69,28 @@ type AgentEvent struct {
 	Message message.Message
 	Error   error
 
+	// The parsed final output for agents using the JSON response format
+	JSON json.RawMessage
+
 	// When summarizing
 	SessionID string
 	Progress  string
 	Done      bool
+
+	// When retrying
+	Retry *provider.RetryInfo
+
+	// When falling back to another model
+	Fallback *provider.FallbackInfo
+
+	// When approaching the tool iteration limit
+	ToolLimit *ToolLimitInfo
+
+	// When compacting the conversation
+	Compaction *CompactionInfo
+
+	// When starting a step of a plan
+	Step *StepInfo
 }
 
 type Service interface {
@@ -57,13 +103,27 @@ type Service interface {
 	IsBusy() bool
 	Summarize(ctx context.Context, sessionID string) error
 	UpdateModel() error
+	UpdateConfig(agentCfg config.Agent) error
+	DryRun(ctx context.Context, sessionID string, content string) (DryRun, error)
+	PromptTokens() int64
 	QueuedPrompts(sessionID string) int
+	// ClearQueue drops the queued prompts and the steering of the session
+	// not sent yet.
 	ClearQueue(sessionID string)
+	// Steer sends content to the running turn of the session at its next
+	// tool-call boundary, without canceling it. It returns false when the
+	// session isn't running.
+	Steer(sessionID, content string) bool
+	// Steering returns the steering messages of the session not sent yet.
+	Steering(sessionID string) []string
 }
 
 type agent struct {
 	*pubsub.Broker[AgentEvent]
-	agentCfg    config.Agent
+	// The config of the agent and the provider of its model, replaced
+	// under updateMu
+	current     atomic.Pointer[agentModel]
+	updateMu    sync.Mutex
 	sessions    session.Service
 	messages    message.Service
 	permissions permission.Service
@@ -71,19 +131,33 @@ type agent struct {
 	mcpTools    *csync.Map[string, tools.BaseTool]
 	lspClients  *csync.Map[string, *lsp.Client]
 
-	// We need this to be able to update it when model changes
-	agentToolFn  func() (tools.BaseTool, error)
+	// newAgentTool returns the tool delegating to the subagents of an agent
+	// config, nil when it has none
+	newAgentTool func(agentCfg config.Agent) tools.BaseTool
 	cleanupFuncs []func()
 
-	provider   provider.Provider
-	providerID string
-
 	titleProvider       provider.Provider
 	summarizeProvider   provider.Provider
 	summarizeProviderID string
 
 	activeRequests *csync.Map[string, context.CancelFunc]
 	promptQueue    *csync.Map[string, []string]
+	// mu makes starting and ending turns atomic with queueing prompts
+	mu sync.Mutex
+	// Messages redirecting the running turns, sent at their next tool call
+	steering *csync.Map[string, []string]
+	// Title generations still running
+	titles sync.WaitGroup
+}
+
+// agentModel is the config of an agent with the provider of its model. They
+// are replaced as a whole when the config is reloaded, while turns run.
+type agentModel struct {
+	cfg        config.Agent
+	provider   provider.Provider
+	providerID string
+	// The tool delegating to the subagents of cfg, nil when it has none
+	agentTool tools.BaseTool
 }
 
 var agentPromptMap = map[string]prompt.PromptID{
@@ -103,19 +177,19 @@ func NewAgent(
 ) (Service, error) {
 	cfg := config.Get()
 
-	var agentToolFn func() (tools.BaseTool, error)
-	if agentCfg.ID == "coder" && slices.Contains(agentCfg.AllowedTools, AgentToolName) {
-		agentToolFn = func() (tools.BaseTool, error) {
-			taskAgentCfg := config.Get().Agents["task"]
-			if taskAgentCfg.ID == "" {
-				return nil, fmt.Errorf("task agent not found in config")
-			}
-			taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, lspClients)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create task agent: %w", err)
-			}
-			return NewAgentTool(taskAgent, sessions, messages), nil
+	newSubagent := func(agentID string) (Service, error) {
+		subagentCfg, ok := config.Get().Agents()[agentID]
+		if !ok || subagentCfg.Disabled {
+			return nil, fmt.Errorf("%s agent not found in config", agentID)
 		}
+		return NewAgent(ctx, subagentCfg, permissions, sessions, messages, history, lspClients)
+	}
+	newAgentTool := func(agentCfg config.Agent) tools.BaseTool {
+		if len(agentCfg.Subagents) == 0 || !slices.Contains(agentCfg.AllowedTools, AgentToolName) {
+			return nil
+		}
+		maxDepth, maxConcurrent := config.Get().Options.SubagentLimits()
+		return NewAgentTool(agentCfg, newSubagent, sessions, messages, maxDepth, maxConcurrent)
 	}
 
 	providerCfg := config.Get().GetProviderForModel(agentCfg.Model)
@@ -128,13 +202,9 @@ func NewAgent(
 		return nil, fmt.Errorf("model not found for agent %s", agentCfg.Name)
 	}
 
-	promptID := agentPromptMap[agentCfg.ID]
-	if promptID == "" {
-		promptID = prompt.PromptDefault
-	}
 	opts := []provider.ProviderClientOption{
 		provider.WithModel(agentCfg.Model),
-		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, config.Get().Options.ContextPaths...)),
+		provider.WithSystemMessage(agentSystemMessage(agentCfg, providerCfg.ID)),
 	}
 	agentProvider, err := provider.NewProvider(*providerCfg, opts...)
 	if err != nil {
@@ -183,9 +253,10 @@ func NewAgent(
 
 		// Base tools available to all agents
 		cwd := cfg.WorkingDir()
+		_, bashMaxOutput := cfg.Options.BashLimits()
 		result := make(map[string]tools.BaseTool)
 		for _, tool := range []tools.BaseTool{
-			tools.NewBashTool(permissions, cwd, cfg.Options.Attribution),
+			newBashTool(permissions, agentCfg),
 			tools.NewDownloadTool(permissions, cwd),
 			tools.NewEditTool(lspClients, permissions, history, cwd),
 			tools.NewMultiEditTool(lspClients, permissions, history, cwd),
@@ -193,6 +264,8 @@ func NewAgent(
 			tools.NewGlobTool(cwd),
 			tools.NewGrepTool(cwd),
 			tools.NewLsTool(permissions, cwd),
+			tools.NewProgressTool(),
+			tools.NewJobsTool(bashMaxOutput),
 			tools.NewSourcegraphTool(),
 			tools.NewViewTool(lspClients, permissions, cwd),
 			tools.NewWriteTool(lspClients, permissions, history, cwd),
@@ -216,28 +289,70 @@ func NewAgent(
 
 	a := &agent{
 		Broker:              pubsub.NewBroker[AgentEvent](),
-		agentCfg:            agentCfg,
-		provider:            agentProvider,
-		providerID:          string(providerCfg.ID),
 		messages:            messages,
 		sessions:            sessions,
 		titleProvider:       titleProvider,
 		summarizeProvider:   summarizeProvider,
 		summarizeProviderID: string(providerCfg.ID),
-		agentToolFn:         agentToolFn,
+		newAgentTool:        newAgentTool,
 		activeRequests:      csync.NewMap[string, context.CancelFunc](),
 		mcpTools:            csync.NewLazyMap(mcpToolsFn),
 		baseTools:           csync.NewLazyMap(baseToolsFn),
 		promptQueue:         csync.NewMap[string, []string](),
+		steering:            csync.NewMap[string, []string](),
 		permissions:         permissions,
 		lspClients:          lspClients,
 	}
+	a.current.Store(&agentModel{
+		cfg:        agentCfg,
+		provider:   withFallbacks(agentCfg, agentProvider, providerCfg.ID),
+		providerID: string(providerCfg.ID),
+		agentTool:  newAgentTool(agentCfg),
+	})
 	a.setupEvents(ctx)
 	return a, nil
 }
 
+// newBashTool returns the bash tool of an agent, running the commands its
+// config allows with its environment.
+func newBashTool(permissions permission.Service, agentCfg config.Agent) tools.BaseTool {
+	cfg := config.Get()
+	timeout, maxOutput := cfg.Options.BashLimits()
+	return tools.NewBashTool(permissions, cfg.WorkingDir(), cfg.Options.Attribution, agentCfg.BashCommands, cfg.BashEnv(agentCfg), timeout, maxOutput)
+}
+
 func (a *agent) Model() catwalk.Model {
-	return *config.Get().GetModelByType(a.agentCfg.Model)
+	return *config.Get().GetModelByType(a.current.Load().cfg.Model)
+}
+
+// PromptTokens estimates how many tokens the system prompt of the agent,
+// context files included, takes for its current model.
+func (a *agent) PromptTokens() int64 {
+	return prompt.EstimateTokens(a.systemPrompt())
+}
+
+// systemPrompt returns the system prompt the agent sends, with the prefix
+// configured for its provider, and the type of that provider.
+func (a *agent) systemPrompt() (string, catwalk.Type) {
+	current := a.current.Load()
+	systemPrompt := agentSystemMessage(current.cfg, current.providerID)
+	providerCfg := config.Get().GetProviderForModel(current.cfg.Model)
+	if providerCfg == nil {
+		return systemPrompt, ""
+	}
+	if providerCfg.SystemPromptPrefix != "" {
+		systemPrompt = providerCfg.SystemPromptPrefix + "\n" + systemPrompt
+	}
+	return systemPrompt, providerCfg.Type
+}
+
+// messageModel returns the model that answered msg, which is not the model
+// of the agent after a fallback.
+func (a *agent) messageModel(msg message.Message) catwalk.Model {
+	if model := config.Get().GetModel(msg.Provider, msg.Model); model != nil {
+		return *model
+	}
+	return a.Model()
 }
 
 func (a *agent) Cancel(sessionID string) {
@@ -253,10 +368,10 @@ func (a *agent) Cancel(sessionID string) {
 		cancel()
 	}
 
-	if a.QueuedPrompts(sessionID) > 0 {
+	if queued, _ := a.takeQueuedPrompts(sessionID); len(queued) > 0 {
 		slog.Info("Clearing queued prompts", "session_id", sessionID)
-		a.promptQueue.Del(sessionID)
 	}
+	a.takeSteering(sessionID)
 }
 
 func (a *agent) IsBusy() bool {
@@ -275,6 +390,45 @@ func (a *agent) IsSessionBusy(sessionID string) bool {
 	return busy
 }
 
+// startTurn makes the turn canceled with cancel the running turn of the
+// session, and reports whether it may start. When the session already runs
+// a turn, content is queued behind it, or ErrSessionBusy is returned if
+// reject is set.
+func (a *agent) startTurn(sessionID, content string, cancel context.CancelFunc, reject bool) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.IsSessionBusy(sessionID) {
+		if reject {
+			return false, ErrSessionBusy
+		}
+		queued, _ := a.promptQueue.Get(sessionID)
+		a.promptQueue.Set(sessionID, append(queued, content))
+		return false, nil
+	}
+	a.activeRequests.Set(sessionID, cancel)
+	return true, nil
+}
+
+// endTurn ends the running turn of the session. It returns what the turn
+// won't answer: the steering it had no tool call left to send, then the
+// prompts queued after it took the last ones.
+func (a *agent) endTurn(sessionID string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.activeRequests.Del(sessionID)
+	steering, _ := a.steering.Take(sessionID)
+	queued, _ := a.promptQueue.Take(sessionID)
+	return append(steering, queued...)
+}
+
+// takeQueuedPrompts returns the prompts queued for the session and removes
+// them from the queue.
+func (a *agent) takeQueuedPrompts(sessionID string) ([]string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.promptQueue.Take(sessionID)
+}
+
 func (a *agent) QueuedPrompts(sessionID string) int {
 	l, ok := a.promptQueue.Get(sessionID)
 	if !ok {
@@ -288,7 +442,7 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 		return nil
 	}
 	if a.titleProvider == nil {
-		return nil
+		return errors.New("no title provider")
 	}
 	session, err := a.sessions.Get(ctx, sessionID)
 	if err != nil {
@@ -330,7 +484,7 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 
 	title = strings.TrimSpace(title)
 	if title == "" {
-		return nil
+		return errors.New("title provider returned an empty title")
 	}
 
 	session.Title = title
@@ -345,24 +499,28 @@ func (a *agent) err(err error) AgentEvent {
 	}
 }
 
+// cancelled reports a cancelled request along with the partial response
+// generated before it was cancelled.
+func (a *agent) cancelled(msg message.Message) AgentEvent {
+	return AgentEvent{
+		Type:    AgentEventTypeError,
+		Message: msg,
+		Error:   ErrRequestCancelled,
+	}
+}
+
 func (a *agent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
 	if !a.Model().SupportsImages && attachments != nil {
 		attachments = nil
 	}
 	events := make(chan AgentEvent, 1)
-	if a.IsSessionBusy(sessionID) {
-		existing, ok := a.promptQueue.Get(sessionID)
-		if !ok {
-			existing = []string{}
-		}
-		existing = append(existing, content)
-		a.promptQueue.Set(sessionID, existing)
-		return nil, nil
-	}
-
 	genCtx, cancel := context.WithCancel(ctx)
-	a.activeRequests.Set(sessionID, cancel)
+	if started, err := a.startTurn(sessionID, content, cancel, config.Get().Options.RejectBusyPrompts); !started {
+		cancel()
+		return nil, err
+	}
 	startTime := time.Now()
+	a.warnDisabledTools(sessionID)
 
 	go func() {
 		slog.Debug("Request started", "sessionID", sessionID)
@@ -373,7 +531,13 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 		for _, attachment := range attachments {
 			attachmentParts = append(attachmentParts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
 		}
-		result := a.processGeneration(genCtx, sessionID, content, attachmentParts)
+		runCtx, watchdog := withActivityWatchdog(genCtx, config.Get().InactivityTimeout(a.current.Load().cfg))
+		defer watchdog.Stop()
+		// Changes made since the last turn are kept apart from the ones of
+		// this turn.
+		a.checkpoint(genCtx, sessionID, "Changes made outside of the session")
+		result := a.processGeneration(runCtx, sessionID, content, attachmentParts)
+		a.checkpoint(context.WithoutCancel(genCtx), sessionID, content)
 		if result.Error != nil {
 			if isCancelledErr(result.Error) {
 				slog.Error("Request canceled", "sessionID", sessionID)
@@ -385,50 +549,60 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 			slog.Debug("Request completed", "sessionID", sessionID)
 		}
 		a.eventPromptResponded(sessionID, time.Since(startTime).Truncate(time.Second))
-		a.activeRequests.Del(sessionID)
+		queued := a.endTurn(sessionID)
 		cancel()
 		a.Publish(pubsub.CreatedEvent, result)
 		events <- result
 		close(events)
+		// The steering the turn ended before sending and the prompts queued
+		// while it was ending start the next one. They run even when the
+		// context of this turn is done, they are cancelled like any turn.
+		for _, prompt := range queued {
+			if _, err := a.Run(context.WithoutCancel(ctx), sessionID, prompt); err != nil {
+				slog.Error("Failed to run queued prompt", "sessionID", sessionID, "error", err)
+			}
+		}
 	}()
 	a.eventPromptSent(sessionID)
 	return events, nil
 }
 
+// checkpoint commits the working directory to the git branch of the session
+// when git checkpoints are enabled. The changes of subagents are committed
+// with the turn that called them.
+func (a *agent) checkpoint(ctx context.Context, sessionID, prompt string) {
+	cfg := config.Get()
+	if !cfg.Options.GitCheckpoints {
+		return
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil || sess.ParentSessionID != "" {
+		return
+	}
+	commit, err := checkpoint.Save(ctx, cfg.WorkingDir(), sessionID, checkpoint.Message(sessionID, prompt), cfg.Options.DataDirectory)
+	if err != nil {
+		slog.Warn("Failed to save the git checkpoint", "sessionID", sessionID, "error", err)
+		return
+	}
+	if commit != "" {
+		slog.Debug("Saved git checkpoint", "sessionID", sessionID, "branch", checkpoint.Branch(sessionID), "commit", commit)
+	}
+}
+
 func (a *agent) processGeneration(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) AgentEvent {
 	cfg := config.Get()
-	// List existing messages; if none, start title generation asynchronously.
 	msgs, err := a.messages.List(ctx, sessionID)
 	if err != nil {
 		return a.err(fmt.Errorf("failed to list messages: %w", err))
 	}
-	if len(msgs) == 0 {
-		go func() {
-			defer log.RecoverPanic("agent.Run", func() {
-				slog.Error("panic while generating title")
-			})
-			titleErr := a.generateTitle(ctx, sessionID, content)
-			if titleErr != nil && !errors.Is(titleErr, context.Canceled) && !errors.Is(titleErr, context.DeadlineExceeded) {
-				slog.Error("failed to generate title", "error", titleErr)
-			}
-		}()
-	}
-	session, err := a.sessions.Get(ctx, sessionID)
+	// Sessions are titled once the model first answered, so the title
+	// reflects the exchange and not just the prompt.
+	needsTitle := !slices.ContainsFunc(msgs, func(msg message.Message) bool {
+		return msg.Role == message.Assistant
+	})
+	msgs, err = a.fromSummary(ctx, sessionID, msgs)
 	if err != nil {
-		return a.err(fmt.Errorf("failed to get session: %w", err))
-	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
-		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
-		}
+		return a.err(err)
 	}
 
 	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
@@ -437,33 +611,74 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 	// Append the new user message to the conversation history.
 	msgHistory := append(msgs, userMsg)
+	jsonCorrected := false
+	// Tool iterations of the turn, the agent is asked to answer once it used
+	// them all.
+	toolLimit := cfg.MaxToolIterations(a.current.Load().cfg)
+	toolIterations := 0
 
 	for {
 		// Check for cancellation before each iteration
 		select {
 		case <-ctx.Done():
-			return a.err(ctx.Err())
+			return a.err(context.Cause(ctx))
 		default:
 			// Continue processing
 		}
+		if err := a.checkBudget(ctx, sessionID); err != nil {
+			return a.err(err)
+		}
+		if compacted, err := a.compact(ctx, sessionID, msgHistory); err != nil {
+			if ctx.Err() != nil {
+				return a.err(context.Cause(ctx))
+			}
+			// The request may still fit, the provider tells otherwise.
+			slog.Warn("Failed to compact the conversation", "sessionID", sessionID, "error", err)
+		} else {
+			msgHistory = compacted
+		}
 		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
 		if err != nil {
+			if errors.Is(context.Cause(ctx), ErrNoActivity) {
+				agentMessage.AddFinish(message.FinishReasonError, "No activity", ErrNoActivity.Error())
+				_ = a.messages.Update(context.Background(), agentMessage)
+				return a.err(ErrNoActivity)
+			}
+			if errors.Is(err, ErrStreamStalled) {
+				return a.err(ErrStreamStalled)
+			}
 			if errors.Is(err, context.Canceled) {
 				agentMessage.AddFinish(message.FinishReasonCanceled, "Request cancelled", "")
 				a.messages.Update(context.Background(), agentMessage)
-				return a.err(ErrRequestCancelled)
+				return a.cancelled(agentMessage)
 			}
 			return a.err(fmt.Errorf("failed to process events: %w", err))
 		}
+		if needsTitle {
+			needsTitle = false
+			a.generateTitleAsync(ctx, sessionID, content, agentMessage.Content().Text)
+		}
 		if cfg.Options.Debug {
 			slog.Info("Result", "message", agentMessage.FinishReason(), "toolResults", toolResults)
 		}
 		if (agentMessage.FinishReason() == message.FinishReasonToolUse) && toolResults != nil {
+			if toolLimit > 0 && toolIterations >= toolLimit {
+				return a.err(fmt.Errorf("%w: the agent called tools again after using its %d tool iterations", ErrToolLimit, toolLimit))
+			}
 			// We are not done, we need to respond with the tool response
 			msgHistory = append(msgHistory, agentMessage, *toolResults)
+			toolIterations++
+			// Steering redirects the turn, which goes on.
+			steering, err := a.steeringMessages(ctx, sessionID)
+			if err != nil {
+				return a.err(err)
+			}
+			msgHistory = append(msgHistory, steering...)
 			// If there are queued prompts, process the next one
-			nextPrompt, ok := a.promptQueue.Take(sessionID)
+			nextPrompt, ok := a.takeQueuedPrompts(sessionID)
 			if ok {
+				// They start a new turn
+				toolIterations = 0
 				for _, prompt := range nextPrompt {
 					// Create a new user message for the queued prompt
 					userMsg, err := a.createUserMessage(ctx, sessionID, prompt, nil)
@@ -474,10 +689,36 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 					msgHistory = append(msgHistory, userMsg)
 				}
 			}
+			if toolLimit > 0 && toolIterations > 0 && (toolIterations >= toolLimit || toolIterations == toolLimitWarningAt(toolLimit)) {
+				info := &ToolLimitInfo{Used: toolIterations, Max: toolLimit}
+				slog.Warn("Agent approaches its tool iteration limit", "sessionID", sessionID, "used", info.Used, "max", info.Max)
+				a.Publish(pubsub.CreatedEvent, AgentEvent{
+					Type:      AgentEventTypeToolLimit,
+					SessionID: sessionID,
+					ToolLimit: info,
+				})
+				if info.Reached() {
+					userMsg, err := a.createUserMessage(ctx, sessionID, toolLimitPrompt(toolLimit), nil)
+					if err != nil {
+						return a.err(fmt.Errorf("failed to create user message for the tool limit: %w", err))
+					}
+					msgHistory = append(msgHistory, userMsg)
+				}
+			}
 
 			continue
 		} else if agentMessage.FinishReason() == message.FinishReasonEndTurn {
-			queuePrompts, ok := a.promptQueue.Take(sessionID)
+			// The turn ended before a tool call could take the steering.
+			steering, err := a.steeringMessages(ctx, sessionID)
+			if err != nil {
+				return a.err(err)
+			}
+			if len(steering) > 0 {
+				msgHistory = append(msgHistory, agentMessage)
+				msgHistory = append(msgHistory, steering...)
+				continue
+			}
+			queuePrompts, ok := a.takeQueuedPrompts(sessionID)
 			if ok {
 				for _, prompt := range queuePrompts {
 					if prompt == "" {
@@ -496,16 +737,69 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			// Kujtim: could not track down where this is happening but this means its cancelled
 			agentMessage.AddFinish(message.FinishReasonCanceled, "Request cancelled", "")
 			_ = a.messages.Update(context.Background(), agentMessage)
-			return a.err(ErrRequestCancelled)
+			return a.cancelled(agentMessage)
+		}
+		var structured json.RawMessage
+		if a.current.Load().cfg.ResponseFormat == config.ResponseFormatJSON {
+			parsed, parseErr := parseJSONResponse(agentMessage.Content().Text)
+			if parseErr != nil {
+				if jsonCorrected {
+					return a.err(fmt.Errorf("%w: %v", ErrInvalidJSONResponse, parseErr))
+				}
+				// Ask the model to fix its output, but only once.
+				jsonCorrected = true
+				slog.Warn("Invalid JSON response, asking for a correction", "sessionID", sessionID, "error", parseErr)
+				a.Publish(pubsub.CreatedEvent, AgentEvent{
+					Type:      AgentEventTypeJSONCorrection,
+					SessionID: sessionID,
+					Progress:  parseErr.Error(),
+				})
+				userMsg, err := a.createUserMessage(ctx, sessionID, jsonCorrectionPrompt(parseErr), nil)
+				if err != nil {
+					return a.err(fmt.Errorf("failed to create user message for JSON correction: %w", err))
+				}
+				msgHistory = append(msgHistory, agentMessage, userMsg)
+				continue
+			}
+			structured = parsed
 		}
 		return AgentEvent{
 			Type:    AgentEventTypeResponse,
 			Message: agentMessage,
+			JSON:    structured,
 			Done:    true,
 		}
 	}
 }
 
+// fromSummary returns the messages of a summarized session from its summary
+// on. Other sessions are left as they are.
+func (a *agent) fromSummary(ctx context.Context, sessionID string, msgs []message.Message) ([]message.Message, error) {
+	session, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return fromSummaryMessage(msgs, session.SummaryMessageID), nil
+}
+
+// fromSummaryMessage returns msgs from the summary with the given ID on,
+// which is sent as a user message. The pinned messages it replaced and the
+// messages a compaction kept follow the summary.
+func fromSummaryMessage(msgs []message.Message, summaryID string) []message.Message {
+	summaryMsgIndex := slices.IndexFunc(msgs, func(msg message.Message) bool { return msg.ID == summaryID })
+	if summaryID == "" || summaryMsgIndex == -1 {
+		return msgs
+	}
+	summary := msgs[summaryMsgIndex]
+	start := summaryMsgIndex
+	if part := summary.SummaryPart(); part != nil && part.KeptFrom != "" {
+		if i := slices.IndexFunc(msgs[:summaryMsgIndex], func(msg message.Message) bool { return msg.ID == part.KeptFrom }); i >= 0 {
+			start = i
+		}
+	}
+	return withSummary(summary, slices.Concat(msgs[:summaryMsgIndex], msgs[summaryMsgIndex+1:]), start)
+}
+
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	parts = append(parts, attachmentParts...)
@@ -515,53 +809,66 @@ func (a *agent) createUserMessage(ctx context.Context, sessionID, content string
 	})
 }
 
-func (a *agent) getAllTools() ([]tools.BaseTool, error) {
+// getAllTools returns the tools of the agent, without those the session
+// forbids.
+func (a *agent) getAllTools(sessionID string) ([]tools.BaseTool, error) {
+	current := a.current.Load()
+	agentCfg := current.cfg
 	var allTools []tools.BaseTool
 	for tool := range a.baseTools.Seq() {
-		if a.agentCfg.AllowedTools == nil || slices.Contains(a.agentCfg.AllowedTools, tool.Name()) {
+		if agentCfg.AllowedTools == nil || slices.Contains(agentCfg.AllowedTools, tool.Name()) {
 			allTools = append(allTools, tool)
 		}
 	}
-	if a.agentCfg.ID == "coder" {
+	if agentCfg.ID == config.Get().DefaultAgentID() {
 		allTools = slices.AppendSeq(allTools, a.mcpTools.Seq())
 		if a.lspClients.Len() > 0 {
 			allTools = append(allTools, tools.NewDiagnosticsTool(a.lspClients))
 		}
 	}
-	if a.agentToolFn != nil {
-		agentTool, agentToolErr := a.agentToolFn()
-		if agentToolErr != nil {
-			return nil, agentToolErr
-		}
-		allTools = append(allTools, agentTool)
+	if current.agentTool != nil {
+		allTools = append(allTools, current.agentTool)
 	}
-	return allTools, nil
+	return withoutDisabledTools(sessionID, allTools), nil
 }
 
 func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msgHistory []message.Message) (message.Message, *message.Message, error) {
 	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+	// The request is answered by the model the agent had when it started,
+	// even if the config is reloaded meanwhile.
+	current := a.current.Load()
 
 	// Create the assistant message first so the spinner shows immediately
 	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
 		Role:     message.Assistant,
 		Parts:    []message.ContentPart{},
-		Model:    a.Model().ID,
-		Provider: a.providerID,
+		Model:    config.Get().GetModelByType(current.cfg.Model).ID,
+		Provider: current.providerID,
 	})
 	if err != nil {
 		return assistantMsg, nil, fmt.Errorf("failed to create assistant message: %w", err)
 	}
 
-	allTools, toolsErr := a.getAllTools()
+	allTools, toolsErr := a.getAllTools(sessionID)
 	if toolsErr != nil {
 		return assistantMsg, nil, toolsErr
 	}
+	// The stream is canceled when the provider sends nothing for too long.
+	streamCtx, stall := withWatchdog(ctx, config.Get().Options.StallTimeout(), ErrStreamStalled)
+	defer stall.Stop()
+	stalled := func() bool {
+		return ctx.Err() == nil && errors.Is(context.Cause(streamCtx), ErrStreamStalled)
+	}
+
 	// Now collect tools (which may block on MCP initialization)
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, allTools)
+	eventChan := current.provider.StreamResponse(streamCtx, msgHistory, allTools)
 
 	// Add the session and message ID into the context if needed by tools.
 	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
 
+	watchdog := activityWatchdogFromContext(ctx)
+	watchdog.Touch()
+
 loop:
 	for {
 		select {
@@ -569,7 +876,17 @@ loop:
 			if !ok {
 				break loop
 			}
+			watchdog.Touch()
+			if event.Type == provider.EventRetry && event.Retry != nil {
+				stall.Extend(event.Retry.Delay)
+			} else {
+				stall.Touch()
+			}
 			if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
+				if stalled() {
+					a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonError, "Stream stalled", ErrStreamStalled.Error())
+					return assistantMsg, nil, ErrStreamStalled
+				}
 				if errors.Is(processErr, context.Canceled) {
 					a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
 				} else {
@@ -577,11 +894,16 @@ loop:
 				}
 				return assistantMsg, nil, processErr
 			}
-		case <-ctx.Done():
+		case <-streamCtx.Done():
+			if stalled() {
+				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonError, "Stream stalled", ErrStreamStalled.Error())
+				return assistantMsg, nil, ErrStreamStalled
+			}
 			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
 			return assistantMsg, nil, ctx.Err()
 		}
 	}
+	stall.Stop()
 
 	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
 	toolCalls := assistantMsg.ToolCalls()
@@ -601,7 +923,7 @@ loop:
 		default:
 			// Continue processing
 			var tool tools.BaseTool
-			allTools, _ = a.getAllTools()
+			allTools, _ = a.getAllTools(sessionID)
 			for _, availableTool := range allTools {
 				if availableTool.Info().Name == toolCall.Name {
 					tool = availableTool
@@ -626,6 +948,9 @@ loop:
 			}
 			resultChan := make(chan toolExecResult, 1)
 
+			// Tools may legitimately run for a long time without reporting
+			// anything, so they don't count as inactivity.
+			watchdog.Pause()
 			go func() {
 				response, err := tool.Run(ctx, tools.ToolCall{
 					ID:    toolCall.ID,
@@ -654,6 +979,7 @@ loop:
 				toolResponse = result.response
 				toolErr = result.err
 			}
+			watchdog.Touch()
 
 			if toolErr != nil {
 				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", toolErr)
@@ -680,6 +1006,9 @@ loop:
 				Metadata:   toolResponse.Metadata,
 				IsError:    toolResponse.IsError,
 			}
+			if toolCall.Name == tools.ProgressToolName && toolErr == nil && !toolResponse.IsError {
+				a.publishStep(sessionID, toolResponse.Metadata)
+			}
 		}
 	}
 out:
@@ -693,7 +1022,7 @@ out:
 	msg, err := a.messages.Create(context.Background(), assistantMsg.SessionID, message.CreateMessageParams{
 		Role:     message.Tool,
 		Parts:    parts,
-		Provider: a.providerID,
+		Provider: current.providerID,
 	})
 	if err != nil {
 		return assistantMsg, nil, fmt.Errorf("failed to create cancelled tool message: %w", err)
@@ -740,6 +1069,21 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		return a.messages.Update(ctx, *assistantMsg)
 	case provider.EventError:
 		return event.Error
+	case provider.EventRetry:
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeRetry,
+			SessionID: sessionID,
+			Retry:     event.Retry,
+		})
+	case provider.EventFallback:
+		// Only kept in memory so usage is charged to the fallback model.
+		assistantMsg.Model = event.Fallback.To.ID
+		assistantMsg.Provider = event.Fallback.Provider
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeFallback,
+			SessionID: sessionID,
+			Fallback:  event.Fallback,
+		})
 	case provider.EventComplete:
 		assistantMsg.FinishThinking()
 		assistantMsg.SetToolCalls(event.Response.ToolCalls)
@@ -747,7 +1091,7 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.trackUsage(ctx, sessionID, a.Model(), event.Response.Usage)
+		return a.trackUsage(ctx, sessionID, a.messageModel(*assistantMsg), event.Response.Usage)
 	}
 
 	return nil
@@ -765,6 +1109,7 @@ func (a *agent) trackUsage(ctx context.Context, sessionID string, model catwalk.
 		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
 
 	a.eventTokensUsed(sessionID, usage, cost)
+	sessionTokens.add(sessionID, totalTokens(usage))
 
 	sess.Cost += cost
 	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
@@ -831,18 +1176,6 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 		}
 		a.Publish(pubsub.CreatedEvent, event)
 
-		// Add a system message to guide the summarization
-		summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
-
-		// Create a new message with the summarize prompt
-		promptMsg := message.Message{
-			Role:  message.User,
-			Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
-		}
-
-		// Append the prompt to the messages
-		msgsWithPrompt := append(msgs, promptMsg)
-
 		event = AgentEvent{
 			Type:     AgentEventTypeSummarize,
 			Progress: "Generating summary...",
@@ -850,36 +1183,17 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 
 		a.Publish(pubsub.CreatedEvent, event)
 
-		// Send the messages to the summarize provider
-		response := a.summarizeProvider.StreamResponse(
-			summarizeCtx,
-			msgsWithPrompt,
-			nil,
-		)
-		var finalResponse *provider.ProviderResponse
-		for r := range response {
-			if r.Error != nil {
-				event = AgentEvent{
-					Type:  AgentEventTypeError,
-					Error: fmt.Errorf("failed to summarize: %w", r.Error),
-					Done:  true,
-				}
-				a.Publish(pubsub.CreatedEvent, event)
-				return
-			}
-			finalResponse = r.Response
-		}
-
-		summary := strings.TrimSpace(finalResponse.Content)
-		if summary == "" {
+		finalResponse, err := a.generateSummary(summarizeCtx, msgs)
+		if err != nil {
 			event = AgentEvent{
 				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("empty summary returned"),
+				Error: err,
 				Done:  true,
 			}
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
+		summary := strings.TrimSpace(finalResponse.Content)
 		shell := shell.GetPersistentShell(config.Get().WorkingDir())
 		summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
 		event = AgentEvent{
@@ -904,6 +1218,7 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			Role: message.Assistant,
 			Parts: []message.ContentPart{
 				message.TextContent{Text: summary},
+				message.Summary{},
 				message.Finish{
 					Reason: message.FinishReasonEndTurn,
 					Time:   time.Now().Unix(),
@@ -932,6 +1247,7 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
 			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
 		oldSession.Cost += cost
+		sessionTokens.add(oldSession.ID, totalTokens(usage))
 		_, err = a.sessions.Save(summarizeCtx, oldSession)
 		if err != nil {
 			event = AgentEvent{
@@ -956,13 +1272,17 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 }
 
 func (a *agent) ClearQueue(sessionID string) {
-	if a.QueuedPrompts(sessionID) > 0 {
+	if queued, _ := a.takeQueuedPrompts(sessionID); len(queued) > 0 {
 		slog.Info("Clearing queued prompts", "session_id", sessionID)
-		a.promptQueue.Del(sessionID)
+	}
+	if _, ok := a.takeSteering(sessionID); ok {
+		slog.Info("Clearing steering", "session_id", sessionID)
 	}
 }
 
 func (a *agent) CancelAll() {
+	// Let titles of the last exchanges be saved.
+	defer a.waitForTitles(5 * time.Second)
 	if !a.IsBusy() {
 		return
 	}
@@ -987,31 +1307,81 @@ func (a *agent) CancelAll() {
 	}
 }
 
+// agentSystemMessage builds the system prompt for the given agent.
+func agentSystemMessage(agentCfg config.Agent, providerID string) string {
+	promptID := agentPromptMap[agentCfg.ID]
+	if promptID == "" {
+		promptID = prompt.PromptDefault
+	}
+	systemMessage := prompt.GetPrompt(promptID, providerID, config.Get().Options.ContextPaths...)
+	if agentCfg.ResponseFormat == config.ResponseFormatJSON {
+		systemMessage += "\n\n" + jsonResponseInstructions
+	}
+	return systemMessage
+}
+
+// UpdateConfig applies a reloaded configuration for this agent. Requests
+// started afterwards use the new prompt, model and tools.
+func (a *agent) UpdateConfig(agentCfg config.Agent) error {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+	cfg := config.Get()
+
+	providerCfg := cfg.GetProviderForModel(agentCfg.Model)
+	if providerCfg == nil || providerCfg.ID == "" {
+		return fmt.Errorf("provider for agent %s not found in config", agentCfg.Name)
+	}
+	if cfg.GetModelByType(agentCfg.Model) == nil {
+		return fmt.Errorf("model not found for agent %s", agentCfg.Name)
+	}
+
+	newProvider, err := provider.NewProvider(*providerCfg,
+		provider.WithModel(agentCfg.Model),
+		provider.WithSystemMessage(agentSystemMessage(agentCfg, providerCfg.ID)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create new provider: %w", err)
+	}
+
+	// The commands and environment of the bash tool, and the subagents of
+	// the agent tool, come from the config.
+	a.baseTools.Set(tools.BashToolName, newBashTool(a.permissions, agentCfg))
+	var agentTool tools.BaseTool
+	if a.newAgentTool != nil {
+		agentTool = a.newAgentTool(agentCfg)
+	}
+	a.current.Store(&agentModel{
+		cfg:        agentCfg,
+		provider:   withFallbacks(agentCfg, newProvider, providerCfg.ID),
+		providerID: string(providerCfg.ID),
+		agentTool:  agentTool,
+	})
+	return nil
+}
+
 func (a *agent) UpdateModel() error {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
 	cfg := config.Get()
+	current := a.current.Load()
 
 	// Get current provider configuration
-	currentProviderCfg := cfg.GetProviderForModel(a.agentCfg.Model)
+	currentProviderCfg := cfg.GetProviderForModel(current.cfg.Model)
 	if currentProviderCfg == nil || currentProviderCfg.ID == "" {
-		return fmt.Errorf("provider for agent %s not found in config", a.agentCfg.Name)
+		return fmt.Errorf("provider for agent %s not found in config", current.cfg.Name)
 	}
 
 	// Check if provider has changed
-	if string(currentProviderCfg.ID) != a.providerID {
+	if string(currentProviderCfg.ID) != current.providerID {
 		// Provider changed, need to recreate the main provider
-		model := cfg.GetModelByType(a.agentCfg.Model)
+		model := cfg.GetModelByType(current.cfg.Model)
 		if model.ID == "" {
-			return fmt.Errorf("model not found for agent %s", a.agentCfg.Name)
-		}
-
-		promptID := agentPromptMap[a.agentCfg.ID]
-		if promptID == "" {
-			promptID = prompt.PromptDefault
+			return fmt.Errorf("model not found for agent %s", current.cfg.Name)
 		}
 
 		opts := []provider.ProviderClientOption{
-			provider.WithModel(a.agentCfg.Model),
-			provider.WithSystemMessage(prompt.GetPrompt(promptID, currentProviderCfg.ID, cfg.Options.ContextPaths...)),
+			provider.WithModel(current.cfg.Model),
+			provider.WithSystemMessage(agentSystemMessage(current.cfg, currentProviderCfg.ID)),
 		}
 
 		newProvider, err := provider.NewProvider(*currentProviderCfg, opts...)
@@ -1020,8 +1390,12 @@ func (a *agent) UpdateModel() error {
 		}
 
 		// Update the provider and provider ID
-		a.provider = newProvider
-		a.providerID = string(currentProviderCfg.ID)
+		a.current.Store(&agentModel{
+			cfg:        current.cfg,
+			provider:   withFallbacks(current.cfg, newProvider, currentProviderCfg.ID),
+			providerID: string(currentProviderCfg.ID),
+			agentTool:  current.agentTool,
+		})
 	}
 
 	// Check if providers have changed for title (small) and summarize (large)
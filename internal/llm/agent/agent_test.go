@@ -0,0 +1,403 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/db"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/lsp"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/permission"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+	"github.com/tulpa-code/tulpa/internal/session"
+	"github.com/tulpa-code/tulpa/internal/shell"
+)
+
+// heldProvider answers every request with its model name once release is
+// closed, and tells on started when a request starts.
+type heldProvider struct {
+	model   string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newHeldProvider(model string) *heldProvider {
+	return &heldProvider{model: model, started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (p *heldProvider) SendMessages(context.Context, []message.Message, []tools.BaseTool) (*provider.ProviderResponse, error) {
+	return &provider.ProviderResponse{Content: p.model}, nil
+}
+
+func (p *heldProvider) StreamResponse(ctx context.Context, _ []message.Message, _ []tools.BaseTool) <-chan provider.ProviderEvent {
+	events := make(chan provider.ProviderEvent, 2)
+	go func() {
+		defer close(events)
+		p.started <- struct{}{}
+		select {
+		case <-p.release:
+			events <- provider.ProviderEvent{Type: provider.EventContentDelta, Content: p.model}
+			events <- provider.ProviderEvent{Type: provider.EventComplete, Response: &provider.ProviderResponse{Content: p.model, FinishReason: message.FinishReasonEndTurn}}
+		case <-ctx.Done():
+			events <- provider.ProviderEvent{Type: provider.EventError, Error: ctx.Err()}
+		}
+	}()
+	return events
+}
+
+func (p *heldProvider) Model() catwalk.Model {
+	return catwalk.Model{ID: p.model, Name: p.model}
+}
+
+// newTestAgent returns an agent answering with p, storing its sessions in a
+// new database. It sets the global config, so its tests may not be parallel.
+func newTestAgent(t *testing.T, p provider.Provider) (*agent, session.Service, message.Service) {
+	cfg, err := config.Init(t.TempDir(), t.TempDir(), false)
+	require.NoError(t, err)
+	cfg.Providers.Set("fake", config.ProviderConfig{
+		ID:     "fake",
+		Type:   catwalk.TypeOpenAI,
+		APIKey: "key",
+		Models: []catwalk.Model{{ID: "fake-model", ContextWindow: 100_000, DefaultMaxTokens: 1000}},
+	})
+	cfg.Models[config.SelectedModelTypeLarge] = config.SelectedModel{Provider: "fake", Model: "fake-model"}
+	cfg.Models[config.SelectedModelTypeSmall] = config.SelectedModel{Provider: "fake", Model: "fake-model"}
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q)
+	messages := message.NewService(q, nil)
+
+	a := &agent{
+		Broker:         pubsub.NewBroker[AgentEvent](),
+		sessions:       sessions,
+		messages:       messages,
+		permissions:    permission.NewPermissionService(cfg.WorkingDir(), true, nil, nil, nil),
+		baseTools:      csync.NewMap[string, tools.BaseTool](),
+		mcpTools:       csync.NewMap[string, tools.BaseTool](),
+		lspClients:     csync.NewMap[string, *lsp.Client](),
+		titleProvider:  p,
+		activeRequests: csync.NewMap[string, context.CancelFunc](),
+		promptQueue:    csync.NewMap[string, []string](),
+		steering:       csync.NewMap[string, []string](),
+	}
+	a.current.Store(&agentModel{cfg: config.Agent{ID: "coder", Name: "Coder", Model: config.SelectedModelTypeLarge}, provider: p, providerID: "fake"})
+	t.Cleanup(func() {
+		a.CancelAll()
+		a.titles.Wait()
+	})
+	return a, sessions, messages
+}
+
+func TestStartTurn(t *testing.T) {
+	t.Parallel()
+
+	const runs = 50
+	for _, reject := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reject=%t", reject), func(t *testing.T) {
+			t.Parallel()
+
+			a := &agent{
+				activeRequests: csync.NewMap[string, context.CancelFunc](),
+				promptQueue:    csync.NewMap[string, []string](),
+				steering:       csync.NewMap[string, []string](),
+			}
+			var started, busy atomic.Int32
+			var wg sync.WaitGroup
+			for i := range runs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ok, err := a.startTurn("session", fmt.Sprintf("prompt %d", i), func() {}, reject)
+					switch {
+					case ok:
+						require.NoError(t, err)
+						started.Add(1)
+					case err != nil:
+						require.ErrorIs(t, err, ErrSessionBusy)
+						busy.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			require.Equal(t, int32(1), started.Load(), "only one turn runs at once")
+			require.True(t, a.IsSessionBusy("session"))
+			if reject {
+				require.Equal(t, int32(runs-1), busy.Load())
+				require.Zero(t, a.QueuedPrompts("session"))
+			} else {
+				require.Zero(t, busy.Load())
+				require.Equal(t, runs-1, a.QueuedPrompts("session"))
+			}
+
+			queued := a.endTurn("session")
+			require.False(t, a.IsSessionBusy("session"))
+			require.Zero(t, a.QueuedPrompts("session"))
+			if !reject {
+				require.Len(t, queued, runs-1, "no queued prompt is lost")
+			}
+		})
+	}
+}
+
+func TestStartTurnWhileTakingQueue(t *testing.T) {
+	t.Parallel()
+
+	a := &agent{
+		activeRequests: csync.NewMap[string, context.CancelFunc](),
+		promptQueue:    csync.NewMap[string, []string](),
+		steering:       csync.NewMap[string, []string](),
+	}
+	ok, err := a.startTurn("session", "first", func() {}, false)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	const runs = 200
+	var taken atomic.Int32
+	var wg sync.WaitGroup
+	for i := range runs {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := a.startTurn("session", fmt.Sprintf("prompt %d", i), func() {}, false)
+			require.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			prompts, _ := a.takeQueuedPrompts("session")
+			taken.Add(int32(len(prompts)))
+		}()
+	}
+	wg.Wait()
+	taken.Add(int32(len(a.endTurn("session"))))
+	require.Equal(t, int32(runs), taken.Load(), "each queued prompt is taken exactly once")
+}
+
+func TestUpdateConfigDuringRun(t *testing.T) {
+	p := newHeldProvider("before")
+	a, sessions, _ := newTestAgent(t, p)
+	sess, err := sessions.Create(t.Context(), "Session")
+	require.NoError(t, err)
+
+	events, err := a.Run(t.Context(), sess.ID, "hello")
+	require.NoError(t, err)
+	<-p.started
+
+	// Reload the config while the request answers.
+	reloaded := make(chan struct{})
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for {
+				require.NoError(t, a.UpdateConfig(config.Agent{ID: "coder", Name: "Reloaded", Model: config.SelectedModelTypeLarge}))
+				_, _ = a.DryRun(t.Context(), "", "")
+				select {
+				case reloaded <- struct{}{}:
+				case <-stop:
+					return
+				default:
+				}
+			}
+		})
+	}
+	<-reloaded
+	close(p.release)
+
+	result := <-events
+	close(stop)
+	wg.Wait()
+	require.NoError(t, result.Error)
+	require.Equal(t, "before", result.Message.Content().Text, "the running request keeps its provider")
+	require.Equal(t, "Reloaded", a.current.Load().cfg.Name)
+	require.NotSame(t, p, a.current.Load().provider)
+}
+
+func TestUpdateConfigBashTool(t *testing.T) {
+	a, _, _ := newTestAgent(t, newHeldProvider("answer"))
+	// The persistent shell is created once, maybe in the removed working
+	// directory of another test.
+	require.NoError(t, shell.GetPersistentShell(os.TempDir()).SetWorkingDir(os.TempDir()))
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, tools.MessageIDContextKey, "message")
+	run := func(command string) tools.ToolResponse {
+		bash, ok := a.baseTools.Get(tools.BashToolName)
+		require.True(t, ok)
+		resp, err := bash.Run(ctx, tools.ToolCall{ID: "call", Name: tools.BashToolName, Input: `{"command":"` + command + `"}`})
+		require.NoError(t, err)
+		return resp
+	}
+
+	agentCfg := a.current.Load().cfg
+	require.NoError(t, a.UpdateConfig(agentCfg))
+	require.False(t, run("ls").IsError)
+
+	// A reload denying a command and setting the environment applies to
+	// the next calls.
+	agentCfg.BashCommands = config.BashCommandRules{Denied: []string{"ls"}}
+	agentCfg.BashEnv = map[string]string{"TULPA_RELOADED": "yes"}
+	require.NoError(t, a.UpdateConfig(agentCfg))
+	resp := run("ls")
+	require.True(t, resp.IsError)
+	require.Equal(t, `command "ls" is denied for this agent`, resp.Content)
+	resp = run("echo $TULPA_RELOADED")
+	require.False(t, resp.IsError)
+	require.True(t, strings.HasPrefix(resp.Content, "yes\n"), resp.Content)
+}
+
+func TestUpdateConfigSubagents(t *testing.T) {
+	a, sessions, messages := newTestAgent(t, newHeldProvider("answer"))
+	a.newAgentTool = func(agentCfg config.Agent) tools.BaseTool {
+		if len(agentCfg.Subagents) == 0 {
+			return nil
+		}
+		return NewAgentTool(agentCfg, nil, sessions, messages, 1, 1)
+	}
+	agentTool := func() tools.BaseTool {
+		allTools, err := a.getAllTools("session")
+		require.NoError(t, err)
+		for _, tool := range allTools {
+			if tool.Name() == AgentToolName {
+				return tool
+			}
+		}
+		return nil
+	}
+	subagents := func() []string {
+		return agentTool().Info().Parameters["agent"].(map[string]any)["enum"].([]string)
+	}
+
+	agentCfg := a.current.Load().cfg
+	agentCfg.Subagents = []string{"task", "docs"}
+	require.NoError(t, a.UpdateConfig(agentCfg))
+	require.Equal(t, []string{"task", "docs"}, subagents())
+
+	// A subagent removed by a reload may no longer be called.
+	agentCfg.Subagents = []string{"task"}
+	require.NoError(t, a.UpdateConfig(agentCfg))
+	require.Equal(t, []string{"task"}, subagents())
+	resp, err := agentTool().Run(t.Context(), tools.ToolCall{ID: "call", Name: AgentToolName, Input: `{"prompt": "Document it", "agent": "docs"}`})
+	require.NoError(t, err)
+	require.Equal(t, `agent "docs" is not available, use one of: task`, resp.Content)
+
+	agentCfg.Subagents = nil
+	require.NoError(t, a.UpdateConfig(agentCfg))
+	require.Nil(t, agentTool(), "an agent without subagents has no agent tool")
+}
+
+func TestReloadAgentsDuringRun(t *testing.T) {
+	p := newHeldProvider("answer")
+	a, sessions, messages := newTestAgent(t, p)
+	sess, err := sessions.Create(t.Context(), "Session")
+	require.NoError(t, err)
+	cfg := config.Get()
+	tool := NewAgentTool(config.Agent{ID: "coder", Subagents: []string{"task"}}, nil, sessions, messages, 1, 1)
+
+	events, err := a.Run(t.Context(), sess.ID, "hello")
+	require.NoError(t, err)
+	<-p.started
+
+	// Reload the agents, as the config watcher does, while the turn ends
+	// and the agent tool describes the subagents.
+	reloaded := make(chan struct{})
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := 0; ; i++ {
+			cfg.SetAgents(map[string]config.Agent{
+				"coder": {ID: "coder", Model: config.SelectedModelTypeLarge},
+				"task":  {ID: "task", Description: fmt.Sprintf("Reload %d", i)},
+			}, map[string]string{"coder": "Code."})
+			select {
+			case reloaded <- struct{}{}:
+			case <-stop:
+				return
+			default:
+			}
+		}
+	})
+	wg.Go(func() {
+		for {
+			require.Contains(t, tool.Info().Description, "- task")
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	})
+	<-reloaded
+	close(p.release)
+
+	result := <-events
+	close(stop)
+	wg.Wait()
+	require.NoError(t, result.Error)
+	require.Contains(t, tool.Info().Description, "- task: Reload ")
+}
+
+func TestRunConcurrently(t *testing.T) {
+	p := newHeldProvider("answer")
+	a, sessions, messages := newTestAgent(t, p)
+	sess, err := sessions.Create(t.Context(), "Session")
+	require.NoError(t, err)
+
+	// The first prompt runs with a context that is done before it answers,
+	// the others are queued meanwhile.
+	ctx, cancel := context.WithCancel(t.Context())
+	events, err := a.Run(ctx, sess.ID, "first")
+	require.NoError(t, err)
+	<-p.started
+
+	const queued = 10
+	var wg sync.WaitGroup
+	for i := range queued {
+		wg.Go(func() {
+			events, err := a.Run(t.Context(), sess.ID, fmt.Sprintf("prompt %d", i))
+			require.NoError(t, err)
+			require.Nil(t, events, "the prompt is queued")
+		})
+	}
+	wg.Wait()
+	require.Equal(t, queued, a.QueuedPrompts(sess.ID))
+
+	cancel()
+	result := <-events
+	require.Error(t, result.Error)
+	close(p.release)
+
+	// The queued prompts are all sent and answered, although the context of
+	// the turn they were queued in is done.
+	answered := func() bool {
+		if a.IsSessionBusy(sess.ID) {
+			return false
+		}
+		msgs, err := messages.List(t.Context(), sess.ID)
+		require.NoError(t, err)
+		var prompts int
+		for _, msg := range msgs {
+			if msg.Role == message.User && strings.HasPrefix(msg.Content().Text, "prompt ") {
+				prompts++
+			}
+		}
+		last := msgs[len(msgs)-1]
+		return prompts == queued && last.Role == message.Assistant && last.Content().Text == "answer"
+	}
+	require.Eventually(t, answered, 30*time.Second, 10*time.Millisecond)
+	require.Zero(t, a.QueuedPrompts(sess.ID))
+}
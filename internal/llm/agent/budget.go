@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+)
+
+// Usage is what a session spent so far, with its budget.
+type Usage struct {
+	// Cost of the session in dollars, subagents and summaries included
+	Cost float64
+	// Input and output tokens the session used since Tulpa started, or since
+	// it started for subagent sessions
+	Tokens int64
+
+	// The budget of the session, zero when unlimited
+	MaxCost   float64
+	MaxTokens int64
+}
+
+// exceeded returns why the usage is over budget, or an empty string when it
+// isn't.
+func (u Usage) exceeded() string {
+	switch {
+	case u.MaxCost > 0 && u.Cost >= u.MaxCost:
+		return fmt.Sprintf("the session cost $%.4f, max_session_cost is $%.4f", u.Cost, u.MaxCost)
+	case u.MaxTokens > 0 && u.Tokens >= u.MaxTokens:
+		return fmt.Sprintf("the session used %d tokens, max_session_tokens is %d", u.Tokens, u.MaxTokens)
+	}
+	return ""
+}
+
+// tokenCounter counts the tokens used by each session. The sessions only
+// store the size of their context, so the counts last as long as the
+// process.
+type tokenCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// sessionTokens is shared by all agents, so that subagents can add their
+// tokens to the session that delegated to them.
+var sessionTokens = &tokenCounter{counts: make(map[string]int64)}
+
+func (c *tokenCounter) add(sessionID string, tokens int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[sessionID] += tokens
+}
+
+func (c *tokenCounter) get(sessionID string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[sessionID]
+}
+
+// take returns the tokens counted for the session and stops counting them.
+func (c *tokenCounter) take(sessionID string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tokens := c.counts[sessionID]
+	delete(c.counts, sessionID)
+	return tokens
+}
+
+// ClearSessionUsage forgets the tokens the session used. Its cost is stored
+// with it.
+func ClearSessionUsage(sessionID string) {
+	sessionTokens.take(sessionID)
+}
+
+func totalTokens(usage provider.TokenUsage) int64 {
+	return usage.InputTokens + usage.OutputTokens + usage.CacheCreationTokens + usage.CacheReadTokens
+}
+
+// usage returns what the session spent so far, with its budget. Subagent
+// sessions share the budget of the sessions that delegated to them, which
+// they are only charged for when they end, so their spending counts too.
+func (a *agent) usage(ctx context.Context, sessionID string) (Usage, error) {
+	var usage Usage
+	for id := sessionID; id != ""; {
+		sess, err := a.sessions.Get(ctx, id)
+		if err != nil {
+			return Usage{}, fmt.Errorf("failed to get session: %w", err)
+		}
+		usage.Cost += sess.Cost
+		usage.Tokens += sessionTokens.get(id)
+		id = sess.ParentSessionID
+	}
+	usage.MaxCost, usage.MaxTokens = config.Get().Options.SessionBudget()
+	return usage, nil
+}
+
+// checkBudget returns an error wrapping [ErrBudgetExceeded] when the session,
+// or the session it's a subagent of, spent its budget.
+func (a *agent) checkBudget(ctx context.Context, sessionID string) error {
+	usage, err := a.usage(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if reason := usage.exceeded(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrBudgetExceeded, reason)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/db"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+func TestUsageExceeded(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		usage Usage
+		want  string
+	}{
+		{name: "unlimited", usage: Usage{Cost: 100, Tokens: 1e9}},
+		{name: "under budget", usage: Usage{Cost: 0.5, Tokens: 1000, MaxCost: 1, MaxTokens: 2000}},
+		{
+			name:  "cost reached",
+			usage: Usage{Cost: 1, MaxCost: 1},
+			want:  "the session cost $1.0000, max_session_cost is $1.0000",
+		},
+		{
+			name:  "tokens over",
+			usage: Usage{Cost: 0.5, Tokens: 2500, MaxCost: 1, MaxTokens: 2000},
+			want:  "the session used 2500 tokens, max_session_tokens is 2000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, tt.usage.exceeded())
+		})
+	}
+}
+
+func TestTokenCounter(t *testing.T) {
+	t.Parallel()
+
+	c := &tokenCounter{counts: make(map[string]int64)}
+	c.add("parent", 100)
+	c.add("child", 40)
+	c.add("parent", c.get("child"))
+	require.Equal(t, int64(140), c.get("parent"))
+	require.Zero(t, c.get("other"))
+}
+
+func TestSessionUsageIncludesParents(t *testing.T) {
+	cfg, err := config.Init(t.TempDir(), t.TempDir(), false)
+	require.NoError(t, err)
+	maxCost := 2.0
+	cfg.Options.MaxSessionCost = &maxCost
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	sessions := session.NewService(db.New(conn))
+	a := &agent{sessions: sessions}
+
+	root, err := sessions.Create(t.Context(), "Root")
+	require.NoError(t, err)
+	root.Cost = 1.5
+	_, err = sessions.Save(t.Context(), root)
+	require.NoError(t, err)
+	child, err := sessions.CreateTaskSession(t.Context(), "usage-child", root.ID, "Child")
+	require.NoError(t, err)
+	child.Cost = 0.25
+	_, err = sessions.Save(t.Context(), child)
+	require.NoError(t, err)
+	sessionTokens.add(root.ID, 100)
+	sessionTokens.add(child.ID, 40)
+	t.Cleanup(func() {
+		ClearSessionUsage(root.ID)
+		ClearSessionUsage(child.ID)
+	})
+
+	usage, err := a.usage(t.Context(), child.ID)
+	require.NoError(t, err)
+	require.InDelta(t, 1.75, usage.Cost, 1e-9)
+	require.Equal(t, int64(140), usage.Tokens)
+	require.NoError(t, a.checkBudget(t.Context(), child.ID))
+
+	child.Cost = 0.5
+	_, err = sessions.Save(t.Context(), child)
+	require.NoError(t, err)
+	require.ErrorIs(t, a.checkBudget(t.Context(), child.ID), ErrBudgetExceeded, "a subagent may not spend past the budget of its parent")
+}
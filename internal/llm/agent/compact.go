@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/llm/prompt"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+)
+
+// CompactionInfo is how the conversation of a session is compacted.
+type CompactionInfo struct {
+	// Estimated tokens of the conversation, and how many it may take
+	Tokens    int64
+	Threshold int64
+	// Messages replaced with the summary, and kept after it
+	Summarized int
+	Kept       int
+}
+
+// summarizePrompt asks the summarizer for a summary of the conversation
+// before it.
+const summarizePrompt = "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
+
+// generateSummary asks the summarize provider for a summary of msgs.
+func (a *agent) generateSummary(ctx context.Context, msgs []message.Message) (*provider.ProviderResponse, error) {
+	promptMsg := message.Message{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
+	}
+	var finalResponse *provider.ProviderResponse
+	for r := range a.summarizeProvider.StreamResponse(ctx, append(slices.Clip(msgs), promptMsg), nil) {
+		if r.Error != nil {
+			return nil, fmt.Errorf("failed to summarize: %w", r.Error)
+		}
+		finalResponse = r.Response
+	}
+	if finalResponse == nil || strings.TrimSpace(finalResponse.Content) == "" {
+		return nil, errors.New("empty summary returned")
+	}
+	return finalResponse, nil
+}
+
+// estimateConversationTokens estimates how many tokens the system prompt and
+// msgs take for the current model.
+func (a *agent) estimateConversationTokens(msgs []message.Message) int64 {
+	systemPrompt, providerType := a.systemPrompt()
+	tokens := prompt.EstimateTokens(systemPrompt, providerType)
+	for _, msg := range msgs {
+		tokens += prompt.EstimateTokens(dryRunContent(msg), providerType)
+	}
+	return tokens
+}
+
+// keptTurnsStart returns the index of the first message of the last
+// keepTurns turns of msgs, or zero when msgs has no older turn. Turns start
+// with user messages, so that the kept messages never begin with the
+// results of a tool call made before them.
+func keptTurnsStart(msgs []message.Message, keepTurns int) int {
+	for i := len(msgs) - 1; i > 0; i-- {
+		if msgs[i].Role != message.User {
+			continue
+		}
+		if keepTurns--; keepTurns == 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// pinnedMessages reports which of msgs are kept because they are pinned, or
+// complete the tool calls of a pinned message: the results of pinned calls,
+// or the calls of pinned results.
+func pinnedMessages(msgs []message.Message) []bool {
+	keep := make([]bool, len(msgs))
+	for i, msg := range msgs {
+		if !msg.Pinned {
+			continue
+		}
+		keep[i] = true
+		switch {
+		case msg.Role == message.Assistant && len(msg.ToolCalls()) > 0 && i+1 < len(msgs) && msgs[i+1].Role == message.Tool:
+			keep[i+1] = true
+		case msg.Role == message.Tool && i > 0 && msgs[i-1].Role == message.Assistant:
+			keep[i-1] = true
+		}
+	}
+	return keep
+}
+
+// withSummary returns the conversation once summary replaced msgs[:start]:
+// the summary, sent as a user message, the pinned messages it replaced, then
+// msgs[start:].
+func withSummary(summary message.Message, msgs []message.Message, start int) []message.Message {
+	summary.Role = message.User
+	history := []message.Message{summary}
+	keep := pinnedMessages(msgs[:start])
+	for i, msg := range msgs[:start] {
+		if keep[i] {
+			history = append(history, msg)
+		}
+	}
+	return append(history, msgs[start:]...)
+}
+
+// compact replaces the older messages of msgHistory with a summary when the
+// conversation fills more of the context window than the compact threshold,
+// keeping its latest turns and pinned messages. It returns the conversation
+// to send from then on.
+func (a *agent) compact(ctx context.Context, sessionID string, msgHistory []message.Message) ([]message.Message, error) {
+	threshold, keepTurns := config.Get().Options.Compaction()
+	if threshold == 0 || a.summarizeProvider == nil {
+		return msgHistory, nil
+	}
+	limit := int64(float64(a.Model().ContextWindow) * threshold)
+	if limit == 0 {
+		return msgHistory, nil
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	// The usage of the last response misses the messages added since.
+	tokens := max(sess.PromptTokens+sess.CompletionTokens, a.estimateConversationTokens(msgHistory))
+	if tokens < limit {
+		return msgHistory, nil
+	}
+	start := keptTurnsStart(msgHistory, keepTurns)
+
+	// Messages may have been pinned since the conversation was loaded.
+	msgs, err := a.messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	pinned := make(map[string]bool)
+	for _, msg := range msgs {
+		pinned[msg.ID] = msg.Pinned
+	}
+	for i := range msgHistory[:start] {
+		msgHistory[i].Pinned = pinned[msgHistory[i].ID]
+	}
+	summarized := 0
+	for i, keep := range pinnedMessages(msgHistory[:start]) {
+		if !keep && msgHistory[i].ID != sess.SummaryMessageID {
+			summarized++
+		}
+	}
+	if summarized == 0 {
+		slog.Warn("Conversation fills the context window but has no turn to compact", "sessionID", sessionID, "tokens", tokens, "threshold", limit)
+		return msgHistory, nil
+	}
+
+	info := &CompactionInfo{Tokens: tokens, Threshold: limit, Summarized: summarized, Kept: len(msgHistory) - summarized}
+	slog.Info("Compacting the conversation", "sessionID", sessionID, "tokens", info.Tokens, "threshold", info.Threshold, "summarized", info.Summarized, "kept", info.Kept)
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:       AgentEventTypeCompaction,
+		SessionID:  sessionID,
+		Compaction: info,
+	})
+
+	response, err := a.generateSummary(ctx, msgHistory[:start])
+	if err != nil {
+		return nil, err
+	}
+	summary, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: strings.TrimSpace(response.Content)},
+			message.Summary{KeptFrom: msgHistory[start].ID},
+			message.Finish{Reason: message.FinishReasonEndTurn, Time: time.Now().Unix()},
+		},
+		Model:    a.summarizeProvider.Model().ID,
+		Provider: a.summarizeProviderID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create summary message: %w", err)
+	}
+
+	// Read the session again, the usage of the summary is tracked on top of
+	// what it spent meanwhile.
+	sess, err = a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	model := a.summarizeProvider.Model()
+	usage := response.Usage
+	sess.Cost += model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
+		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
+		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
+		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+	sessionTokens.add(sessionID, totalTokens(usage))
+	sess.SummaryMessageID = summary.ID
+	sess.PromptTokens = 0
+	sess.CompletionTokens = usage.OutputTokens
+	if _, err := a.sessions.Save(ctx, sess); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return withSummary(summary, msgHistory, start), nil
+}
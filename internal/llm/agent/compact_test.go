@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+func testMessage(id string, role message.MessageRole, parts ...message.ContentPart) message.Message {
+	return message.Message{ID: id, Role: role, Parts: parts}
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestKeptTurnsStart(t *testing.T) {
+	t.Parallel()
+
+	conversation := []message.Message{
+		testMessage("u1", message.User),
+		testMessage("a1", message.Assistant),
+		testMessage("u2", message.User),
+		testMessage("a2", message.Assistant),
+		testMessage("t2", message.Tool),
+		testMessage("a3", message.Assistant),
+		testMessage("u3", message.User),
+		testMessage("a4", message.Assistant),
+	}
+
+	tests := []struct {
+		name      string
+		msgs      []message.Message
+		keepTurns int
+		want      int
+	}{
+		{name: "keeps the last turn", msgs: conversation, keepTurns: 1, want: 6},
+		{name: "keeps turns with tool calls whole", msgs: conversation, keepTurns: 2, want: 2},
+		{name: "nothing older than the kept turns", msgs: conversation, keepTurns: 3, want: 0},
+		{name: "more turns than the conversation", msgs: conversation, keepTurns: 10, want: 0},
+		{name: "empty conversation", keepTurns: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, keptTurnsStart(tt.msgs, tt.keepTurns))
+		})
+	}
+}
+
+func TestFromSummaryMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not summarized", func(t *testing.T) {
+		t.Parallel()
+
+		msgs := []message.Message{testMessage("u1", message.User), testMessage("a1", message.Assistant)}
+		require.Equal(t, msgs, fromSummaryMessage(msgs, ""))
+		require.Equal(t, msgs, fromSummaryMessage(msgs, "deleted"))
+	})
+
+	t.Run("summarized", func(t *testing.T) {
+		t.Parallel()
+
+		msgs := []message.Message{
+			testMessage("u1", message.User),
+			testMessage("a1", message.Assistant),
+			testMessage("s1", message.Assistant, message.TextContent{Text: "summary"}, message.Summary{}),
+			testMessage("u2", message.User),
+		}
+		got := fromSummaryMessage(msgs, "s1")
+		require.Equal(t, []string{"s1", "u2"}, messageIDs(got))
+		require.Equal(t, message.User, got[0].Role)
+		require.Equal(t, message.Assistant, msgs[2].Role)
+	})
+
+	t.Run("compacted", func(t *testing.T) {
+		t.Parallel()
+
+		msgs := []message.Message{
+			testMessage("u1", message.User),
+			testMessage("a1", message.Assistant),
+			testMessage("u2", message.User),
+			testMessage("a2", message.Assistant),
+			testMessage("t2", message.Tool),
+			testMessage("s1", message.Assistant, message.TextContent{Text: "summary"}, message.Summary{KeptFrom: "u2"}),
+			testMessage("a3", message.Assistant),
+		}
+		got := fromSummaryMessage(msgs, "s1")
+		require.Equal(t, []string{"s1", "u2", "a2", "t2", "a3"}, messageIDs(got))
+		require.Equal(t, message.User, got[0].Role)
+	})
+}
+
+func TestWithSummary(t *testing.T) {
+	t.Parallel()
+
+	pinned := func(msg message.Message) message.Message {
+		msg.Pinned = true
+		return msg
+	}
+	summary := testMessage("s1", message.Assistant, message.TextContent{Text: "summary"}, message.Summary{KeptFrom: "u3"})
+
+	tests := []struct {
+		name string
+		msgs []message.Message
+		want []string
+	}{
+		{
+			name: "drops the neighbors of a pinned message",
+			msgs: []message.Message{
+				testMessage("u1", message.User),
+				pinned(testMessage("a1", message.Assistant)),
+				testMessage("u2", message.User),
+				testMessage("a2", message.Assistant),
+				testMessage("u3", message.User),
+				testMessage("a3", message.Assistant),
+			},
+			want: []string{"s1", "a1", "u3", "a3"},
+		},
+		{
+			name: "keeps the call of a pinned tool result",
+			msgs: []message.Message{
+				testMessage("u1", message.User),
+				testMessage("a1", message.Assistant, message.ToolCall{ID: "call"}),
+				pinned(testMessage("t1", message.Tool)),
+				testMessage("a2", message.Assistant),
+				testMessage("u2", message.User),
+				testMessage("u3", message.User),
+			},
+			want: []string{"s1", "a1", "t1", "u3"},
+		},
+		{
+			name: "keeps the results of pinned tool calls",
+			msgs: []message.Message{
+				testMessage("u1", message.User),
+				pinned(testMessage("a1", message.Assistant, message.ToolCall{ID: "call"})),
+				testMessage("t1", message.Tool),
+				testMessage("a2", message.Assistant),
+				testMessage("u3", message.User),
+			},
+			want: []string{"s1", "a1", "t1", "u3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := len(tt.msgs) - 1
+			for tt.msgs[start].ID != "u3" {
+				start--
+			}
+			got := withSummary(summary, tt.msgs, start)
+			require.Equal(t, tt.want, messageIDs(got))
+			require.Equal(t, message.User, got[0].Role)
+
+			// Loading the session again rebuilds the same conversation.
+			stored := append(slices.Clone(tt.msgs), summary)
+			require.Equal(t, tt.want, messageIDs(fromSummaryMessage(stored, "s1")))
+		})
+	}
+}
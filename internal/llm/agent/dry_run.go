@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tulpa-code/tulpa/internal/llm/prompt"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// DryRun is the request an agent would send to its provider, assembled
+// without calling it.
+type DryRun struct {
+	Agent        string `json:"agent"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt"`
+	// Estimated with [prompt.EstimateTokens]
+	SystemPromptTokens int64           `json:"system_prompt_tokens"`
+	Messages           []DryRunMessage `json:"messages"`
+	Tools              []DryRunTool    `json:"tools"`
+}
+
+type DryRunMessage struct {
+	Role    message.MessageRole `json:"role"`
+	Content string              `json:"content"`
+}
+
+type DryRunTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+	Required    []string       `json:"required,omitempty"`
+}
+
+// DryRun assembles the system prompt, the messages and the tools the agent
+// would send for content in the given session. The session and content may
+// be empty; nothing is stored.
+func (a *agent) DryRun(ctx context.Context, sessionID string, content string) (DryRun, error) {
+	systemPrompt, providerType := a.systemPrompt()
+	current := a.current.Load()
+	dryRun := DryRun{
+		Agent:              current.cfg.ID,
+		Provider:           current.providerID,
+		Model:              a.Model().ID,
+		SystemPrompt:       systemPrompt,
+		SystemPromptTokens: prompt.EstimateTokens(systemPrompt, providerType),
+	}
+
+	if sessionID != "" {
+		msgs, err := a.messages.List(ctx, sessionID)
+		if err != nil {
+			return DryRun{}, fmt.Errorf("failed to list messages: %w", err)
+		}
+		msgs, err = a.fromSummary(ctx, sessionID, msgs)
+		if err != nil {
+			return DryRun{}, err
+		}
+		for _, msg := range msgs {
+			dryRun.Messages = append(dryRun.Messages, DryRunMessage{Role: msg.Role, Content: dryRunContent(msg)})
+		}
+	}
+	if content != "" {
+		dryRun.Messages = append(dryRun.Messages, DryRunMessage{Role: message.User, Content: content})
+	}
+
+	allTools, err := a.getAllTools(sessionID)
+	if err != nil {
+		return DryRun{}, err
+	}
+	for _, tool := range allTools {
+		info := tool.Info()
+		dryRun.Tools = append(dryRun.Tools, DryRunTool{
+			Name:        info.Name,
+			Description: info.Description,
+			Parameters:  info.Parameters,
+			Required:    info.Required,
+		})
+	}
+	slices.SortFunc(dryRun.Tools, func(a, b DryRunTool) int { return strings.Compare(a.Name, b.Name) })
+	return dryRun, nil
+}
+
+// dryRunContent renders the parts of msg sent to the provider as text.
+func dryRunContent(msg message.Message) string {
+	var parts []string
+	if text := msg.Content().Text; text != "" {
+		parts = append(parts, text)
+	}
+	for _, binary := range msg.BinaryContent() {
+		parts = append(parts, fmt.Sprintf("[attachment %s (%s)]", binary.Path, binary.MIMEType))
+	}
+	for _, call := range msg.ToolCalls() {
+		parts = append(parts, fmt.Sprintf("[tool call %s %s: %s]", call.ID, call.Name, call.Input))
+	}
+	for _, result := range msg.ToolResults() {
+		parts = append(parts, fmt.Sprintf("[tool result %s]\n%s", result.ToolCallID, result.Content))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// String renders the dry run as Markdown.
+func (d DryRun) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Agent %s\n\nModel: %s (%s)\n\n", d.Agent, d.Model, d.Provider)
+	fmt.Fprintf(&b, "## System prompt (~%d tokens)\n\n%s\n\n", d.SystemPromptTokens, strings.TrimSpace(d.SystemPrompt))
+
+	fmt.Fprintf(&b, "## Messages (%d)\n\n", len(d.Messages))
+	for _, msg := range d.Messages {
+		fmt.Fprintf(&b, "### %s\n\n%s\n\n", msg.Role, strings.TrimSpace(msg.Content))
+	}
+
+	fmt.Fprintf(&b, "## Tools (%d)\n\n", len(d.Tools))
+	for _, tool := range d.Tools {
+		schema := map[string]any{"type": "object", "properties": tool.Parameters}
+		if len(tool.Required) > 0 {
+			schema["required"] = tool.Required
+		}
+		parameters, _ := json.MarshalIndent(schema, "", "  ")
+		fmt.Fprintf(&b, "### %s\n\n%s\n\n```json\n%s\n```\n\n", tool.Name, strings.TrimSpace(tool.Description), parameters)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+func TestDryRunContent(t *testing.T) {
+	t.Parallel()
+
+	msg := message.Message{Role: message.Assistant, Parts: []message.ContentPart{
+		message.TextContent{Text: "Let me look."},
+		message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`},
+	}}
+	require.Equal(t, "Let me look.\n[tool call call_1 view: {\"file_path\":\"main.go\"}]", dryRunContent(msg))
+
+	result := message.Message{Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "call_1", Content: "package main"},
+	}}
+	require.Equal(t, "[tool result call_1]\npackage main", dryRunContent(result))
+}
+
+func TestDryRunString(t *testing.T) {
+	t.Parallel()
+
+	dryRun := DryRun{
+		Agent:              "coder",
+		Provider:           "openai",
+		Model:              "gpt-4o",
+		SystemPrompt:       "You are Tulpa.\n<env>\nWorking directory: /tmp\n</env>\n",
+		SystemPromptTokens: 17,
+		Messages:           []DryRunMessage{{Role: message.User, Content: "hello"}},
+		Tools: []DryRunTool{{
+			Name:        "ls",
+			Description: "Lists files.",
+			Parameters:  map[string]any{"path": map[string]any{"type": "string"}},
+			Required:    []string{"path"},
+		}},
+	}
+	require.Equal(t, `# Agent coder
+
+Model: gpt-4o (openai)
+
+## System prompt (~17 tokens)
+
+You are Tulpa.
+<env>
+Working directory: /tmp
+</env>
+
+## Messages (1)
+
+### user
+
+hello
+
+## Tools (1)
+
+### ls
+
+Lists files.
+
+`+"```json"+`
+{
+  "properties": {
+    "path": {
+      "type": "string"
+    }
+  },
+  "required": [
+    "path"
+  ],
+  "type": "object"
+}
+`+"```"+`
+`, dryRun.String())
+}
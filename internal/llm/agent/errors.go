@@ -6,8 +6,13 @@ import (
 )
 
 var (
-	ErrRequestCancelled = errors.New("request canceled by user")
-	ErrSessionBusy      = errors.New("session is currently processing another request")
+	ErrRequestCancelled    = errors.New("request canceled by user")
+	ErrSessionBusy         = errors.New("session is currently processing another request")
+	ErrNoActivity          = errors.New("no activity from the provider or tools, request canceled")
+	ErrStreamStalled       = errors.New("stream stalled: the provider sent no data, request canceled")
+	ErrInvalidJSONResponse = errors.New("agent response is not valid JSON")
+	ErrBudgetExceeded      = errors.New("budget exceeded")
+	ErrToolLimit           = errors.New("tool iteration limit reached")
 )
 
 func isCancelledErr(err error) bool {
@@ -40,7 +40,7 @@ func (a *agent) eventTokensUsed(sessionID string, usage provider.TokenUsage, cos
 
 func (a *agent) eventCommon(sessionID string) []any {
 	cfg := config.Get()
-	currentModel := cfg.Models[cfg.Agents["coder"].Model]
+	currentModel, _ := cfg.SelectedModel(a.current.Load().cfg.Model)
 
 	return []any{
 		"session id", sessionID,
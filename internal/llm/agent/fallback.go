@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+type modelCandidate struct {
+	provider   provider.Provider
+	providerID string
+}
+
+// fallbackProvider sends requests to the first of its models and moves on
+// to the next one when a model is unavailable, as reported by
+// [provider.IsUnavailable]. A stream only falls back before it produced any
+// output, so a response is never made of several models.
+type fallbackProvider struct {
+	candidates []modelCandidate
+}
+
+// newModelProvider creates the provider answering for the given model tier
+// of an agent.
+func newModelProvider(agentCfg config.Agent, modelType config.SelectedModelType) (modelCandidate, error) {
+	cfg := config.Get()
+	providerCfg := cfg.GetProviderForModel(modelType)
+	if providerCfg == nil || providerCfg.ID == "" {
+		return modelCandidate{}, fmt.Errorf("provider for model %s of agent %s not found in config", modelType, agentCfg.Name)
+	}
+	if cfg.GetModelByType(modelType) == nil {
+		return modelCandidate{}, fmt.Errorf("model %s not found for agent %s", modelType, agentCfg.Name)
+	}
+	p, err := provider.NewProvider(*providerCfg,
+		provider.WithModel(modelType),
+		provider.WithSystemMessage(agentSystemMessage(agentCfg, providerCfg.ID)),
+	)
+	if err != nil {
+		return modelCandidate{}, err
+	}
+	return modelCandidate{provider: p, providerID: providerCfg.ID}, nil
+}
+
+// withFallbacks wraps primary so the fallback models of the agent are tried
+// when it is unavailable. Fallbacks that can't be created are skipped.
+func withFallbacks(agentCfg config.Agent, primary provider.Provider, primaryID string) provider.Provider {
+	if len(agentCfg.FallbackModels) == 0 {
+		return primary
+	}
+	candidates := []modelCandidate{{provider: primary, providerID: primaryID}}
+	for _, modelType := range agentCfg.FallbackModels {
+		candidate, err := newModelProvider(agentCfg, modelType)
+		if err != nil {
+			slog.Warn("Skipping fallback model", "agent", agentCfg.ID, "model", modelType, "error", err)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	return &fallbackProvider{candidates: candidates}
+}
+
+func (p *fallbackProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*provider.ProviderResponse, error) {
+	var err error
+	for i, candidate := range p.candidates {
+		var response *provider.ProviderResponse
+		response, err = candidate.provider.SendMessages(ctx, messages, tools)
+		if err == nil || !provider.IsUnavailable(err) || i+1 == len(p.candidates) {
+			return response, err
+		}
+		slog.Warn("Model unavailable, falling back", "from", candidate.provider.Model().ID, "to", p.candidates[i+1].provider.Model().ID, "error", err)
+	}
+	return nil, err
+}
+
+func (p *fallbackProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan provider.ProviderEvent {
+	out := make(chan provider.ProviderEvent)
+	go func() {
+		defer close(out)
+		for i, candidate := range p.candidates {
+			events := candidate.provider.StreamResponse(ctx, messages, tools)
+			last := i+1 == len(p.candidates)
+			started := false
+			var unavailable error
+			for event := range events {
+				if event.Type == provider.EventError && !started && !last && provider.IsUnavailable(event.Error) {
+					unavailable = event.Error
+					continue
+				}
+				started = started || producesOutput(event.Type)
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					// Let the provider finish without anyone reading.
+					go func() {
+						for range events {
+						}
+					}()
+					return
+				}
+			}
+			if unavailable == nil {
+				return
+			}
+
+			next := p.candidates[i+1]
+			slog.Warn("Model unavailable, falling back", "from", candidate.provider.Model().ID, "to", next.provider.Model().ID, "error", unavailable)
+			select {
+			case out <- provider.ProviderEvent{Type: provider.EventFallback, Fallback: &provider.FallbackInfo{
+				From:     candidate.provider.Model(),
+				To:       next.provider.Model(),
+				Provider: next.providerID,
+				Err:      unavailable,
+			}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Model returns the primary model.
+func (p *fallbackProvider) Model() catwalk.Model {
+	return p.candidates[0].provider.Model()
+}
+
+// producesOutput reports whether events of the given type are part of the
+// response, after which a stream can no longer fall back.
+func producesOutput(eventType provider.EventType) bool {
+	switch eventType {
+	case provider.EventRetry, provider.EventWarning, provider.EventFallback:
+		return false
+	}
+	return true
+}
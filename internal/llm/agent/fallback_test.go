@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// fakeProvider replays events and answers SendMessages with err, or with
+// the content of its events when err is nil.
+type fakeProvider struct {
+	model  string
+	events []provider.ProviderEvent
+	err    error
+	calls  int
+}
+
+func (p *fakeProvider) SendMessages(context.Context, []message.Message, []tools.BaseTool) (*provider.ProviderResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &provider.ProviderResponse{Content: p.model}, nil
+}
+
+func (p *fakeProvider) StreamResponse(context.Context, []message.Message, []tools.BaseTool) <-chan provider.ProviderEvent {
+	p.calls++
+	events := make(chan provider.ProviderEvent, len(p.events))
+	for _, event := range p.events {
+		events <- event
+	}
+	close(events)
+	return events
+}
+
+func (p *fakeProvider) Model() catwalk.Model {
+	return catwalk.Model{ID: p.model, Name: p.model}
+}
+
+func collectEvents(events <-chan provider.ProviderEvent) []provider.ProviderEvent {
+	var collected []provider.ProviderEvent
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func TestFallbackProviderStream(t *testing.T) {
+	t.Parallel()
+
+	unavailable := &openai.Error{StatusCode: http.StatusServiceUnavailable}
+	answer := []provider.ProviderEvent{
+		{Type: provider.EventContentDelta, Content: "hello"},
+		{Type: provider.EventComplete, Response: &provider.ProviderResponse{Content: "hello"}},
+	}
+
+	t.Run("falls back when the primary is unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		primary := &fakeProvider{model: "primary", events: []provider.ProviderEvent{
+			{Type: provider.EventRetry, Retry: &provider.RetryInfo{Attempt: 1}},
+			{Type: provider.EventError, Error: unavailable},
+		}}
+		fallback := &fakeProvider{model: "fallback", events: answer}
+		p := &fallbackProvider{candidates: []modelCandidate{
+			{provider: primary, providerID: "down"},
+			{provider: fallback, providerID: "up"},
+		}}
+
+		events := collectEvents(p.StreamResponse(t.Context(), nil, nil))
+		require.Len(t, events, 4)
+		require.Equal(t, provider.EventRetry, events[0].Type)
+		require.Equal(t, provider.EventFallback, events[1].Type)
+		require.Equal(t, "primary", events[1].Fallback.From.ID)
+		require.Equal(t, "fallback", events[1].Fallback.To.ID)
+		require.Equal(t, "up", events[1].Fallback.Provider)
+		require.ErrorIs(t, events[1].Fallback.Err, unavailable)
+		require.Equal(t, answer, events[2:])
+		require.Equal(t, "primary", p.Model().ID)
+	})
+
+	t.Run("does not fall back on content errors", func(t *testing.T) {
+		t.Parallel()
+
+		badRequest := &openai.Error{StatusCode: http.StatusBadRequest}
+		primary := &fakeProvider{model: "primary", events: []provider.ProviderEvent{{Type: provider.EventError, Error: badRequest}}}
+		fallback := &fakeProvider{model: "fallback", events: answer}
+		p := &fallbackProvider{candidates: []modelCandidate{{provider: primary}, {provider: fallback}}}
+
+		events := collectEvents(p.StreamResponse(t.Context(), nil, nil))
+		require.Equal(t, []provider.ProviderEvent{{Type: provider.EventError, Error: badRequest}}, events)
+		require.Zero(t, fallback.calls)
+	})
+
+	t.Run("does not fall back once the response started", func(t *testing.T) {
+		t.Parallel()
+
+		primary := &fakeProvider{model: "primary", events: []provider.ProviderEvent{
+			{Type: provider.EventContentDelta, Content: "hel"},
+			{Type: provider.EventError, Error: unavailable},
+		}}
+		fallback := &fakeProvider{model: "fallback", events: answer}
+		p := &fallbackProvider{candidates: []modelCandidate{{provider: primary}, {provider: fallback}}}
+
+		events := collectEvents(p.StreamResponse(t.Context(), nil, nil))
+		require.Len(t, events, 2)
+		require.Equal(t, provider.EventError, events[1].Type)
+		require.Zero(t, fallback.calls)
+	})
+
+	t.Run("reports the error of the last model", func(t *testing.T) {
+		t.Parallel()
+
+		lastErr := &openai.Error{StatusCode: http.StatusUnauthorized}
+		primary := &fakeProvider{model: "primary", events: []provider.ProviderEvent{{Type: provider.EventError, Error: unavailable}}}
+		fallback := &fakeProvider{model: "fallback", events: []provider.ProviderEvent{{Type: provider.EventError, Error: lastErr}}}
+		p := &fallbackProvider{candidates: []modelCandidate{{provider: primary}, {provider: fallback}}}
+
+		events := collectEvents(p.StreamResponse(t.Context(), nil, nil))
+		require.Len(t, events, 2)
+		require.Equal(t, provider.EventFallback, events[0].Type)
+		require.Equal(t, provider.ProviderEvent{Type: provider.EventError, Error: lastErr}, events[1])
+	})
+}
+
+func TestFallbackProviderSendMessages(t *testing.T) {
+	t.Parallel()
+
+	primary := &fakeProvider{model: "primary", err: &openai.Error{StatusCode: http.StatusTooManyRequests}}
+	fallback := &fakeProvider{model: "fallback"}
+	p := &fallbackProvider{candidates: []modelCandidate{{provider: primary}, {provider: fallback}}}
+
+	response, err := p.SendMessages(t.Context(), nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "fallback", response.Content)
+
+	primary.err = context.Canceled
+	_, err = p.SendMessages(t.Context(), nil, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, fallback.calls, "canceled requests don't fall back")
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+type activityWatchdogKey struct{}
+
+// activityWatchdog cancels a run with ErrNoActivity when no streaming or tool
+// event is reported within the timeout. A nil watchdog is valid and does
+// nothing, which is the case when the inactivity timeout is disabled.
+type activityWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelCauseFunc
+}
+
+// withActivityWatchdog returns a context that is canceled after timeout
+// without activity. It returns the parent context and a nil watchdog if
+// timeout is not positive.
+func withActivityWatchdog(ctx context.Context, timeout time.Duration) (context.Context, *activityWatchdog) {
+	ctx, w := withWatchdog(ctx, timeout, ErrNoActivity)
+	if w == nil {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, activityWatchdogKey{}, w), w
+}
+
+// withWatchdog returns a context that is canceled with cause after timeout
+// unless the watchdog is touched. It returns the parent context and a nil
+// watchdog if timeout is not positive.
+func withWatchdog(ctx context.Context, timeout time.Duration, cause error) (context.Context, *activityWatchdog) {
+	if timeout <= 0 {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &activityWatchdog{timeout: timeout, cancel: cancel}
+	w.timer = time.AfterFunc(timeout, func() {
+		cancel(cause)
+	})
+	return ctx, w
+}
+
+func activityWatchdogFromContext(ctx context.Context) *activityWatchdog {
+	w, _ := ctx.Value(activityWatchdogKey{}).(*activityWatchdog)
+	return w
+}
+
+// Touch records activity and restarts the timeout.
+func (w *activityWatchdog) Touch() {
+	if w == nil {
+		return
+	}
+	w.timer.Reset(w.timeout)
+}
+
+// Extend restarts the timeout with extra time added, e.g. while waiting to
+// retry a request.
+func (w *activityWatchdog) Extend(extra time.Duration) {
+	if w == nil {
+		return
+	}
+	w.timer.Reset(w.timeout + extra)
+}
+
+// Pause stops the timeout until the next Touch, e.g. while a tool runs.
+func (w *activityWatchdog) Pause() {
+	if w == nil {
+		return
+	}
+	w.timer.Stop()
+}
+
+// Stop releases the watchdog and its context.
+func (w *activityWatchdog) Stop() {
+	if w == nil {
+		return
+	}
+	w.timer.Stop()
+	w.cancel(nil)
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/llm/provider"
+)
+
+// silentStream emits the given number of events and then goes silent without
+// closing the channel, like a provider stream that hangs.
+func silentStream(ctx context.Context, events int, interval time.Duration) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent)
+	go func() {
+		for range events {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Content: "token"}:
+			}
+		}
+	}()
+	return ch
+}
+
+func consume(ctx context.Context, events <-chan provider.ProviderEvent) int {
+	watchdog := activityWatchdogFromContext(ctx)
+	received := 0
+	for {
+		select {
+		case <-events:
+			watchdog.Touch()
+			received++
+		case <-ctx.Done():
+			return received
+		}
+	}
+}
+
+func TestActivityWatchdog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cancels a silent stream", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, watchdog := withActivityWatchdog(t.Context(), 100*time.Millisecond)
+		defer watchdog.Stop()
+
+		// Events arrive faster than the timeout, so only the silence after
+		// the last one triggers the cancellation.
+		received := consume(ctx, silentStream(ctx, 5, 30*time.Millisecond))
+		require.Equal(t, 5, received)
+		require.ErrorIs(t, context.Cause(ctx), ErrNoActivity)
+	})
+
+	t.Run("pause ignores long running tools", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, watchdog := withActivityWatchdog(t.Context(), 50*time.Millisecond)
+		defer watchdog.Stop()
+
+		watchdog.Pause()
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, ctx.Err())
+
+		watchdog.Touch()
+		<-ctx.Done()
+		require.ErrorIs(t, context.Cause(ctx), ErrNoActivity)
+	})
+
+	t.Run("stop does not report inactivity", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, watchdog := withActivityWatchdog(t.Context(), time.Hour)
+		watchdog.Stop()
+		require.ErrorIs(t, context.Cause(ctx), context.Canceled)
+		require.NotErrorIs(t, context.Cause(ctx), ErrNoActivity)
+	})
+
+	t.Run("disabled without timeout", func(t *testing.T) {
+		t.Parallel()
+
+		parent := t.Context()
+		ctx, watchdog := withActivityWatchdog(parent, 0)
+		require.Nil(t, watchdog)
+		require.Equal(t, parent, ctx)
+
+		// A nil watchdog is safe to use.
+		watchdog.Touch()
+		watchdog.Pause()
+		watchdog.Stop()
+		require.Nil(t, activityWatchdogFromContext(ctx))
+	})
+}
+
+func TestStallWatchdog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cancels with its own cause", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, stall := withWatchdog(t.Context(), 50*time.Millisecond, ErrStreamStalled)
+		defer stall.Stop()
+
+		<-ctx.Done()
+		require.ErrorIs(t, context.Cause(ctx), ErrStreamStalled)
+		require.NotErrorIs(t, context.Cause(ctx), context.Canceled)
+		require.Nil(t, activityWatchdogFromContext(ctx), "the stall watchdog must not replace the activity watchdog")
+	})
+
+	t.Run("extends the timeout while waiting to retry", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, stall := withWatchdog(t.Context(), 50*time.Millisecond, ErrStreamStalled)
+		defer stall.Stop()
+
+		stall.Extend(200 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			t.Fatal("stream canceled during the retry delay")
+		case <-time.After(150 * time.Millisecond):
+		}
+		<-ctx.Done()
+		require.ErrorIs(t, context.Cause(ctx), ErrStreamStalled)
+	})
+}
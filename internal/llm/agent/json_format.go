@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const jsonResponseInstructions = `# Response Format
+
+Your final response must be a single valid JSON value and nothing else: no prose before or after it and no Markdown code fences.`
+
+var (
+	errJSONEmpty     = errors.New("response is empty")
+	errJSONTruncated = errors.New("response is truncated JSON")
+)
+
+// parseJSONResponse validates the final output of an agent using the JSON
+// response format and returns the JSON value. A Markdown code fence around
+// the value is tolerated.
+func parseJSONResponse(content string) (json.RawMessage, error) {
+	content = strings.TrimSpace(content)
+	if fenced, ok := strings.CutPrefix(content, "```"); ok {
+		// Drop the optional language tag of the fence.
+		if _, body, found := strings.Cut(fenced, "\n"); found {
+			fenced = body
+		}
+		content = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(fenced), "```"))
+	}
+	if content == "" {
+		return nil, errJSONEmpty
+	}
+
+	dec := json.NewDecoder(strings.NewReader(content))
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errJSONTruncated
+		}
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("response has unexpected content after the JSON value")
+	}
+	return json.RawMessage(content), nil
+}
+
+// jsonCorrectionPrompt is sent once to the model when its final output could
+// not be parsed as JSON.
+func jsonCorrectionPrompt(err error) string {
+	return fmt.Sprintf("Your previous response could not be parsed: %s. Reply again with only the complete, valid JSON value and no other text.", err)
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		err      error
+		errMsg   string
+	}{
+		{
+			name:     "object",
+			content:  `{"status": "ok", "files": ["a.go"]}`,
+			expected: `{"status": "ok", "files": ["a.go"]}`,
+		},
+		{
+			name:     "surrounding whitespace",
+			content:  "\n  [1, 2, 3]\n",
+			expected: `[1, 2, 3]`,
+		},
+		{
+			name:     "code fence with language",
+			content:  "```json\n{\"ok\": true}\n```",
+			expected: `{"ok": true}`,
+		},
+		{
+			name:     "code fence without language",
+			content:  "```\n{\"ok\": true}\n```",
+			expected: `{"ok": true}`,
+		},
+		{
+			name:    "truncated object",
+			content: `{"status": "ok", "files": ["a.go",`,
+			err:     errJSONTruncated,
+		},
+		{
+			name:    "truncated string",
+			content: `{"summary": "the output was cut`,
+			err:     errJSONTruncated,
+		},
+		{
+			name:    "prose",
+			content: "Here is the result you asked for.",
+			errMsg:  "response is not valid JSON",
+		},
+		{
+			name:    "trailing prose",
+			content: `{"ok": true} Let me know if you need anything else.`,
+			errMsg:  "unexpected content after the JSON value",
+		},
+		{
+			name:    "empty",
+			content: "   ",
+			err:     errJSONEmpty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseJSONResponse(tt.content)
+			switch {
+			case tt.err != nil:
+				require.ErrorIs(t, err, tt.err)
+			case tt.errMsg != "":
+				require.ErrorContains(t, err, tt.errMsg)
+			default:
+				require.NoError(t, err)
+				require.JSONEq(t, tt.expected, string(got))
+			}
+		})
+	}
+}
+
+func TestJSONCorrectionPrompt(t *testing.T) {
+	t.Parallel()
+
+	prompt := jsonCorrectionPrompt(errJSONTruncated)
+	require.Contains(t, prompt, errJSONTruncated.Error())
+	require.Contains(t, prompt, "valid JSON")
+}
@@ -56,6 +56,7 @@ type MCPEventType string
 const (
 	MCPEventStateChanged     MCPEventType = "state_changed"
 	MCPEventToolsListChanged MCPEventType = "tools_list_changed"
+	MCPEventDisconnected     MCPEventType = "disconnected"
 )
 
 // MCPEvent represents an event in the MCP system
@@ -84,8 +85,14 @@ var (
 	mcpClients      = csync.NewMap[string, *mcp.ClientSession]()
 	mcpStates       = csync.NewMap[string, MCPClientInfo]()
 	mcpBroker       = pubsub.NewBroker[MCPEvent]()
+	mcpCallSlots    = csync.NewMap[string, chan struct{}]()
+
+	// mcpWatchCtx is canceled by CloseMCPClients to stop reconnecting.
+	mcpWatchCtx, stopMCPWatch = context.WithCancel(context.Background())
 )
 
+var errMCPCallTimeout = errors.New("mcp tool call timed out")
+
 type McpTool struct {
 	mcpName     string
 	tool        *mcp.Tool
@@ -119,7 +126,7 @@ func runTool(ctx context.Context, name, toolName string, input string) (tools.To
 	if err != nil {
 		return tools.NewTextErrorResponse(err.Error()), nil
 	}
-	result, err := c.CallTool(ctx, &mcp.CallToolParams{
+	result, err := callMCPTool(ctx, c, name, config.Get().MCP[name], &mcp.CallToolParams{
 		Name:      toolName,
 		Arguments: args,
 	})
@@ -138,33 +145,121 @@ func runTool(ctx context.Context, name, toolName string, input string) (tools.To
 	return tools.NewTextResponse(strings.Join(output, "\n")), nil
 }
 
+// callMCPTool calls a tool of the named server, first waiting for a free
+// slot when the server limits its concurrent calls. Waiting and calling
+// together can't take longer than the server's call timeout.
+func callMCPTool(ctx context.Context, c *mcp.ClientSession, name string, m config.MCPConfig, params *mcp.CallToolParams) (*mcp.CallToolResult, error) {
+	timeout := mcpCallTimeout(m)
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, errMCPCallTimeout)
+	defer cancel()
+
+	if m.MaxConcurrentCalls > 0 {
+		slots := mcpCallSlots.GetOrSet(name, func() chan struct{} {
+			return make(chan struct{}, m.MaxConcurrentCalls)
+		})
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.Done():
+			if errors.Is(context.Cause(ctx), errMCPCallTimeout) {
+				return nil, fmt.Errorf("mcp '%s' is busy: no call slot freed up within %s (max %d concurrent calls)", name, timeout, m.MaxConcurrentCalls)
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	result, err := c.CallTool(ctx, params)
+	if err != nil && errors.Is(context.Cause(ctx), errMCPCallTimeout) {
+		return nil, fmt.Errorf("mcp '%s' did not answer the %s call within %s; the server may be stuck, try again later or without this tool", name, params.Name, timeout)
+	}
+	return result, err
+}
+
+// getOrRenewClient returns the session of the named server after checking
+// that it still answers. When it doesn't, the session is closed so that
+// watchMCPClient reconnects, and the call fails until it has.
 func getOrRenewClient(ctx context.Context, name string) (*mcp.ClientSession, error) {
 	sess, ok := mcpClients.Get(name)
 	if !ok {
-		return nil, fmt.Errorf("mcp '%s' not available", name)
+		if state, ok := GetMCPState(name); ok && state.Error != nil {
+			return nil, fmt.Errorf("mcp server '%s' is unavailable: %v", name, state.Error)
+		}
+		return nil, fmt.Errorf("mcp server '%s' is unavailable", name)
 	}
 
-	cfg := config.Get()
-	m := cfg.MCP[name]
-	state, _ := mcpStates.Get(name)
-
-	timeout := mcpTimeout(m)
+	timeout := mcpTimeout(config.Get().MCP[name])
 	pingCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	err := sess.Ping(pingCtx, nil)
-	if err == nil {
-		return sess, nil
+	if err := sess.Ping(pingCtx, nil); err != nil {
+		err = maybeTimeoutErr(err, timeout)
+		updateMCPState(name, MCPStateError, fmt.Errorf("%w, reconnecting", err), nil, 0)
+		_ = sess.Close()
+		return nil, fmt.Errorf("mcp server '%s' is unavailable: %w", name, err)
 	}
-	updateMCPState(name, MCPStateError, maybeTimeoutErr(err, timeout), nil, state.ToolCount)
+	return sess, nil
+}
 
-	sess, err = createMCPSession(ctx, name, m, cfg.Resolver())
-	if err != nil {
-		return nil, err
+// watchMCPClient reconnects to the named server with backoff whenever its
+// session closes, until ctx is canceled by CloseMCPClients. Once reconnected, the
+// agents are told to register the server's tools again.
+func watchMCPClient(ctx context.Context, name string, m config.MCPConfig, resolver config.VariableResolver, sess *mcp.ClientSession) {
+	for {
+		err := sess.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+
+		lost := errors.New("connection lost, reconnecting")
+		if err != nil && !errors.Is(err, io.EOF) {
+			lost = fmt.Errorf("connection lost, reconnecting: %w", err)
+		}
+		slog.Warn("MCP connection lost", "name", name, "error", err)
+		mcpClients.Del(name)
+		updateMCPState(name, MCPStateError, lost, nil, 0)
+		mcpBroker.Publish(pubsub.UpdatedEvent, MCPEvent{
+			Type:  MCPEventDisconnected,
+			Name:  name,
+			State: MCPStateError,
+			Error: lost,
+		})
+
+		sess = reconnectMCPClient(ctx, name, m, resolver)
+		if sess == nil {
+			return
+		}
 	}
+}
 
-	updateMCPState(name, MCPStateConnected, nil, sess, state.ToolCount)
-	mcpClients.Set(name, sess)
-	return sess, nil
+func reconnectMCPClient(ctx context.Context, name string, m config.MCPConfig, resolver config.VariableResolver) *mcp.ClientSession {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			delay := min(time.Second<<min(attempt-1, 6), time.Minute)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		sess, err := createMCPSession(ctx, name, m, resolver)
+		if err != nil {
+			slog.Warn("Failed to reconnect to mcp", "name", name, "attempt", attempt+1, "error", err)
+			continue
+		}
+		if ctx.Err() != nil {
+			_ = sess.Close()
+			return nil
+		}
+
+		slog.Info("Reconnected to mcp", "name", name, "attempts", attempt+1)
+		mcpClients.Set(name, sess)
+		updateMCPState(name, MCPStateConnected, nil, sess, 0)
+		mcpBroker.Publish(pubsub.UpdatedEvent, MCPEvent{
+			Type: MCPEventToolsListChanged,
+			Name: name,
+		})
+		return sess
+	}
 }
 
 func (b *McpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolResponse, error) {
@@ -223,6 +318,36 @@ func GetMCPState(name string) (MCPClientInfo, bool) {
 	return mcpStates.Get(name)
 }
 
+// MCPProbe is the result of a successful ProbeMCP.
+type MCPProbe struct {
+	Latency time.Duration
+	Tools   []string
+}
+
+// ProbeMCP connects to the MCP server, lists its tools and disconnects,
+// reporting how long it took.
+func ProbeMCP(ctx context.Context, name string, m config.MCPConfig, resolver config.VariableResolver) (MCPProbe, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, mcpTimeout(m))
+	defer cancel()
+
+	c, err := createMCPSession(ctx, name, m, resolver)
+	if err != nil {
+		return MCPProbe{}, maybeTimeoutErr(err, mcpTimeout(m))
+	}
+	defer c.Close()
+
+	result, err := c.ListTools(ctx, &mcp.ListToolsParams{})
+	if err != nil {
+		return MCPProbe{}, maybeTimeoutErr(err, mcpTimeout(m))
+	}
+	probe := MCPProbe{Latency: time.Since(start)}
+	for _, tool := range result.Tools {
+		probe.Tools = append(probe.Tools, tool.Name)
+	}
+	return probe, nil
+}
+
 // updateMCPState updates the state of an MCP client and publishes an event
 func updateMCPState(name string, state MCPState, err error, client *mcp.ClientSession, toolCount int) {
 	info := MCPClientInfo{
@@ -253,6 +378,7 @@ func updateMCPState(name string, state MCPState, err error, client *mcp.ClientSe
 
 // CloseMCPClients closes all MCP clients. This should be called during application shutdown.
 func CloseMCPClients() error {
+	stopMCPWatch()
 	var errs []error
 	for name, c := range mcpClients.Seq2() {
 		if err := c.Close(); err != nil &&
@@ -301,7 +427,9 @@ func doGetMCPTools(ctx context.Context, permissions permission.Service, cfg *con
 			ctx, cancel := context.WithTimeout(ctx, mcpTimeout(m))
 			defer cancel()
 
-			c, err := createMCPSession(ctx, name, m, cfg.Resolver())
+			// The session outlives this function, so it must not be
+			// tied to ctx: createMCPSession times out on its own.
+			c, err := createMCPSession(mcpWatchCtx, name, m, cfg.Resolver())
 			if err != nil {
 				return
 			}
@@ -319,6 +447,7 @@ func doGetMCPTools(ctx context.Context, permissions permission.Service, cfg *con
 			updateMcpTools(name, tools)
 			mcpClients.Set(name, c)
 			updateMCPState(name, MCPStateConnected, nil, c, len(tools))
+			go watchMCPClient(mcpWatchCtx, name, m, cfg.Resolver(), c)
 		}(name, m)
 	}
 	wg.Wait()
@@ -447,3 +576,7 @@ func (rt headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error
 func mcpTimeout(m config.MCPConfig) time.Duration {
 	return time.Duration(cmp.Or(m.Timeout, 15)) * time.Second
 }
+
+func mcpCallTimeout(m config.MCPConfig) time.Duration {
+	return time.Duration(cmp.Or(m.CallTimeout, 300)) * time.Second
+}
@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/env"
+)
+
+func newFakeMCPServer(release <-chan struct{}) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "fake"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "fast"}, func(ctx context.Context, _ *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "done"}}}, nil, nil
+	})
+	mcp.AddTool(server, &mcp.Tool{Name: "slow"}, func(ctx context.Context, _ *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "done"}}}, nil, nil
+	})
+	return server
+}
+
+// fakeMCPServer connects a client to an in-memory server exposing a "fast"
+// tool and a "slow" one, which only answers once release is closed.
+func fakeMCPServer(t *testing.T, release <-chan struct{}) *mcp.ClientSession {
+	t.Helper()
+
+	server := newFakeMCPServer(release)
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(t.Context(), serverTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { serverSession.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	session, err := client.Connect(t.Context(), clientTransport, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestCallMCPToolTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	session := fakeMCPServer(t, release)
+
+	start := time.Now()
+	_, err := callMCPTool(t.Context(), session, "timeout-test", config.MCPConfig{CallTimeout: 1}, &mcp.CallToolParams{Name: "slow"})
+	require.ErrorContains(t, err, "mcp 'timeout-test' did not answer the slow call within 1s")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	result, err := callMCPTool(t.Context(), session, "timeout-test", config.MCPConfig{CallTimeout: 1}, &mcp.CallToolParams{Name: "fast"})
+	require.NoError(t, err)
+	require.Equal(t, "done", result.Content[0].(*mcp.TextContent).Text)
+}
+
+func TestCallMCPToolConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	session := fakeMCPServer(t, release)
+	m := config.MCPConfig{CallTimeout: 1, MaxConcurrentCalls: 1}
+
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := callMCPTool(t.Context(), session, "limit-test", config.MCPConfig{CallTimeout: 10, MaxConcurrentCalls: 1}, &mcp.CallToolParams{Name: "slow"})
+		slowDone <- err
+	}()
+	require.Eventually(t, func() bool {
+		slots, ok := mcpCallSlots.Get("limit-test")
+		return ok && len(slots) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := callMCPTool(t.Context(), session, "limit-test", m, &mcp.CallToolParams{Name: "fast"})
+	require.ErrorContains(t, err, "mcp 'limit-test' is busy")
+
+	close(release)
+	require.NoError(t, <-slowDone)
+
+	_, err = callMCPTool(t.Context(), session, "limit-test", m, &mcp.CallToolParams{Name: "fast"})
+	require.NoError(t, err, "the slot should be freed once the slow call returns")
+}
+
+func TestCallMCPToolCanceled(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+	session := fakeMCPServer(t, release)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	_, err := callMCPTool(ctx, session, "cancel-test", config.MCPConfig{CallTimeout: 10}, &mcp.CallToolParams{Name: "slow"})
+	require.ErrorIs(t, err, context.DeadlineExceeded, "a canceled run is not reported as a server timeout")
+	require.NotContains(t, err.Error(), "did not answer")
+}
+
+func TestWatchMCPClientReconnects(t *testing.T) {
+	t.Parallel()
+
+	const name = "reconnect-test"
+	release := make(chan struct{})
+	defer close(release)
+
+	// The first session is in memory; reconnecting goes through HTTP.
+	server := newFakeMCPServer(release)
+	httpServer := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil))
+	defer httpServer.Close()
+	m := config.MCPConfig{Type: config.MCPHttp, URL: httpServer.URL}
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(t.Context(), serverTransport, nil)
+	require.NoError(t, err)
+	sess, err := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil).Connect(t.Context(), clientTransport, nil)
+	require.NoError(t, err)
+	mcpClients.Set(name, sess)
+
+	events := SubscribeMCPEvents(t.Context())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go watchMCPClient(ctx, name, m, config.NewShellVariableResolver(env.NewFromMap(nil)), sess)
+
+	require.NoError(t, serverSession.Close())
+
+	var disconnected, reconnected bool
+	for !reconnected {
+		select {
+		case event := <-events:
+			if event.Payload.Name != name {
+				continue
+			}
+			switch event.Payload.Type {
+			case MCPEventDisconnected:
+				disconnected = true
+				require.ErrorContains(t, event.Payload.Error, "connection lost")
+			case MCPEventToolsListChanged:
+				reconnected = true
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for the reconnection")
+		}
+	}
+	require.True(t, disconnected, "the disconnection should be published")
+
+	state, ok := GetMCPState(name)
+	require.True(t, ok)
+	require.Equal(t, MCPStateConnected, state.State)
+
+	renewed, ok := mcpClients.Get(name)
+	require.True(t, ok)
+	require.NotSame(t, sess, renewed)
+	result, err := callMCPTool(t.Context(), renewed, name, m, &mcp.CallToolParams{Name: "fast"})
+	require.NoError(t, err)
+	require.Equal(t, "done", result.Content[0].(*mcp.TextContent).Text)
+	cancel()
+	_ = renewed.Close()
+}
+
+func TestGetOrRenewClientUnavailable(t *testing.T) {
+	t.Parallel()
+
+	const name = "unavailable-test"
+	updateMCPState(name, MCPStateError, errors.New("connection lost, reconnecting"), nil, 0)
+
+	_, err := getOrRenewClient(t.Context(), name)
+	require.EqualError(t, err, "mcp server 'unavailable-test' is unavailable: connection lost, reconnecting")
+}
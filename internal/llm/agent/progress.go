@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+)
+
+// StepInfo is the step of its plan an agent started.
+type StepInfo struct {
+	Step        int
+	Total       int
+	Description string
+}
+
+// Percent returns how much of the plan is done, the started step excluded.
+func (s StepInfo) Percent() int {
+	if s.Total <= 0 {
+		return 0
+	}
+	return 100 * (s.Step - 1) / s.Total
+}
+
+// publishStep tells which step of its plan the agent started, from the
+// metadata of a progress tool response.
+func (a *agent) publishStep(sessionID, metadata string) {
+	var step tools.ProgressResponseMetadata
+	if err := json.Unmarshal([]byte(metadata), &step); err != nil {
+		slog.Warn("Failed to read the progress of the agent", "sessionID", sessionID, "error", err)
+		return
+	}
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeStep,
+		SessionID: sessionID,
+		Step:      &StepInfo{Step: step.Step, Total: step.Total, Description: step.Description},
+	})
+}
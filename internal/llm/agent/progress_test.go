@@ -0,0 +1,16 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepInfoPercent(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0, StepInfo{Step: 1, Total: 4}.Percent())
+	require.Equal(t, 50, StepInfo{Step: 3, Total: 4}.Percent())
+	require.Equal(t, 80, StepInfo{Step: 5, Total: 5}.Percent())
+	require.Equal(t, 0, StepInfo{}.Percent())
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// Steer sends content to the running turn of the session at its next
+// tool-call boundary, so that the user redirects the agent without
+// canceling what it did so far. It returns false when the session isn't
+// running.
+func (a *agent) Steer(sessionID, content string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.IsSessionBusy(sessionID) {
+		return false
+	}
+	existing, _ := a.steering.Get(sessionID)
+	a.steering.Set(sessionID, append(existing, content))
+	return true
+}
+
+// takeSteering returns the steering messages of the session not sent yet and
+// removes them.
+func (a *agent) takeSteering(sessionID string) ([]string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.steering.Take(sessionID)
+}
+
+// Steering returns the steering messages of the session not sent yet.
+func (a *agent) Steering(sessionID string) []string {
+	steering, _ := a.steering.Get(sessionID)
+	return slices.Clone(steering)
+}
+
+// steeringMessages creates the user messages for the steering of the
+// session not sent yet.
+func (a *agent) steeringMessages(ctx context.Context, sessionID string) ([]message.Message, error) {
+	steering, ok := a.takeSteering(sessionID)
+	if !ok {
+		return nil, nil
+	}
+	msgs := make([]message.Message, 0, len(steering))
+	for _, content := range steering {
+		slog.Info("Steering the agent", "sessionID", sessionID)
+		userMsg, err := a.createUserMessage(ctx, sessionID, content, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user message for steering: %w", err)
+		}
+		msgs = append(msgs, userMsg)
+	}
+	return msgs, nil
+}
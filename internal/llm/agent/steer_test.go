@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/csync"
+)
+
+func TestSteer(t *testing.T) {
+	t.Parallel()
+
+	a := &agent{
+		activeRequests: csync.NewMap[string, context.CancelFunc](),
+		promptQueue:    csync.NewMap[string, []string](),
+		steering:       csync.NewMap[string, []string](),
+	}
+	require.False(t, a.Steer("session", "not running"))
+	require.Empty(t, a.Steering("session"))
+
+	a.activeRequests.Set("session", func() {})
+	require.True(t, a.Steer("session", "use the other API"))
+	require.True(t, a.Steer("session", "and keep the tests"))
+	require.Equal(t, []string{"use the other API", "and keep the tests"}, a.Steering("session"))
+	require.Empty(t, a.Steering("other"))
+
+	a.ClearQueue("session")
+	require.Empty(t, a.Steering("session"))
+
+	require.True(t, a.Steer("session", "stop"))
+	a.Cancel("session")
+	require.Empty(t, a.Steering("session"))
+}
+
+func TestSteeringLeftWhenTurnEnds(t *testing.T) {
+	t.Parallel()
+
+	a := &agent{
+		activeRequests: csync.NewMap[string, context.CancelFunc](),
+		promptQueue:    csync.NewMap[string, []string](),
+		steering:       csync.NewMap[string, []string](),
+	}
+	ok, err := a.startTurn("session", "first", func() {}, false)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, a.Steer("session", "use the other API"))
+	_, err = a.startTurn("session", "second", func() {}, false)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"use the other API", "second"}, a.endTurn("session"), "the steering not sent is the next prompt")
+	require.Empty(t, a.Steering("session"))
+	require.False(t, a.Steer("session", "too late"), "a session that ended can't be steered")
+}
+
+func TestSteerWhileTakingSteering(t *testing.T) {
+	t.Parallel()
+
+	a := &agent{
+		activeRequests: csync.NewMap[string, context.CancelFunc](),
+		promptQueue:    csync.NewMap[string, []string](),
+		steering:       csync.NewMap[string, []string](),
+	}
+	a.activeRequests.Set("session", func() {})
+
+	const runs = 200
+	var steered, taken atomic.Int32
+	var wg sync.WaitGroup
+	for i := range runs {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if a.Steer("session", fmt.Sprintf("steer %d", i)) {
+				steered.Add(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			steering, _ := a.takeSteering("session")
+			taken.Add(int32(len(steering)))
+		}()
+	}
+	wg.Wait()
+	taken.Add(int32(len(a.endTurn("session"))))
+	require.Equal(t, int32(runs), steered.Load())
+	require.Equal(t, int32(runs), taken.Load(), "each steering message is sent exactly once")
+}
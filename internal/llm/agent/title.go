@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/log"
+	"github.com/tulpa-code/tulpa/internal/session"
+)
+
+// titleTimeout bounds title generation, which outlives the request.
+const titleTimeout = 30 * time.Second
+
+// maxTitleContentLength is how much of the first exchange, in runes, is sent
+// to generate a title.
+const maxTitleContentLength = 2000
+
+// maxFallbackTitleLength is how much of the prompt, in runes, titles a session
+// when no title could be generated.
+const maxFallbackTitleLength = 100
+
+// generateTitleAsync titles the session from its first exchange without
+// blocking the request. When that fails, a session with the default title is
+// named after the start of the prompt.
+func (a *agent) generateTitleAsync(ctx context.Context, sessionID, prompt, response string) {
+	ctx = context.WithoutCancel(ctx)
+	a.titles.Add(1)
+	go func() {
+		defer a.titles.Done()
+		defer log.RecoverPanic("agent.generateTitle", func() {
+			slog.Error("panic while generating title")
+		})
+		titleCtx, cancel := context.WithTimeout(ctx, titleTimeout)
+		defer cancel()
+		err := a.generateTitle(titleCtx, sessionID, titleContent(prompt, response))
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			slog.Error("failed to generate title", "error", err)
+		}
+		if err := a.setFallbackTitle(ctx, sessionID, prompt); err != nil {
+			slog.Error("failed to set fallback title", "error", err)
+		}
+	}()
+}
+
+// waitForTitles waits at most timeout for running title generations.
+func (a *agent) waitForTitles(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		a.titles.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func (a *agent) setFallbackTitle(ctx context.Context, sessionID, prompt string) error {
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	title := fallbackTitle(prompt)
+	// Sessions created with a title, like non-interactive ones, keep it.
+	if sess.Title != session.DefaultTitle || title == "" {
+		return nil
+	}
+	sess.Title = title
+	_, err = a.sessions.Save(ctx, sess)
+	return err
+}
+
+// titleContent is what a title is generated from: the prompt and the start
+// of the response to it.
+func titleContent(prompt, response string) string {
+	content := prompt
+	if response = strings.TrimSpace(response); response != "" {
+		content += "\n\nResponse:\n" + response
+	}
+	return truncateRunes(content, maxTitleContentLength, "")
+}
+
+// fallbackTitle is the first line of prompt, shortened.
+func fallbackTitle(prompt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	return truncateRunes(strings.TrimSpace(line), maxFallbackTitleLength, "...")
+}
+
+func truncateRunes(s string, n int, ellipsis string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + ellipsis
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackTitle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{
+			name:   "short prompt",
+			prompt: "  fix the flaky test  ",
+			want:   "fix the flaky test",
+		},
+		{
+			name:   "first line only",
+			prompt: "refactor the parser\n\nit is in internal/parse",
+			want:   "refactor the parser",
+		},
+		{
+			name:   "long prompt",
+			prompt: strings.Repeat("ö", 150),
+			want:   strings.Repeat("ö", 100) + "...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, fallbackTitle(tt.prompt))
+		})
+	}
+}
+
+func TestTitleContent(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "hi", titleContent("hi", "  "))
+	require.Equal(t, "hi\n\nResponse:\nhello", titleContent("hi", "hello\n"))
+	require.Len(t, []rune(titleContent("hi", strings.Repeat("é", 3000))), maxTitleContentLength)
+}
@@ -0,0 +1,26 @@
+package agent
+
+import "fmt"
+
+// ToolLimitInfo is how many of the tool iterations of a turn an agent used.
+type ToolLimitInfo struct {
+	Used int
+	Max  int
+}
+
+// Reached reports whether the agent may not call tools anymore.
+func (i ToolLimitInfo) Reached() bool {
+	return i.Used >= i.Max
+}
+
+// toolLimitWarningAt returns after how many tool iterations an agent is told
+// that it approaches limit, or zero when it isn't.
+func toolLimitWarningAt(limit int) int {
+	return limit - max(limit/5, 1)
+}
+
+// toolLimitPrompt is sent to the model when it used all the tool iterations
+// of the turn.
+func toolLimitPrompt(limit int) string {
+	return fmt.Sprintf("You have reached the limit of %d tool iterations for this turn. Do not call any more tools: answer now with what you found so far, and say what is left to do.", limit)
+}
@@ -0,0 +1,26 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolLimitWarningAt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 1, want: 0},
+		{limit: 2, want: 1},
+		{limit: 10, want: 8},
+		{limit: 50, want: 40},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, toolLimitWarningAt(tt.limit), "limit %d", tt.limit)
+	}
+	require.False(t, ToolLimitInfo{Used: 8, Max: 10}.Reached())
+	require.True(t, ToolLimitInfo{Used: 10, Max: 10}.Reached())
+}
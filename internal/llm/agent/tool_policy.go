@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+)
+
+// sessionDisabledTools holds the tools forbidden in a session, whatever its
+// agent allows. It's shared by all agents, so that subagents inherit the
+// restrictions of the session that started them.
+var sessionDisabledTools = csync.NewMap[string, []string]()
+
+// SetSessionDisabledTools forbids tools in the given session and in the
+// subagent sessions it starts, whatever their agents allow: the model isn't
+// offered them and can't call them. No tools lifts the restrictions.
+func SetSessionDisabledTools(sessionID string, names []string) {
+	if len(names) == 0 {
+		sessionDisabledTools.Del(sessionID)
+		return
+	}
+	sessionDisabledTools.Set(sessionID, slices.Clone(names))
+}
+
+// SessionDisabledTools returns the tools forbidden in the given session.
+func SessionDisabledTools(sessionID string) []string {
+	names, _ := sessionDisabledTools.Get(sessionID)
+	return slices.Clone(names)
+}
+
+// withoutDisabledTools returns allTools without the tools the session
+// forbids.
+func withoutDisabledTools(sessionID string, allTools []tools.BaseTool) []tools.BaseTool {
+	disabled, ok := sessionDisabledTools.Get(sessionID)
+	if !ok {
+		return allTools
+	}
+	return slices.DeleteFunc(allTools, func(tool tools.BaseTool) bool {
+		return slices.Contains(disabled, tool.Name())
+	})
+}
+
+// warnDisabledTools logs the tools the agent allows but the session forbids.
+func (a *agent) warnDisabledTools(sessionID string) {
+	agentCfg := a.current.Load().cfg
+	for _, name := range SessionDisabledTools(sessionID) {
+		if agentCfg.AllowedTools == nil || slices.Contains(agentCfg.AllowedTools, name) {
+			slog.Warn("Tool allowed by the agent is forbidden in the session", "agent", agentCfg.ID, "sessionID", sessionID, "tool", name)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/lsp"
+)
+
+type namedTool string
+
+func (t namedTool) Info() tools.ToolInfo { return tools.ToolInfo{Name: string(t)} }
+func (t namedTool) Name() string         { return string(t) }
+func (t namedTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.NewTextResponse(string(t)), nil
+}
+
+// sortedToolNames returns the names of the tools of the agent in the session.
+func sortedToolNames(t *testing.T, a *agent, sessionID string) []string {
+	allTools, err := a.getAllTools(sessionID)
+	require.NoError(t, err)
+	names := make([]string, len(allTools))
+	for i, tool := range allTools {
+		names[i] = tool.Name()
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestSessionDisabledTools(t *testing.T) {
+	cfg, err := config.Init(t.TempDir(), t.TempDir(), false)
+	require.NoError(t, err)
+
+	// The coder agent allows every tool.
+	a := &agent{
+		baseTools: csync.NewLazyMap(func() map[string]tools.BaseTool {
+			return map[string]tools.BaseTool{"bash": namedTool("bash"), "view": namedTool("view")}
+		}),
+		mcpTools:   csync.NewMap[string, tools.BaseTool](),
+		lspClients: csync.NewMap[string, *lsp.Client](),
+	}
+	a.current.Store(&agentModel{cfg: config.Agent{ID: cfg.DefaultAgentID()}, agentTool: namedTool(AgentToolName)})
+	SetSessionDisabledTools("policy-session", []string{"bash", AgentToolName})
+	t.Cleanup(func() { SetSessionDisabledTools("policy-session", nil) })
+
+	require.Equal(t, []string{"view"}, sortedToolNames(t, a, "policy-session"))
+	require.Equal(t, []string{AgentToolName, "bash", "view"}, sortedToolNames(t, a, "other-session"))
+
+	SetSessionDisabledTools("policy-session", nil)
+	require.Empty(t, SessionDisabledTools("policy-session"))
+	require.Equal(t, []string{AgentToolName, "bash", "view"}, sortedToolNames(t, a, "policy-session"))
+}
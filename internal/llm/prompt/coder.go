@@ -1,18 +1,20 @@
 package prompt
 
 import (
-	_ "embed"
+	"embed"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/tulpa-code/tulpa/internal/config"
-	"github.com/tulpa-code/tulpa/internal/llm/tools"
 )
 
-func CoderPrompt(_ string, contextFiles ...string) string {
+// CoderPrompt returns the embedded coder prompt tuned for provider, followed
+// by the contents of contextFiles.
+func CoderPrompt(provider string, contextFiles ...string) string {
 	cfg := config.Get()
 	var cwd string
 	if cfg == nil {
@@ -20,7 +22,7 @@ func CoderPrompt(_ string, contextFiles ...string) string {
 	} else {
 		cwd = cfg.WorkingDir()
 	}
-	basePrompt := string(defaultCoderPrompt)
+	basePrompt := coderPromptFor(provider)
 	contextContent := getContextFromPaths(cwd, contextFiles)
 	if contextContent != "" {
 		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent)
@@ -28,8 +30,22 @@ func CoderPrompt(_ string, contextFiles ...string) string {
 	return basePrompt
 }
 
-//go:embed anthropic.md
-var defaultCoderPrompt []byte
+//go:embed anthropic.md openai.md gemini.md
+var coderPrompts embed.FS
+
+// defaultCoderPromptProvider is the provider whose coder prompt is used when
+// there is none for the requested provider.
+const defaultCoderPromptProvider = "anthropic"
+
+// coderPromptFor returns the embedded coder prompt for provider, or the
+// default one when the provider has no variant of its own.
+func coderPromptFor(provider string) string {
+	if data, err := coderPrompts.ReadFile(provider + ".md"); err == nil {
+		return string(data)
+	}
+	data, _ := coderPrompts.ReadFile(defaultCoderPromptProvider + ".md")
+	return string(data)
+}
 
 func getEnvironmentInfo() string {
 	cfg := config.Get()
@@ -40,23 +56,108 @@ func getEnvironmentInfo() string {
 	isGit := isGitRepo(cwd)
 	platform := runtime.GOOS
 	date := time.Now().Format("1/2/2006")
-	output, _, _ := tools.ListDirectoryTree(cwd, tools.LSParams{})
+	var workspace, output string
+	if roots := cfg.WorkspaceRoots(); len(roots) > 1 {
+		workspace = "Workspace roots:\n" + workspaceRootsInfo(roots) + "\n"
+		output = workspaceTrees(roots)
+	} else {
+		output = directoryTrees.get(cwd)
+	}
 	return fmt.Sprintf(`Here is useful information about the environment you are running in:
 <env>
 Working directory: %s
 Is directory a git repo: %s
-Platform: %s
+%sPlatform: %s
 Today's date: %s
 </env>
 <project>
 %s
 </project>
-		`, cwd, boolToYesNo(isGit), platform, date, output)
+		`, cwd, boolToYesNo(isGit), workspace, platform, date, output)
+}
+
+// workspaceRootsInfo lists the workspace roots with their git status.
+func workspaceRootsInfo(roots []string) string {
+	lines := make([]string, 0, len(roots))
+	for _, root := range roots {
+		var status string
+		switch info, err := os.Stat(root); {
+		case err != nil || !info.IsDir():
+			status = "missing"
+		case isGitRepo(root):
+			status = "git repo"
+			if branch := gitBranch(root); branch != "" {
+				status += ", branch " + branch
+			}
+		default:
+			status = "not a git repo"
+		}
+		lines = append(lines, fmt.Sprintf("- %s (%s)", root, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// workspaceTrees renders the directory tree of each workspace root under a
+// header naming the root.
+func workspaceTrees(roots []string) string {
+	trees := make([]string, 0, len(roots))
+	for _, root := range roots {
+		trees = append(trees, fmt.Sprintf("## %s\n%s", root, strings.TrimRight(directoryTrees.get(root), "\n")))
+	}
+	return strings.Join(trees, "\n\n")
 }
 
 func isGitRepo(dir string) bool {
-	_, err := os.Stat(filepath.Join(dir, ".git"))
-	return err == nil
+	return gitDir(dir) != ""
+}
+
+// gitDir returns the git directory of the repository dir is in, looking in
+// its parents too, or an empty string when dir is not in a repository.
+// Worktrees and submodules have a .git file pointing to their git directory.
+func gitDir(dir string) string {
+	for {
+		path := filepath.Join(dir, ".git")
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() {
+				return path
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return ""
+			}
+			target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+			if !ok {
+				return ""
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			return target
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// gitBranch returns the branch checked out in dir, the commit hash when the
+// HEAD is detached, or an empty string when dir is not in a git repository.
+func gitBranch(dir string) string {
+	gitDir := gitDir(dir)
+	if gitDir == "" {
+		return ""
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref := strings.TrimSpace(string(head))
+	if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok {
+		return branch
+	}
+	return ref
 }
 
 func lspInformation() string {
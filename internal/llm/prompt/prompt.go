@@ -1,11 +1,17 @@
 package prompt
 
 import (
+	"cmp"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/csync"
@@ -26,10 +32,11 @@ const (
 func GetPrompt(promptID PromptID, provider string, contextPaths ...string) string {
 	// Try to get custom prompt from config first
 	cfg := config.Get()
-	if cfg != nil && cfg.AgentPrompts != nil {
+	if cfg != nil {
 		// Map PromptID to agent ID
 		agentID := string(promptID)
-		if customPrompt, ok := cfg.AgentPrompts[agentID]; ok && customPrompt != "" {
+		if customPrompt, ok := cfg.AgentPrompts()[agentID]; ok && customPrompt != "" {
+			customPrompt = renderPrompt(customPrompt, cfg.WorkingDir(), cfg.Agents()[agentID].Name)
 			// For coder prompt, add environment info and context
 			if promptID == PromptCoder {
 				return formatCoderPrompt(customPrompt, contextPaths...)
@@ -55,6 +62,30 @@ func GetPrompt(promptID PromptID, provider string, contextPaths ...string) strin
 	return basePrompt
 }
 
+// renderPrompt fills in the template placeholders of an agent prompt. A
+// prompt that isn't a template, like one with literal braces, is used as
+// written. The placeholders were validated when the prompt was loaded, so a
+// failure to render it is only logged and it's used as written too.
+func renderPrompt(text, workingDir, agentName string) string {
+	tmpl, err := config.ParsePromptTemplate(agentName, text)
+	if err != nil {
+		return text
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, config.PromptData{
+		WorkingDir: workingDir,
+		Platform:   runtime.GOOS,
+		Date:       time.Now().Format("1/2/2006"),
+		GitBranch:  gitBranch(workingDir),
+		AgentName:  agentName,
+	})
+	if err != nil {
+		slog.Error("Failed to render agent prompt template", "agent", agentName, "error", err)
+		return text
+	}
+	return b.String()
+}
+
 // formatCoderPrompt adds environment info and context to a coder prompt.
 func formatCoderPrompt(basePrompt string, contextPaths ...string) string {
 	envInfo := getEnvironmentInfo()
@@ -68,7 +99,12 @@ func formatCoderPrompt(basePrompt string, contextPaths ...string) string {
 }
 
 func getContextFromPaths(workingDir string, contextPaths []string) string {
-	return processContextPaths(workingDir, contextPaths)
+	var opts *config.Options
+	if cfg := config.Get(); cfg != nil {
+		opts = cfg.Options
+	}
+	perFile, total := opts.ContextLimits()
+	return processContextPaths(workingDir, contextPaths, perFile, total)
 }
 
 // expandPath expands ~ and environment variables in file paths
@@ -86,67 +122,110 @@ func expandPath(path string) string {
 	return path
 }
 
-func processContextPaths(workDir string, paths []string) string {
+// contextFile is the content of a context file, ordered by how far from the
+// working directory it is, the position of the context path it was found
+// through and its position in that path.
+type contextFile struct {
+	dirIndex  int
+	pathIndex int
+	fileIndex int
+	path      string
+	content   string
+}
+
+// contextDirs returns the directories relative context paths are looked up
+// in: workDir, then its parents up to the root of the git repository it's
+// in, or up to the home directory, excluded. Parents aren't looked in when
+// workDir is neither in a repository nor in the home directory.
+func contextDirs(workDir string) []string {
+	homeDir := home.Dir()
+	dirs := []string{workDir}
+	for dir := workDir; dir != homeDir; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dirs
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs[:1]
+		}
+		if parent != homeDir {
+			dirs = append(dirs, parent)
+		}
+		dir = parent
+	}
+	return dirs
+}
+
+// processContextPaths reads the files in paths and joins them, truncating
+// each to maxFileBytes and all of them to maxTotalBytes. Relative paths are
+// looked up in workDir and in its parents, see [contextDirs], the files of
+// nearer directories first. Files left out entirely because the total
+// budget ran out are listed at the end. A zero budget means no limit.
+func processContextPaths(workDir string, paths []string, maxFileBytes, maxTotalBytes int) string {
 	var (
 		wg       sync.WaitGroup
-		resultCh = make(chan string)
+		resultCh = make(chan contextFile)
 	)
 
 	// Track processed files to avoid duplicates
 	processedFiles := csync.NewMap[string, bool]()
 
-	for _, path := range paths {
-		wg.Add(1)
-		go func(p string) {
-			defer wg.Done()
-
-			// Expand ~ and environment variables before processing
-			p = expandPath(p)
-
-			// Use absolute path if provided, otherwise join with workDir
-			fullPath := p
-			if !filepath.IsAbs(p) {
-				fullPath = filepath.Join(workDir, p)
+	dirs := contextDirs(workDir)
+	for i, path := range paths {
+		// Expand ~ and environment variables before processing
+		path = expandPath(path)
+		for dirIndex, dir := range dirs {
+			// Use absolute path if provided, otherwise join with the directory
+			fullPath := path
+			if !filepath.IsAbs(path) {
+				fullPath = filepath.Join(dir, path)
+			} else if dirIndex > 0 {
+				break
 			}
+			wg.Add(1)
+			go func(dirIndex, pathIndex int, fullPath string) {
+				defer wg.Done()
 
-			// Check if the path is a directory using os.Stat
-			info, err := os.Stat(fullPath)
-			if err != nil {
-				return // Skip if path doesn't exist or can't be accessed
-			}
+				// Check if the path is a directory using os.Stat
+				info, err := os.Stat(fullPath)
+				if err != nil {
+					return // Skip if path doesn't exist or can't be accessed
+				}
 
-			if info.IsDir() {
-				filepath.WalkDir(fullPath, func(path string, d os.DirEntry, err error) error {
-					if err != nil {
-						return err
-					}
-					if !d.IsDir() {
-						// Check if we've already processed this file (case-insensitive)
-						lowerPath := strings.ToLower(path)
-
-						if alreadyProcessed, _ := processedFiles.Get(lowerPath); !alreadyProcessed {
-							processedFiles.Set(lowerPath, true)
-							if result := processFile(path); result != "" {
-								resultCh <- result
+				if info.IsDir() {
+					fileIndex := 0
+					filepath.WalkDir(fullPath, func(path string, d os.DirEntry, err error) error {
+						if err != nil {
+							return err
+						}
+						if !d.IsDir() {
+							// Check if we've already processed this file (case-insensitive)
+							lowerPath := strings.ToLower(path)
+
+							if alreadyProcessed, _ := processedFiles.Get(lowerPath); !alreadyProcessed {
+								processedFiles.Set(lowerPath, true)
+								if content, ok := readContextFile(path); ok {
+									resultCh <- contextFile{dirIndex, pathIndex, fileIndex, path, content}
+									fileIndex++
+								}
 							}
 						}
-					}
-					return nil
-				})
-			} else {
-				// It's a file, process it directly
-				// Check if we've already processed this file (case-insensitive)
-				lowerPath := strings.ToLower(fullPath)
-
-				if alreadyProcessed, _ := processedFiles.Get(lowerPath); !alreadyProcessed {
-					processedFiles.Set(lowerPath, true)
-					result := processFile(fullPath)
-					if result != "" {
-						resultCh <- result
+						return nil
+					})
+				} else {
+					// It's a file, process it directly
+					// Check if we've already processed this file (case-insensitive)
+					lowerPath := strings.ToLower(fullPath)
+
+					if alreadyProcessed, _ := processedFiles.Get(lowerPath); !alreadyProcessed {
+						processedFiles.Set(lowerPath, true)
+						if content, ok := readContextFile(fullPath); ok {
+							resultCh <- contextFile{dirIndex, pathIndex, 0, fullPath, content}
+						}
 					}
 				}
-			}
-		}(path)
+			}(dirIndex, i, fullPath)
+		}
 	}
 
 	go func() {
@@ -154,18 +233,65 @@ func processContextPaths(workDir string, paths []string) string {
 		close(resultCh)
 	}()
 
-	results := make([]string, 0)
-	for result := range resultCh {
-		results = append(results, result)
+	var files []contextFile
+	for file := range resultCh {
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b contextFile) int {
+		return cmp.Or(cmp.Compare(a.dirIndex, b.dirIndex), cmp.Compare(a.pathIndex, b.pathIndex), cmp.Compare(a.fileIndex, b.fileIndex))
+	})
+
+	results := make([]string, 0, len(files))
+	var skipped []string
+	remaining := maxTotalBytes
+	for _, file := range files {
+		limit := maxFileBytes
+		if maxTotalBytes > 0 {
+			if remaining == 0 {
+				skipped = append(skipped, file.path)
+				continue
+			}
+			if limit == 0 || remaining < limit {
+				limit = remaining
+			}
+		}
+		content, truncated := truncateContext(file.content, limit)
+		if maxTotalBytes > 0 {
+			remaining -= len(content)
+		}
+		if truncated > 0 {
+			slog.Warn("Context file truncated", "path", file.path, "truncated_bytes", truncated)
+			content += fmt.Sprintf("\n[...truncated %d bytes...]", truncated)
+		}
+		results = append(results, "# From:"+file.path+"\n"+content)
+	}
+	if len(skipped) > 0 {
+		slog.Warn("Context files skipped, total context budget exhausted", "paths", skipped)
+		results = append(results, fmt.Sprintf("[...skipped %d context files, total context budget of %d bytes exhausted: %s...]", len(skipped), maxTotalBytes, strings.Join(skipped, ", ")))
 	}
 
 	return strings.Join(results, "\n")
 }
 
-func processFile(filePath string) string {
+// readContextFile returns the content of a context file, or false when it
+// can't be read.
+func readContextFile(filePath string) (string, bool) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return ""
+		return "", false
+	}
+	return string(content), true
+}
+
+// truncateContext cuts s to at most limit bytes without splitting a UTF-8
+// sequence, and reports how many bytes were cut. A zero limit keeps s whole.
+func truncateContext(s string, limit int) (string, int) {
+	if limit <= 0 || len(s) <= limit {
+		return s, 0
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
 	}
-	return "# From:" + filePath + "\n" + string(content)
+	return s[:cut], len(s) - cut
 }
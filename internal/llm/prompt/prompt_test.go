@@ -2,9 +2,12 @@ package prompt
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"github.com/tulpa-code/tulpa/internal/home"
 )
 
@@ -67,3 +70,220 @@ func TestExpandPath(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderPrompt(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/feature/x\n"), 0o644))
+
+	tests := []struct {
+		name     string
+		prompt   string
+		expected string
+	}{
+		{
+			name:     "no placeholders",
+			prompt:   "Plain prompt",
+			expected: "Plain prompt",
+		},
+		{
+			name:     "all placeholders",
+			prompt:   "{{.AgentName}} {{.WorkingDir}} {{.Platform}} {{.GitBranch}}",
+			expected: "Coder " + dir + " " + runtime.GOOS + " feature/x",
+		},
+		{
+			name:     "invalid template is left as is",
+			prompt:   "Broken {{.AgentName",
+			expected: "Broken {{.AgentName",
+		},
+		{
+			name:     "literal braces are left as is",
+			prompt:   "Write Mustache like {{ name }} in {{.WorkingDir}}",
+			expected: "Write Mustache like {{ name }} in {{.WorkingDir}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, renderPrompt(tt.prompt, dir, "Coder"))
+		})
+	}
+}
+
+func TestGitBranch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		head     string
+		subdir   string
+		worktree bool
+		expected string
+	}{
+		{name: "branch", head: "ref: refs/heads/main\n", expected: "main"},
+		{name: "detached", head: "0123456789abcdef\n", expected: "0123456789abcdef"},
+		{name: "subdirectory", head: "ref: refs/heads/main\n", subdir: "internal/cmd", expected: "main"},
+		{name: "worktree", head: "ref: refs/heads/feature\n", worktree: true, expected: "feature"},
+		{name: "not a repository", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if tt.head != "" {
+				gitDir := filepath.Join(dir, ".git")
+				if tt.worktree {
+					gitDir = filepath.Join(dir, "main", ".git", "worktrees", "feature")
+					require.NoError(t, os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: main/.git/worktrees/feature\n"), 0o644))
+				}
+				require.NoError(t, os.MkdirAll(gitDir, 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte(tt.head), 0o644))
+			}
+			dir = filepath.Join(dir, tt.subdir)
+			require.NoError(t, os.MkdirAll(dir, 0o755))
+			require.Equal(t, tt.expected, gitBranch(dir))
+			require.Equal(t, tt.head != "", isGitRepo(dir))
+		})
+	}
+}
+
+func TestCoderPromptFor(t *testing.T) {
+	t.Parallel()
+
+	anthropic := coderPromptFor("anthropic")
+	require.Contains(t, anthropic, "You are Tulpa")
+
+	tests := []struct {
+		provider string
+		contains string
+	}{
+		{provider: "openai", contains: "Act, don't describe"},
+		{provider: "gemini", contains: "Absolute paths"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			t.Parallel()
+			prompt := coderPromptFor(tt.provider)
+			require.Contains(t, prompt, tt.contains)
+			require.NotEqual(t, anthropic, prompt)
+		})
+	}
+
+	for _, provider := range []string{"", "openrouter", "../anthropic", "init"} {
+		t.Run("falls back for "+provider, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, anthropic, coderPromptFor(provider))
+		})
+	}
+}
+
+func TestTruncateContext(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		limit     int
+		expected  string
+		truncated int
+	}{
+		{name: "no limit", input: "hello", limit: 0, expected: "hello"},
+		{name: "fits", input: "hello", limit: 5, expected: "hello"},
+		{name: "ascii", input: "hello world", limit: 5, expected: "hello", truncated: 6},
+		{name: "multibyte boundary", input: "añb", limit: 2, expected: "a", truncated: 3},
+		{name: "after multibyte", input: "añb", limit: 3, expected: "añ", truncated: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			content, truncated := truncateContext(tt.input, tt.limit)
+			require.Equal(t, tt.expected, content)
+			require.Equal(t, tt.truncated, truncated)
+		})
+	}
+}
+
+func TestProcessContextPathsBudget(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.md"), []byte(strings.Repeat("a", 10)), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.md"), []byte(strings.Repeat("b", 10)), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.md"), []byte(strings.Repeat("c", 10)), 0o644))
+	paths := []string{"a.md", "b.md", "c.md"}
+
+	t.Run("no limits", func(t *testing.T) {
+		t.Parallel()
+		result := processContextPaths(dir, paths, 0, 0)
+		require.Equal(t, "# From:"+filepath.Join(dir, "a.md")+"\n"+strings.Repeat("a", 10)+"\n"+
+			"# From:"+filepath.Join(dir, "b.md")+"\n"+strings.Repeat("b", 10)+"\n"+
+			"# From:"+filepath.Join(dir, "c.md")+"\n"+strings.Repeat("c", 10), result)
+	})
+
+	t.Run("per file limit", func(t *testing.T) {
+		t.Parallel()
+		result := processContextPaths(dir, paths, 4, 0)
+		require.Contains(t, result, "aaaa\n[...truncated 6 bytes...]")
+		require.Contains(t, result, "cccc\n[...truncated 6 bytes...]")
+		require.NotContains(t, result, "aaaaa")
+	})
+
+	t.Run("total limit", func(t *testing.T) {
+		t.Parallel()
+		result := processContextPaths(dir, paths, 0, 15)
+		require.Contains(t, result, strings.Repeat("a", 10)+"\n")
+		require.Contains(t, result, "bbbbb\n[...truncated 5 bytes...]")
+		require.NotContains(t, result, "ccc")
+		require.Contains(t, result, "[...skipped 1 context files, total context budget of 15 bytes exhausted: "+filepath.Join(dir, "c.md")+"...]")
+	})
+}
+
+func TestWorkspaceRootsInfo(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "api")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+	plain := filepath.Join(dir, "docs")
+	require.NoError(t, os.MkdirAll(plain, 0o755))
+	missing := filepath.Join(dir, "web")
+
+	require.Equal(t, "- "+repo+" (git repo, branch main)\n- "+plain+" (not a git repo)\n- "+missing+" (missing)", workspaceRootsInfo([]string{repo, plain, missing}))
+}
+
+func TestProcessContextPathsParents(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join(t.TempDir(), "repo")
+	cwd := filepath.Join(root, "services", "api")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0o755))
+	require.NoError(t, os.MkdirAll(cwd, 0o755))
+	write := func(path, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	// Files outside of the repository are left out.
+	write(filepath.Join(filepath.Dir(root), "TULPA.md"), "outside")
+	write(filepath.Join(root, "TULPA.md"), "root")
+	write(filepath.Join(root, "AGENTS.md"), "root agents")
+	write(filepath.Join(root, "services", "TULPA.md"), "services")
+	write(filepath.Join(cwd, "AGENTS.md"), "api agents")
+
+	result := processContextPaths(cwd, []string{"TULPA.md", "AGENTS.md"}, 0, 0)
+	require.Equal(t, "# From:"+filepath.Join(cwd, "AGENTS.md")+"\napi agents\n"+
+		"# From:"+filepath.Join(root, "services", "TULPA.md")+"\nservices\n"+
+		"# From:"+filepath.Join(root, "TULPA.md")+"\nroot\n"+
+		"# From:"+filepath.Join(root, "AGENTS.md")+"\nroot agents", result)
+
+	require.Equal(t, []string{cwd, filepath.Join(root, "services"), root}, contextDirs(cwd))
+	// Outside of a repository and of the home directory only the working
+	// directory is looked in.
+	if outside := t.TempDir(); !strings.HasPrefix(outside, home.Dir()) {
+		require.Equal(t, []string{outside}, contextDirs(outside))
+	}
+}
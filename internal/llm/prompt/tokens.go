@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"unicode"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+// wordCharsPerToken is roughly how many characters of a word fit in one
+// token for the tokenizers of each provider family. Anthropic's tokenizer
+// splits words a little more than the OpenAI and Gemini ones.
+var wordCharsPerToken = map[catwalk.Type]int{
+	catwalk.TypeOpenAI:    6,
+	catwalk.TypeAzure:     6,
+	catwalk.TypeGemini:    6,
+	catwalk.TypeVertexAI:  6,
+	catwalk.TypeAnthropic: 5,
+	catwalk.TypeBedrock:   5,
+}
+
+const defaultWordCharsPerToken = 5
+
+// EstimateTokens estimates how many tokens text takes for the models of the
+// given provider type. Tulpa ships no tokenizer, so text is split like BPE
+// tokenizers do: words, with the space before them, take one token per few
+// characters, numbers one per three digits, CJK characters and symbols one
+// each, and runs of whitespace one.
+func EstimateTokens(text string, providerType catwalk.Type) int64 {
+	wordChars, ok := wordCharsPerToken[providerType]
+	if !ok {
+		wordChars = defaultWordCharsPerToken
+	}
+
+	var tokens int64
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case isIdeograph(r):
+			tokens++
+		case unicode.IsLetter(r):
+			for j < len(runes) && unicode.IsLetter(runes[j]) && !isIdeograph(runes[j]) {
+				j++
+			}
+			tokens += ceilDiv(j-i, wordChars)
+		case unicode.IsDigit(r):
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens += ceilDiv(j-i, 3)
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+			// A single space is part of the next word.
+			if j-i > 1 || r != ' ' {
+				tokens++
+			}
+		default:
+			tokens++
+		}
+		i = j
+	}
+	return tokens
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func ceilDiv(n, d int) int64 {
+	return int64((n + d - 1) / d)
+}
@@ -0,0 +1,46 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		text         string
+		providerType catwalk.Type
+		want         int64
+	}{
+		{name: "empty", text: "", providerType: catwalk.TypeOpenAI, want: 0},
+		{name: "words", text: "hello world", providerType: catwalk.TypeOpenAI, want: 2},
+		{name: "long word", text: "internationalization", providerType: catwalk.TypeOpenAI, want: 4},
+		{name: "long word anthropic", text: "internationalization", providerType: catwalk.TypeAnthropic, want: 4},
+		{name: "words anthropic", text: "configuration files", providerType: catwalk.TypeAnthropic, want: 4},
+		{name: "numbers", text: "1234567", providerType: catwalk.TypeOpenAI, want: 3},
+		{name: "symbols", text: "a.b()", providerType: catwalk.TypeOpenAI, want: 5},
+		{name: "whitespace", text: "a\n\n    b", providerType: catwalk.TypeOpenAI, want: 3},
+		{name: "ideographs", text: "你好世界", providerType: catwalk.TypeGemini, want: 4},
+		{name: "unknown provider type", text: "configuration", providerType: "custom", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, EstimateTokens(tt.text, tt.providerType))
+		})
+	}
+}
+
+func TestEstimateTokensProse(t *testing.T) {
+	t.Parallel()
+
+	// English prose averages about four characters per token.
+	text := strings.Repeat("You are Tulpa, a CLI tool for software engineering tasks. Be concise, direct, and correct.\n", 50)
+	tokens := EstimateTokens(text, catwalk.TypeOpenAI)
+	require.InDelta(t, float64(len(text))/4, float64(tokens), float64(len(text))/4*0.25)
+}
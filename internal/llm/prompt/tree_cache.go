@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+)
+
+// directoryTreeTTL is how long a listed directory tree is reused for the
+// environment block before the directory is walked again.
+const directoryTreeTTL = 30 * time.Second
+
+var directoryTrees = newTreeCache(directoryTreeTTL, func(dir string) string {
+	output, _, _ := tools.ListDirectoryTree(dir, tools.LSParams{})
+	return output
+})
+
+// InvalidateDirectoryTree drops the cached directory trees, so the next
+// prompt build lists the working directory again. Call it when files were
+// created or edited.
+func InvalidateDirectoryTree() {
+	directoryTrees.invalidate()
+}
+
+type treeCacheEntry struct {
+	tree    string
+	expires time.Time
+}
+
+// treeCache keeps the directory tree listed for each working directory for
+// a limited time.
+type treeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	list    func(dir string) string
+	now     func() time.Time
+	entries map[string]treeCacheEntry
+}
+
+func newTreeCache(ttl time.Duration, list func(dir string) string) *treeCache {
+	return &treeCache{
+		ttl:     ttl,
+		list:    list,
+		now:     time.Now,
+		entries: make(map[string]treeCacheEntry),
+	}
+}
+
+func (c *treeCache) get(dir string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if entry, ok := c.entries[dir]; ok && now.Before(entry.expires) {
+		return entry.tree
+	}
+	tree := c.list(dir)
+	c.entries[dir] = treeCacheEntry{tree: tree, expires: now.Add(c.ttl)}
+	return tree
+}
+
+func (c *treeCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
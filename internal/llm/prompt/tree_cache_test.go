@@ -0,0 +1,63 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+func TestTreeCache(t *testing.T) {
+	t.Parallel()
+
+	walks := map[string]int{}
+	now := time.Now()
+	cache := newTreeCache(30*time.Second, func(dir string) string {
+		walks[dir]++
+		return dir + " tree"
+	})
+	cache.now = func() time.Time { return now }
+
+	require.Equal(t, "/a tree", cache.get("/a"))
+	require.Equal(t, "/a tree", cache.get("/a"))
+	require.Equal(t, "/b tree", cache.get("/b"))
+	require.Equal(t, map[string]int{"/a": 1, "/b": 1}, walks)
+
+	now = now.Add(30 * time.Second)
+	cache.get("/a")
+	require.Equal(t, 2, walks["/a"])
+
+	cache.invalidate()
+	cache.get("/a")
+	cache.get("/b")
+	require.Equal(t, map[string]int{"/a": 3, "/b": 2}, walks)
+}
+
+func BenchmarkGetEnvironmentInfo(b *testing.B) {
+	workingDir := b.TempDir()
+	for i := range 50 {
+		dir := filepath.Join(workingDir, "pkg", fmt.Sprintf("dir%d", i%10))
+		require.NoError(b, os.MkdirAll(dir, 0o755))
+		require.NoError(b, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.go", i)), nil, 0o644))
+	}
+	_, err := config.Init(workingDir, b.TempDir(), false)
+	require.NoError(b, err)
+
+	b.Run("cached", func(b *testing.B) {
+		InvalidateDirectoryTree()
+		for b.Loop() {
+			getEnvironmentInfo()
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for b.Loop() {
+			InvalidateDirectoryTree()
+			getEnvironmentInfo()
+		}
+	})
+}
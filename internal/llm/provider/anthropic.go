@@ -310,7 +310,7 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 				return nil, retryErr
 			}
 			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
+				slog.Warn("Retrying after transient error", "attempt", attempts, "error", err)
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -459,7 +459,8 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 				return
 			}
 			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
+				slog.Warn("Retrying after transient error", "attempt", attempts, "error", err)
+				eventChan <- retryEvent(attempts, after, err)
 				select {
 				case <-ctx.Done():
 					// context cancelled
@@ -489,8 +490,9 @@ func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, err
 		return false, 0, err
 	}
 
+	maxRetries, baseDelay := a.providerOptions.retryPolicy()
 	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, 0, errMaxRetries(maxRetries, err)
 	}
 
 	if apiErr.StatusCode == http.StatusUnauthorized {
@@ -518,17 +520,12 @@ func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, err
 	}
 
 	isOverloaded := strings.Contains(apiErr.Error(), "overloaded") || strings.Contains(apiErr.Error(), "rate limit exceeded")
-	// 529 (unofficial): The service is overloaded
-	if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode != 529 && !isOverloaded {
+	if !isTransientStatus(apiErr.StatusCode) && !isOverloaded {
 		return false, 0, err
 	}
 
-	retryMs := 0
 	retryAfterValues := apiErr.Response.Header.Values("Retry-After")
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
+	retryMs := int(retryDelay(attempts, baseDelay).Milliseconds())
 	if len(retryAfterValues) > 0 {
 		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
 			retryMs = retryMs * 1000
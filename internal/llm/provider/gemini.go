@@ -228,7 +228,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 				return nil, retryErr
 			}
 			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
+				slog.Warn("Retrying after transient error", "attempt", attempts, "error", err)
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -317,6 +317,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	go func() {
 		defer close(eventChan)
 
+	attempt:
 		for {
 			attempts++
 
@@ -340,7 +341,8 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 						return
 					}
 					if retry {
-						slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
+						slog.Warn("Retrying after transient error", "attempt", attempts, "error", err)
+						eventChan <- retryEvent(attempts, after, err)
 						select {
 						case <-ctx.Done():
 							if ctx.Err() != nil {
@@ -349,7 +351,7 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 
 							return
 						case <-time.After(time.Duration(after) * time.Millisecond):
-							continue
+							continue attempt
 						}
 					} else {
 						eventChan <- ProviderEvent{Type: EventError, Error: err}
@@ -424,19 +426,22 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 }
 
 func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	// Check if error is a rate limit error
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	// Gemini doesn't have a standard error type we can check against
-	// So we'll check the error message for rate limit indicators
-	if errors.Is(err, io.EOF) {
+	if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false, 0, err
 	}
+	maxRetries, baseDelay := g.providerOptions.retryPolicy()
+	if attempts > maxRetries {
+		return false, 0, errMaxRetries(maxRetries, err)
+	}
 
+	// Check the error message for rate limit indicators, as not every error
+	// carries a status code
 	errMsg := err.Error()
 	isRateLimit := contains(errMsg, "rate limit", "quota exceeded", "too many requests")
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) && isTransientStatus(apiErr.Code) {
+		isRateLimit = true
+	}
 
 	// Check for token expiration (401 Unauthorized)
 	if contains(errMsg, "unauthorized", "invalid api key", "api key expired") {
@@ -463,12 +468,7 @@ func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 		return false, 0, err
 	}
 
-	// Calculate backoff with jitter
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs := backoffMs + jitterMs
-
-	return true, int64(retryMs), nil
+	return true, retryDelay(attempts, baseDelay).Milliseconds(), nil
 }
 
 func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
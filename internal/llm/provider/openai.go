@@ -279,7 +279,7 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 				return nil, retryErr
 			}
 			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
+				slog.Warn("Retrying after transient error", "attempt", attempts, "error", err)
 				select {
 				case <-ctx.Done():
 					return nil, ctx.Err()
@@ -480,7 +480,8 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 				return
 			}
 			if retry {
-				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries, "error", err)
+				slog.Warn("Retrying after transient error", "attempt", attempts, "error", err)
+				eventChan <- retryEvent(attempts, after, err)
 				select {
 				case <-ctx.Done():
 					// context cancelled
@@ -503,12 +504,13 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 }
 
 func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false, 0, err
 	}
+	maxRetries, baseDelay := o.providerOptions.retryPolicy()
+	if attempts > maxRetries {
+		return false, 0, errMaxRetries(maxRetries, err)
+	}
 	var apiErr *openai.Error
 	retryMs := 0
 	retryAfterValues := []string{}
@@ -535,7 +537,7 @@ func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 				return false, 0, fmt.Errorf("OpenAI quota exceeded: %s. Please check your plan and billing details", apiErr.Message)
 			}
 			// Other 429 errors (rate limiting) can be retried
-		} else if apiErr.StatusCode != http.StatusInternalServerError {
+		} else if !isTransientStatus(apiErr.StatusCode) {
 			return false, 0, err
 		}
 
@@ -553,9 +555,7 @@ func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 		slog.Error("OpenAI API error", "error", err.Error(), "attempt", attempts, "max_retries", maxRetries)
 	}
 
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
+	retryMs = int(retryDelay(attempts, baseDelay).Milliseconds())
 	if len(retryAfterValues) > 0 {
 		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
 			retryMs = retryMs * 1000
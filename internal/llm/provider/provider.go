@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 
@@ -13,8 +14,6 @@ import (
 
 type EventType string
 
-const maxRetries = 3
-
 const (
 	EventContentStart   EventType = "content_start"
 	EventToolUseStart   EventType = "tool_use_start"
@@ -27,6 +26,8 @@ const (
 	EventComplete       EventType = "complete"
 	EventError          EventType = "error"
 	EventWarning        EventType = "warning"
+	EventRetry          EventType = "retry"
+	EventFallback       EventType = "fallback"
 )
 
 type TokenUsage struct {
@@ -52,6 +53,8 @@ type ProviderEvent struct {
 	Response  *ProviderResponse
 	ToolCall  *message.ToolCall
 	Error     error
+	Retry     *RetryInfo
+	Fallback  *FallbackInfo
 }
 type Provider interface {
 	SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
@@ -76,6 +79,12 @@ type providerClientOptions struct {
 	extraParams        map[string]string
 }
 
+// retryPolicy returns how many times requests failing with transient errors
+// are retried and the delay before the first retry.
+func (providerClientOptions) retryPolicy() (maxRetries int, baseDelay time.Duration) {
+	return config.Get().Options.RetryPolicy()
+}
+
 type ProviderClientOption func(*providerClientOptions)
 
 type ProviderClient interface {
@@ -140,6 +149,14 @@ func WithMaxTokens(maxTokens int64) ProviderClientOption {
 }
 
 func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provider, error) {
+	p, err := newProvider(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return withRecording(p), nil
+}
+
+func newProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provider, error) {
 	restore := config.PushPopTulpaEnv()
 	defer restore()
 	resolvedAPIKey, err := config.Get().Resolve(cfg.APIKey)
@@ -0,0 +1,318 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// ErrNoRecording is returned in replay mode when no recorded response
+// matches a request.
+var ErrNoRecording = errors.New("no recorded response matches the request")
+
+// A recording holds the requests sent to providers and their responses,
+// stored as JSON lines so sessions can be replayed without calling them.
+type recording struct {
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+	replay map[string][]recordedExchange
+}
+
+// recordedRequest is the part of a request that identifies it: the system
+// prompt is left out since it changes with the date and working directory.
+// Tool results are part of the messages, so tool invocations are recorded
+// with the request that follows them.
+type recordedRequest struct {
+	Kind     string            `json:"kind"`
+	Model    string            `json:"model"`
+	Messages []recordedMessage `json:"messages"`
+	Tools    []string          `json:"tools,omitempty"`
+}
+
+type recordedMessage struct {
+	Role  message.MessageRole `json:"role"`
+	Parts []string            `json:"parts"`
+}
+
+type recordedEvent struct {
+	Type      EventType         `json:"type"`
+	Content   string            `json:"content,omitempty"`
+	Thinking  string            `json:"thinking,omitempty"`
+	Signature string            `json:"signature,omitempty"`
+	Response  *ProviderResponse `json:"response,omitempty"`
+	ToolCall  *message.ToolCall `json:"tool_call,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type recordedExchange struct {
+	Hash    string          `json:"hash"`
+	Request recordedRequest `json:"request"`
+	// Events of streamed requests
+	Events []recordedEvent `json:"events,omitempty"`
+	// Response or error of sent requests
+	Response *ProviderResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+var (
+	activeRecordingMu sync.Mutex
+	activeRecording   *recording
+)
+
+// StartRecording records the requests of all providers created afterwards
+// and their responses to path, replacing its content.
+func StartRecording(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+	activeRecordingMu.Lock()
+	defer activeRecordingMu.Unlock()
+	activeRecording = &recording{file: file}
+	return nil
+}
+
+// StartReplay makes all providers created afterwards answer with the
+// responses recorded in path instead of calling the provider. Requests are
+// matched by a hash of their model, messages and tools; a request recorded
+// several times gets its responses in the recorded order.
+func StartReplay(path string) error {
+	r, err := readRecording(path)
+	if err != nil {
+		return err
+	}
+	activeRecordingMu.Lock()
+	defer activeRecordingMu.Unlock()
+	activeRecording = r
+	return nil
+}
+
+func readRecording(path string) (*recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	replay := make(map[string][]recordedExchange)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var exchange recordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("failed to parse recording %s line %d: %w", path, line, err)
+		}
+		replay[exchange.Hash] = append(replay[exchange.Hash], exchange)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return &recording{replay: replay}, nil
+}
+
+// StopRecording stops recording or replaying for providers created
+// afterwards, and closes the recording: the requests of the providers
+// created before are no longer recorded.
+func StopRecording() error {
+	activeRecordingMu.Lock()
+	defer activeRecordingMu.Unlock()
+	r := activeRecording
+	activeRecording = nil
+	if r == nil || r.file == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.file.Close()
+}
+
+// withRecording wraps p to record or replay its requests when a recording
+// was started.
+func withRecording(p Provider) Provider {
+	activeRecordingMu.Lock()
+	defer activeRecordingMu.Unlock()
+	if activeRecording == nil {
+		return p
+	}
+	return &recordingProvider{provider: p, recording: activeRecording}
+}
+
+type recordingProvider struct {
+	provider  Provider
+	recording *recording
+}
+
+func (p *recordingProvider) Model() catwalk.Model {
+	return p.provider.Model()
+}
+
+func (p *recordingProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	request := newRecordedRequest("send", p.Model().ID, messages, tools)
+	if p.recording.file == nil {
+		exchange, err := p.recording.next(request)
+		if err != nil {
+			return nil, err
+		}
+		if exchange.Error != "" {
+			return nil, errors.New(exchange.Error)
+		}
+		return exchange.Response, nil
+	}
+
+	response, err := p.provider.SendMessages(ctx, messages, tools)
+	if ctx.Err() == nil {
+		exchange := recordedExchange{Request: request, Response: response}
+		if err != nil {
+			exchange.Error = err.Error()
+		}
+		p.recording.write(exchange)
+	}
+	return response, err
+}
+
+func (p *recordingProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	request := newRecordedRequest("stream", p.Model().ID, messages, tools)
+	out := make(chan ProviderEvent)
+	go func() {
+		defer close(out)
+		if p.recording.file == nil {
+			exchange, err := p.recording.next(request)
+			events := make([]ProviderEvent, 0, len(exchange.Events))
+			for _, event := range exchange.Events {
+				events = append(events, event.providerEvent())
+			}
+			if err != nil {
+				events = []ProviderEvent{{Type: EventError, Error: err}}
+			}
+			for _, event := range events {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			return
+		}
+
+		exchange := recordedExchange{Request: request}
+		for event := range p.provider.StreamResponse(ctx, messages, tools) {
+			// Retries are not part of the answer.
+			if event.Type != EventRetry {
+				exchange.Events = append(exchange.Events, newRecordedEvent(event))
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() == nil {
+			p.recording.write(exchange)
+		}
+	}()
+	return out
+}
+
+// next returns the next recorded exchange for request.
+func (r *recording) next(request recordedRequest) (recordedExchange, error) {
+	hash := request.hash()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exchanges := r.replay[hash]
+	if len(exchanges) == 0 {
+		return recordedExchange{}, fmt.Errorf("%w: %s request to %s with %d messages (hash %s)", ErrNoRecording, request.Kind, request.Model, len(request.Messages), hash)
+	}
+	r.replay[hash] = exchanges[1:]
+	return exchanges[0], nil
+}
+
+func (r *recording) write(exchange recordedExchange) {
+	exchange.Hash = exchange.Request.hash()
+	data, err := json.Marshal(exchange)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	_, _ = r.file.Write(append(data, '\n'))
+}
+
+func newRecordedRequest(kind, model string, messages []message.Message, tools []tools.BaseTool) recordedRequest {
+	request := recordedRequest{Kind: kind, Model: model}
+	for _, msg := range messages {
+		var parts []string
+		if text := msg.Content().Text; text != "" {
+			parts = append(parts, "text: "+text)
+		}
+		for _, binary := range msg.BinaryContent() {
+			sum := sha256.Sum256(binary.Data)
+			parts = append(parts, fmt.Sprintf("binary: %s %s %s", binary.Path, binary.MIMEType, hex.EncodeToString(sum[:])))
+		}
+		for _, call := range msg.ToolCalls() {
+			parts = append(parts, fmt.Sprintf("tool call: %s %s %s", call.ID, call.Name, call.Input))
+		}
+		for _, result := range msg.ToolResults() {
+			parts = append(parts, fmt.Sprintf("tool result: %s error=%t %s", result.ToolCallID, result.IsError, result.Content))
+		}
+		// Providers skip messages without content.
+		if len(parts) > 0 {
+			request.Messages = append(request.Messages, recordedMessage{Role: msg.Role, Parts: parts})
+		}
+	}
+	for _, tool := range tools {
+		request.Tools = append(request.Tools, tool.Info().Name)
+	}
+	slices.Sort(request.Tools)
+	return request
+}
+
+func (r recordedRequest) hash() string {
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+func newRecordedEvent(event ProviderEvent) recordedEvent {
+	recorded := recordedEvent{
+		Type:      event.Type,
+		Content:   event.Content,
+		Thinking:  event.Thinking,
+		Signature: event.Signature,
+		Response:  event.Response,
+		ToolCall:  event.ToolCall,
+	}
+	if event.Error != nil {
+		recorded.Error = event.Error.Error()
+	}
+	return recorded
+}
+
+func (e recordedEvent) providerEvent() ProviderEvent {
+	event := ProviderEvent{
+		Type:      e.Type,
+		Content:   e.Content,
+		Thinking:  e.Thinking,
+		Signature: e.Signature,
+		Response:  e.Response,
+		ToolCall:  e.ToolCall,
+	}
+	if e.Error != "" {
+		event.Error = errors.New(e.Error)
+	}
+	return event
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// stubProvider answers every request with the same events.
+type stubProvider struct {
+	events []ProviderEvent
+	calls  int
+}
+
+func (p *stubProvider) SendMessages(context.Context, []message.Message, []tools.BaseTool) (*ProviderResponse, error) {
+	p.calls++
+	return nil, errors.New("overloaded")
+}
+
+func (p *stubProvider) StreamResponse(context.Context, []message.Message, []tools.BaseTool) <-chan ProviderEvent {
+	p.calls++
+	events := make(chan ProviderEvent, len(p.events))
+	for _, event := range p.events {
+		events <- event
+	}
+	close(events)
+	return events
+}
+
+func (p *stubProvider) Model() catwalk.Model {
+	return catwalk.Model{ID: "stub"}
+}
+
+func collect(events <-chan ProviderEvent) []ProviderEvent {
+	var collected []ProviderEvent
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func TestRecordingReplay(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	answer := []ProviderEvent{
+		{Type: EventContentDelta, Content: "Let me look."},
+		{Type: EventToolUseStart, ToolCall: &message.ToolCall{ID: "call_1", Name: "view"}},
+		{Type: EventComplete, Response: &ProviderResponse{
+			Content:      "Let me look.",
+			ToolCalls:    []message.ToolCall{{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`, Finished: true}},
+			Usage:        TokenUsage{InputTokens: 10, OutputTokens: 5},
+			FinishReason: message.FinishReasonToolUse,
+		}},
+	}
+	stub := &stubProvider{events: append([]ProviderEvent{{Type: EventRetry, Retry: &RetryInfo{Attempt: 1}}}, answer...)}
+	recorder := &recordingProvider{provider: stub, recording: &recording{file: file}}
+
+	messages := []message.Message{
+		{ID: "1", Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "what is in main.go?"}}},
+	}
+	require.Len(t, collect(recorder.StreamResponse(t.Context(), messages, nil)), 4)
+	_, err = recorder.SendMessages(t.Context(), messages, nil)
+	require.EqualError(t, err, "overloaded")
+	require.NoError(t, file.Close())
+
+	r, err := readRecording(path)
+	require.NoError(t, err)
+	replayer := &recordingProvider{provider: stub, recording: r}
+
+	// Message IDs don't matter, only what is sent.
+	replayed := []message.Message{
+		{ID: "2", Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "what is in main.go?"}}},
+	}
+	require.Equal(t, answer, collect(replayer.StreamResponse(t.Context(), replayed, nil)))
+	_, err = replayer.SendMessages(t.Context(), replayed, nil)
+	require.EqualError(t, err, "overloaded")
+	require.Equal(t, 2, stub.calls, "replay doesn't call the provider")
+
+	events := collect(replayer.StreamResponse(t.Context(), replayed, nil))
+	require.Len(t, events, 1)
+	require.Equal(t, EventError, events[0].Type)
+	require.ErrorIs(t, events[0].Error, ErrNoRecording, "each recording is replayed once")
+
+	replayed[0].Parts = []message.ContentPart{message.TextContent{Text: "what is in go.mod?"}}
+	_, err = replayer.SendMessages(t.Context(), replayed, nil)
+	require.ErrorIs(t, err, ErrNoRecording)
+}
+
+// TestStopRecording sets the global recording, it may not be parallel.
+func TestStopRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	require.NoError(t, StartRecording(path))
+
+	stub := &stubProvider{events: []ProviderEvent{{Type: EventComplete, Response: &ProviderResponse{Content: "done"}}}}
+	recorded := withRecording(stub)
+	require.IsType(t, &recordingProvider{}, recorded)
+	collect(recorded.StreamResponse(t.Context(), nil, nil))
+
+	require.NoError(t, StopRecording())
+	require.NoError(t, StopRecording(), "stopping twice is harmless")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotEmpty(t, data)
+
+	// The providers created before aren't recorded anymore, the ones created
+	// afterwards aren't wrapped.
+	collect(recorded.StreamResponse(t.Context(), nil, nil))
+	_, err = recorded.SendMessages(t.Context(), nil, nil)
+	require.Error(t, err)
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, after)
+	require.Same(t, stub, withRecording(stub))
+}
@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/openai/openai-go"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"google.golang.org/genai"
+)
+
+// RetryInfo describes a retry of a request that failed with a transient
+// error.
+type RetryInfo struct {
+	Attempt    int
+	MaxRetries int
+	Delay      time.Duration
+	Err        error
+}
+
+func retryEvent(attempt int, afterMs int64, err error) ProviderEvent {
+	maxRetries, _ := config.Get().Options.RetryPolicy()
+	return ProviderEvent{Type: EventRetry, Retry: &RetryInfo{
+		Attempt:    attempt,
+		MaxRetries: maxRetries,
+		Delay:      time.Duration(afterMs) * time.Millisecond,
+		Err:        err,
+	}}
+}
+
+// retryDelay returns the delay before the given retry: baseDelay doubled for
+// every earlier retry, plus up to 20% of random jitter so clients hitting
+// the same rate limit don't retry in lockstep.
+func retryDelay(attempt int, baseDelay time.Duration) time.Duration {
+	delay := baseDelay << max(attempt-1, 0)
+	if jitter := int64(delay) / 5; jitter > 0 {
+		delay += time.Duration(rand.Int64N(jitter))
+	}
+	return delay
+}
+
+// isTransientStatus reports whether a request that failed with the HTTP
+// status code may succeed when sent again.
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // 529 (unofficial): The service is overloaded
+		return true
+	}
+	return false
+}
+
+// FallbackInfo describes a switch to a fallback model after the previous
+// one was unavailable.
+type FallbackInfo struct {
+	From     catwalk.Model
+	To       catwalk.Model
+	Provider string
+	Err      error
+}
+
+// IsUnavailable reports whether a request failed because the provider or
+// the model could not be used, e.g. an authentication, rate limit or server
+// error, rather than because of the request content. Another model may
+// still answer such a request.
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if code, ok := statusCode(err); ok {
+		switch code {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusRequestTimeout:
+			return true
+		}
+		return isTransientStatus(code)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// statusCode returns the HTTP status code of a provider API error.
+func statusCode(err error) (int, bool) {
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode, true
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode, true
+	}
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code, true
+	}
+	return 0, false
+}
+
+func errMaxRetries(maxRetries int, err error) error {
+	return fmt.Errorf("maximum retry attempts reached: %d retries: %w", maxRetries, err)
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	base := 100 * time.Millisecond
+	for attempt, want := range []time.Duration{100, 100, 200, 400, 800} {
+		want *= time.Millisecond
+		for range 20 {
+			got := retryDelay(attempt, base)
+			require.GreaterOrEqual(t, got, want, "attempt %d", attempt)
+			require.Less(t, got, want+want/5+1, "attempt %d", attempt)
+		}
+	}
+	require.Zero(t, retryDelay(3, 0))
+}
+
+func TestIsTransientStatus(t *testing.T) {
+	t.Parallel()
+
+	for _, code := range []int{429, 500, 502, 503, 504, 529} {
+		require.True(t, isTransientStatus(code), "status %d", code)
+	}
+	for _, code := range []int{200, 400, 401, 403, 404, 422} {
+		require.False(t, isTransientStatus(code), "status %d", code)
+	}
+}
+
+func TestShouldRetryTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	openaiClient := &openaiClient{}
+	geminiClient := &geminiClient{}
+
+	tests := []struct {
+		name      string
+		retry     func(attempts int, err error) (bool, int64, error)
+		err       error
+		wantRetry bool
+	}{
+		{
+			name:      "openai service unavailable",
+			retry:     openaiClient.shouldRetry,
+			err:       &openai.Error{StatusCode: http.StatusServiceUnavailable},
+			wantRetry: true,
+		},
+		{
+			name:      "openai bad gateway",
+			retry:     openaiClient.shouldRetry,
+			err:       &openai.Error{StatusCode: http.StatusBadGateway},
+			wantRetry: true,
+		},
+		{
+			name:  "openai bad request",
+			retry: openaiClient.shouldRetry,
+			err:   &openai.Error{StatusCode: http.StatusBadRequest},
+		},
+		{
+			name:  "openai forbidden",
+			retry: openaiClient.shouldRetry,
+			err:   &openai.Error{StatusCode: http.StatusForbidden},
+		},
+		{
+			name:  "openai canceled",
+			retry: openaiClient.shouldRetry,
+			err:   context.Canceled,
+		},
+		{
+			name:      "gemini service unavailable",
+			retry:     geminiClient.shouldRetry,
+			err:       genai.APIError{Code: http.StatusServiceUnavailable, Message: "The model is overloaded"},
+			wantRetry: true,
+		},
+		{
+			name:  "gemini bad request",
+			retry: geminiClient.shouldRetry,
+			err:   genai.APIError{Code: http.StatusBadRequest, Message: "Invalid argument"},
+		},
+		{
+			name:  "gemini canceled",
+			retry: geminiClient.shouldRetry,
+			err:   context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			retry, after, err := tt.retry(1, tt.err)
+			require.Equal(t, tt.wantRetry, retry)
+			if tt.wantRetry {
+				require.NoError(t, err)
+				require.Positive(t, after)
+				return
+			}
+			require.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestShouldRetryMaxRetries(t *testing.T) {
+	t.Parallel()
+
+	client := &openaiClient{}
+	apiErr := &openai.Error{StatusCode: http.StatusServiceUnavailable}
+	retry, _, err := client.shouldRetry(100, apiErr)
+	require.False(t, retry)
+	require.ErrorContains(t, err, "maximum retry attempts reached")
+	var target *openai.Error
+	require.True(t, errors.As(err, &target), "the last error should be wrapped")
+}
+
+func TestIsUnavailable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unauthorized", err: &openai.Error{StatusCode: http.StatusUnauthorized}, want: true},
+		{name: "rate limited", err: &openai.Error{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "overloaded", err: genai.APIError{Code: http.StatusServiceUnavailable}, want: true},
+		{name: "after retries", err: errMaxRetries(3, &openai.Error{StatusCode: http.StatusBadGateway}), want: true},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "bad request", err: &openai.Error{StatusCode: http.StatusBadRequest}},
+		{name: "content error", err: genai.APIError{Code: http.StatusUnprocessableEntity}},
+		{name: "canceled", err: context.Canceled},
+		{name: "other", err: errors.New("unexpected end of JSON input")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, IsUnavailable(tt.err))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/tulpa-code/tulpa/internal/backup"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+func backupsEnabled() bool {
+	cfg := config.Get()
+	return cfg != nil && cfg.Tools.Backup.Enabled
+}
+
+// backupDescription returns the permission description for overwriting a
+// file, mentioning the backup when backups are enabled.
+func backupDescription(description string) string {
+	if !backupsEnabled() {
+		return description
+	}
+	return description + " (a backup will be made)"
+}
+
+// backupFile keeps a copy of the file at path before it is overwritten, when
+// backups are enabled.
+func backupFile(path string) error {
+	if !backupsEnabled() {
+		return nil
+	}
+	cfg := config.Get()
+	b, err := backup.Create(cfg.Options.DataDirectory, path, cfg.Tools.Backup.Limit())
+	if err != nil {
+		return fmt.Errorf("error backing up file: %w", err)
+	}
+	slog.Debug("Backed up file", "path", path, "backup", b.File)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"strings"
@@ -16,13 +17,15 @@ import (
 )
 
 type BashParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	RunInBackground bool   `json:"run_in_background"`
 }
 
 type BashPermissionsParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
 }
 
 type BashResponseMetadata struct {
@@ -30,22 +33,35 @@ type BashResponseMetadata struct {
 	EndTime          int64  `json:"end_time"`
 	Output           string `json:"output"`
 	WorkingDirectory string `json:"working_directory"`
+	// The job of a command run in the background
+	JobID string `json:"job_id,omitempty"`
 }
 type bashTool struct {
 	permissions permission.Service
 	workingDir  string
 	attribution *config.Attribution
+	// The commands the agent may run, nil when it may run any
+	commands    *commandPolicy
+	commandsErr error
+	// Environment variables set for the commands, see [bashEnviron]
+	env map[string]string
+	// The longest a command may run, and the most bytes of its output and of
+	// its errors kept
+	timeout   time.Duration
+	maxOutput int
 }
 
 const (
 	BashToolName = "bash"
 
-	DefaultTimeout  = 1 * 60 * 1000  // 1 minutes in milliseconds
-	MaxTimeout      = 10 * 60 * 1000 // 10 minutes in milliseconds
-	MaxOutputLength = 30000
-	BashNoOutput    = "no output"
+	DefaultTimeout = 1 * 60 * 1000 // 1 minutes in milliseconds
+	BashNoOutput   = "no output"
 )
 
+// errCommandTimeout is the cause of the cancellation of a command that ran
+// for longer than its timeout.
+var errCommandTimeout = errors.New("command timed out")
+
 //go:embed bash.md
 var bashDescription []byte
 
@@ -57,6 +73,8 @@ var bashDescriptionTpl = template.Must(
 type bashDescriptionData struct {
 	BannedCommands     string
 	MaxOutputLength    int
+	DefaultTimeout     int
+	MaxTimeout         int
 	AttributionStep    string
 	AttributionExample string
 	PRAttribution      string
@@ -183,9 +201,14 @@ git commit -m "$(cat <<'EOF'
 	}
 
 	var out bytes.Buffer
+	if b.commands != nil && len(b.commands.allowedEntries) > 0 {
+		fmt.Fprintf(&out, "IMPORTANT: you may only run these commands, any other is refused: %s\n\n", strings.Join(b.commands.allowedEntries, ", "))
+	}
 	if err := bashDescriptionTpl.Execute(&out, bashDescriptionData{
 		BannedCommands:     bannedCommandsStr,
-		MaxOutputLength:    MaxOutputLength,
+		MaxOutputLength:    b.maxOutput,
+		DefaultTimeout:     int(b.defaultTimeout().Milliseconds()),
+		MaxTimeout:         int(b.timeout.Milliseconds()),
 		AttributionStep:    attributionStep,
 		AttributionExample: attributionExample,
 		PRAttribution:      prAttribution,
@@ -228,18 +251,29 @@ func blockFuncs() []shell.BlockFunc {
 	}
 }
 
-func NewBashTool(permission permission.Service, workingDir string, attribution *config.Attribution) BaseTool {
+func NewBashTool(permission permission.Service, workingDir string, attribution *config.Attribution, commands config.BashCommandRules, env map[string]string, timeout time.Duration, maxOutput int) BaseTool {
 	// Set up command blocking on the persistent shell
 	persistentShell := shell.GetPersistentShell(workingDir)
 	persistentShell.SetBlockFuncs(blockFuncs())
 
+	policy, err := newCommandPolicy(commands)
 	return &bashTool{
 		permissions: permission,
 		workingDir:  workingDir,
 		attribution: attribution,
+		commands:    policy,
+		commandsErr: err,
+		env:         env,
+		timeout:     timeout,
+		maxOutput:   maxOutput,
 	}
 }
 
+// defaultTimeout returns the timeout of the commands the model asks for none.
+func (b *bashTool) defaultTimeout() time.Duration {
+	return min(DefaultTimeout*time.Millisecond, b.timeout)
+}
+
 func (b *bashTool) Name() string {
 	return BashToolName
 }
@@ -255,7 +289,11 @@ func (b *bashTool) Info() ToolInfo {
 			},
 			"timeout": map[string]any{
 				"type":        "number",
-				"description": "Optional timeout in milliseconds (max 600000)",
+				"description": fmt.Sprintf("Optional timeout in milliseconds (max %d)", b.timeout.Milliseconds()),
+			},
+			"run_in_background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command in the background, without timeout, and return the ID of its job for the jobs tool",
 			},
 		},
 		Required: []string{"command"},
@@ -268,16 +306,27 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return NewTextErrorResponse("invalid parameters"), nil
 	}
 
-	if params.Timeout > MaxTimeout {
-		params.Timeout = MaxTimeout
-	} else if params.Timeout <= 0 {
-		params.Timeout = DefaultTimeout
+	timeout := b.defaultTimeout()
+	if params.Timeout > 0 {
+		timeout = min(time.Duration(params.Timeout)*time.Millisecond, b.timeout)
 	}
 
 	if params.Command == "" {
 		return NewTextErrorResponse("missing command"), nil
 	}
 
+	// The commands of the agent are checked before asking for permission,
+	// and again while the shell runs them.
+	if b.commandsErr != nil {
+		return NewTextErrorResponse(b.commandsErr.Error()), nil
+	}
+	if b.commands != nil {
+		if refusal := b.commands.check(params.Command); refusal != "" {
+			return NewTextErrorResponse(refusal), nil
+		}
+		ctx = shell.WithBlockFuncs(ctx, b.commands.blockFunc())
+	}
+
 	isSafeReadOnly := false
 	cmdLower := strings.ToLower(params.Command)
 
@@ -294,6 +343,13 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for executing shell command")
 	}
+	// The variables are only set for this command, never in the environment
+	// of Tulpa or of the other tools.
+	environ, err := bashEnviron(b.env, sessionID)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	ctx = shell.WithEnv(ctx, environ...)
 	if !isSafeReadOnly {
 		shell := shell.GetPersistentShell(b.workingDir)
 		p := b.permissions.Request(
@@ -305,7 +361,8 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 				Action:      "execute",
 				Description: fmt.Sprintf("Execute command: %s", params.Command),
 				Params: BashPermissionsParams{
-					Command: params.Command,
+					Command:         params.Command,
+					RunInBackground: params.RunInBackground,
 				},
 			},
 		)
@@ -314,32 +371,56 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 	startTime := time.Now()
-	if params.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
-		defer cancel()
+	if params.RunInBackground {
+		job, err := startJob(ctx, b.workingDir, sessionID, params.Command)
+		if err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		metadata := BashResponseMetadata{
+			StartTime:        startTime.UnixMilli(),
+			EndTime:          time.Now().UnixMilli(),
+			WorkingDirectory: shell.GetPersistentShell(b.workingDir).GetWorkingDir(),
+			JobID:            job.ID,
+		}
+		return WithResponseMetadata(NewTextResponse(fmt.Sprintf(
+			"Started %s in the background. Read its output with the jobs tool to check it started well, and stop it once you no longer need it.",
+			job.ID,
+		)), metadata), nil
 	}
 
+	// The shell kills the processes of the command when the context ends.
+	runCtx, cancel := context.WithTimeoutCause(ctx, timeout, errCommandTimeout)
+	defer cancel()
+
 	persistentShell := shell.GetPersistentShell(b.workingDir)
-	stdout, stderr, err := persistentShell.Exec(ctx, params.Command)
+	stdout, stderr, err := persistentShell.Exec(shell.WithOutputLimit(runCtx, b.maxOutput), params.Command)
 
 	// Get the current working directory after command execution
 	currentWorkingDir := persistentShell.GetWorkingDir()
+	truncated := errors.Is(err, shell.ErrOutputTruncated)
+	if err == shell.ErrOutputTruncated {
+		err = nil
+	}
+	timedOut := errors.Is(context.Cause(runCtx), errCommandTimeout)
 	interrupted := shell.IsInterrupt(err)
 	exitCode := shell.ExitCode(err)
 	if exitCode == 0 && !interrupted && err != nil {
 		return ToolResponse{}, fmt.Errorf("error executing command: %w", err)
 	}
 
-	stdout = truncateOutput(stdout)
-	stderr = truncateOutput(stderr)
-
 	errorMessage := stderr
 	if errorMessage == "" && err != nil {
 		errorMessage = err.Error()
 	}
 
-	if interrupted {
+	if timedOut {
+		if errorMessage != "" {
+			errorMessage += "\n"
+		}
+		errorMessage += fmt.Sprintf("Command timed out after %s and its processes were killed. "+
+			"Pass a longer timeout for slow commands, at most %s, and don't run commands that never exit, like servers or tail -f.",
+			timeout, b.timeout)
+	} else if interrupted {
 		if errorMessage != "" {
 			errorMessage += "\n"
 		}
@@ -360,6 +441,11 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	if errorMessage != "" {
 		stdout += "\n" + errorMessage
 	}
+	if truncated {
+		stdout += fmt.Sprintf("\nThe output was longer than %d bytes and its middle was left out. "+
+			"Narrow it down, for example with grep, head or tail, or redirect it to a file and read the parts you need.",
+			b.maxOutput)
+	}
 
 	metadata := BashResponseMetadata{
 		StartTime:        startTime.UnixMilli(),
@@ -371,25 +457,8 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return WithResponseMetadata(NewTextResponse(BashNoOutput), metadata), nil
 	}
 	stdout += fmt.Sprintf("\n\n<cwd>%s</cwd>", currentWorkingDir)
-	return WithResponseMetadata(NewTextResponse(stdout), metadata), nil
-}
-
-func truncateOutput(content string) string {
-	if len(content) <= MaxOutputLength {
-		return content
-	}
-
-	halfLength := MaxOutputLength / 2
-	start := content[:halfLength]
-	end := content[len(content)-halfLength:]
-
-	truncatedLinesCount := countLines(content[halfLength : len(content)-halfLength])
-	return fmt.Sprintf("%s\n\n... [%d lines truncated] ...\n\n%s", start, truncatedLinesCount, end)
-}
-
-func countLines(s string) int {
-	if s == "" {
-		return 0
-	}
-	return len(strings.Split(s, "\n"))
+	response := NewTextResponse(stdout)
+	// The model is told it didn't get the whole outcome so it can adapt.
+	response.IsError = timedOut || truncated
+	return WithResponseMetadata(response, metadata), nil
 }
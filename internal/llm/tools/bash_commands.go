@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/shell"
+)
+
+// commandPolicy refuses the commands an agent may not run with the bash tool,
+// following its [config.BashCommandRules].
+type commandPolicy struct {
+	allowed []commandPattern
+	denied  []commandPattern
+	// The entries allowing commands, to tell the model what it may run
+	allowedEntries []string
+}
+
+type commandPattern struct {
+	prefix []string
+	regex  *regexp.Regexp
+}
+
+// newCommandPolicy compiles rules, and returns nil when they restrict
+// nothing.
+func newCommandPolicy(rules config.BashCommandRules) (*commandPolicy, error) {
+	if len(rules.Allowed) == 0 && len(rules.Denied) == 0 {
+		return nil, nil
+	}
+	p := &commandPolicy{allowedEntries: rules.Allowed}
+	for _, list := range []struct {
+		entries  []string
+		patterns *[]commandPattern
+	}{{rules.Allowed, &p.allowed}, {rules.Denied, &p.denied}} {
+		for _, entry := range list.entries {
+			pattern, err := compileCommandPattern(entry)
+			if err != nil {
+				return nil, err
+			}
+			*list.patterns = append(*list.patterns, pattern)
+		}
+	}
+	return p, nil
+}
+
+func compileCommandPattern(entry string) (commandPattern, error) {
+	if expr, ok := config.CommandRegex(entry); ok {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return commandPattern{}, fmt.Errorf("invalid bash command rule %s: %w", entry, err)
+		}
+		return commandPattern{regex: regex}, nil
+	}
+	return commandPattern{prefix: strings.Fields(entry)}, nil
+}
+
+func (p commandPattern) matches(args []string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(strings.Join(args, " "))
+	}
+	return len(p.prefix) > 0 && len(args) >= len(p.prefix) && slices.Equal(args[:len(p.prefix)], p.prefix)
+}
+
+// refusal returns why the command with args may not run, or an empty string
+// when it may.
+func (p *commandPolicy) refusal(args []string) string {
+	command := strings.Join(args, " ")
+	for _, pattern := range p.denied {
+		if pattern.matches(args) {
+			return fmt.Sprintf("command %q is denied for this agent", command)
+		}
+	}
+	if len(p.allowed) == 0 || slices.ContainsFunc(p.allowed, func(pattern commandPattern) bool { return pattern.matches(args) }) {
+		return ""
+	}
+	return fmt.Sprintf("command %q is not allowed for this agent, it may only run: %s", command, strings.Join(p.allowedEntries, ", "))
+}
+
+// check returns why command may not run, or an empty string when each of
+// the commands it runs may.
+func (p *commandPolicy) check(command string) string {
+	commands, err := shell.SimpleCommands(command)
+	if err != nil {
+		return err.Error()
+	}
+	for _, args := range commands {
+		if refusal := p.refusal(args); refusal != "" {
+			return refusal
+		}
+	}
+	return ""
+}
+
+// blockFunc blocks the commands refused by the policy while the shell runs,
+// like the ones built from variables that check can't see.
+func (p *commandPolicy) blockFunc() shell.BlockFunc {
+	return func(args []string) bool {
+		return p.refusal(args) != ""
+	}
+}
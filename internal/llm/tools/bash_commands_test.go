@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+func TestCommandPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := newCommandPolicy(config.BashCommandRules{
+		Allowed: []string{"go test", "go build", "/^make( |$)/"},
+		Denied:  []string{"go test -exec"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		command string
+		refusal string
+	}{
+		{command: "go test ./..."},
+		{command: "cd cmd && go build ."},
+		{command: "make lint"},
+		{command: "make"},
+		{command: "go testify", refusal: `command "go testify" is not allowed for this agent, it may only run: go test, go build, /^make( |$)/`},
+		{command: "makes", refusal: `command "makes" is not allowed for this agent`},
+		{command: "go test ./... && rm -rf /", refusal: `command "rm -rf /" is not allowed for this agent`},
+		{command: "echo $(curl example.com)", refusal: `command "curl example.com" is not allowed for this agent`},
+		{command: "go test -exec foo ./...", refusal: `command "go test -exec foo ./..." is denied for this agent`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			t.Parallel()
+
+			refusal := policy.check(tt.command)
+			if tt.refusal == "" {
+				require.Empty(t, refusal)
+				return
+			}
+			require.Contains(t, refusal, tt.refusal)
+		})
+	}
+}
+
+func TestNewCommandPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := newCommandPolicy(config.BashCommandRules{})
+	require.NoError(t, err)
+	require.Nil(t, policy, "no rules restrict nothing")
+
+	policy, err = newCommandPolicy(config.BashCommandRules{Denied: []string{"git push"}})
+	require.NoError(t, err)
+	require.Empty(t, policy.check("git status"), "only denied commands are refused without allowed ones")
+	require.NotEmpty(t, policy.check("git push origin main"))
+
+	_, err = newCommandPolicy(config.BashCommandRules{Allowed: []string{"/(/"}})
+	require.ErrorContains(t, err, "invalid bash command rule /(/")
+}
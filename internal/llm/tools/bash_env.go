@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/tulpa-code/tulpa/internal/csync"
+)
+
+// sessionEnv holds the environment variables set for the bash commands of a
+// session, over those of its agent.
+var sessionEnv = csync.NewMap[string, map[string]string]()
+
+// SetSessionEnv sets environment variables for the bash commands run in the
+// given session, overriding those configured for the agent. An empty env
+// removes the overrides of the session.
+func SetSessionEnv(sessionID string, env map[string]string) {
+	if len(env) == 0 {
+		sessionEnv.Del(sessionID)
+		return
+	}
+	sessionEnv.Set(sessionID, maps.Clone(env))
+}
+
+// bashEnviron returns the environment variables of the bash commands run in
+// the given session as sorted "NAME=value" pairs: those of the agent
+// overridden by those of the session. $NAME and ${NAME} in a value are
+// replaced with the variable of the environment Tulpa runs in, so secrets
+// can be referenced by name instead of written in the config.
+func bashEnviron(agentEnv map[string]string, sessionID string) ([]string, error) {
+	env := maps.Clone(agentEnv)
+	if overrides, ok := sessionEnv.Get(sessionID); ok {
+		if env == nil {
+			env = make(map[string]string, len(overrides))
+		}
+		maps.Copy(env, overrides)
+	}
+
+	environ := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, fmt.Errorf("invalid bash environment variable name %q", name)
+		}
+		var missing []string
+		value := os.Expand(env[name], func(ref string) string {
+			value, ok := os.LookupEnv(ref)
+			if !ok {
+				missing = append(missing, ref)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("bash environment variable %s references %s, which is not set", name, strings.Join(missing, ", "))
+		}
+		environ = append(environ, name+"="+value)
+	}
+	return environ, nil
+}
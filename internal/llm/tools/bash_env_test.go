@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/shell"
+)
+
+func TestBashEnviron(t *testing.T) {
+	t.Setenv("TULPA_TEST_SECRET", "s3cret")
+	SetSessionEnv("environ-session", map[string]string{"MODE": "session"})
+	t.Cleanup(func() { SetSessionEnv("environ-session", nil) })
+
+	agentEnv := map[string]string{"MODE": "agent", "TOKEN": "${TULPA_TEST_SECRET}", "URL": "https://$TULPA_TEST_SECRET@example.com"}
+
+	environ, err := bashEnviron(agentEnv, "other-session")
+	require.NoError(t, err)
+	require.Equal(t, []string{"MODE=agent", "TOKEN=s3cret", "URL=https://s3cret@example.com"}, environ)
+
+	environ, err = bashEnviron(agentEnv, "environ-session")
+	require.NoError(t, err)
+	require.Equal(t, []string{"MODE=session", "TOKEN=s3cret", "URL=https://s3cret@example.com"}, environ)
+
+	environ, err = bashEnviron(nil, "other-session")
+	require.NoError(t, err)
+	require.Empty(t, environ)
+
+	_, err = bashEnviron(map[string]string{"TOKEN": "$TULPA_TEST_UNSET"}, "other-session")
+	require.EqualError(t, err, "bash environment variable TOKEN references TULPA_TEST_UNSET, which is not set")
+
+	_, err = bashEnviron(map[string]string{"A=B": "c"}, "other-session")
+	require.EqualError(t, err, `invalid bash environment variable name "A=B"`)
+}
+
+func TestBashToolEnv(t *testing.T) {
+	t.Setenv("TULPA_TEST_SECRET", "s3cret")
+	workingDir := os.TempDir()
+
+	tool := NewBashTool(nil, workingDir, nil, config.BashCommandRules{}, map[string]string{"TULPA_TEST_TOKEN": "$TULPA_TEST_SECRET"}, config.DefaultBashTimeout, config.DefaultBashMaxOutput)
+	resp, err := tool.Run(bashTestContext(t), ToolCall{ID: "call", Name: BashToolName, Input: `{"command":"echo token=$TULPA_TEST_TOKEN"}`})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+	require.Contains(t, resp.Content, "token=s3cret")
+
+	// Neither the commands run by other tools in the same shell nor Tulpa
+	// itself see the variable.
+	stdout, _, err := shell.GetPersistentShell(workingDir).Exec(t.Context(), "echo token=$TULPA_TEST_TOKEN")
+	require.NoError(t, err)
+	require.Equal(t, "token=\n", stdout)
+	_, ok := os.LookupEnv("TULPA_TEST_TOKEN")
+	require.False(t, ok)
+}
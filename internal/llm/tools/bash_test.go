@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+// bashTestContext returns the context of a tool call of a session. The
+// commands of the tests must be safe ones so no permission is asked, and all
+// of them run in os.TempDir as the persistent shell is created once.
+func bashTestContext(t *testing.T) context.Context {
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "bash-session")
+	return context.WithValue(ctx, MessageIDContextKey, "bash-message")
+}
+
+func TestBashToolTimeout(t *testing.T) {
+	tool := NewBashTool(nil, os.TempDir(), nil, config.BashCommandRules{}, nil, 200*time.Millisecond, config.DefaultBashMaxOutput)
+	require.Contains(t, tool.Info().Description, "up to 200ms")
+
+	start := time.Now()
+	resp, err := tool.Run(bashTestContext(t), ToolCall{ID: "call", Name: BashToolName, Input: `{"command":"echo started && sleep 10", "timeout": 60000}`})
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "started")
+	require.Contains(t, resp.Content, "Command timed out after 200ms and its processes were killed.")
+}
+
+func TestBashToolMaxOutput(t *testing.T) {
+	tool := NewBashTool(nil, os.TempDir(), nil, config.BashCommandRules{}, nil, config.DefaultBashTimeout, 20)
+
+	resp, err := tool.Run(bashTestContext(t), ToolCall{ID: "call", Name: BashToolName, Input: `{"command":"echo short"}`})
+	require.NoError(t, err)
+	require.False(t, resp.IsError)
+
+	resp, err = tool.Run(bashTestContext(t), ToolCall{ID: "call", Name: BashToolName, Input: `{"command":"echo ` + strings.Repeat("a", 100) + `b"}`})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.True(t, strings.HasPrefix(resp.Content, "aaaaaaaaaa\n\n... [82 bytes, 0 lines truncated] ...\n\naaaaaaaab\n"), resp.Content)
+	require.Contains(t, resp.Content, "The output was longer than 20 bytes and its middle was left out.")
+}
@@ -1,11 +1,13 @@
 package tools
 
 import (
+	"cmp"
 	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -61,9 +63,94 @@ func (b *diagnosticsTool) Run(ctx context.Context, call ToolCall) (ToolResponse,
 	if b.lspClients.Len() == 0 {
 		return NewTextErrorResponse("no LSP clients available"), nil
 	}
-	notifyLSPs(ctx, b.lspClients, params.FilePath)
-	output := getDiagnostics(params.FilePath, b.lspClients)
-	return NewTextResponse(output), nil
+	return NewTextResponse(Diagnostics(ctx, b.lspClients, params.FilePath)), nil
+}
+
+// maxDiagnosticsPerSeverity caps how many diagnostics of each severity
+// Diagnostics lists.
+const maxDiagnosticsPerSeverity = 50
+
+// Diagnostics returns the diagnostics reported by the LSP clients grouped by
+// severity. When filePath is set, the clients handling it are notified of
+// its content first, and only its diagnostics are listed.
+func Diagnostics(ctx context.Context, lsps *csync.Map[string, *lsp.Client], filePath string) string {
+	notifyLSPs(ctx, lsps, filePath)
+
+	type entry struct {
+		path         string
+		line, column uint32
+		text         string
+	}
+	groups := make(map[protocol.DiagnosticSeverity][]entry)
+	var otherFiles int
+	for lspName, client := range lsps.Seq2() {
+		for location, diags := range client.GetDiagnostics() {
+			path, err := location.Path()
+			if err != nil {
+				slog.Error("Failed to convert diagnostic location URI to path", "uri", location, "error", err)
+				continue
+			}
+			if filePath != "" && path != filePath {
+				otherFiles += len(diags)
+				continue
+			}
+			for _, diag := range diags {
+				severity := diag.Severity
+				if severity == 0 {
+					severity = protocol.SeverityInformation
+				}
+				groups[severity] = append(groups[severity], entry{
+					path:   path,
+					line:   diag.Range.Start.Line,
+					column: diag.Range.Start.Character,
+					text:   diagnosticLine(path, diag, lspName),
+				})
+			}
+		}
+	}
+
+	var output strings.Builder
+	for _, group := range []struct {
+		severity protocol.DiagnosticSeverity
+		title    string
+	}{
+		{protocol.SeverityError, "Errors"},
+		{protocol.SeverityWarning, "Warnings"},
+		{protocol.SeverityInformation, "Info"},
+		{protocol.SeverityHint, "Hints"},
+	} {
+		entries := groups[group.severity]
+		if len(entries) == 0 {
+			continue
+		}
+		slices.SortFunc(entries, func(a, b entry) int {
+			return cmp.Or(
+				strings.Compare(a.path, b.path),
+				cmp.Compare(a.line, b.line),
+				cmp.Compare(a.column, b.column),
+				strings.Compare(a.text, b.text),
+			)
+		})
+		fmt.Fprintf(&output, "%s (%d):\n", group.title, len(entries))
+		for _, e := range entries[:min(len(entries), maxDiagnosticsPerSeverity)] {
+			fmt.Fprintf(&output, "  %s\n", e.text)
+		}
+		if len(entries) > maxDiagnosticsPerSeverity {
+			fmt.Fprintf(&output, "  ... and %d more\n", len(entries)-maxDiagnosticsPerSeverity)
+		}
+	}
+
+	if output.Len() == 0 {
+		if filePath != "" {
+			fmt.Fprintf(&output, "No diagnostics for %s\n", filePath)
+		} else {
+			output.WriteString("No diagnostics\n")
+		}
+	}
+	if otherFiles > 0 {
+		fmt.Fprintf(&output, "\n%d more diagnostics in other files of the project\n", otherFiles)
+	}
+	return output.String()
 }
 
 func notifyLSPs(ctx context.Context, lsps *csync.Map[string, *lsp.Client], filepath string) {
@@ -162,7 +249,12 @@ func formatDiagnostic(pth string, diagnostic protocol.Diagnostic, source string)
 	case protocol.SeverityHint:
 		severity = "Hint"
 	}
+	return severity + ": " + diagnosticLine(pth, diagnostic, source)
+}
 
+// diagnosticLine formats the location, source, code, tags and message of the
+// diagnostic.
+func diagnosticLine(pth string, diagnostic protocol.Diagnostic, source string) string {
 	location := fmt.Sprintf("%s:%d:%d", pth, diagnostic.Range.Start.Line+1, diagnostic.Range.Start.Character+1)
 
 	sourceInfo := ""
@@ -193,8 +285,7 @@ func formatDiagnostic(pth string, diagnostic protocol.Diagnostic, source string)
 		}
 	}
 
-	return fmt.Sprintf("%s: %s [%s]%s%s %s",
-		severity,
+	return fmt.Sprintf("%s [%s]%s%s %s",
 		location,
 		sourceInfo,
 		codeInfo,
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/powernap/pkg/lsp/protocol"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/env"
+	"github.com/tulpa-code/tulpa/internal/lsp"
+)
+
+// newDiagnosticsClient returns an LSP client that published the given
+// diagnostics. Its server exits right away, and it handles no Go file so
+// that it isn't asked to check one.
+func newDiagnosticsClient(t *testing.T, name string, diagnostics map[string][]protocol.Diagnostic) *lsp.Client {
+	t.Helper()
+	client, err := lsp.New(t.Context(), name, config.LSPConfig{Command: "true", FileTypes: []string{"py"}}, config.NewEnvironmentVariableResolver(env.NewFromMap(nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_ = client.Close(ctx)
+	})
+	for path, diags := range diagnostics {
+		params, err := json.Marshal(protocol.PublishDiagnosticsParams{URI: protocol.URIFromPath(path), Diagnostics: diags})
+		require.NoError(t, err)
+		lsp.HandleDiagnostics(client, params)
+	}
+	return client
+}
+
+func diagnosticAt(line, column uint32, severity protocol.DiagnosticSeverity, message string) protocol.Diagnostic {
+	return protocol.Diagnostic{
+		Range:    protocol.Range{Start: protocol.Position{Line: line, Character: column}},
+		Severity: severity,
+		Message:  message,
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	lsps := csync.NewMap[string, *lsp.Client]()
+	lsps.Set("gopls", newDiagnosticsClient(t, "gopls", map[string][]protocol.Diagnostic{
+		"/project/main.go": {
+			diagnosticAt(9, 4, protocol.SeverityWarning, "unused variable"),
+			diagnosticAt(2, 0, protocol.SeverityError, "undefined: foo"),
+		},
+		"/project/util.go": {
+			diagnosticAt(0, 0, protocol.SeverityError, "missing return"),
+			diagnosticAt(4, 1, 0, "no severity"),
+		},
+	}))
+	tool := NewDiagnosticsTool(lsps)
+
+	tests := []struct {
+		name     string
+		filePath string
+		want     string
+	}{
+		{
+			name: "project",
+			want: "Errors (2):\n" +
+				"  /project/main.go:3:1 [gopls] undefined: foo\n" +
+				"  /project/util.go:1:1 [gopls] missing return\n" +
+				"Warnings (1):\n" +
+				"  /project/main.go:10:5 [gopls] unused variable\n" +
+				"Info (1):\n" +
+				"  /project/util.go:5:2 [gopls] no severity\n",
+		},
+		{
+			name:     "file",
+			filePath: "/project/main.go",
+			want: "Errors (1):\n" +
+				"  /project/main.go:3:1 [gopls] undefined: foo\n" +
+				"Warnings (1):\n" +
+				"  /project/main.go:10:5 [gopls] unused variable\n" +
+				"\n2 more diagnostics in other files of the project\n",
+		},
+		{
+			name:     "clean file",
+			filePath: "/project/clean.go",
+			want:     "No diagnostics for /project/clean.go\n\n4 more diagnostics in other files of the project\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			input, err := json.Marshal(DiagnosticsParams{FilePath: tt.filePath})
+			require.NoError(t, err)
+			resp, err := tool.Run(t.Context(), ToolCall{Name: DiagnosticsToolName, Input: string(input)})
+			require.NoError(t, err)
+			require.False(t, resp.IsError)
+			require.Equal(t, tt.want, resp.Content)
+		})
+	}
+}
+
+func TestDiagnosticsLimit(t *testing.T) {
+	t.Parallel()
+
+	var diags []protocol.Diagnostic
+	for i := range maxDiagnosticsPerSeverity + 3 {
+		diags = append(diags, diagnosticAt(uint32(i), 0, protocol.SeverityError, fmt.Sprintf("error %d", i)))
+	}
+	lsps := csync.NewMap[string, *lsp.Client]()
+	lsps.Set("gopls", newDiagnosticsClient(t, "gopls", map[string][]protocol.Diagnostic{"/project/main.go": diags}))
+
+	out := Diagnostics(t.Context(), lsps, "")
+	require.True(t, strings.HasPrefix(out, fmt.Sprintf("Errors (%d):\n", maxDiagnosticsPerSeverity+3)))
+	require.Equal(t, maxDiagnosticsPerSeverity, strings.Count(out, "[gopls] error"))
+	require.True(t, strings.HasSuffix(out, "  ... and 3 more\n"))
+}
+
+func TestDiagnosticsWithoutLSP(t *testing.T) {
+	t.Parallel()
+
+	tool := NewDiagnosticsTool(csync.NewMap[string, *lsp.Client]())
+	resp, err := tool.Run(t.Context(), ToolCall{Name: DiagnosticsToolName, Input: "{}"})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Equal(t, "no LSP clients available", resp.Content)
+}
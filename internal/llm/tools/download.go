@@ -98,11 +98,9 @@ func (t *downloadTool) Run(ctx context.Context, call ToolCall) (ToolResponse, er
 	}
 
 	// Convert relative path to absolute path
-	var filePath string
-	if filepath.IsAbs(params.FilePath) {
-		filePath = params.FilePath
-	} else {
-		filePath = filepath.Join(t.workingDir, params.FilePath)
+	filePath, err := workspacePath(t.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
 	}
 
 	sessionID, messageID := GetContextValues(ctx)
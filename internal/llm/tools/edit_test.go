@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/history"
+	"github.com/tulpa-code/tulpa/internal/lsp"
+	"github.com/tulpa-code/tulpa/internal/permission"
+)
+
+// editTestPermissions grants every request, after running request if set.
+type editTestPermissions struct {
+	permission.Service
+	request  func(permission.CreatePermissionRequest)
+	requests []permission.CreatePermissionRequest
+}
+
+func (p *editTestPermissions) Request(opts permission.CreatePermissionRequest) bool {
+	p.requests = append(p.requests, opts)
+	if p.request != nil {
+		p.request(opts)
+	}
+	return true
+}
+
+type editTestHistory struct {
+	history.Service
+}
+
+func (editTestHistory) GetByPathAndSession(context.Context, string, string) (history.File, error) {
+	return history.File{}, errors.New("not found")
+}
+
+func (editTestHistory) Create(context.Context, string, string, string) (history.File, error) {
+	return history.File{}, nil
+}
+
+func (editTestHistory) CreateVersion(context.Context, string, string, string) (history.File, error) {
+	return history.File{}, nil
+}
+
+func TestEditToolConflicts(t *testing.T) {
+	t.Parallel()
+
+	const original = "package main\n\nfunc main() {\n\tprintln(\"old\")\n}\n"
+	input := func(path string) string {
+		return `{"file_path":"` + filepath.ToSlash(path) + `","old_string":"println(\"old\")","new_string":"println(\"new\")"}`
+	}
+	run := func(t *testing.T, permissions *editTestPermissions, path string) ToolResponse {
+		dir := filepath.Dir(path)
+		tool := NewEditTool(csync.NewMap[string, *lsp.Client](), permissions, editTestHistory{}, dir)
+		resp, err := tool.Run(bashTestContext(t), ToolCall{ID: "call", Name: EditToolName, Input: input(path)})
+		require.NoError(t, err)
+		return resp
+	}
+	setup := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "main.go")
+		require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+		return path
+	}
+	requireContent := func(t *testing.T, path, want string) {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, want, string(content))
+	}
+
+	t.Run("applies the diff to a fresh read", func(t *testing.T) {
+		t.Parallel()
+		path := setup(t)
+		recordFileRead(path)
+		// A file saved again with the same content may still be edited.
+		later := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(path, later, later))
+
+		permissions := &editTestPermissions{}
+		resp := run(t, permissions, path)
+		require.False(t, resp.IsError, resp.Content)
+		requireContent(t, path, "package main\n\nfunc main() {\n\tprintln(\"new\")\n}\n")
+
+		var meta EditResponseMetadata
+		require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &meta))
+		require.Contains(t, meta.Diff, "-\tprintln(\"old\")")
+		require.Contains(t, meta.Diff, "+\tprintln(\"new\")")
+		require.Len(t, permissions.requests, 1)
+		require.Equal(t, meta.Diff, permissions.requests[0].Params.(EditPermissionsParams).Diff)
+	})
+
+	t.Run("refuses a file never read", func(t *testing.T) {
+		t.Parallel()
+		path := setup(t)
+
+		resp := run(t, &editTestPermissions{}, path)
+		require.True(t, resp.IsError)
+		require.Contains(t, resp.Content, "you must read the file before editing it")
+		requireContent(t, path, original)
+	})
+
+	t.Run("refuses a stale read", func(t *testing.T) {
+		t.Parallel()
+		path := setup(t)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		recordFileRead(path)
+
+		// Changed by the user after the read, even keeping the modification time.
+		changed := "package main\n\nfunc main() {\n\tprintln(\"old\")\n\tprintln(\"user\")\n}\n"
+		require.NoError(t, os.WriteFile(path, []byte(changed), 0o644))
+		require.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+
+		permissions := &editTestPermissions{}
+		resp := run(t, permissions, path)
+		require.True(t, resp.IsError)
+		require.Contains(t, resp.Content, "has changed since it was last read")
+		require.Empty(t, permissions.requests)
+		requireContent(t, path, changed)
+	})
+
+	t.Run("refuses a file changed while asking for permission", func(t *testing.T) {
+		t.Parallel()
+		path := setup(t)
+		recordFileRead(path)
+
+		changed := "package main\n\nfunc main() {\n\tprintln(\"old\")\n}\n\nfunc user() {}\n"
+		permissions := &editTestPermissions{request: func(permission.CreatePermissionRequest) {
+			require.NoError(t, os.WriteFile(path, []byte(changed), 0o644))
+		}}
+		resp := run(t, permissions, path)
+		require.True(t, resp.IsError)
+		require.Contains(t, resp.Content, "changed while waiting for the permission to edit it")
+		requireContent(t, path, changed)
+	})
+}
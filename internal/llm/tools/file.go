@@ -1,6 +1,10 @@
 package tools
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
 	"sync"
 	"time"
 )
@@ -10,6 +14,9 @@ type fileRecord struct {
 	path      string
 	readTime  time.Time
 	writeTime time.Time
+	// Hash of the content of the file when it was last read, to find out
+	// whether it changed since
+	readHash string
 }
 
 var (
@@ -18,6 +25,11 @@ var (
 )
 
 func recordFileRead(path string) {
+	var hash string
+	if content, err := os.ReadFile(path); err == nil {
+		hash = contentHash(content)
+	}
+
 	fileRecordMutex.Lock()
 	defer fileRecordMutex.Unlock()
 
@@ -26,6 +38,7 @@ func recordFileRead(path string) {
 		record = fileRecord{path: path}
 	}
 	record.readTime = time.Now()
+	record.readHash = hash
 	fileRecords[path] = record
 }
 
@@ -51,3 +64,27 @@ func recordFileWrite(path string) {
 	record.writeTime = time.Now()
 	fileRecords[path] = record
 }
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkUnchangedSinceRead returns why the file at path with the given
+// content may not be edited: when it wasn't read first, or it changed since
+// it was last read, by the user or another tool. Edits are made to what the
+// model saw, never on top of changes it doesn't know about.
+func checkUnchangedSinceRead(path string, content []byte) string {
+	fileRecordMutex.RLock()
+	record, exists := fileRecords[path]
+	fileRecordMutex.RUnlock()
+
+	if !exists || record.readTime.IsZero() {
+		return "you must read the file before editing it. Use the View tool first"
+	}
+	if record.readHash != contentHash(content) {
+		return fmt.Sprintf("file %s has changed since it was last read (last read: %s). Read it again with the View tool before editing it",
+			path, record.readTime.Format(time.RFC3339))
+	}
+	return ""
+}
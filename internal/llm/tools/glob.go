@@ -75,6 +75,11 @@ func (g *globTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	searchPath := params.Path
 	if searchPath == "" {
 		searchPath = g.workingDir
+	} else {
+		var err error
+		if searchPath, err = workspacePath(g.workingDir, searchPath); err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
 	}
 
 	files, truncated, err := globFiles(ctx, params.Pattern, searchPath, 100)
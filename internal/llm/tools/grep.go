@@ -165,6 +165,11 @@ func (g *grepTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	searchPath := params.Path
 	if searchPath == "" {
 		searchPath = g.workingDir
+	} else {
+		var err error
+		if searchPath, err = workspacePath(g.workingDir, searchPath); err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
 	}
 
 	matches, truncated, err := searchFiles(ctx, searchPattern, searchPath, params.Include, 100)
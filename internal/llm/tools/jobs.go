@@ -0,0 +1,307 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/csync"
+	"github.com/tulpa-code/tulpa/internal/pubsub"
+	"github.com/tulpa-code/tulpa/internal/shell"
+)
+
+const JobsToolName = "jobs"
+
+//go:embed jobs.md
+var jobsDescription []byte
+
+// JobStatus tells whether a background job still runs.
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	JobExited  JobStatus = "exited"
+	JobStopped JobStatus = "stopped"
+)
+
+// Job is a command the bash tool runs in the background of a session, like
+// a dev server the agent needs while it works.
+type Job struct {
+	ID        string
+	SessionID string
+	Command   string
+	Status    JobStatus
+	// The exit code of an exited job
+	ExitCode  int
+	StartedAt time.Time
+}
+
+// jobLogSize is how many bytes of the latest output of a job are kept.
+const jobLogSize = 256 * 1024
+
+type job struct {
+	mu     sync.Mutex
+	info   Job
+	log    []byte
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var (
+	jobs      = csync.NewMap[string, *job]()
+	jobBroker = pubsub.NewBroker[Job]()
+	jobCount  atomic.Int64
+)
+
+// SubscribeJobs returns a channel for the events of the background jobs.
+func SubscribeJobs(ctx context.Context) <-chan pubsub.Event[Job] {
+	return jobBroker.Subscribe(ctx)
+}
+
+// Jobs returns the background jobs of the session, oldest first.
+func Jobs(sessionID string) []Job {
+	var result []Job
+	for _, j := range jobs.Seq2() {
+		if info := j.snapshot(); info.SessionID == sessionID {
+			result = append(result, info)
+		}
+	}
+	slices.SortFunc(result, func(a, b Job) int {
+		return cmp.Or(a.StartedAt.Compare(b.StartedAt), strings.Compare(a.ID, b.ID))
+	})
+	return result
+}
+
+// StopJobs stops the background jobs of the session, waiting for their
+// processes to exit, and forgets them. It's called when the session ends.
+func StopJobs(sessionID string) {
+	stopJobs(func(info Job) bool { return info.SessionID == sessionID })
+}
+
+// StopAllJobs stops the background jobs of all sessions, when Tulpa exits.
+func StopAllJobs() {
+	stopJobs(func(Job) bool { return true })
+}
+
+func stopJobs(match func(Job) bool) {
+	var wg sync.WaitGroup
+	for id, j := range jobs.Seq2() {
+		if !match(j.snapshot()) {
+			continue
+		}
+		wg.Go(func() {
+			j.stop()
+			jobs.Del(id)
+			jobBroker.Publish(pubsub.DeletedEvent, j.snapshot())
+		})
+	}
+	wg.Wait()
+}
+
+// startJob runs command in the background of the session, with the
+// persistent shell. The job outlives ctx but keeps its values, like the
+// environment and the command checks of the bash tool.
+func startJob(ctx context.Context, workingDir, sessionID, command string) (Job, error) {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j := &job{
+		info: Job{
+			ID:        fmt.Sprintf("job-%d", jobCount.Add(1)),
+			SessionID: sessionID,
+			Command:   command,
+			Status:    JobRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	result, err := shell.GetPersistentShell(workingDir).Start(jobCtx, command, j)
+	if err != nil {
+		cancel()
+		return Job{}, err
+	}
+	jobs.Set(j.info.ID, j)
+	jobBroker.Publish(pubsub.CreatedEvent, j.snapshot())
+
+	go func() {
+		err := <-result
+		cancel()
+		j.mu.Lock()
+		if j.info.Status == JobRunning {
+			j.info.Status = JobExited
+			j.info.ExitCode = shell.ExitCode(err)
+		}
+		j.mu.Unlock()
+		close(j.done)
+		jobBroker.Publish(pubsub.UpdatedEvent, j.snapshot())
+	}()
+	return j.snapshot(), nil
+}
+
+// Write keeps the latest output of the job.
+func (j *job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.log = append(j.log, p...)
+	if over := len(j.log) - jobLogSize; over > 0 {
+		j.log = j.log[over:]
+	}
+	return len(p), nil
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// stop kills the processes of the job and waits for them to exit.
+func (j *job) stop() {
+	j.mu.Lock()
+	if j.info.Status == JobRunning {
+		j.info.Status = JobStopped
+	}
+	j.mu.Unlock()
+	j.cancel()
+	<-j.done
+}
+
+// tail returns the last lines of the output of the job.
+func (j *job) tail(lines int) string {
+	j.mu.Lock()
+	out := strings.TrimSuffix(string(j.log), "\n")
+	j.mu.Unlock()
+
+	end := len(out)
+	for range lines {
+		i := strings.LastIndexByte(out[:end], '\n')
+		if i < 0 {
+			return out
+		}
+		end = i
+	}
+	return out[end+1:]
+}
+
+// describe returns a line telling what the job runs and how it's doing.
+func (info Job) describe() string {
+	var status string
+	switch info.Status {
+	case JobRunning:
+		status = fmt.Sprintf("running for %s", time.Since(info.StartedAt).Round(time.Second))
+	case JobExited:
+		status = fmt.Sprintf("exited with code %d", info.ExitCode)
+	case JobStopped:
+		status = "stopped"
+	}
+	return fmt.Sprintf("%s (%s): %s", info.ID, status, info.Command)
+}
+
+const (
+	JobsActionList   = "list"
+	JobsActionOutput = "output"
+	JobsActionStop   = "stop"
+
+	defaultJobOutputLines = 50
+	maxJobOutputLines     = 1000
+)
+
+type JobsParams struct {
+	Action string `json:"action"`
+	ID     string `json:"id"`
+	Lines  int    `json:"lines"`
+}
+
+type jobsTool struct {
+	// The most bytes of output returned to the model
+	maxOutput int
+}
+
+func NewJobsTool(maxOutput int) BaseTool {
+	return &jobsTool{maxOutput: maxOutput}
+}
+
+func (t *jobsTool) Name() string {
+	return JobsToolName
+}
+
+func (t *jobsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobsToolName,
+		Description: string(jobsDescription),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{JobsActionList, JobsActionOutput, JobsActionStop},
+				"description": "list the jobs of the session, read the output of one, or stop one",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job, for output and stop",
+			},
+			"lines": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("How many of the last lines of output to read (default %d, max %d)", defaultJobOutputLines, maxJobOutputLines),
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *jobsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	sessionID, _ := GetContextValues(ctx)
+	if sessionID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID is required for background jobs")
+	}
+
+	if params.Action == JobsActionList {
+		sessionJobs := Jobs(sessionID)
+		if len(sessionJobs) == 0 {
+			return NewTextResponse("No background jobs. Start one with the bash tool and run_in_background."), nil
+		}
+		lines := make([]string, len(sessionJobs))
+		for i, info := range sessionJobs {
+			lines[i] = info.describe()
+		}
+		return NewTextResponse(strings.Join(lines, "\n")), nil
+	}
+
+	if params.Action != JobsActionOutput && params.Action != JobsActionStop {
+		return NewTextErrorResponse(fmt.Sprintf("unknown action %q, expected %s, %s or %s", params.Action, JobsActionList, JobsActionOutput, JobsActionStop)), nil
+	}
+	if params.ID == "" {
+		return NewTextErrorResponse("id is required"), nil
+	}
+	j, ok := jobs.Get(params.ID)
+	if !ok || j.snapshot().SessionID != sessionID {
+		return NewTextErrorResponse(fmt.Sprintf("unknown job %q, list the jobs to see their IDs", params.ID)), nil
+	}
+
+	if params.Action == JobsActionStop {
+		j.stop()
+		return NewTextResponse(j.snapshot().describe()), nil
+	}
+
+	lines := defaultJobOutputLines
+	if params.Lines > 0 {
+		lines = min(params.Lines, maxJobOutputLines)
+	}
+	output := j.tail(lines)
+	if len(output) > t.maxOutput {
+		output = "... [earlier output left out] ...\n" + output[len(output)-t.maxOutput:]
+	}
+	if output == "" {
+		output = BashNoOutput
+	}
+	return NewTextResponse(j.snapshot().describe() + "\n\n" + output), nil
+}
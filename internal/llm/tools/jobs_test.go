@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+func TestJobs(t *testing.T) {
+	bash := NewBashTool(nil, os.TempDir(), nil, config.BashCommandRules{}, nil, config.DefaultBashTimeout, config.DefaultBashMaxOutput)
+	jobsTool := NewJobsTool(config.DefaultBashMaxOutput)
+	ctx := bashTestContext(t)
+	t.Cleanup(func() { StopJobs("bash-session") })
+
+	start := time.Now()
+	resp, err := bash.Run(ctx, ToolCall{ID: "call", Name: BashToolName, Input: `{"command":"echo ready && sleep 30", "run_in_background": true}`})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	sessionJobs := Jobs("bash-session")
+	require.Len(t, sessionJobs, 1)
+	id := sessionJobs[0].ID
+	require.Contains(t, resp.Content, "Started "+id)
+	require.Equal(t, JobRunning, sessionJobs[0].Status)
+	require.Empty(t, Jobs("other-session"))
+
+	require.Eventually(t, func() bool {
+		resp, err := jobsTool.Run(ctx, ToolCall{Name: JobsToolName, Input: `{"action":"output","id":"` + id + `"}`})
+		require.NoError(t, err)
+		return resp.Content == id+" (running for 0s): echo ready && sleep 30\n\nready"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, err = jobsTool.Run(ctx, ToolCall{Name: JobsToolName, Input: `{"action":"stop","id":"` + id + `"}`})
+	require.NoError(t, err)
+	require.Equal(t, id+" (stopped): echo ready && sleep 30", resp.Content)
+	require.Less(t, time.Since(start), 10*time.Second)
+
+	_, err = bash.Run(ctx, ToolCall{ID: "call", Name: BashToolName, Input: `{"command":"echo failing; exit 3", "run_in_background": true}`})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		sessionJobs := Jobs("bash-session")
+		return len(sessionJobs) == 2 && sessionJobs[1].Status == JobExited
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, Jobs("bash-session")[1].ExitCode)
+
+	resp, err = jobsTool.Run(ctx, ToolCall{Name: JobsToolName, Input: `{"action":"list"}`})
+	require.NoError(t, err)
+	require.Contains(t, resp.Content, id+" (stopped): echo ready && sleep 30\n")
+	require.Contains(t, resp.Content, " (exited with code 3): echo failing; exit 3")
+
+	resp, err = jobsTool.Run(ctx, ToolCall{Name: JobsToolName, Input: `{"action":"output","id":"job-unknown"}`})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+
+	StopJobs("bash-session")
+	require.Empty(t, Jobs("bash-session"))
+}
+
+func TestJobTail(t *testing.T) {
+	t.Parallel()
+
+	j := &job{}
+	require.Empty(t, j.tail(5))
+
+	_, _ = j.Write([]byte("one\ntwo\nthree\n"))
+	require.Equal(t, "three", j.tail(1))
+	require.Equal(t, "two\nthree", j.tail(2))
+	require.Equal(t, "one\ntwo\nthree", j.tail(5))
+}
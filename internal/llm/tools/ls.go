@@ -99,23 +99,13 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 		return ToolResponse{}, fmt.Errorf("error expanding path: %w", err)
 	}
 
-	if !filepath.IsAbs(searchPath) {
-		searchPath = filepath.Join(l.workingDir, searchPath)
-	}
-
-	// Check if directory is outside working directory and request permission if needed
-	absWorkingDir, err := filepath.Abs(l.workingDir)
-	if err != nil {
-		return ToolResponse{}, fmt.Errorf("error resolving working directory: %w", err)
-	}
-
-	absSearchPath, err := filepath.Abs(searchPath)
+	searchPath, err = workspacePath(l.workingDir, searchPath)
 	if err != nil {
-		return ToolResponse{}, fmt.Errorf("error resolving search path: %w", err)
+		return NewTextErrorResponse(err.Error()), nil
 	}
 
-	relPath, err := filepath.Rel(absWorkingDir, absSearchPath)
-	if err != nil || strings.HasPrefix(relPath, "..") {
+	// Check if directory is outside the workspace and request permission if needed
+	if !inWorkspace(l.workingDir, searchPath) {
 		// Directory is outside working directory, request permission
 		sessionID, messageID := GetContextValues(ctx)
 		if sessionID == "" || messageID == "" {
@@ -125,11 +115,11 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 		granted := l.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
-				Path:        absSearchPath,
+				Path:        searchPath,
 				ToolCallID:  call.ID,
 				ToolName:    LSToolName,
 				Action:      "list",
-				Description: fmt.Sprintf("List directory outside working directory: %s", absSearchPath),
+				Description: fmt.Sprintf("List directory outside working directory: %s", searchPath),
 				Params:      LSPermissionsParams(params),
 			},
 		)
@@ -150,6 +140,8 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 	), nil
 }
 
+// ListDirectoryTree renders the contents of searchPath as a tree. Paths
+// matched by .gitignore files are left out.
 func ListDirectoryTree(searchPath string, params LSParams) (string, LSResponseMetadata, error) {
 	if _, err := os.Stat(searchPath); os.IsNotExist(err) {
 		return "", LSResponseMetadata{}, fmt.Errorf("path does not exist: %s", searchPath)
@@ -122,9 +122,11 @@ func (m *multiEditTool) Run(ctx context.Context, call ToolCall) (ToolResponse, e
 		return NewTextErrorResponse("at least one edit operation is required"), nil
 	}
 
-	if !filepath.IsAbs(params.FilePath) {
-		params.FilePath = filepath.Join(m.workingDir, params.FilePath)
+	filePath, err := workspacePath(m.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
 	}
+	params.FilePath = filePath
 
 	// Validate all edits before applying any
 	if err := m.validateEdits(params.Edits); err != nil {
@@ -132,7 +134,6 @@ func (m *multiEditTool) Run(ctx context.Context, call ToolCall) (ToolResponse, e
 	}
 
 	var response ToolResponse
-	var err error
 
 	// Handle file creation case (first edit has empty old_string)
 	if len(params.Edits) > 0 && params.Edits[0].OldString == "" {
@@ -232,13 +233,13 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 	}
 
 	// Write the file
-	err := os.WriteFile(params.FilePath, []byte(currentContent), 0o644)
+	err := fsext.WriteFileAtomic(params.FilePath, []byte(currentContent), 0o644)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
 	// Update file history
-	_, err = m.files.Create(ctx, sessionID, params.FilePath, "")
+	_, err = m.files.CreateNew(ctx, sessionID, params.FilePath)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
 	}
@@ -329,7 +330,7 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 		ToolCallID:  call.ID,
 		ToolName:    MultiEditToolName,
 		Action:      "write",
-		Description: fmt.Sprintf("Apply %d edits to file %s", len(params.Edits), params.FilePath),
+		Description: backupDescription(fmt.Sprintf("Apply %d edits to file %s", len(params.Edits), params.FilePath)),
 		Params: MultiEditPermissionsParams{
 			FilePath:   params.FilePath,
 			OldContent: oldContent,
@@ -344,8 +345,12 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 		currentContent, _ = fsext.ToWindowsLineEndings(currentContent)
 	}
 
+	if err = backupFile(params.FilePath); err != nil {
+		return ToolResponse{}, err
+	}
+
 	// Write the updated content
-	err = os.WriteFile(params.FilePath, []byte(currentContent), 0o644)
+	err = fsext.WriteFileAtomic(params.FilePath, []byte(currentContent), fileInfo.Mode().Perm())
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
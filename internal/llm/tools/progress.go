@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+const ProgressToolName = "progress"
+
+//go:embed progress.md
+var progressDescription []byte
+
+type ProgressParams struct {
+	Step        int    `json:"step"`
+	Total       int    `json:"total"`
+	Description string `json:"description"`
+}
+
+type ProgressResponseMetadata struct {
+	Step        int    `json:"step"`
+	Total       int    `json:"total"`
+	Description string `json:"description"`
+}
+
+type progressTool struct{}
+
+func NewProgressTool() BaseTool {
+	return &progressTool{}
+}
+
+func (p *progressTool) Name() string {
+	return ProgressToolName
+}
+
+func (p *progressTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ProgressToolName,
+		Description: string(progressDescription),
+		Parameters: map[string]any{
+			"step": map[string]any{
+				"type":        "integer",
+				"description": "The step of the plan you start, from 1",
+			},
+			"total": map[string]any{
+				"type":        "integer",
+				"description": "How many steps the plan has",
+			},
+			"description": map[string]any{
+				"type":        "string",
+				"description": "What the step does, in a few words",
+			},
+		},
+		Required: []string{"step", "total"},
+	}
+}
+
+func (p *progressTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ProgressParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Total < 1 {
+		return NewTextErrorResponse("total must be at least 1"), nil
+	}
+	if params.Step < 1 || params.Step > params.Total {
+		return NewTextErrorResponse(fmt.Sprintf("step must be between 1 and %d", params.Total)), nil
+	}
+
+	output := fmt.Sprintf("Started step %d of %d", params.Step, params.Total)
+	if params.Description != "" {
+		output += ": " + params.Description
+	}
+	return WithResponseMetadata(
+		NewTextResponse(output),
+		ProgressResponseMetadata(params),
+	), nil
+}
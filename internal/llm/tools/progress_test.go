@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTool(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "step", input: `{"step":2,"total":5,"description":"Update the tests"}`, want: "Started step 2 of 5: Update the tests"},
+		{name: "without description", input: `{"step":1,"total":1}`, want: "Started step 1 of 1"},
+		{name: "no total", input: `{"step":1}`, want: "total must be at least 1", wantErr: true},
+		{name: "step after the last", input: `{"step":6,"total":5}`, want: "step must be between 1 and 5", wantErr: true},
+		{name: "step zero", input: `{"step":0,"total":5}`, want: "step must be between 1 and 5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := NewProgressTool().Run(t.Context(), ToolCall{Name: ProgressToolName, Input: tt.input})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, resp.Content)
+			require.Equal(t, tt.wantErr, resp.IsError)
+			if tt.wantErr {
+				return
+			}
+			var metadata ProgressResponseMetadata
+			require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &metadata))
+			var params ProgressParams
+			require.NoError(t, json.Unmarshal([]byte(tt.input), &params))
+			require.Equal(t, ProgressResponseMetadata(params), metadata)
+		})
+	}
+}
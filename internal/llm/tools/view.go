@@ -96,24 +96,13 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	}
 
 	// Handle relative paths
-	filePath := params.FilePath
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(v.workingDir, filePath)
-	}
-
-	// Check if file is outside working directory and request permission if needed
-	absWorkingDir, err := filepath.Abs(v.workingDir)
-	if err != nil {
-		return ToolResponse{}, fmt.Errorf("error resolving working directory: %w", err)
-	}
-
-	absFilePath, err := filepath.Abs(filePath)
+	filePath, err := workspacePath(v.workingDir, params.FilePath)
 	if err != nil {
-		return ToolResponse{}, fmt.Errorf("error resolving file path: %w", err)
+		return NewTextErrorResponse(err.Error()), nil
 	}
 
-	relPath, err := filepath.Rel(absWorkingDir, absFilePath)
-	if err != nil || strings.HasPrefix(relPath, "..") {
+	// Check if file is outside the workspace and request permission if needed
+	if !inWorkspace(v.workingDir, filePath) {
 		// File is outside working directory, request permission
 		sessionID, messageID := GetContextValues(ctx)
 		if sessionID == "" || messageID == "" {
@@ -123,11 +112,11 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		granted := v.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
-				Path:        absFilePath,
+				Path:        filePath,
 				ToolCallID:  call.ID,
 				ToolName:    ViewToolName,
 				Action:      "read",
-				Description: fmt.Sprintf("Read file outside working directory: %s", absFilePath),
+				Description: fmt.Sprintf("Read file outside working directory: %s", filePath),
 				Params:      ViewPermissionsParams(params),
 			},
 		)
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+// workspaceRoots returns workingDir followed by the other workspace roots
+// from the config.
+func workspaceRoots(workingDir string) []string {
+	roots := []string{workingDir}
+	cfg := config.Get()
+	if cfg == nil {
+		return roots
+	}
+	for _, root := range cfg.WorkspaceRoots() {
+		if !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// resolvePath makes path absolute. A relative path is resolved against the
+// working directory, or against the first other workspace root it exists in
+// when it doesn't exist in the working directory.
+func resolvePath(workingDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	resolved := filepath.Join(workingDir, path)
+	if _, err := os.Stat(resolved); err == nil {
+		return resolved
+	}
+	for _, root := range workspaceRoots(workingDir)[1:] {
+		if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+			return filepath.Join(root, path)
+		}
+	}
+	return resolved
+}
+
+// inWorkspace reports whether the absolute path is inside the working
+// directory or one of the other workspace roots.
+func inWorkspace(workingDir, path string) bool {
+	for _, root := range workspaceRoots(workingDir) {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOutsideWorkspace reports whether file tools may access paths outside
+// the workspace roots, after asking for permission.
+func allowOutsideWorkspace() bool {
+	cfg := config.Get()
+	return cfg != nil && cfg.Options != nil && cfg.Options.AllowOutsideWorkspace
+}
+
+// workspacePath resolves path like resolvePath and makes it absolute. Unless
+// the config allows it, it returns an error for paths outside the workspace
+// roots, including the ones that only get there through a symlink.
+func workspacePath(workingDir, path string) (string, error) {
+	resolved, err := filepath.Abs(resolvePath(workingDir, path))
+	if err != nil {
+		return "", fmt.Errorf("error resolving path: %w", err)
+	}
+	if allowOutsideWorkspace() {
+		return resolved, nil
+	}
+	roots := workspaceRoots(workingDir)
+	if !inRoots(roots, resolved) {
+		return "", fmt.Errorf("%s is outside the workspace, file tools may only access %s", path, strings.Join(roots, ", "))
+	}
+	return resolved, nil
+}
+
+// inRoots reports whether path is inside one of roots once the symlinks of
+// both are followed.
+func inRoots(roots []string, path string) bool {
+	resolved, err := realPath(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range roots {
+		root, err := realPath(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath returns the absolute path with its symlinks followed. The part of
+// the path that doesn't exist yet, like a file about to be written, is kept
+// as it is.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		parent := filepath.Dir(path)
+		if !errors.Is(err, fs.ErrNotExist) || parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspacePath(t *testing.T) {
+	t.Parallel()
+
+	// The temporary directory may itself be behind a symlink, like on macOS.
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	workingDir := filepath.Join(base, "project")
+	outside := filepath.Join(base, "secrets")
+	require.NoError(t, os.MkdirAll(filepath.Join(workingDir, "src"), 0o755))
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "src", "main.go"), []byte("package main"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "id_rsa"), []byte("key"), 0o600))
+	require.NoError(t, os.Symlink(outside, filepath.Join(workingDir, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "id_rsa"), filepath.Join(workingDir, "key")))
+	require.NoError(t, os.Symlink(filepath.Join(workingDir, "src"), filepath.Join(workingDir, "source")))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "relative", path: "src/main.go", want: filepath.Join(workingDir, "src", "main.go")},
+		{name: "new file", path: "src/new/file.go", want: filepath.Join(workingDir, "src", "new", "file.go")},
+		{name: "absolute inside", path: filepath.Join(workingDir, "src"), want: filepath.Join(workingDir, "src")},
+		{name: "working directory", path: ".", want: workingDir},
+		{name: "dot dot staying inside", path: "src/../src/main.go", want: filepath.Join(workingDir, "src", "main.go")},
+		{name: "symlink inside", path: "source/main.go", want: filepath.Join(workingDir, "source", "main.go")},
+		{name: "dot dot traversal", path: "../secrets/id_rsa"},
+		{name: "dot dot to parent", path: ".."},
+		{name: "absolute outside", path: filepath.Join(outside, "id_rsa")},
+		{name: "symlinked directory escape", path: "escape/id_rsa"},
+		{name: "symlinked file escape", path: "key"},
+		{name: "new file through symlink escape", path: "escape/authorized_keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := workspacePath(workingDir, tt.path)
+			if tt.want == "" {
+				require.ErrorContains(t, err, "is outside the workspace")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInRootsSiblingPrefix(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	require.False(t, inRoots([]string{filepath.Join(base, "app")}, filepath.Join(base, "app-secrets", "key")))
+	require.True(t, inRoots([]string{filepath.Join(base, "app")}, filepath.Join(base, "app", "..file")))
+}
@@ -94,9 +94,9 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return NewTextErrorResponse("content is required"), nil
 	}
 
-	filePath := params.FilePath
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(w.workingDir, filePath)
+	filePath, err := workspacePath(w.workingDir, params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
 	}
 
 	fileInfo, err := os.Stat(filePath)
@@ -144,6 +144,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		strings.TrimPrefix(filePath, w.workingDir),
 	)
 
+	description := fmt.Sprintf("Create file %s", filePath)
+	if fileInfo != nil {
+		description = backupDescription(fmt.Sprintf("Overwrite file %s", filePath))
+	}
+
 	p := w.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
@@ -151,7 +156,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 			ToolCallID:  call.ID,
 			ToolName:    WriteToolName,
 			Action:      "write",
-			Description: fmt.Sprintf("Create file %s", filePath),
+			Description: description,
 			Params: WritePermissionsParams{
 				FilePath:   filePath,
 				OldContent: oldContent,
@@ -163,7 +168,17 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
-	err = os.WriteFile(filePath, []byte(params.Content), 0o644)
+	if fileInfo != nil {
+		if err = backupFile(filePath); err != nil {
+			return ToolResponse{}, err
+		}
+	}
+
+	perm := os.FileMode(0o644)
+	if fileInfo != nil {
+		perm = fileInfo.Mode().Perm()
+	}
+	err = fsext.WriteFileAtomic(filePath, []byte(params.Content), perm)
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error writing file: %w", err)
 	}
@@ -171,7 +186,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	// Check if file exists in history
 	file, err := w.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		if fileInfo == nil {
+			_, err = w.files.CreateNew(ctx, sessionID, filePath)
+		} else {
+			_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		}
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
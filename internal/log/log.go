@@ -9,30 +9,81 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/charmbracelet/log/v2"
+	"github.com/charmbracelet/x/term"
 	"github.com/tulpa-code/tulpa/internal/event"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// LevelEnv is the environment variable overriding the configured log level.
+const LevelEnv = "TULPA_LOG_LEVEL"
+
 var (
 	initOnce    sync.Once
 	initialized atomic.Bool
 )
 
-func Setup(logFile string, debug bool) {
+// Init installs the logger used until Setup is called, so messages logged
+// before the configuration is loaded aren't lost. They are written to stderr
+// as text when it is a terminal and as JSON otherwise, from the level in
+// [LevelEnv] or warnings up. An invalid level is reported once the
+// configuration is loaded.
+func Init() {
+	level, err := ResolveLevel(false, "", false, slog.LevelWarn)
+	if err != nil {
+		level = slog.LevelWarn
+	}
+
+	var handler slog.Handler
+	if term.IsTerminal(os.Stderr.Fd()) {
+		handler = log.NewWithOptions(os.Stderr, log.Options{
+			Level:           log.Level(level),
+			ReportTimestamp: true,
+			TimeFormat:      time.TimeOnly,
+		})
+	} else {
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// ResolveLevel returns the log level from, in order of precedence: the
+// --debug flag, [LevelEnv], the configured level (options.log_level) and the
+// debug option (options.debug). When none is set, it's fallback.
+func ResolveLevel(debugFlag bool, configured string, configDebug bool, fallback slog.Level) (slog.Level, error) {
+	if debugFlag {
+		return slog.LevelDebug, nil
+	}
+	name := os.Getenv(LevelEnv)
+	if name == "" {
+		name = configured
+	}
+	if name == "" {
+		if configDebug {
+			return slog.LevelDebug, nil
+		}
+		return fallback, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
+
+// Setup sends the logs to logFile as JSON, replacing the logger installed
+// by Init. The file is rotated when it reaches 10MB, and rotated files are
+// removed after 30 days.
+func Setup(logFile string, level slog.Level) {
 	initOnce.Do(func() {
 		logRotator := &lumberjack.Logger{
 			Filename:   logFile,
 			MaxSize:    10,    // Max size in MB
-			MaxBackups: 0,     // Number of backups
+			MaxBackups: 5,     // Number of backups
 			MaxAge:     30,    // Days
 			Compress:   false, // Enable compression
 		}
 
-		level := slog.LevelInfo
-		if debug {
-			level = slog.LevelDebug
-		}
-
 		logger := slog.NewJSONHandler(logRotator, &slog.HandlerOptions{
 			Level:     level,
 			AddSource: true,
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLevel(t *testing.T) {
+	tests := []struct {
+		name        string
+		debugFlag   bool
+		env         string
+		configured  string
+		configDebug bool
+		want        slog.Level
+		wantErr     bool
+	}{
+		{name: "default", want: slog.LevelWarn},
+		{name: "configured", configured: "error", want: slog.LevelError},
+		{name: "case insensitive", configured: "ERROR", want: slog.LevelError},
+		{name: "config debug", configDebug: true, want: slog.LevelDebug},
+		{name: "configured overrides config debug", configured: "error", configDebug: true, want: slog.LevelError},
+		{name: "env overrides config", env: "debug", configured: "error", want: slog.LevelDebug},
+		{name: "env overrides config debug", env: "error", configDebug: true, want: slog.LevelError},
+		{name: "flag overrides env", debugFlag: true, env: "error", configured: "error", want: slog.LevelDebug},
+		{name: "flag overrides invalid env", debugFlag: true, env: "loud", want: slog.LevelDebug},
+		{name: "invalid config", configured: "loud", wantErr: true},
+		{name: "invalid env", env: "loud", configured: "error", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(LevelEnv, tt.env)
+			level, err := ResolveLevel(tt.debugFlag, tt.configured, tt.configDebug, slog.LevelWarn)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "invalid log level")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, level)
+		})
+	}
+}
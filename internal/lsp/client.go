@@ -404,6 +404,13 @@ func (c *Client) DidChangeWatchedFiles(ctx context.Context, params protocol.DidC
 	return c.client.NotifyDidChangeWatchedFiles(ctx, params.Changes)
 }
 
+// Ping checks that the language server still accepts messages. It sends an
+// empty workspace/didChangeWatchedFiles notification, which servers treat as
+// a no-op but which fails once the server process has exited.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.client.NotifyDidChangeWatchedFiles(ctx, nil)
+}
+
 // openKeyConfigFiles opens important configuration files that help initialize the server.
 func (c *Client) openKeyConfigFiles(ctx context.Context) {
 	wd, err := os.Getwd()
@@ -0,0 +1,106 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/env"
+)
+
+// fakeServerEnv makes the test binary act as a language server writing the
+// params of the initialize request to the file it names.
+const fakeServerEnv = "TULPA_FAKE_LSP_OUTPUT"
+
+func TestMain(m *testing.M) {
+	if out := os.Getenv(fakeServerEnv); out != "" {
+		runFakeServer(out)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeServer answers every request on stdin with an empty result until
+// it receives the exit notification.
+func runFakeServer(out string) {
+	r := bufio.NewReader(os.Stdin)
+	for {
+		var length int
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if v, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+				length, _ = strconv.Atoi(v)
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		result := "null"
+		switch msg.Method {
+		case "initialize":
+			_ = os.WriteFile(out, msg.Params, 0o600)
+			result = `{"capabilities":{}}`
+		case "exit":
+			return
+		}
+		if msg.ID == nil {
+			continue
+		}
+		resp := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, msg.ID, result)
+		fmt.Fprintf(os.Stdout, "Content-Length: %d\r\n\r\n%s", len(resp), resp)
+	}
+}
+
+func TestInitializeSendsInitOptions(t *testing.T) {
+	t.Parallel()
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	out := filepath.Join(t.TempDir(), "initialize.json")
+
+	cfg := config.LSPConfig{
+		Command: exe,
+		Env:     map[string]string{fakeServerEnv: out},
+		InitOptions: map[string]any{
+			"buildFlags": []any{"-tags=integration"},
+		},
+	}
+	client, err := New(t.Context(), "fake", cfg, config.NewEnvironmentVariableResolver(env.NewFromMap(nil)))
+	require.NoError(t, err)
+	_, err = client.Initialize(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close(t.Context()) })
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var params struct {
+		InitializationOptions map[string]any `json:"initializationOptions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &params))
+	require.Equal(t, cfg.InitOptions, params.InitializationOptions)
+}
@@ -1,8 +1,57 @@
 package message
 
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxAttachmentSize is the size of the largest file that can be attached to a
+// prompt.
+const MaxAttachmentSize = int64(5 * 1024 * 1024) // 5MB
+
 type Attachment struct {
 	FilePath string
 	FileName string
 	MimeType string
 	Content  []byte
 }
+
+// ReadAttachment reads the file at path as an attachment, detecting its MIME
+// type from its content. Files larger than maxSize are rejected.
+func ReadAttachment(path string, maxSize int64) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %s is a directory", path)
+	}
+	if info.Size() > maxSize {
+		return Attachment{}, fmt.Errorf("attachment %s is too large: %d bytes, max %d", path, info.Size(), maxSize)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	mimeBufferSize := min(512, len(content))
+	return Attachment{
+		FilePath: path,
+		FileName: filepath.Base(path),
+		MimeType: http.DetectContentType(content[:mimeBufferSize]),
+		Content:  content,
+	}, nil
+}
+
+// IsImage reports whether the attachment is an image.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// IsText reports whether the attachment is text, which is sent as part of the
+// prompt rather than as binary content.
+func (a Attachment) IsText() bool {
+	return strings.HasPrefix(a.MimeType, "text/")
+}
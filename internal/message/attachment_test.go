@@ -0,0 +1,54 @@
+package message
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAttachment(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shot.png"), png, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "build.log"), []byte("error: undefined: foo\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "doc.pdf"), []byte("%PDF-1.7\n"), 0o644))
+
+	tests := []struct {
+		name    string
+		file    string
+		maxSize int64
+		mime    string
+		image   bool
+		text    bool
+		err     string
+	}{
+		{name: "image", file: "shot.png", maxSize: MaxAttachmentSize, mime: "image/png", image: true},
+		{name: "text", file: "build.log", maxSize: MaxAttachmentSize, mime: "text/plain; charset=utf-8", text: true},
+		{name: "other", file: "doc.pdf", maxSize: MaxAttachmentSize, mime: "application/pdf"},
+		{name: "too large", file: "build.log", maxSize: 4, err: "is too large: 22 bytes, max 4"},
+		{name: "missing", file: "nope.txt", maxSize: MaxAttachmentSize, err: "no such file"},
+		{name: "directory", file: ".", maxSize: MaxAttachmentSize, err: "is a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(dir, tt.file)
+			attachment, err := ReadAttachment(path, tt.maxSize)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, path, attachment.FilePath)
+			require.Equal(t, filepath.Base(path), attachment.FileName)
+			require.Equal(t, tt.mime, attachment.MimeType)
+			require.Equal(t, tt.image, attachment.IsImage())
+			require.Equal(t, tt.text, attachment.IsText())
+		})
+	}
+}
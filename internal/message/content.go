@@ -113,6 +113,15 @@ type Finish struct {
 
 func (Finish) isPart() {}
 
+// Summary marks a message summarizing the conversation before it. When the
+// conversation was compacted, the messages from KeptFrom on were kept and
+// follow the summary in the conversation sent to the model.
+type Summary struct {
+	KeptFrom string `json:"kept_from,omitempty"`
+}
+
+func (Summary) isPart() {}
+
 type Message struct {
 	ID        string
 	Role      MessageRole
@@ -120,6 +129,8 @@ type Message struct {
 	Parts     []ContentPart
 	Model     string
 	Provider  string
+	// Kept as it is when the conversation is compacted
+	Pinned    bool
 	CreatedAt int64
 	UpdatedAt int64
 }
@@ -200,6 +211,15 @@ func (m *Message) FinishPart() *Finish {
 	return nil
 }
 
+func (m *Message) SummaryPart() *Summary {
+	for _, part := range m.Parts {
+		if c, ok := part.(Summary); ok {
+			return &c
+		}
+	}
+	return nil
+}
+
 func (m *Message) FinishReason() FinishReason {
 	for _, part := range m.Parts {
 		if c, ok := part.(Finish); ok {
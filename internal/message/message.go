@@ -23,21 +23,29 @@ type Service interface {
 	pubsub.Suscriber[Message]
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
 	Update(ctx context.Context, message Message) error
+	// SetPinned pins or unpins a message. Compacting the conversation keeps
+	// pinned messages as they are.
+	SetPinned(ctx context.Context, id string, pinned bool) error
 	Get(ctx context.Context, id string) (Message, error)
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	Search(ctx context.Context, query string, opts SearchOpts) ([]MessageHit, error)
 }
 
 type service struct {
 	*pubsub.Broker[Message]
-	q db.Querier
+	q        db.Querier
+	redactor *Redactor
 }
 
-func NewService(q db.Querier) Service {
+// NewService returns a message service that redacts secrets from tool
+// results with redactor before storing them. redactor may be nil.
+func NewService(q db.Querier, redactor *Redactor) Service {
 	return &service{
-		Broker: pubsub.NewBroker[Message](),
-		q:      q,
+		Broker:   pubsub.NewBroker[Message](),
+		q:        q,
+		redactor: redactor,
 	}
 }
 
@@ -60,6 +68,7 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 			Reason: "stop",
 		})
 	}
+	params.Parts = s.redactor.redactParts(params.Parts)
 	partsJSON, err := marshallParts(params.Parts)
 	if err != nil {
 		return Message{}, err
@@ -100,6 +109,7 @@ func (s *service) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 func (s *service) Update(ctx context.Context, message Message) error {
+	message.Parts = s.redactor.redactParts(message.Parts)
 	parts, err := marshallParts(message.Parts)
 	if err != nil {
 		return err
@@ -122,6 +132,19 @@ func (s *service) Update(ctx context.Context, message Message) error {
 	return nil
 }
 
+func (s *service) SetPinned(ctx context.Context, id string, pinned bool) error {
+	message, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.q.SetMessagePinned(ctx, db.SetMessagePinnedParams{ID: id, Pinned: pinned}); err != nil {
+		return err
+	}
+	message.Pinned = pinned
+	s.Publish(pubsub.UpdatedEvent, message)
+	return nil
+}
+
 func (s *service) Get(ctx context.Context, id string) (Message, error) {
 	dbMessage, err := s.q.GetMessage(ctx, id)
 	if err != nil {
@@ -157,6 +180,7 @@ func (s *service) fromDBItem(item db.Message) (Message, error) {
 		Parts:     parts,
 		Model:     item.Model.String,
 		Provider:  item.Provider.String,
+		Pinned:    item.Pinned,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}, nil
@@ -172,6 +196,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	summaryType    partType = "summary"
 )
 
 type partWrapper struct {
@@ -200,6 +225,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case Summary:
+			typ = summaryType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -273,6 +300,12 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case summaryType:
+			part := Summary{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
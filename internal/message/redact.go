@@ -0,0 +1,123 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Redacted replaces the secrets found in tool output.
+const Redacted = "[REDACTED]"
+
+type redactPattern struct {
+	re *regexp.Regexp
+	// replacement is expanded like in [regexp.Regexp.ReplaceAllString], so
+	// detectors can keep the name of the variable holding a secret.
+	replacement string
+}
+
+// builtinRedactPatterns detect common credential formats.
+var builtinRedactPatterns = []redactPattern{
+	// AWS access key IDs.
+	{re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), replacement: Redacted},
+	// OpenAI and Anthropic style API keys.
+	{re: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`), replacement: Redacted},
+	// GitHub tokens.
+	{re: regexp.MustCompile(`\b(?:gh[pousr]|github_pat)_[A-Za-z0-9_]{30,}`), replacement: Redacted},
+	// Google API keys.
+	{re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`), replacement: Redacted},
+	// Slack tokens.
+	{re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), replacement: Redacted},
+	// PEM private keys.
+	{re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), replacement: Redacted},
+	// Values of environment variables named like secrets, in env or .env
+	// output: lines like NAME=value, where NAME is in uppercase. Quotes are
+	// left alone.
+	{re: regexp.MustCompile(`(?m)^((?:export )?[A-Z0-9_]*(?:API_KEY|SECRET|TOKEN|PASSWORD)(?:_[A-Z0-9_]*)?=["']?)[^\s"']+`), replacement: "${1}" + Redacted},
+}
+
+// Redactor replaces secrets in tool results before they are stored. A nil
+// Redactor leaves them as they are.
+type Redactor struct {
+	patterns []redactPattern
+}
+
+// NewRedactor returns a Redactor using the built-in detectors and the given
+// regular expressions, whose matches are replaced as a whole.
+func NewRedactor(patterns []string) (*Redactor, error) {
+	r := &Redactor{patterns: append([]redactPattern{}, builtinRedactPatterns...)}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, redactPattern{re: re, replacement: Redacted})
+	}
+	return r, nil
+}
+
+// Redact replaces the secrets in s with [Redacted].
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// redactParts redacts the content and metadata of the tool results in parts.
+func (r *Redactor) redactParts(parts []ContentPart) []ContentPart {
+	if r == nil {
+		return parts
+	}
+	redacted := make([]ContentPart, len(parts))
+	for i, part := range parts {
+		if result, ok := part.(ToolResult); ok {
+			result.Content = r.Redact(result.Content)
+			result.Metadata = r.RedactJSON(result.Metadata)
+			part = result
+		}
+		redacted[i] = part
+	}
+	return redacted
+}
+
+// RedactJSON redacts the strings of the JSON document s, so that detectors
+// see the lines of the output it holds. s is redacted as text when it isn't
+// JSON.
+func (r *Redactor) RedactJSON(s string) string {
+	if r == nil {
+		return s
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return r.Redact(s)
+	}
+	data, err := json.Marshal(r.redactValue(doc))
+	if err != nil {
+		return r.Redact(s)
+	}
+	return string(data)
+}
+
+// redactValue redacts the strings of a decoded JSON value.
+func (r *Redactor) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v)
+	case map[string]any:
+		for key, value := range v {
+			v[key] = r.redactValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = r.redactValue(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tulpa-code/tulpa/internal/db"
+)
+
+// DefaultSearchLimit is how many hits a search returns when the options set
+// no limit.
+const DefaultSearchLimit = 50
+
+// snippetContext is how many bytes of text are kept on each side of a match
+// in a snippet.
+const snippetContext = 60
+
+// SearchOpts narrows a message search. Zero values don't filter.
+type SearchOpts struct {
+	SessionID string
+	Role      MessageRole
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// MessageHit is a message matching a search. Snippet is the text around the
+// first match, which is Snippet[MatchStart:MatchEnd].
+type MessageHit struct {
+	SessionID  string      `json:"session_id"`
+	MessageID  string      `json:"message_id"`
+	Role       MessageRole `json:"role"`
+	CreatedAt  int64       `json:"created_at"`
+	Snippet    string      `json:"snippet"`
+	MatchStart int         `json:"match_start"`
+	MatchEnd   int         `json:"match_end"`
+}
+
+// Search returns the messages whose text, tool calls or tool results contain
+// query, ignoring case, newest first.
+func (s *service) Search(ctx context.Context, query string, opts SearchOpts) ([]MessageHit, error) {
+	if query == "" {
+		return nil, nil
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	params := db.SearchMessagesParams{
+		Pattern:   "%" + likeEscape(jsonEscape(query)) + "%",
+		SessionID: sql.NullString{String: opts.SessionID, Valid: opts.SessionID != ""},
+		Role:      sql.NullString{String: string(opts.Role), Valid: opts.Role != ""},
+		Since:     sql.NullInt64{Int64: opts.Since.Unix(), Valid: !opts.Since.IsZero()},
+		Until:     sql.NullInt64{Int64: opts.Until.Unix(), Valid: !opts.Until.IsZero()},
+	}
+	rows, err := s.q.SearchMessages(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []MessageHit{}
+	for _, row := range rows {
+		msg, err := s.fromDBItem(row)
+		if err != nil {
+			return nil, err
+		}
+		// The query matched the stored JSON, which also has the part
+		// types and field names, so check the text itself.
+		hit, ok := searchHit(msg, query)
+		if !ok {
+			continue
+		}
+		hits = append(hits, hit)
+		if len(hits) == limit {
+			break
+		}
+	}
+	return hits, nil
+}
+
+// searchHit returns the hit for the first match of query in msg.
+func searchHit(msg Message, query string) (MessageHit, bool) {
+	for _, text := range searchableText(msg) {
+		i := indexFold(text, query)
+		if i < 0 {
+			continue
+		}
+		snippet, start := snippetAround(text, i, i+len(query))
+		return MessageHit{
+			SessionID:  msg.SessionID,
+			MessageID:  msg.ID,
+			Role:       msg.Role,
+			CreatedAt:  msg.CreatedAt,
+			Snippet:    snippet,
+			MatchStart: start,
+			MatchEnd:   start + len(query),
+		}, true
+	}
+	return MessageHit{}, false
+}
+
+func searchableText(msg Message) []string {
+	var texts []string
+	if text := msg.Content().Text; text != "" {
+		texts = append(texts, text)
+	}
+	for _, call := range msg.ToolCalls() {
+		texts = append(texts, call.Input)
+	}
+	for _, result := range msg.ToolResults() {
+		texts = append(texts, result.Content)
+	}
+	return texts
+}
+
+// snippetAround returns the text around text[start:end] on a single line,
+// and where the match starts in it.
+func snippetAround(text string, start, end int) (string, int) {
+	from := max(start-snippetContext, 0)
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	to := min(end+snippetContext, len(text))
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	var prefix, suffix string
+	if from > 0 {
+		prefix = "…"
+	}
+	if to < len(text) {
+		suffix = "…"
+	}
+	flatten := strings.NewReplacer("\r", " ", "\n", " ", "\t", " ")
+	snippet := prefix + flatten.Replace(text[from:to]) + suffix
+	return snippet, len(prefix) + start - from
+}
+
+// indexFold returns the index of the first match of substr in s ignoring
+// case, or -1.
+func indexFold(s, substr string) int {
+	for i := range s {
+		if len(s)-i < len(substr) {
+			break
+		}
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// jsonEscape escapes s the way it is stored in the JSON of message parts.
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// likeEscape escapes the LIKE wildcards in s, using ! as the escape
+// character.
+func likeEscape(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
@@ -0,0 +1,132 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/db"
+)
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	for _, id := range []string{"s1", "s2"} {
+		_, err := q.CreateSession(t.Context(), db.CreateSessionParams{ID: id, Title: id})
+		require.NoError(t, err)
+	}
+
+	svc := NewService(q, nil)
+	create := func(sessionID string, role MessageRole, parts ...ContentPart) Message {
+		msg, err := svc.Create(t.Context(), sessionID, CreateMessageParams{Role: role, Parts: parts})
+		require.NoError(t, err)
+		return msg
+	}
+	question := create("s1", User, TextContent{Text: "Where is the Retry policy defined?"})
+	call := create("s1", Assistant, ToolCall{ID: "c1", Name: "grep", Input: `{"pattern":"retryPolicy"}`, Finished: true})
+	result := create("s1", Tool, ToolResult{ToolCallID: "c1", Content: "internal/llm/retry.go:12: var retryPolicy = \"100%_done\""})
+	other := create("s2", User, TextContent{Text: "Is the retry policy\nconfigurable?"})
+
+	tests := []struct {
+		name  string
+		query string
+		opts  SearchOpts
+		want  []string
+	}{
+		{
+			name:  "ignores case",
+			query: "retry policy",
+			want:  []string{question.ID, other.ID},
+		},
+		{
+			name:  "tool calls and results",
+			query: "retrypolicy",
+			want:  []string{call.ID, result.ID},
+		},
+		{
+			name:  "wildcards are literal",
+			query: `100%_`,
+			want:  []string{result.ID},
+		},
+		{
+			name:  "quotes are matched in the text",
+			query: `"100%`,
+			want:  []string{result.ID},
+		},
+		{
+			name:  "part fields are not matched",
+			query: "tool_call_id",
+		},
+		{
+			name:  "by session",
+			query: "retry policy",
+			opts:  SearchOpts{SessionID: "s2"},
+			want:  []string{other.ID},
+		},
+		{
+			name:  "by role",
+			query: "retry",
+			opts:  SearchOpts{Role: Tool},
+			want:  []string{result.ID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			hits, err := svc.Search(t.Context(), tt.query, tt.opts)
+			require.NoError(t, err)
+			var got []string
+			for _, hit := range hits {
+				require.True(t, strings.EqualFold(hit.Snippet[hit.MatchStart:hit.MatchEnd], tt.query), hit.Snippet)
+				got = append(got, hit.MessageID)
+			}
+			// Messages created in the same second come back in any order.
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+
+	hits, err := svc.Search(t.Context(), "retry", SearchOpts{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, hits, 3)
+}
+
+func TestSnippetAround(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("é", 100) + "needle" + strings.Repeat("ü", 100)
+	tests := []struct {
+		name      string
+		text      string
+		match     string
+		want      string
+		wantStart int
+	}{
+		{
+			name:  "short text",
+			text:  "find the needle\nhere",
+			match: "needle",
+			want:  "find the needle here",
+		},
+		{
+			name:  "cut on both ends",
+			text:  long,
+			match: "needle",
+			want:  "…" + strings.Repeat("é", 30) + "needle" + strings.Repeat("ü", 30) + "…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := strings.Index(tt.text, tt.match)
+			snippet, i := snippetAround(tt.text, start, start+len(tt.match))
+			require.Equal(t, tt.want, snippet)
+			require.Equal(t, tt.match, snippet[i:i+len(tt.match)])
+		})
+	}
+}
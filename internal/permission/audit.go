@@ -0,0 +1,109 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/message"
+)
+
+// Reasons recorded in the audit log for a decision.
+const (
+	ReasonRule        = "rule"
+	ReasonAllowlist   = "allowlist"
+	ReasonSkip        = "skip"
+	ReasonAutoApprove = "auto_approve_session"
+	ReasonSession     = "session_grant"
+	ReasonRemembered  = "remembered"
+	ReasonUser        = "user"
+)
+
+// AuditLogPath returns the path of the audit log in dataDir.
+func AuditLogPath(dataDir string) string {
+	return filepath.Join(dataDir, "audit.jsonl")
+}
+
+// AuditEntry is a line of the audit log.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	SessionID  string    `json:"session_id"`
+	ToolCallID string    `json:"tool_call_id,omitempty"`
+	ToolName   string    `json:"tool_name"`
+	Action     string    `json:"action"`
+	Params     any       `json:"params,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Granted    bool      `json:"granted"`
+	Reason     string    `json:"reason"`
+}
+
+// AuditLog appends every permission decision to a JSONL file, with the
+// secrets in the tool parameters redacted. A nil AuditLog records nothing.
+type AuditLog struct {
+	mu       sync.Mutex
+	path     string
+	redactor *message.Redactor
+	now      func() time.Time
+}
+
+func NewAuditLog(path string, redactor *message.Redactor) *AuditLog {
+	return &AuditLog{path: path, redactor: redactor, now: time.Now}
+}
+
+func (l *AuditLog) record(opts CreatePermissionRequest, granted bool, reason string) {
+	if l == nil {
+		return
+	}
+	entry := AuditEntry{
+		Time:       l.now(),
+		SessionID:  opts.SessionID,
+		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Action:     opts.Action,
+		Params:     l.redactParams(opts.Params),
+		Path:       opts.Path,
+		Granted:    granted,
+		Reason:     reason,
+	}
+	if err := l.append(entry); err != nil {
+		slog.Error("Failed to write permission audit log", "path", l.path, "error", err)
+	}
+}
+
+// redactParams returns params as JSON with its secrets redacted.
+func (l *AuditLog) redactParams(params any) any {
+	if params == nil {
+		return nil
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		// The entry fails to encode as well and isn't written.
+		return params
+	}
+	return json.RawMessage(l.redactor.RedactJSON(string(data)))
+}
+
+func (l *AuditLog) append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,59 @@
+package permission
+
+import (
+	"slices"
+	"strings"
+)
+
+// DecisionKey identifies the requests a remembered decision applies to: the
+// same tool and action with the same argument, ignoring differences in
+// whitespace.
+type DecisionKey struct {
+	ToolName string
+	Action   string
+	Argument string
+}
+
+func NewDecisionKey(toolName, action string, params any) DecisionKey {
+	argument := ruleArgument(CreatePermissionRequest{Params: params})
+	return DecisionKey{
+		ToolName: toolName,
+		Action:   action,
+		Argument: strings.Join(strings.Fields(argument), " "),
+	}
+}
+
+// RememberDecision grants or denies the requests of the session matching key
+// without prompting, until the session is cleared.
+func (s *permissionService) RememberDecision(sessionID string, key DecisionKey, granted bool) {
+	s.decisionsMu.Lock()
+	defer s.decisionsMu.Unlock()
+	if s.decisions[sessionID] == nil {
+		s.decisions[sessionID] = make(map[DecisionKey]bool)
+	}
+	s.decisions[sessionID][key] = granted
+}
+
+// ClearSession forgets the decisions remembered and granted for the session.
+func (s *permissionService) ClearSession(sessionID string) {
+	s.decisionsMu.Lock()
+	delete(s.decisions, sessionID)
+	s.decisionsMu.Unlock()
+
+	s.sessionPermissionsMu.Lock()
+	s.sessionPermissions = slices.DeleteFunc(s.sessionPermissions, func(p PermissionRequest) bool {
+		return p.SessionID == sessionID
+	})
+	s.sessionPermissionsMu.Unlock()
+
+	s.autoApproveSessionsMu.Lock()
+	delete(s.autoApproveSessions, sessionID)
+	s.autoApproveSessionsMu.Unlock()
+}
+
+func (s *permissionService) rememberedDecision(sessionID string, key DecisionKey) (granted, ok bool) {
+	s.decisionsMu.RLock()
+	defer s.decisionsMu.RUnlock()
+	granted, ok = s.decisions[sessionID][key]
+	return granted, ok
+}
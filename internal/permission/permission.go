@@ -8,6 +8,7 @@ import (
 	"slices"
 	"sync"
 
+	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/csync"
 	"github.com/tulpa-code/tulpa/internal/pubsub"
 	"github.com/google/uuid"
@@ -49,6 +50,8 @@ type Service interface {
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
 	AutoApproveSession(sessionID string)
+	RememberDecision(sessionID string, key DecisionKey, granted bool)
+	ClearSession(sessionID string)
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
@@ -64,8 +67,12 @@ type permissionService struct {
 	pendingRequests       *csync.Map[string, chan bool]
 	autoApproveSessions   map[string]bool
 	autoApproveSessionsMu sync.RWMutex
+	decisions             map[string]map[DecisionKey]bool
+	decisionsMu           sync.RWMutex
 	skip                  bool
 	allowedTools          []string
+	rules                 []Rule
+	audit                 *AuditLog
 
 	// used to make sure we only process one request at a time
 	requestMu     sync.Mutex
@@ -86,6 +93,8 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 	s.sessionPermissions = append(s.sessionPermissions, permission)
 	s.sessionPermissionsMu.Unlock()
 
+	s.RememberDecision(permission.SessionID, NewDecisionKey(permission.ToolName, permission.Action, permission.Params), true)
+
 	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
 		s.activeRequest = nil
 	}
@@ -123,8 +132,25 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 }
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
+	granted, reason := s.request(opts)
+	s.audit.record(opts, granted, reason)
+	return granted
+}
+
+// request decides a permission request and reports why, for the audit log.
+func (s *permissionService) request(opts CreatePermissionRequest) (bool, string) {
+	// Deny rules hold even when requests are skipped.
+	ruleAction, ruled := matchRule(s.rules, opts)
+	if ruled && ruleAction == config.PermissionDeny {
+		s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+			ToolCallID: opts.ToolCallID,
+			Denied:     true,
+		})
+		return false, ReasonRule
+	}
+
 	if s.skip {
-		return true
+		return true, ReasonSkip
 	}
 
 	// tell the UI that a permission was requested
@@ -134,10 +160,15 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
 
-	// Check if the tool/action combination is in the allowlist
+	if ruled && ruleAction == config.PermissionAllow {
+		return true, ReasonRule
+	}
+
+	// Check if the tool/action combination is in the allowlist, unless a
+	// rule asks for a prompt
 	commandKey := opts.ToolName + ":" + opts.Action
-	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
-		return true
+	if !ruled && (slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName)) {
+		return true, ReasonAllowlist
 	}
 
 	s.autoApproveSessionsMu.RLock()
@@ -145,7 +176,11 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
-		return true
+		return true, ReasonAutoApprove
+	}
+
+	if granted, ok := s.rememberedDecision(opts.SessionID, NewDecisionKey(opts.ToolName, opts.Action, opts.Params)); ok {
+		return granted, ReasonRemembered
 	}
 
 	fileInfo, err := os.Stat(opts.Path)
@@ -176,7 +211,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	for _, p := range s.sessionPermissions {
 		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
 			s.sessionPermissionsMu.RUnlock()
-			return true
+			return true, ReasonSession
 		}
 	}
 	s.sessionPermissionsMu.RUnlock()
@@ -185,7 +220,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	for _, p := range s.sessionPermissions {
 		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
 			s.sessionPermissionsMu.RUnlock()
-			return true
+			return true, ReasonSession
 		}
 	}
 	s.sessionPermissionsMu.RUnlock()
@@ -199,7 +234,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	// Publish the request
 	s.Publish(pubsub.CreatedEvent, permission)
 
-	return <-respCh
+	return <-respCh, ReasonUser
 }
 
 func (s *permissionService) AutoApproveSession(sessionID string) {
@@ -220,15 +255,18 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
+func NewPermissionService(workingDir string, skip bool, allowedTools []string, rules []Rule, audit *AuditLog) Service {
 	return &permissionService{
 		Broker:              pubsub.NewBroker[PermissionRequest](),
 		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
 		workingDir:          workingDir,
 		sessionPermissions:  make([]PermissionRequest, 0),
 		autoApproveSessions: make(map[string]bool),
+		decisions:           make(map[string]map[DecisionKey]bool),
 		skip:                skip,
 		allowedTools:        allowedTools,
+		rules:               rules,
+		audit:               audit,
 		pendingRequests:     csync.NewMap[string, chan bool](),
 	}
 }
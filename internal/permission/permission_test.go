@@ -54,7 +54,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewPermissionService("/tmp", false, tt.allowedTools)
+			service := NewPermissionService("/tmp", false, tt.allowedTools, nil, nil)
 
 			// Create a channel to capture the permission request
 			// Since we're testing the allowlist logic, we need to simulate the request
@@ -79,7 +79,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 }
 
 func TestPermissionService_SkipMode(t *testing.T) {
-	service := NewPermissionService("/tmp", true, []string{})
+	service := NewPermissionService("/tmp", true, []string{}, nil, nil)
 
 	result := service.Request(CreatePermissionRequest{
 		SessionID:   "test-session",
@@ -96,7 +96,7 @@ func TestPermissionService_SkipMode(t *testing.T) {
 
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
 
 		req1 := CreatePermissionRequest{
 			SessionID:   "session1",
@@ -140,7 +140,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result2, "Second request should be auto-approved")
 	})
 	t.Run("Sequential requests with temporary grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
 
 		req := CreatePermissionRequest{
 			SessionID:   "session2",
@@ -180,7 +180,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.False(t, result2, "Second request should be denied")
 	})
 	t.Run("Concurrent requests with different outcomes", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
 
 		events := service.Subscribe(t.Context())
 
@@ -245,3 +245,64 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result, "Repeated request should be auto-approved due to persistent permission")
 	})
 }
+
+func TestPermissionService_RememberDecision(t *testing.T) {
+	t.Parallel()
+
+	bash := func(command string) CreatePermissionRequest {
+		return CreatePermissionRequest{
+			SessionID: "session",
+			ToolName:  "bash",
+			Action:    "execute",
+			Params:    map[string]string{"command": command},
+			Path:      "/tmp",
+		}
+	}
+
+	t.Run("remembered decisions skip the prompt", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		service.RememberDecision("session", NewDecisionKey("bash", "execute", map[string]string{"command": "go test ./..."}), true)
+		service.RememberDecision("session", NewDecisionKey("bash", "execute", map[string]string{"command": "rm -rf /"}), false)
+
+		assert.True(t, service.Request(bash("go  test   ./...")), "whitespace should be normalized")
+		assert.False(t, service.Request(bash("rm -rf /")))
+	})
+
+	t.Run("clearing the session prompts again", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		events := service.Subscribe(t.Context())
+		service.RememberDecision("session", NewDecisionKey("bash", "execute", map[string]string{"command": "make"}), true)
+		service.ClearSession("session")
+
+		var result bool
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			result = service.Request(bash("make"))
+		})
+
+		event := <-events
+		service.Deny(event.Payload)
+		wg.Wait()
+		assert.False(t, result, "request should have been prompted and denied")
+	})
+
+	t.Run("decisions are per session", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, []string{}, nil, nil)
+		events := service.Subscribe(t.Context())
+		service.RememberDecision("other", NewDecisionKey("bash", "execute", map[string]string{"command": "make"}), true)
+
+		var result bool
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			result = service.Request(bash("make"))
+		})
+
+		event := <-events
+		service.Grant(event.Payload)
+		wg.Wait()
+		assert.True(t, result)
+	})
+}
@@ -0,0 +1,114 @@
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+// Rule is a [config.PermissionRule] ready to be matched against requests.
+type Rule struct {
+	tool    string
+	pattern *regexp.Regexp
+	action  config.PermissionAction
+}
+
+// CompileRules checks the rules and compiles their patterns, keeping their
+// order.
+func CompileRules(rules []config.PermissionRule) ([]Rule, error) {
+	compiled := make([]Rule, 0, len(rules))
+	for i, r := range rules {
+		rule, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("permission rule %d: %w", i+1, err)
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+func compileRule(r config.PermissionRule) (Rule, error) {
+	if r.Tool == "" {
+		return Rule{}, errors.New("no tool given")
+	}
+	switch r.Action {
+	case config.PermissionAllow, config.PermissionDeny, config.PermissionAsk:
+	default:
+		return Rule{}, fmt.Errorf("invalid action %q: must be allow, deny or ask", r.Action)
+	}
+
+	rule := Rule{tool: r.Tool, action: r.Action}
+	switch {
+	case r.Glob != "" && r.Regex != "":
+		return Rule{}, errors.New("glob and regex can't both be set")
+	case r.Glob != "":
+		rule.pattern = globPattern(r.Glob)
+	case r.Regex != "":
+		pattern, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid regex: %w", err)
+		}
+		rule.pattern = pattern
+	}
+	return rule, nil
+}
+
+// globPattern turns a glob into an anchored regular expression. Unlike
+// [path.Match], * matches across slashes, so "go *" matches "go test ./...".
+func globPattern(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func (r Rule) matches(toolName, argument string) bool {
+	if r.tool != "*" && r.tool != toolName {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(argument)
+}
+
+// ruleArgument returns what rule patterns are matched against: the command,
+// URL or file path in the request's parameters, or its path when the
+// parameters have none of these.
+func ruleArgument(opts CreatePermissionRequest) string {
+	var params map[string]any
+	if data, err := json.Marshal(opts.Params); err == nil {
+		_ = json.Unmarshal(data, &params)
+	}
+	for _, key := range []string{"command", "url", "file_path", "path"} {
+		if value, ok := params[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return opts.Path
+}
+
+// matchRule returns the action of the first rule matching the request.
+func matchRule(rules []Rule, opts CreatePermissionRequest) (config.PermissionAction, bool) {
+	if len(rules) == 0 {
+		return "", false
+	}
+	argument := ruleArgument(opts)
+	for _, rule := range rules {
+		if rule.matches(opts.ToolName, argument) {
+			return rule.action, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,185 @@
+package permission
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+)
+
+func TestCompileRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		rule    config.PermissionRule
+		wantErr string
+	}{
+		{
+			name: "glob",
+			rule: config.PermissionRule{Tool: "bash", Glob: "go *", Action: config.PermissionAllow},
+		},
+		{
+			name: "regex",
+			rule: config.PermissionRule{Tool: "bash", Regex: "^(go|git) ", Action: config.PermissionAsk},
+		},
+		{
+			name: "no pattern",
+			rule: config.PermissionRule{Tool: "*", Action: config.PermissionDeny},
+		},
+		{
+			name:    "no tool",
+			rule:    config.PermissionRule{Action: config.PermissionAllow},
+			wantErr: "permission rule 1: no tool given",
+		},
+		{
+			name:    "invalid action",
+			rule:    config.PermissionRule{Tool: "bash", Action: "maybe"},
+			wantErr: `permission rule 1: invalid action "maybe"`,
+		},
+		{
+			name:    "glob and regex",
+			rule:    config.PermissionRule{Tool: "bash", Glob: "go *", Regex: "^go", Action: config.PermissionAllow},
+			wantErr: "permission rule 1: glob and regex can't both be set",
+		},
+		{
+			name:    "invalid regex",
+			rule:    config.PermissionRule{Tool: "bash", Regex: "(go", Action: config.PermissionAllow},
+			wantErr: "permission rule 1: invalid regex",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := CompileRules([]config.PermissionRule{tt.rule})
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	t.Parallel()
+
+	rules, err := CompileRules([]config.PermissionRule{
+		{Tool: "view", Action: config.PermissionAllow},
+		{Tool: "bash", Regex: "^(go|git) ", Action: config.PermissionAllow},
+		{Tool: "bash", Glob: "rm *", Action: config.PermissionDeny},
+		{Tool: "*", Glob: "/etc/*", Action: config.PermissionDeny},
+		{Tool: "bash", Action: config.PermissionAsk},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		opts   CreatePermissionRequest
+		want   config.PermissionAction
+		wantOK bool
+	}{
+		{
+			name:   "tool without pattern",
+			opts:   CreatePermissionRequest{ToolName: "view", Params: map[string]string{"file_path": "/home/me/notes.txt"}},
+			want:   config.PermissionAllow,
+			wantOK: true,
+		},
+		{
+			name:   "regex on command",
+			opts:   CreatePermissionRequest{ToolName: "bash", Params: map[string]string{"command": "go test ./..."}},
+			want:   config.PermissionAllow,
+			wantOK: true,
+		},
+		{
+			name:   "glob on command",
+			opts:   CreatePermissionRequest{ToolName: "bash", Params: map[string]string{"command": "rm -rf build/"}},
+			want:   config.PermissionDeny,
+			wantOK: true,
+		},
+		{
+			name:   "glob on path for any tool",
+			opts:   CreatePermissionRequest{ToolName: "edit", Params: map[string]string{"file_path": "/etc/hosts"}},
+			want:   config.PermissionDeny,
+			wantOK: true,
+		},
+		{
+			name:   "fallback rule",
+			opts:   CreatePermissionRequest{ToolName: "bash", Params: map[string]string{"command": "make"}},
+			want:   config.PermissionAsk,
+			wantOK: true,
+		},
+		{
+			name: "no matching rule",
+			opts: CreatePermissionRequest{ToolName: "fetch", Params: map[string]string{"url": "https://example.com"}},
+		},
+		{
+			name:   "request path without params",
+			opts:   CreatePermissionRequest{ToolName: "write", Path: "/etc"},
+			want:   "",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := matchRule(rules, tt.opts)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPermissionService_Rules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := CompileRules([]config.PermissionRule{
+		{Tool: "bash", Regex: "^(go|git) ", Action: config.PermissionAllow},
+		{Tool: "bash", Glob: "rm *", Action: config.PermissionDeny},
+		{Tool: "bash", Action: config.PermissionAsk},
+	})
+	require.NoError(t, err)
+
+	bash := func(command string) CreatePermissionRequest {
+		return CreatePermissionRequest{
+			SessionID: "session",
+			ToolName:  "bash",
+			Action:    "execute",
+			Params:    map[string]string{"command": command},
+			Path:      "/tmp",
+		}
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, nil, rules, nil)
+		require.True(t, service.Request(bash("git status")))
+	})
+
+	t.Run("deny even when skipping requests", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", true, nil, rules, nil)
+		require.False(t, service.Request(bash("rm -rf /")))
+	})
+
+	t.Run("ask overrides allowed tools", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", false, []string{"bash"}, rules, nil)
+		events := service.Subscribe(t.Context())
+
+		var granted bool
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			granted = service.Request(bash("make"))
+		})
+
+		event := <-events
+		require.Equal(t, "bash", event.Payload.ToolName)
+		service.Deny(event.Payload)
+		wg.Wait()
+		require.False(t, granted)
+	})
+}
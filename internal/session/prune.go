@@ -0,0 +1,200 @@
+package session
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tulpa-code/tulpa/internal/db"
+)
+
+// Retention limits the sessions kept in the database. Zero disables a limit.
+type Retention struct {
+	// The most sessions kept
+	MaxSessions int
+	// How long sessions are kept after their last update
+	MaxAge time.Duration
+	// The most bytes the database may take
+	MaxSize int64
+}
+
+// Enabled reports whether the retention has any limit.
+func (r Retention) Enabled() bool {
+	return r.MaxSessions > 0 || r.MaxAge > 0 || r.MaxSize > 0
+}
+
+// PrunedSession is a session deleted by [Prune], with its subagent sessions.
+type PrunedSession struct {
+	ID        string
+	Title     string
+	UpdatedAt int64
+	// The bytes of the messages and the file history of the session
+	Size int64
+	// Why the session was deleted
+	Reason string
+}
+
+// PruneResult tells what [Prune] deleted.
+type PruneResult struct {
+	Sessions []PrunedSession
+	// The IDs of the sessions deleted, with their subagent sessions, none on
+	// a dry run
+	DeletedIDs []string
+	// The bytes the database took before and after pruning, the same on a
+	// dry run
+	SizeBefore int64
+	SizeAfter  int64
+}
+
+// Prune deletes the sessions the retention doesn't keep, with their messages,
+// their file history and their subagent sessions, then vacuums the database.
+// Starred sessions and sessions with pinned messages are kept, and the
+// subagent sessions whose parent is gone are deleted. A dry run only tells
+// what would be deleted.
+func Prune(ctx context.Context, conn *sql.DB, retention Retention, dryRun bool) (PruneResult, error) {
+	usage, err := db.New(conn).ListSessionUsage(ctx)
+	if err != nil {
+		return PruneResult{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	size, err := databaseSize(ctx, conn)
+	if err != nil {
+		return PruneResult{}, err
+	}
+	pruned, ids := planPrune(usage, retention, size, time.Now())
+	result := PruneResult{Sessions: pruned, SizeBefore: size, SizeAfter: size}
+	if dryRun || len(ids) == 0 {
+		return result, nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := db.New(tx)
+	for _, id := range ids {
+		// Their messages and files are deleted with them.
+		if err := qtx.DeleteSession(ctx, id); err != nil {
+			return result, fmt.Errorf("failed to delete session %s: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	result.DeletedIDs = ids
+
+	if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+		return result, fmt.Errorf("failed to vacuum the database: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return result, fmt.Errorf("failed to checkpoint the database: %w", err)
+	}
+	if result.SizeAfter, err = databaseSize(ctx, conn); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func databaseSize(ctx context.Context, conn *sql.DB) (int64, error) {
+	var size int64
+	err := conn.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the size of the database: %w", err)
+	}
+	return size, nil
+}
+
+// sessionTree is a top level session with its subagent sessions.
+type sessionTree struct {
+	PrunedSession
+	// Whether the session is starred or has pinned messages
+	exempt bool
+	ids    []string
+}
+
+// planPrune returns the sessions of usage the retention doesn't keep, and the
+// IDs of all the sessions to delete, their subagent sessions included.
+// Sessions are deleted least recently updated first.
+func planPrune(usage []db.ListSessionUsageRow, retention Retention, dbSize int64, now time.Time) ([]PrunedSession, []string) {
+	known := make(map[string]bool, len(usage))
+	children := make(map[string][]db.ListSessionUsageRow)
+	for _, row := range usage {
+		known[row.ID] = true
+		if row.ParentSessionID.Valid {
+			children[row.ParentSessionID.String] = append(children[row.ParentSessionID.String], row)
+		}
+	}
+
+	var trees []*sessionTree
+	var walk func(tree *sessionTree, row db.ListSessionUsageRow)
+	walk = func(tree *sessionTree, row db.ListSessionUsageRow) {
+		tree.ids = append(tree.ids, row.ID)
+		tree.Size += row.Size
+		tree.UpdatedAt = max(tree.UpdatedAt, row.UpdatedAt)
+		tree.exempt = tree.exempt || row.Starred || row.Pinned
+		for _, child := range children[row.ID] {
+			walk(tree, child)
+		}
+	}
+	for _, row := range usage {
+		orphan := row.ParentSessionID.Valid && !known[row.ParentSessionID.String]
+		if row.ParentSessionID.Valid && !orphan {
+			continue
+		}
+		tree := &sessionTree{PrunedSession: PrunedSession{ID: row.ID, Title: row.Title}}
+		walk(tree, row)
+		if orphan {
+			tree.Reason = "its parent session was deleted"
+		}
+		trees = append(trees, tree)
+	}
+	// Most recently updated first
+	slices.SortStableFunc(trees, func(a, b *sessionTree) int {
+		return cmp.Compare(b.UpdatedAt, a.UpdatedAt)
+	})
+
+	kept := 0
+	var keptTrees []*sessionTree
+	for _, tree := range trees {
+		switch {
+		case tree.Reason != "":
+		case tree.exempt:
+			continue
+		case retention.MaxSessions > 0 && kept >= retention.MaxSessions:
+			tree.Reason = fmt.Sprintf("more than %d sessions", retention.MaxSessions)
+		case retention.MaxAge > 0 && now.Sub(time.Unix(tree.UpdatedAt, 0)) > retention.MaxAge:
+			tree.Reason = fmt.Sprintf("not updated for more than %d days", int(retention.MaxAge.Hours()/24))
+		default:
+			kept++
+			keptTrees = append(keptTrees, tree)
+		}
+	}
+
+	// The size freed is estimated from the size of the messages and of the
+	// file history of the sessions, the database shrinks when vacuumed.
+	if retention.MaxSize > 0 {
+		size := dbSize
+		for _, tree := range trees {
+			if tree.Reason != "" {
+				size -= tree.Size
+			}
+		}
+		for i := len(keptTrees) - 1; i >= 0 && size > retention.MaxSize; i-- {
+			keptTrees[i].Reason = fmt.Sprintf("the database is larger than %d MiB", retention.MaxSize/(1024*1024))
+			size -= keptTrees[i].Size
+		}
+	}
+
+	var pruned []PrunedSession
+	var ids []string
+	for i := len(trees) - 1; i >= 0; i-- {
+		if tree := trees[i]; tree.Reason != "" {
+			pruned = append(pruned, tree.PrunedSession)
+			ids = append(ids, tree.ids...)
+		}
+	}
+	return pruned, ids
+}
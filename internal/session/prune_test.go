@@ -0,0 +1,167 @@
+package session
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/db"
+)
+
+func TestPlanPrune(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000_000_000, 0)
+	daysAgo := func(days int) int64 {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).Unix()
+	}
+	child := func(id, parent string, updatedAt, size int64) db.ListSessionUsageRow {
+		return db.ListSessionUsageRow{
+			ID:              id,
+			ParentSessionID: sql.NullString{String: parent, Valid: true},
+			UpdatedAt:       updatedAt,
+			Size:            size,
+		}
+	}
+	// Most recently updated first, like ListSessionUsage returns them.
+	usage := []db.ListSessionUsageRow{
+		{ID: "new", UpdatedAt: daysAgo(1), Size: 100},
+		child("new-task", "new", daysAgo(1), 10),
+		{ID: "recent", UpdatedAt: daysAgo(5), Size: 200},
+		{ID: "pinned", UpdatedAt: daysAgo(60), Size: 300, Pinned: true},
+		{ID: "starred", UpdatedAt: daysAgo(90), Size: 500, Starred: true},
+		{ID: "old", UpdatedAt: daysAgo(40), Size: 400},
+		child("old-task", "old", daysAgo(40), 40),
+		child("orphan", "gone", daysAgo(2), 50),
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		dbSize    int64
+		pruned    []string
+		reasons   []string
+		ids       []string
+	}{
+		{
+			name:    "no limit",
+			pruned:  []string{"orphan"},
+			reasons: []string{"its parent session was deleted"},
+			ids:     []string{"orphan"},
+		},
+		{
+			name:      "max sessions",
+			retention: Retention{MaxSessions: 2},
+			pruned:    []string{"old", "orphan"},
+			reasons:   []string{"more than 2 sessions", "its parent session was deleted"},
+			ids:       []string{"old", "old-task", "orphan"},
+		},
+		{
+			name:      "max age",
+			retention: Retention{MaxAge: 30 * 24 * time.Hour},
+			pruned:    []string{"old", "orphan"},
+			reasons:   []string{"not updated for more than 30 days", "its parent session was deleted"},
+			ids:       []string{"old", "old-task", "orphan"},
+		},
+		{
+			name:      "max size",
+			retention: Retention{MaxSize: 600},
+			dbSize:    1200,
+			pruned:    []string{"old", "recent", "orphan"},
+			reasons:   []string{"the database is larger than 0 MiB", "the database is larger than 0 MiB", "its parent session was deleted"},
+			ids:       []string{"old", "old-task", "recent", "orphan"},
+		},
+		{
+			name:      "max size already met",
+			retention: Retention{MaxSize: 2000},
+			dbSize:    1200,
+			pruned:    []string{"orphan"},
+			reasons:   []string{"its parent session was deleted"},
+			ids:       []string{"orphan"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pruned, ids := planPrune(usage, tt.retention, tt.dbSize, now)
+			var names, reasons []string
+			for _, s := range pruned {
+				names = append(names, s.ID)
+				reasons = append(reasons, s.Reason)
+			}
+			require.Equal(t, tt.pruned, names)
+			require.Equal(t, tt.reasons, reasons)
+			require.ElementsMatch(t, tt.ids, ids)
+		})
+	}
+
+	pruned, _ := planPrune(usage, Retention{MaxSessions: 2}, 0, now)
+	require.Equal(t, int64(440), pruned[0].Size, "the size includes the subagent sessions")
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+
+	for _, params := range []db.CreateSessionParams{
+		{ID: "starred", Title: "Starred"},
+		{ID: "old", Title: "Old"},
+		{ID: "old-task", ParentSessionID: sql.NullString{String: "old", Valid: true}},
+		{ID: "pinned", Title: "Pinned"},
+		{ID: "new", Title: "New"},
+	} {
+		_, err := q.CreateSession(t.Context(), params)
+		require.NoError(t, err)
+		_, err = q.CreateMessage(t.Context(), db.CreateMessageParams{ID: params.ID + "-message", SessionID: params.ID, Role: "user", Parts: "[]"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, q.SetMessagePinned(t.Context(), db.SetMessagePinnedParams{ID: "pinned-message", Pinned: true}))
+	sessions := NewService(q)
+	require.NoError(t, sessions.SetStarred(t.Context(), "starred", true))
+	// The sessions are created within the same second, order them by age.
+	// Updating a session sets its updated_at to now, so the order is set by
+	// created_at.
+	for i, id := range []string{"starred", "old", "old-task", "pinned", "new"} {
+		_, err := conn.ExecContext(t.Context(), "UPDATE sessions SET created_at = ? WHERE id = ?", 1000+i, id)
+		require.NoError(t, err)
+	}
+
+	retention := Retention{MaxSessions: 1}
+	result, err := Prune(t.Context(), conn, retention, true)
+	require.NoError(t, err)
+	require.Len(t, result.Sessions, 1)
+	require.Equal(t, "old", result.Sessions[0].ID)
+	require.Equal(t, result.SizeBefore, result.SizeAfter)
+	require.Empty(t, result.DeletedIDs)
+	_, err = q.GetSessionByID(t.Context(), "old")
+	require.NoError(t, err, "a dry run deletes nothing")
+
+	result, err = Prune(t.Context(), conn, retention, false)
+	require.NoError(t, err)
+	require.Len(t, result.Sessions, 1)
+	require.Equal(t, []string{"old", "old-task"}, result.DeletedIDs)
+	for _, id := range []string{"old", "old-task"} {
+		_, err = q.GetSessionByID(t.Context(), id)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+		messages, err := q.ListMessagesBySession(t.Context(), id)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	}
+	for _, id := range []string{"starred", "pinned", "new"} {
+		_, err = q.GetSessionByID(t.Context(), id)
+		require.NoError(t, err)
+	}
+	starred, err := sessions.Get(t.Context(), "starred")
+	require.NoError(t, err)
+	require.True(t, starred.Starred)
+
+	result, err = Prune(t.Context(), conn, retention, false)
+	require.NoError(t, err)
+	require.Empty(t, result.Sessions)
+}
@@ -10,6 +10,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// DefaultTitle is the title of sessions created before their first prompt,
+// until a title is generated.
+const DefaultTitle = "New Session"
+
 type Session struct {
 	ID               string
 	ParentSessionID  string
@@ -19,6 +23,7 @@ type Session struct {
 	CompletionTokens int64
 	SummaryMessageID string
 	Cost             float64
+	Starred          bool
 	CreatedAt        int64
 	UpdatedAt        int64
 }
@@ -31,6 +36,8 @@ type Service interface {
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
+	// SetStarred stars or unstars a session. Pruning keeps starred sessions.
+	SetStarred(ctx context.Context, id string, starred bool) error
 	Delete(ctx context.Context, id string) error
 }
 
@@ -123,6 +130,18 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 	return session, nil
 }
 
+func (s *service) SetStarred(ctx context.Context, id string, starred bool) error {
+	if err := s.q.SetSessionStarred(ctx, db.SetSessionStarredParams{ID: id, Starred: starred}); err != nil {
+		return err
+	}
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	s.Publish(pubsub.UpdatedEvent, session)
+	return nil
+}
+
 func (s *service) List(ctx context.Context) ([]Session, error) {
 	dbSessions, err := s.q.ListSessions(ctx)
 	if err != nil {
@@ -145,6 +164,7 @@ func (s service) fromDBItem(item db.Session) Session {
 		CompletionTokens: item.CompletionTokens,
 		SummaryMessageID: item.SummaryMessageID.String,
 		Cost:             item.Cost,
+		Starred:          item.Starred,
 		CreatedAt:        item.CreatedAt,
 		UpdatedAt:        item.UpdatedAt,
 	}
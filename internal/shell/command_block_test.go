@@ -374,3 +374,32 @@ func TestSplitArgsFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestWithBlockFuncs(t *testing.T) {
+	t.Parallel()
+
+	shell := NewShell(&Options{WorkingDir: t.TempDir()})
+	ctx := WithBlockFuncs(t.Context(), CommandsBlocker([]string{"ls"}))
+
+	_, _, err := shell.Exec(ctx, "ls")
+	require.ErrorContains(t, err, "not allowed for security reasons: ls")
+
+	// Commands run without the context aren't blocked.
+	_, _, err = shell.Exec(t.Context(), "ls")
+	require.NoError(t, err)
+}
+
+func TestSimpleCommands(t *testing.T) {
+	t.Parallel()
+
+	commands, err := SimpleCommands(`cd sub && go test ./... | tee "$OUT"; echo $(git status --short)`)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"go", "test", "./..."},
+		{"tee", `"$OUT"`},
+		{"git", "status", "--short"},
+	}, commands, "builtins like cd and echo are left out")
+
+	_, err = SimpleCommands("go test (")
+	require.Error(t, err)
+}
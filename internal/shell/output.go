@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrOutputTruncated is returned by [Shell.Exec], joined with the error of
+// the command if any, when its output went past the limit set with
+// [WithOutputLimit].
+var ErrOutputTruncated = errors.New("output truncated")
+
+type outputLimitKey struct{}
+
+// WithOutputLimit returns a context whose commands run with [Shell.Exec] keep
+// at most limit bytes of their stdout and of their stderr: the first and the
+// last halves, with a line telling how much was left out between them. The
+// commands keep running past the limit. Zero or less keeps everything.
+func WithOutputLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, outputLimitKey{}, limit)
+}
+
+// limitedBuffer is a writer keeping the first and last halves of at most
+// limit bytes written to it, so a command printing gigabytes doesn't have to
+// be held in memory.
+type limitedBuffer struct {
+	limit        int
+	head         []byte
+	tail         []byte
+	dropped      int
+	droppedLines int
+}
+
+func newLimitedBuffer(ctx context.Context) *limitedBuffer {
+	limit, _ := ctx.Value(outputLimitKey{}).(int)
+	return &limitedBuffer{limit: limit}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.limit <= 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+
+	if room := b.limit - b.limit/2 - len(b.head); room > 0 {
+		k := min(room, len(p))
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+	b.tail = append(b.tail, p...)
+	if over := len(b.tail) - b.limit/2; over > 0 {
+		b.dropped += over
+		b.droppedLines += bytes.Count(b.tail[:over], []byte("\n"))
+		b.tail = b.tail[over:]
+	}
+	return n, nil
+}
+
+func (b *limitedBuffer) truncated() bool {
+	return b.dropped > 0
+}
+
+func (b *limitedBuffer) String() string {
+	if !b.truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	return fmt.Sprintf("%s\n\n... [%d bytes, %d lines truncated] ...\n\n%s", b.head, b.dropped, b.droppedLines, b.tail)
+}
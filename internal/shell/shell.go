@@ -11,10 +11,10 @@
 package shell
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"slices"
 	"strings"
@@ -210,6 +210,59 @@ func splitArgsFlags(parts []string) (args []string, flags []string) {
 	return args, flags
 }
 
+type blockFuncsKey struct{}
+
+// WithBlockFuncs returns a context whose commands run with [Shell.Exec] are
+// also blocked by blockFuncs, on top of the block functions of the shell.
+func WithBlockFuncs(ctx context.Context, blockFuncs ...BlockFunc) context.Context {
+	existing, _ := ctx.Value(blockFuncsKey{}).([]BlockFunc)
+	return context.WithValue(ctx, blockFuncsKey{}, slices.Concat(existing, blockFuncs))
+}
+
+type envKey struct{}
+
+// WithEnv returns a context whose commands run with [Shell.Exec] also get the
+// environment variables of env, in the form "key=value". They override the
+// ones of the shell for these commands only, the shell keeps its own values.
+func WithEnv(ctx context.Context, env ...string) context.Context {
+	existing, _ := ctx.Value(envKey{}).([]string)
+	return context.WithValue(ctx, envKey{}, slices.Concat(existing, env))
+}
+
+// SimpleCommands parses command and returns the arguments of each command it
+// runs that is not a shell builtin, including the ones in command
+// substitutions. Arguments that aren't literal are returned as written, like
+// $PKG.
+func SimpleCommands(command string) ([][]string, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse command: %w", err)
+	}
+	printer := syntax.NewPrinter()
+	var commands [][]string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		args := make([]string, 0, len(call.Args))
+		for _, word := range call.Args {
+			arg := word.Lit()
+			if arg == "" {
+				var b strings.Builder
+				_ = printer.Print(&b, word)
+				arg = b.String()
+			}
+			args = append(args, arg)
+		}
+		if !interp.IsBuiltin(args[0]) {
+			commands = append(commands, args)
+		}
+		return true
+	})
+	return commands, nil
+}
+
 func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
 	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
 		return func(ctx context.Context, args []string) error {
@@ -217,7 +270,8 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 				return next(ctx, args)
 			}
 
-			for _, blockFunc := range s.blockFuncs {
+			contextBlockFuncs, _ := ctx.Value(blockFuncsKey{}).([]BlockFunc)
+			for _, blockFunc := range slices.Concat(s.blockFuncs, contextBlockFuncs) {
 				if blockFunc(args) {
 					return fmt.Errorf("command is not allowed for security reasons: %s", strings.Join(args, " "))
 				}
@@ -228,6 +282,51 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 	}
 }
 
+// newRunner returns an interpreter running commands in the working directory
+// and environment of the shell, with the variables of overlay set over them.
+func (s *Shell) newRunner(stdout, stderr io.Writer, overlay []string) (*interp.Runner, error) {
+	runner, err := interp.New(
+		interp.StdIO(nil, stdout, stderr),
+		interp.Interactive(false),
+		interp.Env(expand.ListEnviron(slices.Concat(s.env, overlay)...)),
+		interp.Dir(s.cwd),
+		interp.ExecHandlers(s.blockHandler(), coreutils.ExecHandler),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not run command: %w", err)
+	}
+	return runner, nil
+}
+
+// Start runs command in the background, writing its stdout and stderr to
+// out, which must be safe for concurrent use, until it exits or ctx ends, which kills its processes. It runs in the
+// working directory and environment of the shell at the time of the call,
+// with the variables of [WithEnv], but changes neither of them. The returned
+// channel receives the outcome of the command, see [ExitCode] and
+// [IsInterrupt].
+func (s *Shell) Start(ctx context.Context, command string, out io.Writer) (<-chan error, error) {
+	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse command: %w", err)
+	}
+
+	overlay, _ := ctx.Value(envKey{}).([]string)
+	s.mu.Lock()
+	runner, err := s.newRunner(out, out, overlay)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := runner.Run(ctx, line)
+		s.logger.InfoPersist("Background command finished", "command", command, "err", err)
+		done <- err
+	}()
+	return done, nil
+}
+
 // execPOSIX executes commands using POSIX shell emulation (cross-platform)
 func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string, error) {
 	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
@@ -235,23 +334,40 @@ func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string,
 		return "", "", fmt.Errorf("could not parse command: %w", err)
 	}
 
-	var stdout, stderr bytes.Buffer
-	runner, err := interp.New(
-		interp.StdIO(nil, &stdout, &stderr),
-		interp.Interactive(false),
-		interp.Env(expand.ListEnviron(s.env...)),
-		interp.Dir(s.cwd),
-		interp.ExecHandlers(s.blockHandler(), coreutils.ExecHandler),
-	)
+	overlay, _ := ctx.Value(envKey{}).([]string)
+	overlaid := make(map[string]bool, len(overlay))
+	for _, kv := range overlay {
+		name, _, _ := strings.Cut(kv, "=")
+		overlaid[name] = true
+	}
+
+	stdout, stderr := newLimitedBuffer(ctx), newLimitedBuffer(ctx)
+	runner, err := s.newRunner(stdout, stderr, overlay)
 	if err != nil {
-		return "", "", fmt.Errorf("could not run command: %w", err)
+		return "", "", err
 	}
 
 	err = runner.Run(ctx, line)
 	s.cwd = runner.Dir
+	previous := s.env
 	s.env = []string{}
 	for name, vr := range runner.Vars {
-		s.env = append(s.env, fmt.Sprintf("%s=%s", name, vr.Str))
+		if !overlaid[name] {
+			s.env = append(s.env, fmt.Sprintf("%s=%s", name, vr.Str))
+		}
+	}
+	// The overlaid variables keep the values they had before the command.
+	for _, kv := range previous {
+		if name, _, _ := strings.Cut(kv, "="); overlaid[name] {
+			s.env = append(s.env, kv)
+		}
+	}
+	if stdout.truncated() || stderr.truncated() {
+		if err == nil {
+			err = ErrOutputTruncated
+		} else {
+			err = errors.Join(err, ErrOutputTruncated)
+		}
 	}
 	s.logger.InfoPersist("POSIX command finished", "command", command, "err", err)
 	return stdout.String(), stderr.String(), err
@@ -265,7 +381,7 @@ func IsInterrupt(err error) bool {
 
 // ExitCode extracts the exit code from an error
 func ExitCode(err error) int {
-	if err == nil {
+	if err == nil || err == ErrOutputTruncated {
 		return 0
 	}
 	var exitErr interp.ExitStatus
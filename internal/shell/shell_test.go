@@ -2,6 +2,7 @@ package shell
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -118,3 +119,55 @@ func TestCrossPlatformExecution(t *testing.T) {
 		t.Errorf("Echo output should contain 'hello', got: %q", stdout)
 	}
 }
+
+func TestWithEnv(t *testing.T) {
+	shell := NewShell(&Options{WorkingDir: t.TempDir(), Env: []string{"TULPA_MODE=base"}})
+	ctx := WithEnv(t.Context(), "TULPA_MODE=overlay", "TULPA_TOKEN=secret")
+
+	out, _, err := shell.Exec(ctx, "echo $TULPA_MODE $TULPA_TOKEN; export TULPA_TOKEN=changed")
+	if err != nil {
+		t.Fatalf("failed to echo: %v", err)
+	}
+	if out != "overlay secret\n" {
+		t.Fatalf("expected the overlaid variables, got %q", out)
+	}
+
+	// Later commands without the overlay get the values of the shell back.
+	out, _, err = shell.Exec(t.Context(), "echo $TULPA_MODE $TULPA_TOKEN")
+	if err != nil {
+		t.Fatalf("failed to echo: %v", err)
+	}
+	if out != "base\n" {
+		t.Fatalf("expected the shell variables, got %q", out)
+	}
+}
+
+func TestWithOutputLimit(t *testing.T) {
+	shell := NewShell(&Options{WorkingDir: t.TempDir()})
+
+	stdout, _, err := shell.Exec(WithOutputLimit(t.Context(), 100), "echo short")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stdout != "short\n" {
+		t.Fatalf("Expected untouched output, got %q", stdout)
+	}
+
+	command := "for i in 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20; do echo line$i; done; exit 3"
+	stdout, _, err = shell.Exec(WithOutputLimit(t.Context(), 20), command)
+	if !errors.Is(err, ErrOutputTruncated) {
+		t.Fatalf("Expected ErrOutputTruncated, got %v", err)
+	}
+	if status := ExitCode(err); status != 3 {
+		t.Fatalf("Expected exit status 3, got %d", status)
+	}
+	want := "line1\nline\n\n... [111 bytes, 17 lines truncated] ...\n\n19\nline20\n"
+	if stdout != want {
+		t.Fatalf("Expected %q, got %q", want, stdout)
+	}
+
+	_, _, err = shell.Exec(WithOutputLimit(t.Context(), 4), "echo truncated")
+	if err != ErrOutputTruncated || ExitCode(err) != 0 {
+		t.Fatalf("Expected only ErrOutputTruncated, got %v", err)
+	}
+}
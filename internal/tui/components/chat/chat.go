@@ -2,12 +2,14 @@ package chat
 
 import (
 	"context"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
 	"github.com/tulpa-code/tulpa/internal/app"
 	"github.com/tulpa-code/tulpa/internal/llm/agent"
 	"github.com/tulpa-code/tulpa/internal/message"
@@ -73,6 +75,7 @@ type messageListCmp struct {
 	lastClickY    int
 	clickCount    int
 	promptQueue   int
+	steering      []string
 }
 
 // New creates a new message list component with custom keybindings
@@ -105,8 +108,10 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	if m.session.ID != "" && m.app.CoderAgent != nil {
 		queueSize := m.app.CoderAgent.QueuedPrompts(m.session.ID)
-		if queueSize != m.promptQueue {
+		steering := m.app.CoderAgent.Steering(m.session.ID)
+		if queueSize != m.promptQueue || !slices.Equal(steering, m.steering) {
 			m.promptQueue = queueSize
+			m.steering = steering
 			cmds = append(cmds, m.SetSize(m.width, m.height))
 		}
 	}
@@ -189,6 +194,9 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, m.CopySelectedText(true))
 			return m, tea.Batch(cmds...)
 		}
+	case messages.TogglePinMsg:
+		cmds = append(cmds, m.togglePin(msg.MessageID))
+		return m, tea.Batch(cmds...)
 	case pubsub.Event[permission.PermissionNotification]:
 		cmds = append(cmds, m.handlePermissionRequest(msg.Payload))
 		return m, tea.Batch(cmds...)
@@ -223,7 +231,7 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *messageListCmp) View() string {
 	t := styles.CurrentTheme()
 	height := m.height
-	if m.promptQueue > 0 {
+	if m.hasPills() {
 		height -= 4 // pill height and padding
 	}
 	view := []string{
@@ -235,9 +243,15 @@ func (m *messageListCmp) View() string {
 				m.listCmp.View(),
 			),
 	}
-	if m.app.CoderAgent != nil && m.promptQueue > 0 {
-		queuePill := queuePill(m.promptQueue, t)
-		view = append(view, t.S().Base.PaddingLeft(4).PaddingTop(1).Render(queuePill))
+	if m.app.CoderAgent != nil && m.hasPills() {
+		var pills []string
+		if m.promptQueue > 0 {
+			pills = append(pills, queuePill(m.promptQueue, t), " ")
+		}
+		if len(m.steering) > 0 {
+			pills = append(pills, steeringPill(m.steering, m.width-8-lipgloss.Width(strings.Join(pills, "")), t))
+		}
+		view = append(view, t.S().Base.PaddingLeft(4).PaddingTop(1).Render(lipgloss.JoinHorizontal(lipgloss.Top, pills...)))
 	}
 	return strings.Join(view, "\n")
 }
@@ -316,6 +330,28 @@ func (m *messageListCmp) handleChildSession(event pubsub.Event[message.Message])
 	return tea.Batch(cmds...)
 }
 
+// togglePin pins the message with the given ID, or unpins it.
+func (m *messageListCmp) togglePin(messageID string) tea.Cmd {
+	msg, err := m.app.Messages.Get(context.Background(), messageID)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	msg.Pinned = !msg.Pinned
+	if err := m.app.Messages.SetPinned(context.Background(), msg.ID, msg.Pinned); err != nil {
+		return util.ReportError(err)
+	}
+	for _, item := range m.listCmp.Items() {
+		if uiMsg, ok := item.(messages.MessageCmp); ok && uiMsg.GetMessage().ID == msg.ID {
+			uiMsg.SetMessage(msg)
+			m.listCmp.UpdateItem(uiMsg.ID(), uiMsg)
+		}
+	}
+	if msg.Pinned {
+		return util.ReportInfo("Message pinned, compacting the conversation keeps it")
+	}
+	return util.ReportInfo("Message unpinned")
+}
+
 // handleMessageEvent processes different types of message events (created/updated).
 func (m *messageListCmp) handleMessageEvent(event pubsub.Event[message.Message]) tea.Cmd {
 	switch event.Type {
@@ -454,12 +490,15 @@ func (m *messageListCmp) updateAssistantMessageContent(msg message.Message, assi
 	if shouldShowMessage {
 		items := m.listCmp.Items()
 		uiMsg := items[assistantIndex].(messages.MessageCmp)
+		// Finished messages are updated again when they are pinned.
+		shown := uiMsg.GetMessage()
+		wasFinished := shown.IsFinished()
 		uiMsg.SetMessage(msg)
 		m.listCmp.UpdateItem(
 			items[assistantIndex].ID(),
 			uiMsg,
 		)
-		if msg.FinishPart() != nil && msg.FinishPart().Reason == message.FinishReasonEndTurn {
+		if !wasFinished && msg.FinishPart() != nil && msg.FinishPart().Reason == message.FinishReasonEndTurn {
 			m.listCmp.AppendItem(
 				messages.NewAssistantSection(
 					msg,
@@ -656,7 +695,7 @@ func (m *messageListCmp) GetSize() (int, int) {
 func (m *messageListCmp) SetSize(width int, height int) tea.Cmd {
 	m.width = width
 	m.height = height
-	if m.promptQueue > 0 {
+	if m.hasPills() {
 		queueHeight := 3 + 1 // 1 for padding top
 		lHight := max(0, height-(1+queueHeight))
 		return m.listCmp.SetSize(width-2, lHight)
@@ -664,6 +703,12 @@ func (m *messageListCmp) SetSize(width int, height int) tea.Cmd {
 	return m.listCmp.SetSize(width-2, max(0, height-1)) // for padding
 }
 
+// hasPills reports whether the queued prompts or the steering not sent yet
+// are shown below the messages.
+func (m *messageListCmp) hasPills() bool {
+	return m.promptQueue > 0 || len(m.steering) > 0
+}
+
 // Blur implements MessageListCmp.
 func (m *messageListCmp) Blur() tea.Cmd {
 	return m.listCmp.Blur()
@@ -49,6 +49,12 @@ type FileCompletionItem struct {
 	Path string // The file path
 }
 
+// CommandCompletionItem is a command of the command dialog offered when the
+// prompt starts with a slash.
+type CommandCompletionItem struct {
+	Command commands.Command
+}
+
 type editorCmp struct {
 	width              int
 	height             int
@@ -146,6 +152,11 @@ func (m *editorCmp) send() tea.Cmd {
 		m.textarea.Reset()
 		return util.CmdHandler(dialogs.OpenDialogMsg{Model: quit.NewQuitDialog()})
 	}
+	// Messages sent while the agent works steer it, which can't carry
+	// attachments: keep them until it's done.
+	if len(m.attachments) > 0 && value != "" && m.app.CoderAgent != nil && m.app.CoderAgent.IsSessionBusy(m.session.ID) {
+		return util.ReportWarn("Attachments can't be sent while the agent is working, send them once it's done")
+	}
 
 	m.textarea.Reset()
 	attachments := m.attachments
@@ -209,6 +220,15 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.completionsStartIndex = 0
 			}
 		}
+		// A command chosen at the start of the prompt runs instead of being
+		// inserted, like from the command dialog.
+		if item, ok := msg.Value.(CommandCompletionItem); ok && !msg.Insert {
+			m.textarea.Reset()
+			m.isCompletionsOpen = false
+			m.currentQuery = ""
+			m.completionsStartIndex = 0
+			return m, item.Command.Handler(item.Command)
+		}
 
 	case commands.OpenExternalEditorMsg:
 		if m.app.CoderAgent.IsSessionBusy(m.session.ID) {
@@ -396,6 +416,7 @@ var readyPlaceholders = [...]string{
 	"Ready...",
 	"Ready?",
 	"Ready for instructions",
+	"Ready! Type / for commands",
 }
 
 var workingPlaceholders = [...]string{
@@ -485,6 +506,10 @@ func (m *editorCmp) startCompletions() tea.Msg {
 	files, _, _ := fsext.ListDirectory(".", nil, depth, limit)
 	slices.Sort(files)
 	completionItems := make([]completions.Completion, 0, len(files))
+	// A slash starting the prompt offers the commands before the files.
+	if m.completionsStartIndex == 0 {
+		completionItems = append(completionItems, commandCompletions(m.session.ID, m.width)...)
+	}
 	for _, file := range files {
 		file = strings.TrimPrefix(file, "./")
 		completionItems = append(completionItems, completions.Completion{
@@ -503,6 +528,20 @@ func (m *editorCmp) startCompletions() tea.Msg {
 	}
 }
 
+// commandCompletions returns the commands of the command dialog as
+// completions.
+func commandCompletions(sessionID string, width int) []completions.Completion {
+	cmds := commands.SystemCommandList(sessionID, width)
+	items := make([]completions.Completion, 0, len(cmds))
+	for _, cmd := range cmds {
+		items = append(items, completions.Completion{
+			Title: cmd.Title,
+			Value: CommandCompletionItem{Command: cmd},
+		})
+	}
+	return items
+}
+
 // Blur implements Container.
 func (c *editorCmp) Blur() tea.Cmd {
 	c.textarea.Blur()
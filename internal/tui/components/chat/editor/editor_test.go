@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/v2/textarea"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/tui/components/completions"
+	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs/commands"
+)
+
+func newTestEditor(value string) *editorCmp {
+	ta := textarea.New()
+	ta.SetVirtualCursor(false)
+	ta.SetWidth(100)
+	ta.Focus()
+	ta.SetValue(value)
+	return &editorCmp{textarea: ta, keyMap: DefaultEditorKeyMap(), width: 100}
+}
+
+func TestSlashOpensCompletions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		open  bool
+		start int
+	}{
+		{name: "empty prompt", value: "", open: true, start: 0},
+		{name: "after a space", value: "look at ", open: true, start: 8},
+		{name: "within a word", value: "and", open: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newTestEditor(tt.value)
+			_, _ = m.Update(tea.KeyPressMsg{Code: '/', Text: "/"})
+			require.Equal(t, tt.value+"/", m.textarea.Value(), "the slash is typed, no dialog takes it")
+			require.Equal(t, tt.open, m.isCompletionsOpen)
+			if tt.open {
+				require.Equal(t, tt.start, m.completionsStartIndex)
+			}
+		})
+	}
+}
+
+// The commands depend on the global config, the tests listing them may not
+// be parallel.
+func initConfig(t *testing.T) {
+	_, err := config.Init(t.TempDir(), t.TempDir(), false)
+	require.NoError(t, err)
+}
+
+func TestCommandCompletions(t *testing.T) {
+	initConfig(t)
+
+	items := commandCompletions("session", 100)
+	require.NotEmpty(t, items)
+	require.Equal(t, "New Session", items[0].Title)
+	item, ok := items[0].Value.(CommandCompletionItem)
+	require.True(t, ok)
+	require.Equal(t, "new_session", item.Command.ID)
+}
+
+func TestSelectCompletion(t *testing.T) {
+	initConfig(t)
+	newSession := commandCompletions("session", 100)[0].Value.(CommandCompletionItem)
+
+	t.Run("command runs", func(t *testing.T) {
+		t.Parallel()
+
+		m := newTestEditor("/new")
+		m.isCompletionsOpen = true
+		_, cmd := m.Update(completions.SelectCompletionMsg{Value: newSession})
+		require.Empty(t, m.textarea.Value(), "the command isn't sent as a prompt")
+		require.False(t, m.isCompletionsOpen)
+		require.NotNil(t, cmd)
+		require.IsType(t, commands.NewSessionsMsg{}, cmd())
+	})
+
+	t.Run("command is not inserted", func(t *testing.T) {
+		t.Parallel()
+
+		m := newTestEditor("/new")
+		m.isCompletionsOpen = true
+		_, _ = m.Update(completions.SelectCompletionMsg{Value: newSession, Insert: true})
+		require.Equal(t, "/new", m.textarea.Value())
+	})
+
+	t.Run("file is inserted", func(t *testing.T) {
+		t.Parallel()
+
+		m := newTestEditor("read /ma")
+		m.isCompletionsOpen = true
+		m.completionsStartIndex = 5
+		_, _ = m.Update(completions.SelectCompletionMsg{Value: FileCompletionItem{Path: "main.go"}})
+		require.Equal(t, "read main.go", m.textarea.Value())
+		require.False(t, m.isCompletionsOpen)
+	})
+}
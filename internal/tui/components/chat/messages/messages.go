@@ -28,6 +28,15 @@ import (
 // CopyKey is the key binding for copying message content to the clipboard.
 var CopyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y", "copy"))
 
+// PinKey is the key binding for pinning a message, so that compacting the
+// conversation keeps it as it is.
+var PinKey = key.NewBinding(key.WithKeys("p", "P"), key.WithHelp("p", "pin"))
+
+// TogglePinMsg asks to pin the message with the given ID, or to unpin it.
+type TogglePinMsg struct {
+	MessageID string
+}
+
 // ClearSelectionKey is the key binding for clearing the current selection in the chat interface.
 var ClearSelectionKey = key.NewBinding(key.WithKeys("esc", "alt+esc"), key.WithHelp("esc", "clear selection"))
 
@@ -113,6 +122,9 @@ func (m *messageCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				util.ReportInfo("Message copied to clipboard"),
 			)
 		}
+		if key.Matches(msg, PinKey) {
+			return m, util.CmdHandler(TogglePinMsg{MessageID: m.message.ID})
+		}
 	}
 	return m, nil
 }
@@ -203,6 +215,17 @@ func (m *messageCmp) renderAssistantMessage() string {
 		parts = append(parts, thinkingContent)
 	}
 
+	// Summaries replace the messages before them in the conversation sent
+	// to the model.
+	if summary := m.message.SummaryPart(); summary != nil && content != "" {
+		tag := t.S().Base.Padding(0, 1).Background(t.BlueLight).Foreground(t.White).Render("SUMMARY")
+		note := "of the conversation before it"
+		if summary.KeptFrom != "" {
+			note = "of the older messages, the latest turns are kept"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", tag, t.S().Base.Foreground(t.FgHalfMuted).Render(note)), "")
+	}
+
 	if content != "" {
 		if thinkingContent != "" {
 			parts = append(parts, "")
@@ -210,7 +233,7 @@ func (m *messageCmp) renderAssistantMessage() string {
 		parts = append(parts, m.toMarkdown(content))
 	}
 
-	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	joined := lipgloss.JoinVertical(lipgloss.Left, m.withPinned(parts)...)
 	return m.style().Render(joined)
 }
 
@@ -241,10 +264,20 @@ func (m *messageCmp) renderUserMessage() string {
 		parts = append(parts, "", strings.Join(attachments, ""))
 	}
 
-	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	joined := lipgloss.JoinVertical(lipgloss.Left, m.withPinned(parts)...)
 	return m.style().Render(joined)
 }
 
+// withPinned adds a note to parts when the message is pinned.
+func (m *messageCmp) withPinned(parts []string) []string {
+	if !m.message.Pinned {
+		return parts
+	}
+	t := styles.CurrentTheme()
+	note := t.S().Base.Foreground(t.FgMuted).Render(fmt.Sprintf("%s Pinned, kept when the conversation is compacted", styles.PinIcon))
+	return append(parts, "", note)
+}
+
 // toMarkdown converts text content to rendered markdown using the configured renderer
 func (m *messageCmp) toMarkdown(content string) string {
 	r := styles.GetMarkdownRenderer(m.textWidth())
@@ -168,6 +168,9 @@ func (m *toolCallCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if key.Matches(msg, CopyKey) {
 			return m, m.copyTool()
 		}
+		if key.Matches(msg, PinKey) {
+			return m, util.CmdHandler(TogglePinMsg{MessageID: m.parentMessageID})
+		}
 	}
 	return m, nil
 }
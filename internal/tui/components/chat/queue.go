@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/tulpa-code/tulpa/internal/tui/styles"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/tulpa-code/tulpa/internal/tui/styles"
 )
 
 func queuePill(queue int, t *styles.Theme) string {
@@ -26,3 +27,26 @@ func queuePill(queue int, t *styles.Theme) string {
 		PaddingRight(1).
 		Render(fmt.Sprintf("%s %d Queued", allTriangles, queue))
 }
+
+// steeringPill shows the last steering message not sent yet, truncated to
+// width.
+func steeringPill(steering []string, width int, t *styles.Theme) string {
+	if len(steering) == 0 {
+		return ""
+	}
+	label := "Steering"
+	if len(steering) > 1 {
+		label = fmt.Sprintf("%d Steering", len(steering))
+	}
+	label = fmt.Sprintf("%s %s", t.S().Base.Foreground(t.Accent).Render("↪"), label)
+	// The border and padding take four columns.
+	text := strings.Join(strings.Fields(steering[len(steering)-1]), " ")
+	text = ansi.Truncate(text, max(10, width-lipgloss.Width(label)-5), "…")
+
+	return t.S().Base.
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(t.BgOverlay).
+		PaddingLeft(1).
+		PaddingRight(1).
+		Render(fmt.Sprintf("%s %s", label, t.S().Muted.Render(text)))
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/tulpa-code/tulpa/internal/fsext"
 	"github.com/tulpa-code/tulpa/internal/history"
 	"github.com/tulpa-code/tulpa/internal/home"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
 	"github.com/tulpa-code/tulpa/internal/lsp"
 	"github.com/tulpa-code/tulpa/internal/pubsub"
 	"github.com/tulpa-code/tulpa/internal/session"
@@ -100,6 +101,9 @@ func (m *sidebarCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.session = session.Session{}
 	case pubsub.Event[history.File]:
 		return m, m.handleFileHistoryEvent(msg)
+	case pubsub.Event[tools.Job]:
+		// The jobs block reads the jobs of the session when rendered.
+		return m, nil
 	case pubsub.Event[session.Session]:
 		if msg.Type == pubsub.UpdatedEvent {
 			if m.session.ID == msg.Payload.ID {
@@ -155,6 +159,9 @@ func (m *sidebarCmp) View() string {
 		// Vertical layout (default)
 		if m.session.ID != "" {
 			parts = append(parts, "", m.filesBlock())
+			if jobs := m.jobsBlock(); jobs != "" {
+				parts = append(parts, "", jobs)
+			}
 		}
 		parts = append(parts,
 			"",
@@ -464,6 +471,38 @@ func (m *sidebarCmp) filesBlock() string {
 	}, true)
 }
 
+// jobsBlock renders the commands running in the background of the session,
+// or nothing when there are none.
+func (m *sidebarCmp) jobsBlock() string {
+	jobs := tools.Jobs(m.session.ID)
+	if len(jobs) == 0 {
+		return ""
+	}
+
+	t := styles.CurrentTheme()
+	maxWidth := m.getMaxWidth()
+	list := []string{t.S().Subtle.Render(core.Section("Jobs", maxWidth)), ""}
+	for _, job := range jobs {
+		icon := t.ItemOfflineIcon
+		description := t.S().Subtle.Render(job.Command)
+		switch {
+		case job.Status == tools.JobRunning:
+			icon = t.ItemOnlineIcon
+		case job.Status == tools.JobExited && job.ExitCode != 0:
+			icon = t.ItemErrorIcon
+			description = t.S().Subtle.Render(fmt.Sprintf("exit %d: %s", job.ExitCode, job.Command))
+		default:
+			description = t.S().Subtle.Render(fmt.Sprintf("%s: %s", job.Status, job.Command))
+		}
+		list = append(list, core.Status(core.StatusOpts{
+			Icon:        icon.String(),
+			Title:       job.ID,
+			Description: description,
+		}, maxWidth))
+	}
+	return lipgloss.NewStyle().Width(maxWidth).Render(lipgloss.JoinVertical(lipgloss.Left, list...))
+}
+
 func (m *sidebarCmp) lspBlock() string {
 	// Limit the number of LSPs shown
 	_, maxLSPs, _ := m.getDynamicLimits()
@@ -532,9 +571,9 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 
 func (s *sidebarCmp) currentModelBlock() string {
 	cfg := config.Get()
-	agentCfg := cfg.Agents["coder"]
+	agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
 
-	selectedModel := cfg.Models[agentCfg.Model]
+	selectedModel, _ := cfg.SelectedModel(agentCfg.Model)
 
 	model := config.Get().GetModelByType(agentCfg.Model)
 	modelProvider := config.Get().GetProviderForModel(agentCfg.Model)
@@ -393,7 +393,9 @@ func (s *splashCmp) setPreferredModel(selectedItem models.ModelOption) tea.Cmd {
 			return util.ReportError(err)
 		}
 	}
-	cfg.SetupAgents()
+	if err := cfg.SetupAgents(); err != nil {
+		return util.ReportError(err)
+	}
 	return nil
 }
 
@@ -575,9 +577,9 @@ func (s *splashCmp) logoBlock() string {
 	}
 	return logoStyle.Render(
 		logo.Render(version.Version, false, logo.Opts{
-			FieldColor:    t.Primary,
-			TitleColorA:   t.Secondary,
-			TitleColorB:   t.Primary,
+			FieldColor:    t.LogoField,
+			TitleColorA:   t.LogoGradientA,
+			TitleColorB:   t.LogoGradientB,
 			SubtitleColor: t.Secondary,
 			VersionColor:  t.Primary,
 			Width:         s.width - logoStyle.GetHorizontalFrameSize(),
@@ -695,7 +697,7 @@ func (s *splashCmp) mcpBlock() string {
 
 func (s *splashCmp) currentModelBlock() string {
 	cfg := config.Get()
-	agentCfg := cfg.Agents["coder"]
+	agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
 	model := config.Get().GetModelByType(agentCfg.Model)
 	if model == nil {
 		return ""
@@ -1,6 +1,8 @@
 package status
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/help"
@@ -17,12 +19,22 @@ type StatusCmp interface {
 	SetKeyMap(keyMap help.KeyMap)
 }
 
+// PromptTokensMsg updates the estimated size of the agent's system prompt
+// shown next to the help.
+type PromptTokensMsg struct {
+	Tokens        int64
+	ContextWindow int64
+	// Whether the prompt takes more of the context window than it should
+	TooLarge bool
+}
+
 type statusCmp struct {
-	info       util.InfoMsg
-	width      int
-	messageTTL time.Duration
-	help       help.Model
-	keyMap     help.KeyMap
+	info         util.InfoMsg
+	width        int
+	messageTTL   time.Duration
+	help         help.Model
+	keyMap       help.KeyMap
+	promptTokens PromptTokensMsg
 }
 
 // clearMessageCmd is a command that clears status messages after a timeout
@@ -53,17 +65,56 @@ func (m *statusCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.clearMessageCmd(ttl)
 	case util.ClearStatusMsg:
 		m.info = util.InfoMsg{}
+	case PromptTokensMsg:
+		m.promptTokens = msg
 	}
 	return m, nil
 }
 
 func (m *statusCmp) View() string {
 	t := styles.CurrentTheme()
-	status := t.S().Base.Padding(0, 1, 1, 1).Render(m.help.View(m.keyMap))
 	if m.info.Msg != "" {
-		status = m.infoMsg()
+		return m.infoMsg()
+	}
+	tokens := m.promptTokensView()
+	m.help.Width = m.width - 2 - lipgloss.Width(tokens)
+	helpView := m.help.View(m.keyMap)
+	if tokens != "" && !m.help.ShowAll {
+		gap := max(m.width-2-lipgloss.Width(helpView)-lipgloss.Width(tokens), 1)
+		helpView += strings.Repeat(" ", gap) + tokens
+	}
+	return t.S().Base.Padding(0, 1, 1, 1).Render(helpView)
+}
+
+// promptTokensView renders the estimated size of the system prompt, e.g.
+// "prompt ~12.3K tokens (6%)".
+func (m *statusCmp) promptTokensView() string {
+	if m.promptTokens.Tokens == 0 {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	text := "prompt ~" + formatTokens(m.promptTokens.Tokens) + " tokens"
+	if m.promptTokens.ContextWindow > 0 {
+		text += fmt.Sprintf(" (%d%%)", m.promptTokens.Tokens*100/m.promptTokens.ContextWindow)
+	}
+	if m.promptTokens.TooLarge {
+		return t.S().Base.Foreground(t.Warning).Render(styles.WarningIcon + " " + text)
+	}
+	return t.S().Base.Foreground(t.FgSubtle).Render(text)
+}
+
+// formatTokens formats a token count like 950, 12.3K or 1.2M.
+func formatTokens(tokens int64) string {
+	var formatted string
+	switch {
+	case tokens >= 1_000_000:
+		formatted = fmt.Sprintf("%.1fM", float64(tokens)/1_000_000)
+	case tokens >= 1_000:
+		formatted = fmt.Sprintf("%.1fK", float64(tokens)/1_000)
+	default:
+		return fmt.Sprintf("%d", tokens)
 	}
-	return status
+	return strings.Replace(formatted, ".0", "", 1)
 }
 
 func (m *statusCmp) infoMsg() string {
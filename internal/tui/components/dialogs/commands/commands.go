@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"maps"
 	"os"
+	"slices"
 
 	"github.com/charmbracelet/bubbles/v2/help"
 	"github.com/charmbracelet/bubbles/v2/key"
@@ -61,6 +63,7 @@ type commandDialogCmp struct {
 
 type (
 	SwitchSessionsMsg      struct{}
+	SearchMessagesMsg      struct{}
 	NewSessionsMsg         struct{}
 	SwitchModelMsg         struct{}
 	QuitMsg                struct{}
@@ -74,6 +77,13 @@ type (
 	CompactMsg             struct {
 		SessionID string
 	}
+	// DryRunMsg shows what the agent would send for the session.
+	DryRunMsg struct {
+		SessionID string
+	}
+	RestartLSPMsg struct {
+		Name string
+	}
 )
 
 func NewCommandDialog(sessionID string) CommandsDialog {
@@ -107,6 +117,13 @@ func NewCommandDialog(sessionID string) CommandsDialog {
 	}
 }
 
+// SystemCommandList returns the system commands the dialog lists for the
+// session in a terminal of the given width.
+func SystemCommandList(sessionID string, windowWidth int) []Command {
+	c := &commandDialogCmp{sessionID: sessionID, wWidth: windowWidth}
+	return c.defaultCommands()
+}
+
 func (c *commandDialogCmp) Init() tea.Cmd {
 	commands, err := LoadCustomCommands()
 	if err != nil {
@@ -277,6 +294,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(SwitchSessionsMsg{})
 			},
 		},
+		{
+			ID:          "search_messages",
+			Title:       "Search Messages",
+			Description: "Search the messages of all sessions",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(SearchMessagesMsg{})
+			},
+		},
 		{
 			ID:          "switch_model",
 			Title:       "Switch Model",
@@ -303,11 +328,11 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 
 	// Add reasoning toggle for models that support it
 	cfg := config.Get()
-	if agentCfg, ok := cfg.Agents["coder"]; ok {
+	if agentCfg, ok := cfg.Agents()[cfg.DefaultAgentID()]; ok {
 		providerCfg := cfg.GetProviderForModel(agentCfg.Model)
 		model := cfg.GetModelByType(agentCfg.Model)
 		if providerCfg != nil && model != nil && model.CanReason {
-			selectedModel := cfg.Models[agentCfg.Model]
+			selectedModel, _ := cfg.SelectedModel(agentCfg.Model)
 
 			// Anthropic models: thinking toggle
 			if providerCfg.Type == catwalk.TypeAnthropic {
@@ -350,8 +375,9 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		})
 	}
 	if c.sessionID != "" {
-		agentCfg := config.Get().Agents["coder"]
-		model := config.Get().GetModelByType(agentCfg.Model)
+		cfg := config.Get()
+		agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
+		model := cfg.GetModelByType(agentCfg.Model)
 		if model.SupportsImages {
 			commands = append(commands, Command{
 				ID:          "file_picker",
@@ -378,7 +404,30 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		})
 	}
 
+	lspConfigs := config.Get().LSP
+	for _, name := range slices.Sorted(maps.Keys(lspConfigs)) {
+		if lspConfigs[name].Disabled {
+			continue
+		}
+		commands = append(commands, Command{
+			ID:          "restart_lsp_" + name,
+			Title:       "Restart LSP: " + name,
+			Description: "Restart the " + name + " language server",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(RestartLSPMsg{Name: name})
+			},
+		})
+	}
+
 	return append(commands, []Command{
+		{
+			ID:          "dry_run",
+			Title:       "Show Prompt",
+			Description: "Show the system prompt, messages and tools the agent would send",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(DryRunMsg{SessionID: c.sessionID})
+			},
+		},
 		{
 			ID:          "toggle_yolo",
 			Title:       "Toggle Yolo Mode",
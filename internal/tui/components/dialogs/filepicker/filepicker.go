@@ -22,7 +22,7 @@ import (
 )
 
 const (
-	MaxAttachmentSize   = int64(5 * 1024 * 1024) // 5MB
+	MaxAttachmentSize   = message.MaxAttachmentSize
 	FilePickerID        = "filepicker"
 	fileSelectionHeight = 10
 	previewHeight       = 20
@@ -120,8 +120,8 @@ func (r *reasoningDialogCmp) Init() tea.Cmd {
 
 func (r *reasoningDialogCmp) populateEffortOptions() tea.Cmd {
 	cfg := config.Get()
-	if agentCfg, ok := cfg.Agents["coder"]; ok {
-		selectedModel := cfg.Models[agentCfg.Model]
+	if agentCfg, ok := cfg.Agents()[cfg.DefaultAgentID()]; ok {
+		selectedModel, _ := cfg.SelectedModel(agentCfg.Model)
 		model := cfg.GetModelByType(agentCfg.Model)
 
 		// Get current reasoning effort
@@ -0,0 +1,259 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/tulpa-code/tulpa/internal/event"
+	"github.com/tulpa-code/tulpa/internal/message"
+	"github.com/tulpa-code/tulpa/internal/session"
+	"github.com/tulpa-code/tulpa/internal/tui/components/chat"
+	"github.com/tulpa-code/tulpa/internal/tui/components/core"
+	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs"
+	"github.com/tulpa-code/tulpa/internal/tui/styles"
+	"github.com/tulpa-code/tulpa/internal/tui/util"
+)
+
+const SearchDialogID dialogs.DialogID = "search"
+
+// SearchDialog interface for the message search dialog
+type SearchDialog interface {
+	dialogs.DialogModel
+}
+
+// hit is a search hit with the title of its session.
+type hit struct {
+	message.MessageHit
+	session session.Session
+}
+
+type searchResultsMsg struct {
+	query string
+	hits  []hit
+	err   error
+}
+
+type searchDialogCmp struct {
+	wWidth   int
+	wHeight  int
+	width    int
+	keyMap   KeyMap
+	input    textinput.Model
+	help     help.Model
+	messages message.Service
+	sessions session.Service
+
+	query    string // query of the shown hits
+	hits     []hit
+	err      error
+	selected int
+	offset   int
+}
+
+// NewSearchDialogCmp creates a new dialog searching the messages of all
+// sessions.
+func NewSearchDialogCmp(messages message.Service, sessions session.Service) SearchDialog {
+	t := styles.CurrentTheme()
+
+	input := textinput.New()
+	input.Placeholder = "Search messages"
+	input.SetVirtualCursor(false)
+	input.Prompt = "> "
+	input.SetStyles(t.S().TextInput)
+	input.Focus()
+
+	help := help.New()
+	help.Styles = t.S().Help
+	return &searchDialogCmp{
+		keyMap:   DefaultKeyMap(),
+		input:    input,
+		help:     help,
+		messages: messages,
+		sessions: sessions,
+	}
+}
+
+func (s *searchDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (s *searchDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.wWidth = msg.Width
+		s.wHeight = msg.Height
+		s.width = min(120, s.wWidth-8)
+		s.input.SetWidth(s.width - 6)
+		return s, nil
+	case searchResultsMsg:
+		if msg.query != s.input.Value() {
+			return s, nil // a newer search is running
+		}
+		s.query = msg.query
+		s.hits = msg.hits
+		s.err = msg.err
+		s.selected = 0
+		s.offset = 0
+		return s, nil
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, s.keyMap.Select):
+			if s.input.Value() != s.query {
+				return s, s.search(s.input.Value())
+			}
+			if len(s.hits) == 0 {
+				return s, nil
+			}
+			event.SessionSwitched()
+			return s, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(chat.SessionSelectedMsg(s.hits[s.selected].session)),
+			)
+		case key.Matches(msg, s.keyMap.Next):
+			if len(s.hits) > 0 {
+				s.selected = (s.selected + 1) % len(s.hits)
+				s.scroll()
+			}
+			return s, nil
+		case key.Matches(msg, s.keyMap.Previous):
+			if len(s.hits) > 0 {
+				s.selected = (s.selected - 1 + len(s.hits)) % len(s.hits)
+				s.scroll()
+			}
+			return s, nil
+		case key.Matches(msg, s.keyMap.Close):
+			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			var cmd tea.Cmd
+			s.input, cmd = s.input.Update(msg)
+			return s, cmd
+		}
+	}
+	return s, nil
+}
+
+// search looks for query in the messages and the sessions of the hits.
+func (s *searchDialogCmp) search(query string) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		found, err := s.messages.Search(ctx, query, message.SearchOpts{})
+		if err != nil {
+			return searchResultsMsg{query: query, err: err}
+		}
+		sessions := make(map[string]session.Session)
+		hits := make([]hit, 0, len(found))
+		for _, h := range found {
+			sess, ok := sessions[h.SessionID]
+			if !ok {
+				sess, err = s.sessions.Get(ctx, h.SessionID)
+				if err != nil {
+					continue
+				}
+				sessions[h.SessionID] = sess
+			}
+			hits = append(hits, hit{MessageHit: h, session: sess})
+		}
+		return searchResultsMsg{query: query, hits: hits}
+	}
+}
+
+// scroll keeps the selected hit visible.
+func (s *searchDialogCmp) scroll() {
+	visible := s.visibleHits()
+	if s.selected < s.offset {
+		s.offset = s.selected
+	} else if s.selected >= s.offset+visible {
+		s.offset = s.selected - visible + 1
+	}
+}
+
+func (s *searchDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Search Messages", s.width-4)),
+		t.S().Base.PaddingLeft(1).PaddingBottom(1).Render(s.input.View()),
+		s.hitsView(),
+		"",
+		t.S().Base.Width(s.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(s.help.View(s.keyMap)),
+	)
+
+	return s.style().Render(content)
+}
+
+func (s *searchDialogCmp) hitsView() string {
+	t := styles.CurrentTheme()
+	base := t.S().Base.PaddingLeft(1)
+	switch {
+	case s.err != nil:
+		return base.Render(t.S().Error.Render(fmt.Sprintf("Search failed: %v", s.err)))
+	case s.query == "":
+		return base.Render(t.S().Muted.Render("Type a query and press enter"))
+	case len(s.hits) == 0:
+		return base.Render(t.S().Muted.Render(fmt.Sprintf("No messages match %q", s.query)))
+	}
+
+	width := s.width - 4
+	var lines []string
+	end := min(s.offset+s.visibleHits(), len(s.hits))
+	for i := s.offset; i < end; i++ {
+		h := s.hits[i]
+		header := fmt.Sprintf("%s · %s · %s", h.session.Title, h.Role, time.Unix(h.CreatedAt, 0).Format(time.DateTime))
+		snippet := h.Snippet[:h.MatchStart] +
+			t.S().Base.Foreground(t.Accent).Bold(true).Render(h.Snippet[h.MatchStart:h.MatchEnd]) +
+			h.Snippet[h.MatchEnd:]
+		titleStyle, snippetStyle := t.S().Text, t.S().Muted
+		if i == s.selected {
+			titleStyle = t.S().TextSelected
+			snippetStyle = t.S().Base.Foreground(t.FgHalfMuted)
+		}
+		lines = append(lines,
+			base.Render(titleStyle.Width(width).Render(ansi.Truncate(header, width, "…"))),
+			base.Render(snippetStyle.Render(ansi.Truncate(snippet, width, "…"))),
+		)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (s *searchDialogCmp) Cursor() *tea.Cursor {
+	cursor := s.input.Cursor()
+	if cursor == nil {
+		return nil
+	}
+	row, col := s.Position()
+	cursor.Y += row + 3 // Border + title
+	cursor.X += col + 2
+	return cursor
+}
+
+func (s *searchDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(s.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+// visibleHits is how many hits fit in the dialog, two lines each.
+func (s *searchDialogCmp) visibleHits() int {
+	return max((s.wHeight/2-8)/2, 1) // 8 for the border, title, input and help
+}
+
+func (s *searchDialogCmp) Position() (int, int) {
+	row := s.wHeight/4 - 2 // just a bit above the center
+	col := s.wWidth / 2
+	col -= s.width / 2
+	return row, col
+}
+
+// ID implements SearchDialog.
+func (s *searchDialogCmp) ID() dialogs.DialogID {
+	return SearchDialogID
+}
@@ -1,6 +1,8 @@
 package sessions
 
 import (
+	"context"
+
 	"github.com/charmbracelet/bubbles/v2/help"
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
@@ -33,10 +35,11 @@ type sessionDialogCmp struct {
 	keyMap            KeyMap
 	sessionsList      SessionsList
 	help              help.Model
+	sessions          session.Service
 }
 
 // NewSessionDialogCmp creates a new session switching dialog
-func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionDialog {
+func NewSessionDialogCmp(service session.Service, sessions []session.Session, selectedID string) SessionDialog {
 	t := styles.CurrentTheme()
 	listKeyMap := list.DefaultKeyMap()
 	keyMap := DefaultKeyMap()
@@ -48,7 +51,7 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 	items := make([]list.CompletionItem[session.Session], len(sessions))
 	if len(sessions) > 0 {
 		for i, session := range sessions {
-			items[i] = list.NewCompletionItem(session.Title, session, list.WithCompletionID(session.ID))
+			items[i] = newSessionItem(session)
 		}
 	}
 
@@ -69,11 +72,21 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 		keyMap:            DefaultKeyMap(),
 		sessionsList:      sessionsList,
 		help:              help,
+		sessions:          service,
 	}
 
 	return s
 }
 
+// newSessionItem returns the list item of a session, marked when starred.
+func newSessionItem(s session.Session) list.CompletionItem[session.Session] {
+	opts := []list.CompletionItemOption{list.WithCompletionID(s.ID)}
+	if s.Starred {
+		opts = append(opts, list.WithCompletionShortcut(styles.StarIcon))
+	}
+	return list.NewCompletionItem(s.Title, s, opts...)
+}
+
 func (s *sessionDialogCmp) Init() tea.Cmd {
 	var cmds []tea.Cmd
 	cmds = append(cmds, s.sessionsList.Init())
@@ -108,6 +121,10 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					),
 				)
 			}
+		case key.Matches(msg, s.keyMap.Star):
+			if selectedItem := s.sessionsList.SelectedItem(); selectedItem != nil {
+				return s, s.toggleStar((*selectedItem).Value())
+			}
 		case key.Matches(msg, s.keyMap.Close):
 			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
 		default:
@@ -119,6 +136,22 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return s, nil
 }
 
+// toggleStar stars the given session, or unstars it.
+func (s *sessionDialogCmp) toggleStar(selected session.Session) tea.Cmd {
+	selected.Starred = !selected.Starred
+	if err := s.sessions.SetStarred(context.Background(), selected.ID, selected.Starred); err != nil {
+		return util.ReportError(err)
+	}
+	item := newSessionItem(selected)
+	cmds := []tea.Cmd{item.Focus(), s.sessionsList.UpdateItem(selected.ID, item)}
+	if selected.Starred {
+		cmds = append(cmds, util.ReportInfo("Session starred, pruning keeps it"))
+	} else {
+		cmds = append(cmds, util.ReportInfo("Session unstarred"))
+	}
+	return tea.Batch(cmds...)
+}
+
 func (s *sessionDialogCmp) View() string {
 	t := styles.CurrentTheme()
 	listView := s.sessionsList.View()
@@ -118,7 +118,7 @@ func SmallRender(width int) string {
 	// Apply gradient to each line
 	var gradientLines []string
 	for _, line := range lines {
-		gradientLines = append(gradientLines, styles.ApplyForegroundGrad(line, t.Secondary, t.Primary))
+		gradientLines = append(gradientLines, styles.ApplyForegroundGrad(line, t.LogoGradientA, t.LogoGradientB))
 	}
 
 	// Add version on top
@@ -279,6 +279,16 @@ func (f *filterableList[T]) SetItems(items []T) tea.Cmd {
 	return f.list.SetItems(items)
 }
 
+// UpdateItem replaces the item with the given ID, filtered out or not.
+func (f *filterableList[T]) UpdateItem(id string, item T) tea.Cmd {
+	for i, existing := range f.items {
+		if existing.ID() == id {
+			f.items[i] = item
+		}
+	}
+	return f.list.UpdateItem(id, item)
+}
+
 func (f *filterableList[T]) Cursor() *tea.Cursor {
 	if f.inputHidden {
 		return nil
@@ -1,7 +1,13 @@
 package tui
 
 import (
+	"log/slog"
+	"maps"
+
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/tui/page/chat"
+	"github.com/tulpa-code/tulpa/internal/tui/util"
 )
 
 type KeyMap struct {
@@ -38,3 +44,40 @@ func DefaultKeyMap() KeyMap {
 		),
 	}
 }
+
+// LoadKeyMap returns the default key map with the keybindings from cfg
+// applied. Overrides that name no binding here or on the chat page, and keys
+// bound to more than one action, are logged.
+func LoadKeyMap(cfg *config.Config) KeyMap {
+	km := DefaultKeyMap()
+	if cfg == nil || len(cfg.Keybindings) == 0 {
+		return km
+	}
+	named := km.named()
+	for _, err := range util.ApplyKeybindings(named, cfg.Keybindings) {
+		slog.Warn("Ignoring keybinding", "error", err)
+	}
+
+	chatKeys := chat.LoadKeyMap(cfg)
+	all := maps.Clone(named)
+	maps.Copy(all, chatKeys.Named())
+	for name := range cfg.Keybindings {
+		if _, ok := all[name]; !ok {
+			slog.Warn("Ignoring keybinding for unknown action", "name", name)
+		}
+	}
+	for _, conflict := range util.KeybindingConflicts(all, cfg.Keybindings) {
+		slog.Warn("Conflicting keybindings", "conflict", conflict)
+	}
+	return km
+}
+
+func (k *KeyMap) named() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":     &k.Quit,
+		"help":     &k.Help,
+		"commands": &k.Commands,
+		"suspend":  &k.Suspend,
+		"sessions": &k.Sessions,
+	}
+}
@@ -3,6 +3,7 @@ package chat
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/help"
@@ -14,6 +15,7 @@ import (
 	"github.com/tulpa-code/tulpa/internal/app"
 	"github.com/tulpa-code/tulpa/internal/config"
 	"github.com/tulpa-code/tulpa/internal/history"
+	"github.com/tulpa-code/tulpa/internal/llm/tools"
 	"github.com/tulpa-code/tulpa/internal/message"
 	"github.com/tulpa-code/tulpa/internal/permission"
 	"github.com/tulpa-code/tulpa/internal/pubsub"
@@ -120,7 +122,7 @@ type chatPage struct {
 func New(app *app.App) ChatPage {
 	return &chatPage{
 		app:         app,
-		keyMap:      DefaultKeyMap(),
+		keyMap:      LoadKeyMap(config.Get()),
 		header:      header.New(app.LSPClients),
 		sidebar:     sidebar.New(app.History, app.LSPClients, false),
 		chat:        chat.New(app),
@@ -221,7 +223,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return p, cmd
 		}
 		return p, nil
-	case chat.SelectionCopyMsg:
+	case chat.SelectionCopyMsg, messages.TogglePinMsg:
 		u, cmd := p.chat.Update(msg)
 		p.chat = u.(chat.MessageListCmp)
 		return p, cmd
@@ -319,7 +321,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
 		return p, cmd
-	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
+	case pubsub.Event[history.File], pubsub.Event[tools.Job], sidebar.SessionFilesMsg:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
 		cmds = append(cmds, cmd)
@@ -371,8 +373,9 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return p, p.newSession()
 		case key.Matches(msg, p.keyMap.AddAttachment):
-			agentCfg := config.Get().Agents["coder"]
-			model := config.Get().GetModelByType(agentCfg.Model)
+			cfg := config.Get()
+			agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
+			model := cfg.GetModelByType(agentCfg.Model)
 			if model.SupportsImages {
 				return p, util.CmdHandler(commands.OpenFilePickerMsg{})
 			} else {
@@ -530,8 +533,8 @@ func (p *chatPage) updateCompactConfig(compact bool) tea.Cmd {
 func (p *chatPage) toggleThinking() tea.Cmd {
 	return func() tea.Msg {
 		cfg := config.Get()
-		agentCfg := cfg.Agents["coder"]
-		currentModel := cfg.Models[agentCfg.Model]
+		agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
+		currentModel, _ := cfg.SelectedModel(agentCfg.Model)
 
 		// Toggle the thinking mode
 		currentModel.Think = !currentModel.Think
@@ -559,7 +562,7 @@ func (p *chatPage) toggleThinking() tea.Cmd {
 func (p *chatPage) openReasoningDialog() tea.Cmd {
 	return func() tea.Msg {
 		cfg := config.Get()
-		agentCfg := cfg.Agents["coder"]
+		agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
 		model := cfg.GetModelByType(agentCfg.Model)
 		providerCfg := cfg.GetProviderForModel(agentCfg.Model)
 
@@ -577,8 +580,8 @@ func (p *chatPage) openReasoningDialog() tea.Cmd {
 func (p *chatPage) handleReasoningEffortSelected(effort string) tea.Cmd {
 	return func() tea.Msg {
 		cfg := config.Get()
-		agentCfg := cfg.Agents["coder"]
-		currentModel := cfg.Models[agentCfg.Model]
+		agentCfg := cfg.Agents()[cfg.DefaultAgentID()]
+		currentModel, _ := cfg.SelectedModel(agentCfg.Model)
 
 		// Update the model configuration
 		currentModel.ReasoningEffort = effort
@@ -712,7 +715,7 @@ func (p *chatPage) cancel() tea.Cmd {
 		return nil
 	}
 
-	if p.app.CoderAgent != nil && p.app.CoderAgent.QueuedPrompts(p.session.ID) > 0 {
+	if p.app.CoderAgent != nil && p.hasPending() {
 		p.app.CoderAgent.ClearQueue(p.session.ID)
 		return nil
 	}
@@ -720,6 +723,12 @@ func (p *chatPage) cancel() tea.Cmd {
 	return cancelTimerCmd()
 }
 
+// hasPending reports whether the session has queued prompts or steering not
+// sent yet.
+func (p *chatPage) hasPending() bool {
+	return p.app.CoderAgent.QueuedPrompts(p.session.ID) > 0 || len(p.app.CoderAgent.Steering(p.session.ID)) > 0
+}
+
 func (p *chatPage) setShowDetails(show bool) {
 	p.showingDetails = show
 	p.header.SetDetailsOpen(p.showingDetails)
@@ -736,20 +745,33 @@ func (p *chatPage) toggleDetails() {
 }
 
 func (p *chatPage) sendMessage(text string, attachments []message.Attachment) tea.Cmd {
-	session := p.session
+	sess := p.session
 	var cmds []tea.Cmd
 	if p.session.ID == "" {
-		newSession, err := p.app.Sessions.Create(context.Background(), "New Session")
+		newSession, err := p.app.Sessions.Create(context.Background(), session.DefaultTitle)
 		if err != nil {
 			return util.ReportError(err)
 		}
-		session = newSession
-		cmds = append(cmds, util.CmdHandler(chat.SessionSelectedMsg(session)))
+		sess = newSession
+		cmds = append(cmds, util.CmdHandler(chat.SessionSelectedMsg(sess)))
 	}
 	if p.app.CoderAgent == nil {
 		return util.ReportError(fmt.Errorf("coder agent is not initialized"))
 	}
-	_, err := p.app.CoderAgent.Run(context.Background(), session.ID, text, attachments...)
+	text, warnings := p.app.ExpandMentions(text)
+	if len(warnings) > 0 {
+		cmds = append(cmds, util.ReportWarn(strings.Join(warnings, "; ")))
+	}
+	// Messages sent while the agent works redirect it at its next tool call,
+	// which can't carry attachments.
+	if len(attachments) > 0 && p.app.CoderAgent.IsSessionBusy(sess.ID) {
+		return util.ReportWarn("Attachments can't be sent while the agent is working, send them once it's done")
+	}
+	if p.app.CoderAgent.Steer(sess.ID, text) {
+		cmds = append(cmds, p.chat.GoToBottom())
+		return tea.Batch(cmds...)
+	}
+	_, err := p.app.CoderAgent.Run(context.Background(), sess.ID, text, attachments...)
 	if err != nil {
 		return util.ReportError(err)
 	}
@@ -894,7 +916,7 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("esc", "press again to cancel"),
 				)
 			}
-			if p.app.CoderAgent != nil && p.app.CoderAgent.QueuedPrompts(p.session.ID) > 0 {
+			if p.hasPending() {
 				cancelBinding = key.NewBinding(
 					key.WithKeys("esc", "alt+esc"),
 					key.WithHelp("esc", "clear queue"),
@@ -959,6 +981,7 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("↑↓", "scroll"),
 				),
 				messages.CopyKey,
+				messages.PinKey,
 			)
 			fullList = append(fullList,
 				[]key.Binding{
@@ -999,6 +1022,7 @@ func (p *chatPage) Help() help.KeyMap {
 				},
 				[]key.Binding{
 					messages.CopyKey,
+					messages.PinKey,
 					messages.ClearSelectionKey,
 				},
 			)
@@ -1,7 +1,11 @@
 package chat
 
 import (
+	"log/slog"
+
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/tulpa-code/tulpa/internal/config"
+	"github.com/tulpa-code/tulpa/internal/tui/util"
 )
 
 type KeyMap struct {
@@ -36,3 +40,27 @@ func DefaultKeyMap() KeyMap {
 		),
 	}
 }
+
+// LoadKeyMap returns the default key map with the keybindings from cfg
+// applied.
+func LoadKeyMap(cfg *config.Config) KeyMap {
+	km := DefaultKeyMap()
+	if cfg == nil {
+		return km
+	}
+	for _, err := range util.ApplyKeybindings(km.Named(), cfg.Keybindings) {
+		slog.Warn("Ignoring keybinding", "error", err)
+	}
+	return km
+}
+
+// Named returns the bindings that can be overridden in the config, by name.
+func (k *KeyMap) Named() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"new_session":    &k.NewSession,
+		"add_attachment": &k.AddAttachment,
+		"cancel":         &k.Cancel,
+		"tab":            &k.Tab,
+		"details":        &k.Details,
+	}
+}
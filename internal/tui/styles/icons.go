@@ -10,6 +10,8 @@ const (
 	LoadingIcon  string = "⟳"
 	DocumentIcon string = "🖼"
 	ModelIcon    string = "◇"
+	PinIcon      string = "⚑"
+	StarIcon     string = "★"
 
 	// Tool call icons
 	ToolPending string = "●"
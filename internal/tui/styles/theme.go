@@ -75,6 +75,11 @@ type Theme struct {
 	RedLight color.Color
 	Cherry   color.Color
 
+	// Logo: the diagonal field lines and the title gradient.
+	LogoField     color.Color
+	LogoGradientA color.Color
+	LogoGradientB color.Color
+
 	// Text selection.
 	TextSelection lipgloss.Style
 
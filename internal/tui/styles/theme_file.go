@@ -0,0 +1,158 @@
+package styles
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ThemeFile is a theme as written in a YAML or JSON file in the themes
+// directory. Colors are hex strings keyed by their snake_case name, e.g.
+// primary or bg_base; the ones left out keep the default theme's values.
+type ThemeFile struct {
+	Name   string            `yaml:"name"`
+	Dark   *bool             `yaml:"dark"`
+	Colors map[string]string `yaml:"colors"`
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// themeColors returns pointers to the colors of t that theme files can set,
+// by name.
+func themeColors(t *Theme) map[string]*color.Color {
+	return map[string]*color.Color{
+		"primary":         &t.Primary,
+		"secondary":       &t.Secondary,
+		"tertiary":        &t.Tertiary,
+		"accent":          &t.Accent,
+		"bg_base":         &t.BgBase,
+		"bg_base_lighter": &t.BgBaseLighter,
+		"bg_subtle":       &t.BgSubtle,
+		"bg_overlay":      &t.BgOverlay,
+		"fg_base":         &t.FgBase,
+		"fg_muted":        &t.FgMuted,
+		"fg_half_muted":   &t.FgHalfMuted,
+		"fg_subtle":       &t.FgSubtle,
+		"fg_selected":     &t.FgSelected,
+		"border":          &t.Border,
+		"border_focus":    &t.BorderFocus,
+		"success":         &t.Success,
+		"error":           &t.Error,
+		"warning":         &t.Warning,
+		"info":            &t.Info,
+		"white":           &t.White,
+		"blue_light":      &t.BlueLight,
+		"blue":            &t.Blue,
+		"yellow":          &t.Yellow,
+		"citron":          &t.Citron,
+		"green":           &t.Green,
+		"green_dark":      &t.GreenDark,
+		"green_light":     &t.GreenLight,
+		"red":             &t.Red,
+		"red_dark":        &t.RedDark,
+		"red_light":       &t.RedLight,
+		"cherry":          &t.Cherry,
+		"logo_field":      &t.LogoField,
+		"logo_gradient_a": &t.LogoGradientA,
+		"logo_gradient_b": &t.LogoGradientB,
+	}
+}
+
+// Theme builds the theme described by f on top of the default theme. The
+// logo colors follow primary and secondary unless they are set themselves.
+func (f ThemeFile) Theme() (*Theme, error) {
+	if f.Name == "" {
+		return nil, errors.New("theme has no name")
+	}
+	t := NewCharmtoneTheme()
+	t.Name = f.Name
+	if f.Dark != nil {
+		t.IsDark = *f.Dark
+	}
+
+	colors := themeColors(t)
+	for _, name := range slices.Sorted(maps.Keys(f.Colors)) {
+		value := f.Colors[name]
+		target, ok := colors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", name)
+		}
+		if !hexColor.MatchString(value) {
+			return nil, fmt.Errorf("color %s: %q is not a hex color like #rrggbb", name, value)
+		}
+		*target = lipgloss.Color(value)
+	}
+	if _, ok := f.Colors["logo_field"]; !ok {
+		t.LogoField = t.Primary
+	}
+	if _, ok := f.Colors["logo_gradient_a"]; !ok {
+		t.LogoGradientA = t.Secondary
+	}
+	if _, ok := f.Colors["logo_gradient_b"]; !ok {
+		t.LogoGradientB = t.Primary
+	}
+	t.buildIndicatorStyles()
+	return t, nil
+}
+
+// LoadThemeFile reads a theme from a YAML or JSON file. A theme without a
+// name is named after the file.
+func LoadThemeFile(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme: %w", err)
+	}
+	var f ThemeFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse theme %s: %w", path, err)
+	}
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	t, err := f.Theme()
+	if err != nil {
+		return nil, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// LoadThemes registers the themes in the YAML and JSON files in dir. A
+// missing directory is not an error; files that fail to load are skipped and
+// their errors returned.
+func (m *Manager) LoadThemes(dir string) []error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []error{fmt.Errorf("failed to read themes directory: %w", err)}
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		t, err := LoadThemeFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.Register(t)
+	}
+	return errs
+}
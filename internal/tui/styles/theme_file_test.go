@@ -0,0 +1,55 @@
+package styles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadThemes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("ocean.yaml", "colors:\n  primary: \"#0077be\"\n  secondary: \"#00a8e8\"\n  bg_base: \"#001f3f\"\n")
+	write("paper.json", `{"name": "Paper", "dark": false, "colors": {"primary": "#333", "logo_field": "#999999"}}`)
+	write("broken.yml", "colors:\n  primary: blue\n")
+	write("typo.yaml", "colors:\n  primray: \"#ffffff\"\n")
+	write("notes.txt", "not a theme")
+
+	m := NewManager()
+	errs := m.LoadThemes(dir)
+	require.Len(t, errs, 2)
+	require.ErrorContains(t, errs[0], `color primary: "blue" is not a hex color`)
+	require.ErrorContains(t, errs[1], `unknown color "primray"`)
+	require.ElementsMatch(t, []string{"charmtone", "ocean", "Paper"}, m.List())
+
+	require.NoError(t, m.SetTheme("ocean"))
+	ocean := m.Current()
+	require.True(t, ocean.IsDark)
+	require.Equal(t, lipgloss.Color("#0077be"), ocean.Primary)
+	require.Equal(t, lipgloss.Color("#001f3f"), ocean.BgBase)
+	require.Equal(t, ocean.Primary, ocean.LogoField)
+	require.Equal(t, ocean.Secondary, ocean.LogoGradientA)
+	require.Equal(t, ocean.Primary, ocean.LogoGradientB)
+	require.Equal(t, NewCharmtoneTheme().FgBase, ocean.FgBase)
+
+	require.NoError(t, m.SetTheme("Paper"))
+	paper := m.Current()
+	require.False(t, paper.IsDark)
+	require.Equal(t, lipgloss.Color("#999999"), paper.LogoField)
+	require.Equal(t, lipgloss.Color("#333"), paper.LogoGradientB)
+}
+
+func TestLoadThemesMissingDirectory(t *testing.T) {
+	t.Parallel()
+
+	m := NewManager()
+	require.Empty(t, m.LoadThemes(filepath.Join(t.TempDir(), "themes")))
+	require.Equal(t, []string{"charmtone"}, m.List())
+}
@@ -3,7 +3,10 @@ package tui
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"os"
+	"os/exec"
 	"strings"
 	"time"
 
@@ -28,6 +31,7 @@ import (
 	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs/models"
 	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs/permissions"
 	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs/quit"
+	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs/search"
 	"github.com/tulpa-code/tulpa/internal/tui/components/dialogs/sessions"
 	"github.com/tulpa-code/tulpa/internal/tui/page"
 	"github.com/tulpa-code/tulpa/internal/tui/page/chat"
@@ -73,7 +77,8 @@ type appModel struct {
 	isConfigured bool
 
 	// Chat Page Specific
-	selectedSessionID string // The ID of the currently selected session
+	selectedSessionID string          // The ID of the currently selected session
+	step              *agent.StepInfo // The step of its plan the agent of the selected session started
 }
 
 // Init initializes the application model and returns initial commands.
@@ -90,6 +95,7 @@ func (a appModel) Init() tea.Cmd {
 
 	cmd = a.status.Init()
 	cmds = append(cmds, cmd)
+	cmds = append(cmds, a.promptTokens())
 
 	cmds = append(cmds, tea.EnableMouseAllMotion)
 
@@ -157,20 +163,38 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.status = s.(status.StatusCmp)
 		cmds = append(cmds, statusCmd)
 		return a, tea.Batch(cmds...)
+	case status.PromptTokensMsg:
+		s, _ := a.status.Update(msg)
+		a.status = s.(status.StatusCmp)
+		if msg.TooLarge {
+			return a, util.ReportWarn(fmt.Sprintf(
+				"The system prompt takes %d%% of the context window, check the context files",
+				msg.Tokens*100/msg.ContextWindow,
+			))
+		}
+		return a, nil
 
 	// Session
 	case cmpChat.SessionSelectedMsg:
+		if msg.ID != a.selectedSessionID {
+			a.step = nil
+		}
 		a.selectedSessionID = msg.ID
 	case cmpChat.SessionClearedMsg:
 		a.selectedSessionID = ""
+		a.step = nil
 	// Commands
 	case commands.SwitchSessionsMsg:
 		return a, func() tea.Msg {
 			allSessions, _ := a.app.Sessions.List(context.Background())
 			return dialogs.OpenDialogMsg{
-				Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
+				Model: sessions.NewSessionDialogCmp(a.app.Sessions, allSessions, a.selectedSessionID),
 			}
 		}
+	case commands.SearchMessagesMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: search.NewSearchDialogCmp(a.app.Messages, a.app.Sessions),
+		})
 
 	case commands.SwitchModelMsg:
 		return a, util.CmdHandler(
@@ -189,6 +213,18 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 	case commands.ToggleYoloModeMsg:
 		a.app.Permissions.SetSkipRequests(!a.app.Permissions.SkipRequests())
+	case commands.RestartLSPMsg:
+		return a, tea.Sequence(
+			util.ReportInfo(fmt.Sprintf("Restarting %s...", msg.Name)),
+			func() tea.Msg {
+				if err := a.app.RestartLSP(msg.Name); err != nil {
+					return util.ReportError(fmt.Errorf("failed to restart %s: %w", msg.Name, err))()
+				}
+				return util.ReportInfo(fmt.Sprintf("Restarted %s", msg.Name))()
+			},
+		)
+	case commands.DryRunMsg:
+		return a, a.showDryRun(msg.SessionID)
 	case commands.ToggleHelpMsg:
 		a.status.ToggleFullHelp()
 		a.showingFullHelp = !a.showingFullHelp
@@ -210,7 +246,10 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.ModelType == config.SelectedModelTypeSmall {
 			modelTypeName = "small"
 		}
-		return a, util.ReportInfo(fmt.Sprintf("%s model changed to %s", modelTypeName, msg.Model.Model))
+		return a, tea.Batch(
+			util.ReportInfo(fmt.Sprintf("%s model changed to %s", modelTypeName, msg.Model.Model)),
+			a.promptTokens(),
+		)
 
 	// File Picker
 	case commands.OpenFilePickerMsg:
@@ -267,6 +306,41 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, dialogCmd)
 		}
 
+		if payload.Type == agent.AgentEventTypeRetry && payload.Retry != nil {
+			cmds = append(cmds, util.ReportWarn(fmt.Sprintf(
+				"Provider error, retrying (%d/%d) in %s...",
+				payload.Retry.Attempt, payload.Retry.MaxRetries, payload.Retry.Delay.Round(time.Second),
+			)))
+		}
+		if payload.Type == agent.AgentEventTypeFallback && payload.Fallback != nil {
+			cmds = append(cmds, util.ReportWarn(fmt.Sprintf(
+				"%s is unavailable, fell back to %s",
+				payload.Fallback.From.Name, payload.Fallback.To.Name,
+			)))
+		}
+		if payload.Type == agent.AgentEventTypeToolLimit && payload.ToolLimit != nil {
+			if payload.ToolLimit.Reached() {
+				cmds = append(cmds, util.ReportWarn(fmt.Sprintf("Tool limit of %d iterations reached, asking for a final answer", payload.ToolLimit.Max)))
+			} else {
+				cmds = append(cmds, util.ReportWarn(fmt.Sprintf("Used %d of %d tool iterations", payload.ToolLimit.Used, payload.ToolLimit.Max)))
+			}
+		}
+
+		if payload.Type == agent.AgentEventTypeStep && payload.Step != nil && payload.SessionID == a.selectedSessionID {
+			a.step = payload.Step
+		}
+		// The plan of a turn ends with it.
+		if payload.Type == agent.AgentEventTypeResponse || payload.Type == agent.AgentEventTypeError {
+			a.step = nil
+		}
+
+		if payload.Type == agent.AgentEventTypeCompaction && payload.Compaction != nil {
+			cmds = append(cmds, util.ReportInfo(fmt.Sprintf(
+				"Conversation fills the context window, summarizing %d older messages",
+				payload.Compaction.Summarized,
+			)))
+		}
+
 		// Handle auto-compact logic
 		if payload.Done && payload.Type == agent.AgentEventTypeResponse && a.selectedSessionID != "" {
 			// Get current session to check token usage
@@ -479,7 +553,7 @@ func (a *appModel) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 			func() tea.Msg {
 				allSessions, _ := a.app.Sessions.List(context.Background())
 				return dialogs.OpenDialogMsg{
-					Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
+					Model: sessions.NewSessionDialogCmp(a.app.Sessions, allSessions, a.selectedSessionID),
 				}
 			},
 		)
@@ -603,7 +677,10 @@ func (a *appModel) View() tea.View {
 	view.Layer = canvas
 	view.Cursor = cursor
 	view.ProgressBar = tea.NewProgressBar(tea.ProgressBarNone, 0)
-	if a.app.CoderAgent.IsBusy() {
+	switch {
+	case a.step != nil && a.app.CoderAgent.IsSessionBusy(a.selectedSessionID):
+		view.ProgressBar = tea.NewProgressBar(tea.ProgressBarDefault, a.step.Percent())
+	case a.app.CoderAgent.IsBusy():
 		// use a random percentage to prevent the ghostty from hiding it after
 		// a timeout.
 		view.ProgressBar = tea.NewProgressBar(tea.ProgressBarIndeterminate, rand.Intn(100))
@@ -611,10 +688,25 @@ func (a *appModel) View() tea.View {
 	return view
 }
 
+// setupTheme loads the custom themes and selects the configured one.
+func setupTheme(cfg *config.Config) {
+	manager := styles.DefaultManager()
+	for _, err := range manager.LoadThemes(config.ThemesConfigDir()) {
+		slog.Warn("Skipping theme", "error", err)
+	}
+	if cfg == nil || cfg.Options == nil || cfg.Options.TUI == nil || cfg.Options.TUI.Theme == "" {
+		return
+	}
+	if err := manager.SetTheme(cfg.Options.TUI.Theme); err != nil {
+		slog.Warn("Using the default theme", "error", err)
+	}
+}
+
 // New creates and initializes a new TUI application model.
 func New(app *app.App) tea.Model {
+	setupTheme(config.Get())
 	chatPage := chat.New(app)
-	keyMap := DefaultKeyMap()
+	keyMap := LoadKeyMap(config.Get())
 	keyMap.pageBindings = chatPage.Bindings()
 
 	model := &appModel{
@@ -634,3 +726,57 @@ func New(app *app.App) tea.Model {
 
 	return model
 }
+
+// promptTokens estimates the size of the coder agent's system prompt for the
+// footer, warning when it takes more of the context window than the
+// prompt_token_warning option allows.
+func (a *appModel) promptTokens() tea.Cmd {
+	coder := a.app.CoderAgent
+	if coder == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := status.PromptTokensMsg{
+			Tokens:        coder.PromptTokens(),
+			ContextWindow: coder.Model().ContextWindow,
+		}
+		ratio := config.Get().Options.PromptTokenWarningRatio()
+		msg.TooLarge = ratio > 0 && msg.ContextWindow > 0 && float64(msg.Tokens) > ratio*float64(msg.ContextWindow)
+		return msg
+	}
+}
+
+// showDryRun writes what the coder agent would send for the session to a
+// file and opens it in $EDITOR, if set.
+func (a *appModel) showDryRun(sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		dryRun, err := a.app.CoderAgent.DryRun(context.Background(), sessionID, "")
+		if err != nil {
+			return util.ReportError(fmt.Errorf("failed to assemble the prompt: %w", err))()
+		}
+		f, err := os.CreateTemp("", "tulpa-prompt-*.md")
+		if err != nil {
+			return util.ReportError(err)()
+		}
+		defer f.Close() //nolint:errcheck
+		if _, err := f.WriteString(dryRun.String()); err != nil {
+			return util.ReportError(err)()
+		}
+
+		written := util.ReportInfo("Prompt written to " + f.Name())
+		editor := os.Getenv("EDITOR")
+		if editor == "" {
+			return written()
+		}
+		c := exec.CommandContext(context.TODO(), editor, f.Name())
+		c.Stdin = os.Stdin
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		return tea.ExecProcess(c, func(err error) tea.Msg {
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			return written()
+		})()
+	}
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// keyModifiers lists the modifiers in the order bubbletea writes them in key
+// strings, e.g. "ctrl+alt+shift+tab".
+var keyModifiers = []string{"ctrl", "alt", "shift", "meta", "hyper", "super"}
+
+var namedKeys = func() map[string]bool {
+	names := map[string]bool{}
+	for _, name := range []string{
+		"enter", "tab", "backspace", "esc", "space", "up", "down", "left",
+		"right", "begin", "find", "insert", "delete", "select", "pgup",
+		"pgdown", "home", "end", "capslock", "scrolllock", "numlock",
+		"printscreen", "pause", "menu",
+	} {
+		names[name] = true
+	}
+	for i := 1; i <= 63; i++ {
+		names["f"+strconv.Itoa(i)] = true
+	}
+	return names
+}()
+
+// ParseKey checks that s is a key as bubbletea reports it: modifiers in
+// their canonical order followed by a key name or a single character.
+func ParseKey(s string) error {
+	if s == "+" {
+		return nil
+	}
+	parts := strings.Split(s, "+")
+	// A trailing "+" is the plus key itself, e.g. "ctrl++".
+	if strings.HasSuffix(s, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	last := 0
+	for _, mod := range parts[:len(parts)-1] {
+		i := slices.Index(keyModifiers, mod)
+		switch {
+		case i < 0:
+			return fmt.Errorf("invalid key %q: unknown modifier %q", s, mod)
+		case i < last:
+			return fmt.Errorf("invalid key %q: modifiers must be in the order %s", s, strings.Join(keyModifiers, "+"))
+		}
+		last = i + 1
+	}
+	name := parts[len(parts)-1]
+	if namedKeys[name] || (utf8.RuneCountInString(name) == 1 && name != " ") {
+		return nil
+	}
+	return fmt.Errorf("invalid key %q: unknown key %q", s, name)
+}
+
+// ApplyKeybindings replaces the keys of the bindings named in overrides and
+// updates their help to match. Names not in bindings are left for other key
+// maps. Overrides with keys that don't parse are skipped and returned as
+// errors.
+func ApplyKeybindings(bindings map[string]*key.Binding, overrides map[string][]string) []error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(overrides)) {
+		binding, ok := bindings[name]
+		if !ok {
+			continue
+		}
+		keys := overrides[name]
+		if len(keys) == 0 {
+			errs = append(errs, fmt.Errorf("keybinding %s: no keys given", name))
+			continue
+		}
+		var invalid bool
+		for _, k := range keys {
+			if err := ParseKey(k); err != nil {
+				errs = append(errs, fmt.Errorf("keybinding %s: %w", name, err))
+				invalid = true
+			}
+		}
+		if invalid {
+			continue
+		}
+		binding.SetKeys(keys...)
+		binding.SetHelp(strings.Join(keys, "/"), binding.Help().Desc)
+	}
+	return errs
+}
+
+// KeybindingConflicts describes the keys that more than one of bindings
+// uses, considering only the bindings named in overridden so conflicts
+// between the defaults are not reported.
+func KeybindingConflicts(bindings map[string]*key.Binding, overridden map[string][]string) []string {
+	users := map[string][]string{}
+	for _, name := range slices.Sorted(maps.Keys(bindings)) {
+		for _, k := range bindings[name].Keys() {
+			users[k] = append(users[k], name)
+		}
+	}
+	var conflicts []string
+	for _, k := range slices.Sorted(maps.Keys(users)) {
+		names := users[k]
+		if len(names) < 2 || !slices.ContainsFunc(names, func(name string) bool {
+			_, ok := overridden[name]
+			return ok
+		}) {
+			continue
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s is bound to %s", k, strings.Join(names, ", ")))
+	}
+	return conflicts
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "ctrl+s", valid: true},
+		{key: "ctrl+shift+tab", valid: true},
+		{key: "alt+enter", valid: true},
+		{key: "f12", valid: true},
+		{key: "?", valid: true},
+		{key: "+", valid: true},
+		{key: "ctrl++", valid: true},
+		{key: "shift+ctrl+tab", valid: false},
+		{key: "cmd+s", valid: false},
+		{key: "ctrl+escape", valid: false},
+		{key: "ctrl+", valid: false},
+		{key: "", valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+			err := ParseKey(tt.key)
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestApplyKeybindings(t *testing.T) {
+	t.Parallel()
+
+	quit := key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit"))
+	sessions := key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "sessions"))
+	help := key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "more"))
+	bindings := map[string]*key.Binding{"quit": &quit, "sessions": &sessions, "help": &help}
+
+	overrides := map[string][]string{
+		"sessions": {"ctrl+o", "f2"},
+		"help":     {"ctrl+huh"},
+		"other":    {"ctrl+x"},
+	}
+	errs := ApplyKeybindings(bindings, overrides)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], `keybinding help: invalid key "ctrl+huh"`)
+
+	require.Equal(t, []string{"ctrl+o", "f2"}, sessions.Keys())
+	require.Equal(t, key.Help{Key: "ctrl+o/f2", Desc: "sessions"}, sessions.Help())
+	require.Equal(t, []string{"ctrl+g"}, help.Keys())
+	require.Equal(t, []string{"ctrl+c"}, quit.Keys())
+}
+
+func TestKeybindingConflicts(t *testing.T) {
+	t.Parallel()
+
+	quit := key.NewBinding(key.WithKeys("ctrl+c"))
+	cancel := key.NewBinding(key.WithKeys("esc", "ctrl+c"))
+	sessions := key.NewBinding(key.WithKeys("ctrl+o"))
+	commands := key.NewBinding(key.WithKeys("ctrl+o"))
+	bindings := map[string]*key.Binding{"quit": &quit, "cancel": &cancel, "sessions": &sessions, "commands": &commands}
+
+	require.Equal(t, []string{"ctrl+o is bound to commands, sessions"}, KeybindingConflicts(bindings, map[string][]string{"sessions": {"ctrl+o"}}))
+	require.Empty(t, KeybindingConflicts(bindings, nil))
+}
@@ -8,9 +8,12 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/tulpa-code/tulpa/internal/cmd"
+	"github.com/tulpa-code/tulpa/internal/log"
 )
 
 func main() {
+	log.Init()
+
 	if os.Getenv("TULPA_PROFILE") != "" {
 		go func() {
 			slog.Info("Serving pprof at localhost:6060")